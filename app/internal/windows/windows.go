@@ -65,6 +65,14 @@ type WindowPlacement struct {
 	rcDevice         Rect
 }
 
+type IconInfo struct {
+	FIcon    int32
+	XHotspot uint32
+	YHotspot uint32
+	HbmMask  syscall.Handle
+	HbmColor syscall.Handle
+}
+
 type MonitorInfo struct {
 	cbSize   uint32
 	Monitor  Rect
@@ -86,13 +94,18 @@ const (
 
 	HTCLIENT = 1
 
-	IDC_ARROW   = 32512
-	IDC_IBEAM   = 32513
-	IDC_HAND    = 32649
-	IDC_CROSS   = 32515
-	IDC_SIZENS  = 32645
-	IDC_SIZEWE  = 32644
-	IDC_SIZEALL = 32646
+	IDC_APPSTARTING = 32650
+	IDC_ARROW       = 32512
+	IDC_IBEAM       = 32513
+	IDC_HAND        = 32649
+	IDC_CROSS       = 32515
+	IDC_NO          = 32648
+	IDC_SIZENS      = 32645
+	IDC_SIZEWE      = 32644
+	IDC_SIZEALL     = 32646
+	IDC_SIZENESW    = 32643
+	IDC_SIZENWSE    = 32642
+	IDC_WAIT        = 32514
 
 	INFINITE = 0xFFFFFFFF
 
@@ -251,9 +264,13 @@ var (
 	user32                       = syscall.NewLazySystemDLL("user32.dll")
 	_AdjustWindowRectEx          = user32.NewProc("AdjustWindowRectEx")
 	_CallMsgFilter               = user32.NewProc("CallMsgFilterW")
+	_ClientToScreen              = user32.NewProc("ClientToScreen")
+	_ClipCursor                  = user32.NewProc("ClipCursor")
 	_CloseClipboard              = user32.NewProc("CloseClipboard")
+	_CreateIconIndirect          = user32.NewProc("CreateIconIndirect")
 	_CreateWindowEx              = user32.NewProc("CreateWindowExW")
 	_DefWindowProc               = user32.NewProc("DefWindowProcW")
+	_DestroyIcon                 = user32.NewProc("DestroyIcon")
 	_DestroyWindow               = user32.NewProc("DestroyWindow")
 	_DispatchMessage             = user32.NewProc("DispatchMessageW")
 	_EmptyClipboard              = user32.NewProc("EmptyClipboard")
@@ -285,6 +302,7 @@ var (
 	_ShowWindow                  = user32.NewProc("ShowWindow")
 	_SetCapture                  = user32.NewProc("SetCapture")
 	_SetCursor                   = user32.NewProc("SetCursor")
+	_SetCursorPos                = user32.NewProc("SetCursorPos")
 	_SetClipboardData            = user32.NewProc("SetClipboardData")
 	_SetForegroundWindow         = user32.NewProc("SetForegroundWindow")
 	_SetFocus                    = user32.NewProc("SetFocus")
@@ -302,6 +320,8 @@ var (
 	_GetDpiForMonitor = shcore.NewProc("GetDpiForMonitor")
 
 	gdi32          = syscall.NewLazySystemDLL("gdi32")
+	_CreateBitmap  = gdi32.NewProc("CreateBitmap")
+	_DeleteObject  = gdi32.NewProc("DeleteObject")
 	_GetDeviceCaps = gdi32.NewProc("GetDeviceCaps")
 )
 
@@ -316,6 +336,18 @@ func CallMsgFilter(m *Msg, nCode uintptr) bool {
 	return r != 0
 }
 
+func ClientToScreen(hwnd syscall.Handle, p *Point) {
+	_ClientToScreen.Call(uintptr(hwnd), uintptr(unsafe.Pointer(p)))
+	issue34474KeepAlive(p)
+}
+
+// ClipCursor confines the cursor to r, in screen coordinates. A nil
+// r releases the cursor.
+func ClipCursor(r *Rect) {
+	_ClipCursor.Call(uintptr(unsafe.Pointer(r)))
+	issue34474KeepAlive(r)
+}
+
 func CloseClipboard() error {
 	r, _, err := _CloseClipboard.Call()
 	if r == 0 {
@@ -324,6 +356,23 @@ func CloseClipboard() error {
 	return nil
 }
 
+func CreateBitmap(width, height int32, planes, bitCount uint32, bits unsafe.Pointer) (syscall.Handle, error) {
+	h, _, err := _CreateBitmap.Call(uintptr(width), uintptr(height), uintptr(planes), uintptr(bitCount), uintptr(bits))
+	if h == 0 {
+		return 0, fmt.Errorf("CreateBitmap failed: %v", err)
+	}
+	return syscall.Handle(h), nil
+}
+
+func CreateIconIndirect(info *IconInfo) (syscall.Handle, error) {
+	h, _, err := _CreateIconIndirect.Call(uintptr(unsafe.Pointer(info)))
+	issue34474KeepAlive(info)
+	if h == 0 {
+		return 0, fmt.Errorf("CreateIconIndirect failed: %v", err)
+	}
+	return syscall.Handle(h), nil
+}
+
 func CreateWindowEx(dwExStyle uint32, lpClassName uint16, lpWindowName string, dwStyle uint32, x, y, w, h int32, hWndParent, hMenu, hInstance syscall.Handle, lpParam uintptr) (syscall.Handle, error) {
 	wname := syscall.StringToUTF16Ptr(lpWindowName)
 	hwnd, _, err := _CreateWindowEx.Call(
@@ -349,6 +398,14 @@ func DefWindowProc(hwnd syscall.Handle, msg uint32, wparam, lparam uintptr) uint
 	return r
 }
 
+func DeleteObject(h syscall.Handle) {
+	_DeleteObject.Call(uintptr(h))
+}
+
+func DestroyIcon(h syscall.Handle) {
+	_DestroyIcon.Call(uintptr(h))
+}
+
 func DestroyWindow(hwnd syscall.Handle) {
 	_DestroyWindow.Call(uintptr(hwnd))
 }
@@ -637,6 +694,10 @@ func SetCursor(h syscall.Handle) {
 	_SetCursor.Call(uintptr(h))
 }
 
+func SetCursorPos(x, y int32) {
+	_SetCursorPos.Call(uintptr(x), uintptr(y))
+}
+
 func SetTimer(hwnd syscall.Handle, nIDEvent uintptr, uElapse uint32, timerProc uintptr) error {
 	r, _, err := _SetTimer.Call(uintptr(hwnd), uintptr(nIDEvent), uintptr(uElapse), timerProc)
 	if r == 0 {
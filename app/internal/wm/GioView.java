@@ -12,6 +12,7 @@ import android.app.Fragment;
 import android.app.FragmentManager;
 import android.app.FragmentTransaction;
 import android.content.Context;
+import android.graphics.Bitmap;
 import android.graphics.Color;
 import android.graphics.Rect;
 import android.os.Build;
@@ -141,6 +142,14 @@ public final class GioView extends SurfaceView implements Choreographer.FrameCal
 		setPointerIcon(pointerIcon);
 	}
 
+	private void setCursorImage(int[] colors, int width, int height, int hotX, int hotY) {
+		if (Build.VERSION.SDK_INT < Build.VERSION_CODES.N) {
+			return;
+		}
+		Bitmap bitmap = Bitmap.createBitmap(colors, width, height, Bitmap.Config.ARGB_8888);
+		setPointerIcon(PointerIcon.create(bitmap, hotX, hotY));
+	}
+
     private void setOrientation(int id, int fallback) {
         if (Build.VERSION.SDK_INT < Build.VERSION_CODES.JELLY_BEAN_MR2) {
             id = fallback;
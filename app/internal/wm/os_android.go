@@ -103,6 +103,18 @@ static jobject jni_CallObjectMethodA(JNIEnv *env, jobject obj, jmethodID method,
 static jobject jni_CallStaticObjectMethodA(JNIEnv *env, jclass cls, jmethodID method, jvalue *args) {
 	return (*env)->CallStaticObjectMethodA(env, cls, method, args);
 }
+
+static jintArray jni_NewIntArray(JNIEnv *env, jsize length) {
+	return (*env)->NewIntArray(env, length);
+}
+
+static void jni_SetIntArrayRegion(JNIEnv *env, jintArray arr, jsize start, jsize len, const jint *buf) {
+	(*env)->SetIntArrayRegion(env, arr, start, len, buf);
+}
+
+static void jni_DeleteLocalRef(JNIEnv *env, jobject obj) {
+	(*env)->DeleteLocalRef(env, obj);
+}
 */
 import "C"
 
@@ -155,6 +167,7 @@ var gioView struct {
 	setInputHint       C.jmethodID
 	postFrameCallback  C.jmethodID
 	setCursor          C.jmethodID
+	setCursorImage     C.jmethodID
 	setOrientation     C.jmethodID
 	setNavigationColor C.jmethodID
 	setStatusColor     C.jmethodID
@@ -286,6 +299,7 @@ func Java_org_gioui_GioView_onCreateView(env *C.JNIEnv, class C.jclass, view C.j
 		m.setInputHint = getMethodID(env, class, "setInputHint", "(I)V")
 		m.postFrameCallback = getMethodID(env, class, "postFrameCallback", "()V")
 		m.setCursor = getMethodID(env, class, "setCursor", "(I)V")
+		m.setCursorImage = getMethodID(env, class, "setCursorImage", "([IIIII)V")
 		m.setOrientation = getMethodID(env, class, "setOrientation", "(II)V")
 		m.setNavigationColor = getMethodID(env, class, "setNavigationColor", "(II)V")
 		m.setStatusColor = getMethodID(env, class, "setStatusColor", "(II)V")
@@ -774,6 +788,14 @@ func (w *window) SetCursor(name pointer.CursorName) {
 	})
 }
 
+func (w *window) SetPointerLock(mode pointer.LockMode) {}
+
+func (w *window) SetCursorImage(img *pointer.CursorImage) {
+	runInJVM(javaVM(), func(env *C.JNIEnv) {
+		setCursorImage(env, w.view, img)
+	})
+}
+
 func (w *window) Wakeup() {
 	runOnMain(func(env *C.JNIEnv) {
 		w.callbacks.Event(WakeupEvent{})
@@ -797,12 +819,51 @@ func setCursor(env *C.JNIEnv, view C.jobject, name pointer.CursorName) {
 		curID = 1014 // TYPE_HORIZONTAL_DOUBLE_ARROW
 	case pointer.CursorRowResize:
 		curID = 1015 // TYPE_VERTICAL_DOUBLE_ARROW
+	case pointer.CursorGrab:
+		curID = 1020 // TYPE_GRAB
+	case pointer.CursorWait, pointer.CursorProgress:
+		curID = 1004 // TYPE_WAIT
+	case pointer.CursorNotAllowed:
+		curID = 1012 // TYPE_NO_DROP
+	case pointer.CursorMove:
+		curID = 1013 // TYPE_ALL_SCROLL
+	case pointer.CursorNorthEastResize, pointer.CursorSouthWestResize, pointer.CursorNorthEastSouthWestResize:
+		curID = 1017 // TYPE_TOP_RIGHT_DIAGONAL_DOUBLE_ARROW
+	case pointer.CursorNorthWestResize, pointer.CursorSouthEastResize, pointer.CursorNorthWestSouthEastResize:
+		curID = 1016 // TYPE_TOP_LEFT_DIAGONAL_DOUBLE_ARROW
+	case pointer.CursorZoomIn:
+		curID = 1018 // TYPE_ZOOM_IN
+	case pointer.CursorZoomOut:
+		curID = 1019 // TYPE_ZOOM_OUT
 	case pointer.CursorNone:
 		curID = 0 // TYPE_NULL
 	}
 	callVoidMethod(env, view, gioView.setCursor, jvalue(curID))
 }
 
+func setCursorImage(env *C.JNIEnv, view C.jobject, img *pointer.CursorImage) {
+	src := img.Image()
+	size := src.Bounds().Size()
+	// Convert to the non-premultiplied ARGB colors expected by
+	// android.graphics.Bitmap.
+	colors := make([]C.jint, 0, size.X*size.Y)
+	for y := 0; y < size.Y; y++ {
+		row := src.Pix[y*src.Stride : y*src.Stride+size.X*4]
+		for x := 0; x < len(row); x += 4 {
+			c := f32color.RGBAToNRGBA(color.RGBA{R: row[x], G: row[x+1], B: row[x+2], A: row[x+3]})
+			colors = append(colors, C.jint(int32(uint32(c.A)<<24|uint32(c.R)<<16|uint32(c.G)<<8|uint32(c.B))))
+		}
+	}
+	arr := C.jni_NewIntArray(env, C.jsize(len(colors)))
+	if arr == 0 {
+		return
+	}
+	defer C.jni_DeleteLocalRef(env, C.jobject(arr))
+	C.jni_SetIntArrayRegion(env, arr, 0, C.jsize(len(colors)), &colors[0])
+	hot := img.Hotspot()
+	callVoidMethod(env, view, gioView.setCursorImage, jvalue(arr), jvalue(size.X), jvalue(size.Y), jvalue(hot.X), jvalue(hot.Y))
+}
+
 func setOrientation(env *C.JNIEnv, view C.jobject, mode Orientation) {
 	var (
 		id         int
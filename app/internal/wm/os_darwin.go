@@ -14,6 +14,7 @@ __attribute__ ((visibility ("hidden"))) void gio_setDisplayLinkDisplay(CFTypeRef
 __attribute__ ((visibility ("hidden"))) void gio_hideCursor();
 __attribute__ ((visibility ("hidden"))) void gio_showCursor();
 __attribute__ ((visibility ("hidden"))) void gio_setCursor(NSUInteger curID);
+__attribute__ ((visibility ("hidden"))) void gio_setCursorImage(const void *pixels, NSUInteger width, NSUInteger height, NSUInteger hotX, NSUInteger hotY);
 
 static bool isMainThread() {
 	return [NSThread isMainThread];
@@ -217,8 +218,10 @@ func windowSetCursor(from, to pointer.CursorName) pointer.CursorName {
 		curID = 5
 	case pointer.CursorRowResize:
 		curID = 6
-	case pointer.CursorGrab:
+	case pointer.CursorGrab, pointer.CursorMove:
 		curID = 7
+	case pointer.CursorNotAllowed:
+		curID = 8
 	case pointer.CursorNone:
 		C.gio_hideCursor()
 		return to
@@ -230,6 +233,23 @@ func windowSetCursor(from, to pointer.CursorName) pointer.CursorName {
 	return to
 }
 
+// cursorImageName is the cursor name recorded while a custom
+// cursor image is active.
+const cursorImageName pointer.CursorName = "image"
+
+// windowSetCursorImage updates the cursor from the current one to
+// the custom cursor img and returns the new cursor name.
+func windowSetCursorImage(from pointer.CursorName, img *pointer.CursorImage) pointer.CursorName {
+	src := img.Image()
+	size := src.Bounds().Size()
+	hot := img.Hotspot()
+	if from == pointer.CursorNone {
+		C.gio_showCursor()
+	}
+	C.gio_setCursorImage(unsafe.Pointer(&src.Pix[0]), C.NSUInteger(size.X), C.NSUInteger(size.Y), C.NSUInteger(hot.X), C.NSUInteger(hot.Y))
+	return cursorImageName
+}
+
 func (w *window) Wakeup() {
 	runOnMain(func() {
 		w.w.Event(WakeupEvent{})
@@ -315,6 +315,12 @@ func (w *window) SetCursor(name pointer.CursorName) {
 	w.cursor = windowSetCursor(w.cursor, name)
 }
 
+func (w *window) SetCursorImage(img *pointer.CursorImage) {
+	w.cursor = windowSetCursorImage(w.cursor, img)
+}
+
+func (w *window) SetPointerLock(mode pointer.LockMode) {}
+
 func (w *window) onKeyCommand(name string) {
 	w.w.Event(key.Event{
 		Name: name,
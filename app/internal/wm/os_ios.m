@@ -270,3 +270,7 @@ void gio_showCursor() {
 void gio_setCursor(NSUInteger curID) {
 	// Not supported.
 }
+
+void gio_setCursorImage(const void *pixels, NSUInteger width, NSUInteger height, NSUInteger hotX, NSUInteger hotY) {
+	// Not supported.
+}
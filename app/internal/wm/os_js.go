@@ -3,9 +3,12 @@
 package wm
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
 	"image"
 	"image/color"
+	"image/png"
 	"strings"
 	"syscall/js"
 	"time"
@@ -50,6 +53,13 @@ type window struct {
 	inset     f32.Point
 	scale     float32
 	animating bool
+	lastPos   f32.Point
+	// lock is the requested pointer lock mode, and pos the pointer
+	// position when the lock was requested.
+	lock struct {
+		mode pointer.LockMode
+		pos  f32.Point
+	}
 	// animRequested tracks whether a requestAnimationFrame callback
 	// is pending.
 	animRequested bool
@@ -443,17 +453,36 @@ func (w *window) pointerEvent(typ pointer.Type, dx, dy float32, e js.Value) {
 	if jbtns&4 != 0 {
 		btns |= pointer.ButtonTertiary
 	}
+	var delta f32.Point
+	if w.pointerLocked() {
+		pos = w.lock.pos
+		if typ == pointer.Move {
+			delta = f32.Point{
+				X: float32(e.Get("movementX").Float()) * scale,
+				Y: float32(e.Get("movementY").Float()) * scale,
+			}
+		}
+	} else {
+		w.lastPos = pos
+	}
 	w.w.Event(pointer.Event{
 		Type:      typ,
 		Source:    pointer.Mouse,
 		Buttons:   btns,
 		Position:  pos,
 		Scroll:    scroll,
+		Delta:     delta,
 		Time:      t,
 		Modifiers: modifiersFor(e),
 	})
 }
 
+// pointerLocked reports whether the browser granted the pointer lock
+// of the canvas.
+func (w *window) pointerLocked() bool {
+	return w.lock.mode == pointer.LockRelative && w.document.Get("pointerLockElement").Equal(w.cnv)
+}
+
 func (w *window) addEventListener(this js.Value, event string, f func(this js.Value, args []js.Value) interface{}) {
 	jsf := w.funcOf(f)
 	this.Call("addEventListener", event, jsf)
@@ -529,6 +558,36 @@ func (w *window) SetCursor(name pointer.CursorName) {
 	style.Set("cursor", string(name))
 }
 
+func (w *window) SetPointerLock(mode pointer.LockMode) {
+	// Browsers can't confine the pointer without locking it.
+	if mode == pointer.LockConfine {
+		mode = pointer.LockRelative
+	}
+	if mode == w.lock.mode {
+		return
+	}
+	w.lock.mode = mode
+	w.lock.pos = w.lastPos
+	if mode == pointer.LockRelative {
+		// The request may be denied if it isn't made while
+		// handling a user gesture.
+		w.cnv.Call("requestPointerLock")
+	} else if w.document.Get("pointerLockElement").Equal(w.cnv) {
+		w.document.Call("exitPointerLock")
+	}
+}
+
+func (w *window) SetCursorImage(img *pointer.CursorImage) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img.Image()); err != nil {
+		return
+	}
+	hot := img.Hotspot()
+	url := base64.StdEncoding.EncodeToString(buf.Bytes())
+	style := w.cnv.Get("style")
+	style.Set("cursor", fmt.Sprintf("url(data:image/png;base64,%s) %d %d, auto", url, hot.X, hot.Y))
+}
+
 func (w *window) Wakeup() {
 	select {
 	case w.wakeups <- struct{}{}:
@@ -58,6 +58,19 @@ static CFTypeRef readClipboard(void) {
 	}
 }
 
+static void setPointerLocked(int locked) {
+	@autoreleasepool {
+		// Detach the cursor from mouse movements; mouse events still
+		// report the motion in their deltas.
+		CGAssociateMouseAndMouseCursorPosition(!locked);
+		if (locked) {
+			[NSCursor hide];
+		} else {
+			[NSCursor unhide];
+		}
+	}
+}
+
 static CGFloat viewHeight(CFTypeRef viewRef) {
 	NSView *view = (__bridge NSView *)viewRef;
 	return [view bounds].size.height;
@@ -151,6 +164,13 @@ type window struct {
 	stage       system.Stage
 	displayLink *displayLink
 	cursor      pointer.CursorName
+	lastPos     f32.Point
+	// lock is the active pointer lock mode, and pos the pointer
+	// position when the lock was taken.
+	lock struct {
+		mode pointer.LockMode
+		pos  f32.Point
+	}
 
 	scale float32
 	mode  WindowMode
@@ -260,6 +280,27 @@ func (w *window) SetCursor(name pointer.CursorName) {
 	w.cursor = windowSetCursor(w.cursor, name)
 }
 
+func (w *window) SetCursorImage(img *pointer.CursorImage) {
+	w.cursor = windowSetCursorImage(w.cursor, img)
+}
+
+func (w *window) SetPointerLock(mode pointer.LockMode) {
+	// There is no API for confining the cursor to a window.
+	if mode == pointer.LockConfine {
+		mode = pointer.LockNone
+	}
+	if mode == w.lock.mode {
+		return
+	}
+	w.lock.mode = mode
+	w.lock.pos = w.lastPos
+	relative := C.int(0)
+	if mode == pointer.LockRelative {
+		relative = 1
+	}
+	C.setPointerLocked(relative)
+}
+
 func (w *window) ShowTextInput(show bool) {}
 
 func (w *window) SetInputHint(_ key.InputHint) {}
@@ -356,15 +397,26 @@ func gio_onMouse(view C.CFTypeRef, cdir C.int, cbtns C.NSUInteger, x, y, dx, dy
 	w := mustView(view)
 	xf, yf := float32(x)*w.scale, float32(y)*w.scale
 	dxf, dyf := float32(dx)*w.scale, float32(dy)*w.scale
-	w.w.Event(pointer.Event{
+	e := pointer.Event{
 		Type:      typ,
 		Source:    pointer.Mouse,
 		Time:      t,
 		Buttons:   btns,
 		Position:  f32.Point{X: xf, Y: yf},
-		Scroll:    f32.Point{X: dxf, Y: dyf},
 		Modifiers: convertMods(mods),
-	})
+	}
+	switch {
+	case typ == pointer.Scroll:
+		e.Scroll = f32.Point{X: dxf, Y: dyf}
+	case w.lock.mode == pointer.LockRelative:
+		e.Position = w.lock.pos
+		if typ == pointer.Move {
+			e.Delta = f32.Point{X: dxf, Y: dyf}
+		}
+	default:
+		w.lastPos = e.Position
+	}
+	w.w.Event(e)
 }
 
 //export gio_onDraw
@@ -44,7 +44,7 @@
 
 static void handleMouse(NSView *view, NSEvent *event, int typ, CGFloat dx, CGFloat dy) {
 	NSPoint p = [view convertPoint:[event locationInWindow] fromView:nil];
-	if (!event.hasPreciseScrollingDeltas) {
+	if (typ == MOUSE_SCROLL && !event.hasPreciseScrollingDeltas) {
 		// dx and dy are in rows and columns.
 		dx *= 10;
 		dy *= 10;
@@ -80,10 +80,10 @@ static void handleMouse(NSView *view, NSEvent *event, int typ, CGFloat dx, CGFlo
 	handleMouse(self, event, MOUSE_UP, 0, 0);
 }
 - (void)mouseMoved:(NSEvent *)event {
-	handleMouse(self, event, MOUSE_MOVE, 0, 0);
+	handleMouse(self, event, MOUSE_MOVE, event.deltaX, event.deltaY);
 }
 - (void)mouseDragged:(NSEvent *)event {
-	handleMouse(self, event, MOUSE_MOVE, 0, 0);
+	handleMouse(self, event, MOUSE_MOVE, event.deltaX, event.deltaY);
 }
 - (void)scrollWheel:(NSEvent *)event {
 	CGFloat dx = -event.scrollingDeltaX;
@@ -178,6 +178,9 @@ void gio_setCursor(NSUInteger curID) {
 			case 7:
 				[NSCursor.openHandCursor set];
 				break;
+			case 8:
+				[NSCursor.operationNotAllowedCursor set];
+				break;
 			default:
 				[NSCursor.arrowCursor set];
 				break;
@@ -185,6 +188,29 @@ void gio_setCursor(NSUInteger curID) {
 	}
 }
 
+// customCursor keeps the most recent custom cursor alive.
+static NSCursor *customCursor;
+
+void gio_setCursorImage(const void *pixels, NSUInteger width, NSUInteger height, NSUInteger hotX, NSUInteger hotY) {
+	@autoreleasepool {
+		NSBitmapImageRep *rep = [[NSBitmapImageRep alloc] initWithBitmapDataPlanes:NULL
+																		pixelsWide:width
+																		pixelsHigh:height
+																	 bitsPerSample:8
+																   samplesPerPixel:4
+																		  hasAlpha:YES
+																		  isPlanar:NO
+																	colorSpaceName:NSDeviceRGBColorSpace
+																	   bytesPerRow:width*4
+																	  bitsPerPixel:32];
+		memcpy(rep.bitmapData, pixels, width*height*4);
+		NSImage *img = [[NSImage alloc] initWithSize:NSMakeSize(width, height)];
+		[img addRepresentation:rep];
+		customCursor = [[NSCursor alloc] initWithImage:img hotSpot:NSMakePoint(hotX, hotY)];
+		[customCursor set];
+	}
+}
+
 CFTypeRef gio_createWindow(CFTypeRef viewRef, const char *title, CGFloat width, CGFloat height, CGFloat minWidth, CGFloat minHeight, CGFloat maxWidth, CGFloat maxHeight) {
 	@autoreleasepool {
 		NSRect rect = NSMakeRect(0, 0, width, height);
@@ -7,6 +7,9 @@ package wm
 
 import (
 	"errors"
+	"image"
+
+	"github.com/cybriq/giocore/io/pointer"
 )
 
 type ViewEvent struct{}
@@ -41,4 +44,40 @@ func NewWindow(window Callbacks, opts *Options) error {
 	return errors.New("app: no window driver available")
 }
 
+// xCursorFallbacks maps cursor names to the legacy X cursor font
+// names, for cursor themes that lack the CSS cursor names.
+var xCursorFallbacks = map[pointer.CursorName]string{
+	pointer.CursorText:                     "xterm",
+	pointer.CursorPointer:                  "hand2",
+	pointer.CursorColResize:                "sb_h_double_arrow",
+	pointer.CursorRowResize:                "sb_v_double_arrow",
+	pointer.CursorGrab:                     "hand1",
+	pointer.CursorWait:                     "watch",
+	pointer.CursorProgress:                 "left_ptr_watch",
+	pointer.CursorNotAllowed:               "crossed_circle",
+	pointer.CursorMove:                     "fleur",
+	pointer.CursorNorthEastResize:          "top_right_corner",
+	pointer.CursorNorthWestResize:          "top_left_corner",
+	pointer.CursorSouthEastResize:          "bottom_right_corner",
+	pointer.CursorSouthWestResize:          "bottom_left_corner",
+	pointer.CursorNorthEastSouthWestResize: "fd_double_arrow",
+	pointer.CursorNorthWestSouthEastResize: "bd_double_arrow",
+}
+
+// cursorPixels converts a premultiplied RGBA bitmap to the
+// premultiplied 32-bit ARGB pixels used by both X11 and Wayland
+// cursors.
+func cursorPixels(img *image.RGBA) []uint32 {
+	size := img.Bounds().Size()
+	pixels := make([]uint32, 0, size.X*size.Y)
+	for y := 0; y < size.Y; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+size.X*4]
+		for x := 0; x < len(row); x += 4 {
+			r, g, b, a := uint32(row[x]), uint32(row[x+1]), uint32(row[x+2]), uint32(row[x+3])
+			pixels = append(pixels, a<<24|r<<16|g<<8|b)
+		}
+	}
+	return pixels
+}
+
 func (_ ViewEvent) ImplementsEvent() {}
@@ -5,6 +5,8 @@
 #include <wayland-client.h>
 #include "wayland_xdg_shell.h"
 #include "wayland_text_input.h"
+#include "wayland_relative_pointer.h"
+#include "wayland_pointer_gestures.h"
 #include "_cgo_export.h"
 
 const struct wl_registry_listener gio_registry_listener = {
@@ -115,3 +117,13 @@ const struct wl_data_source_listener gio_data_source_listener = {
 	.dnd_finished = gio_onDataSourceDNDFinished,
 	.action = gio_onDataSourceAction,
 };
+
+const struct zwp_relative_pointer_v1_listener gio_relative_pointer_listener = {
+	.relative_motion = gio_onRelativeMotion,
+};
+
+const struct zwp_pointer_gesture_pinch_v1_listener gio_pointer_gesture_pinch_listener = {
+	.begin = gio_onPinchBegin,
+	.update = gio_onPinchUpdate,
+	.end = gio_onPinchEnd,
+};
@@ -7,6 +7,7 @@ package wm
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"image"
@@ -24,7 +25,6 @@ import (
 
 	"github.com/cybriq/giocore/app/internal/xkb"
 	"github.com/cybriq/giocore/f32"
-	"github.com/cybriq/giocore/internal/fling"
 	"github.com/cybriq/giocore/io/clipboard"
 	"github.com/cybriq/giocore/io/key"
 	"github.com/cybriq/giocore/io/pointer"
@@ -42,9 +42,21 @@ import (
 //go:generate wayland-scanner client-header /usr/share/wayland-protocols/unstable/xdg-decoration/xdg-decoration-unstable-v1.xml wayland_xdg_decoration.h
 //go:generate wayland-scanner private-code /usr/share/wayland-protocols/unstable/xdg-decoration/xdg-decoration-unstable-v1.xml wayland_xdg_decoration.c
 
+//go:generate wayland-scanner client-header /usr/share/wayland-protocols/unstable/pointer-constraints/pointer-constraints-unstable-v1.xml wayland_pointer_constraints.h
+//go:generate wayland-scanner private-code /usr/share/wayland-protocols/unstable/pointer-constraints/pointer-constraints-unstable-v1.xml wayland_pointer_constraints.c
+
+//go:generate wayland-scanner client-header /usr/share/wayland-protocols/unstable/relative-pointer/relative-pointer-unstable-v1.xml wayland_relative_pointer.h
+//go:generate wayland-scanner private-code /usr/share/wayland-protocols/unstable/relative-pointer/relative-pointer-unstable-v1.xml wayland_relative_pointer.c
+
+//go:generate wayland-scanner client-header /usr/share/wayland-protocols/unstable/pointer-gestures/pointer-gestures-unstable-v1.xml wayland_pointer_gestures.h
+//go:generate wayland-scanner private-code /usr/share/wayland-protocols/unstable/pointer-gestures/pointer-gestures-unstable-v1.xml wayland_pointer_gestures.c
+
 //go:generate sed -i "1s;^;// +build linux,!android,!nowayland freebsd\\n\\n;" wayland_xdg_shell.c
 //go:generate sed -i "1s;^;// +build linux,!android,!nowayland freebsd\\n\\n;" wayland_xdg_decoration.c
 //go:generate sed -i "1s;^;// +build linux,!android,!nowayland freebsd\\n\\n;" wayland_text_input.c
+//go:generate sed -i "1s;^;// +build linux,!android,!nowayland freebsd\\n\\n;" wayland_pointer_constraints.c
+//go:generate sed -i "1s;^;// +build linux,!android,!nowayland freebsd\\n\\n;" wayland_relative_pointer.c
+//go:generate sed -i "1s;^;// +build linux,!android,!nowayland freebsd\\n\\n;" wayland_pointer_gestures.c
 
 /*
 #cgo linux pkg-config: wayland-client wayland-cursor
@@ -58,6 +70,9 @@ import (
 #include "wayland_text_input.h"
 #include "wayland_xdg_shell.h"
 #include "wayland_xdg_decoration.h"
+#include "wayland_pointer_constraints.h"
+#include "wayland_relative_pointer.h"
+#include "wayland_pointer_gestures.h"
 
 extern const struct wl_registry_listener gio_registry_listener;
 extern const struct wl_surface_listener gio_surface_listener;
@@ -74,6 +89,8 @@ extern const struct zwp_text_input_v3_listener gio_zwp_text_input_v3_listener;
 extern const struct wl_data_device_listener gio_data_device_listener;
 extern const struct wl_data_offer_listener gio_data_offer_listener;
 extern const struct wl_data_source_listener gio_data_source_listener;
+extern const struct zwp_relative_pointer_v1_listener gio_relative_pointer_listener;
+extern const struct zwp_pointer_gesture_pinch_v1_listener gio_pointer_gesture_pinch_listener;
 */
 import "C"
 
@@ -86,6 +103,9 @@ type wlDisplay struct {
 	shm               *C.struct_wl_shm
 	dataDeviceManager *C.struct_wl_data_device_manager
 	decor             *C.struct_zxdg_decoration_manager_v1
+	constraints       *C.struct_zwp_pointer_constraints_v1
+	relPointers       *C.struct_zwp_relative_pointer_manager_v1
+	gestures          *C.struct_zwp_pointer_gestures_v1
 	seat              *wlSeat
 	xkb               *xkb.Context
 	outputMap         map[C.uint32_t]*C.struct_wl_output
@@ -107,6 +127,7 @@ type wlSeat struct {
 	touch    *C.struct_wl_touch
 	keyboard *C.struct_wl_keyboard
 	im       *C.struct_zwp_text_input_v3
+	pinch    *C.struct_zwp_pointer_gesture_pinch_v1
 
 	// The most recent input serial.
 	serial C.uint32_t
@@ -157,23 +178,42 @@ type window struct {
 		time  time.Duration
 		steps image.Point
 		dist  f32.Point
+		// source is the axis source of the current pointer frame.
+		source pointer.ScrollSource
+		// stop is set when the current pointer frame ends a
+		// scroll sequence.
+		stop bool
+		// seq is the source of the scroll sequence in progress,
+		// if any.
+		seq pointer.ScrollSource
 	}
 	pointerBtns pointer.Buttons
 	lastPos     f32.Point
 	lastTouch   f32.Point
+	// pinchScale is the absolute scale of the pinch gesture
+	// in progress.
+	pinchScale float32
+
+	// lock is the active pointer constraint, if any.
+	lock struct {
+		mode     pointer.LockMode
+		locked   *C.struct_zwp_locked_pointer_v1
+		confined *C.struct_zwp_confined_pointer_v1
+		relative *C.struct_zwp_relative_pointer_v1
+	}
 
 	cursor struct {
 		theme  *C.struct_wl_cursor_theme
 		cursor *C.struct_wl_cursor
 		surf   *C.struct_wl_surface
-	}
-
-	fling struct {
-		yExtrapolation fling.Extrapolation
-		xExtrapolation fling.Extrapolation
-		anim           fling.Animation
-		start          bool
-		dir            f32.Point
+		// custom is the custom bitmap cursor, if any.
+		custom struct {
+			buf     *C.struct_wl_buffer
+			size    image.Point
+			hotspot image.Point
+		}
+		// hidden is set for CursorNone.
+		hidden bool
 	}
 
 	stage             system.Stage
@@ -413,6 +453,10 @@ func (s *wlSeat) destroy() {
 		C.zwp_text_input_v3_destroy(s.im)
 		s.im = nil
 	}
+	if s.pinch != nil {
+		C.zwp_pointer_gesture_pinch_v1_destroy(s.pinch)
+		s.pinch = nil
+	}
 	if s.pointer != nil {
 		C.wl_pointer_release(s.pointer)
 	}
@@ -442,7 +486,15 @@ func (s *wlSeat) updateCaps(caps C.uint32_t) {
 	case s.pointer == nil && caps&C.WL_SEAT_CAPABILITY_POINTER != 0:
 		s.pointer = C.wl_seat_get_pointer(s.seat)
 		C.wl_pointer_add_listener(s.pointer, &C.gio_pointer_listener, unsafe.Pointer(s.seat))
+		if s.disp.gestures != nil {
+			s.pinch = C.zwp_pointer_gestures_v1_get_pinch_gesture(s.disp.gestures, s.pointer)
+			C.zwp_pointer_gesture_pinch_v1_add_listener(s.pinch, &C.gio_pointer_gesture_pinch_listener, unsafe.Pointer(s.seat))
+		}
 	case s.pointer != nil && caps&C.WL_SEAT_CAPABILITY_POINTER == 0:
+		if s.pinch != nil {
+			C.zwp_pointer_gesture_pinch_v1_destroy(s.pinch)
+			s.pinch = nil
+		}
 		C.wl_pointer_release(s.pointer)
 		s.pointer = nil
 	}
@@ -603,6 +655,12 @@ func gio_onRegistryGlobal(data unsafe.Pointer, reg *C.struct_wl_registry, name C
 		d.wm = (*C.struct_xdg_wm_base)(C.wl_registry_bind(reg, name, &C.xdg_wm_base_interface, 1))
 	case "zxdg_decoration_manager_v1":
 		d.decor = (*C.struct_zxdg_decoration_manager_v1)(C.wl_registry_bind(reg, name, &C.zxdg_decoration_manager_v1_interface, 1))
+	case "zwp_pointer_constraints_v1":
+		d.constraints = (*C.struct_zwp_pointer_constraints_v1)(C.wl_registry_bind(reg, name, &C.zwp_pointer_constraints_v1_interface, 1))
+	case "zwp_relative_pointer_manager_v1":
+		d.relPointers = (*C.struct_zwp_relative_pointer_manager_v1)(C.wl_registry_bind(reg, name, &C.zwp_relative_pointer_manager_v1_interface, 1))
+	case "zwp_pointer_gestures_v1":
+		d.gestures = (*C.struct_zwp_pointer_gestures_v1)(C.wl_registry_bind(reg, name, &C.zwp_pointer_gestures_v1_interface, 1))
 		// TODO: Implement and test text-input support.
 		/*case "zwp_text_input_manager_v3":
 		d.imm = (*C.struct_zwp_text_input_manager_v3)(C.wl_registry_bind(reg, name, &C.zwp_text_input_manager_v3_interface, 1))*/
@@ -774,7 +832,6 @@ func gio_onPointerLeave(data unsafe.Pointer, p *C.struct_wl_pointer, serial C.ui
 func gio_onPointerMotion(data unsafe.Pointer, p *C.struct_wl_pointer, t C.uint32_t, x, y C.wl_fixed_t) {
 	s := callbackLoad(data).(*wlSeat)
 	w := s.pointerFocus
-	w.resetFling()
 	w.onPointerMotion(x, y, t)
 }
 
@@ -810,7 +867,6 @@ func gio_onPointerButton(data unsafe.Pointer, p *C.struct_wl_pointer, serial, t,
 		typ = pointer.Press
 	}
 	w.flushScroll()
-	w.resetFling()
 	w.w.Event(pointer.Event{
 		Type:      typ,
 		Source:    pointer.Mouse,
@@ -826,7 +882,6 @@ func gio_onPointerAxis(data unsafe.Pointer, p *C.struct_wl_pointer, t, axis C.ui
 	s := callbackLoad(data).(*wlSeat)
 	w := s.pointerFocus
 	v := fromFixed(value)
-	w.resetFling()
 	if w.scroll.dist == (f32.Point{}) {
 		w.scroll.time = time.Duration(t) * time.Millisecond
 	}
@@ -843,43 +898,102 @@ func gio_onPointerFrame(data unsafe.Pointer, p *C.struct_wl_pointer) {
 	s := callbackLoad(data).(*wlSeat)
 	w := s.pointerFocus
 	w.flushScroll()
-	w.flushFling()
 }
 
-func (w *window) flushFling() {
-	if !w.fling.start {
-		return
+//export gio_onPointerAxisSource
+func gio_onPointerAxisSource(data unsafe.Pointer, p *C.struct_wl_pointer, source C.uint32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	w := s.pointerFocus
+	switch source {
+	case C.WL_POINTER_AXIS_SOURCE_WHEEL, C.WL_POINTER_AXIS_SOURCE_WHEEL_TILT:
+		w.scroll.source = pointer.ScrollSourceWheel
+	case C.WL_POINTER_AXIS_SOURCE_FINGER:
+		w.scroll.source = pointer.ScrollSourceFinger
+	case C.WL_POINTER_AXIS_SOURCE_CONTINUOUS:
+		w.scroll.source = pointer.ScrollSourceContinuous
 	}
-	w.fling.start = false
-	estx, esty := w.fling.xExtrapolation.Estimate(), w.fling.yExtrapolation.Estimate()
-	w.fling.xExtrapolation = fling.Extrapolation{}
-	w.fling.yExtrapolation = fling.Extrapolation{}
-	vel := float32(math.Sqrt(float64(estx.Velocity*estx.Velocity + esty.Velocity*esty.Velocity)))
-	_, _, c := w.config()
-	if !w.fling.anim.Start(c, time.Now(), vel) {
-		return
+}
+
+//export gio_onPointerAxisStop
+func gio_onPointerAxisStop(data unsafe.Pointer, p *C.struct_wl_pointer, t, axis C.uint32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	w := s.pointerFocus
+	if w.scroll.dist == (f32.Point{}) {
+		w.scroll.time = time.Duration(t) * time.Millisecond
 	}
-	invDist := 1 / vel
-	w.fling.dir.X = estx.Velocity * invDist
-	w.fling.dir.Y = esty.Velocity * invDist
+	w.scroll.stop = true
 }
 
-//export gio_onPointerAxisSource
-func gio_onPointerAxisSource(data unsafe.Pointer, pointer *C.struct_wl_pointer, source C.uint32_t) {
+//export gio_onPinchBegin
+func gio_onPinchBegin(data unsafe.Pointer, p *C.struct_zwp_pointer_gesture_pinch_v1, serial, t C.uint32_t, surf *C.struct_wl_surface, fingers C.uint32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	s.serial = serial
+	w := callbackLoad(unsafe.Pointer(surf)).(*window)
+	s.pointerFocus = w
+	w.pinchScale = 1
+	w.flushScroll()
+	w.w.Event(pointer.Event{
+		Type:        pointer.Pinch,
+		Source:      pointer.Mouse,
+		Buttons:     w.pointerBtns,
+		Position:    w.lastPos,
+		Time:        time.Duration(t) * time.Millisecond,
+		Modifiers:   w.disp.xkb.Modifiers(),
+		ScrollPhase: pointer.ScrollPhaseBegin,
+		PinchScale:  1,
+	})
 }
 
-//export gio_onPointerAxisStop
-func gio_onPointerAxisStop(data unsafe.Pointer, p *C.struct_wl_pointer, t, axis C.uint32_t) {
+//export gio_onPinchUpdate
+func gio_onPinchUpdate(data unsafe.Pointer, p *C.struct_zwp_pointer_gesture_pinch_v1, t C.uint32_t, dx, dy, scale, rotation C.wl_fixed_t) {
+	s := callbackLoad(data).(*wlSeat)
+	w := s.pointerFocus
+	// The protocol reports the scale relative to the beginning
+	// of the gesture.
+	abs := fromFixed(scale)
+	if abs <= 0 || w.pinchScale <= 0 {
+		return
+	}
+	rel := abs / w.pinchScale
+	w.pinchScale = abs
+	w.w.Event(pointer.Event{
+		Type:     pointer.Pinch,
+		Source:   pointer.Mouse,
+		Buttons:  w.pointerBtns,
+		Position: w.lastPos,
+		Delta: f32.Point{
+			X: fromFixed(dx) * float32(w.scale),
+			Y: fromFixed(dy) * float32(w.scale),
+		},
+		Time:          time.Duration(t) * time.Millisecond,
+		Modifiers:     w.disp.xkb.Modifiers(),
+		ScrollPhase:   pointer.ScrollPhaseUpdate,
+		PinchScale:    rel,
+		PinchRotation: fromFixed(rotation) * math.Pi / 180,
+	})
+}
+
+//export gio_onPinchEnd
+func gio_onPinchEnd(data unsafe.Pointer, p *C.struct_zwp_pointer_gesture_pinch_v1, serial, t C.uint32_t, cancelled C.int32_t) {
 	s := callbackLoad(data).(*wlSeat)
+	s.serial = serial
 	w := s.pointerFocus
-	w.fling.start = true
+	w.pinchScale = 0
+	w.w.Event(pointer.Event{
+		Type:        pointer.Pinch,
+		Source:      pointer.Mouse,
+		Buttons:     w.pointerBtns,
+		Position:    w.lastPos,
+		Time:        time.Duration(t) * time.Millisecond,
+		Modifiers:   w.disp.xkb.Modifiers(),
+		ScrollPhase: pointer.ScrollPhaseEnd,
+	})
 }
 
 //export gio_onPointerAxisDiscrete
 func gio_onPointerAxisDiscrete(data unsafe.Pointer, p *C.struct_wl_pointer, axis C.uint32_t, discrete C.int32_t) {
 	s := callbackLoad(data).(*wlSeat)
 	w := s.pointerFocus
-	w.resetFling()
 	switch axis {
 	case C.WL_POINTER_AXIS_HORIZONTAL_SCROLL:
 		w.scroll.steps.X += int(discrete)
@@ -926,12 +1040,11 @@ func (w *window) setOptions(opts *Options) {
 
 func (w *window) SetCursor(name pointer.CursorName) {
 	if name == pointer.CursorNone {
-		C.wl_pointer_set_cursor(w.disp.seat.pointer, w.serial, nil, 0, 0)
+		w.cursor.hidden = true
+		w.setCursor(w.disp.seat.pointer, w.serial)
 		return
 	}
 	switch name {
-	default:
-		fallthrough
 	case pointer.CursorDefault:
 		name = "left_ptr"
 	case pointer.CursorText:
@@ -947,32 +1060,171 @@ func (w *window) SetCursor(name pointer.CursorName) {
 	case pointer.CursorGrab:
 		name = "hand1"
 	}
-	cname := C.CString(string(name))
-	defer C.free(unsafe.Pointer(cname))
-	c := C.wl_cursor_theme_get_cursor(w.cursor.theme, cname)
+	c := w.loadCursor(string(name))
+	if fallback, ok := xCursorFallbacks[name]; c == nil && ok {
+		c = w.loadCursor(fallback)
+	}
+	if c == nil {
+		c = w.loadCursor("left_ptr")
+	}
 	if c == nil {
 		return
 	}
+	old := w.cursor.custom.buf
+	w.cursor.custom.buf = nil
 	w.cursor.cursor = c
+	w.cursor.hidden = false
 	w.setCursor(w.disp.seat.pointer, w.serial)
+	// Destroy the previous custom cursor after the new cursor
+	// replaced it.
+	if old != nil {
+		C.wl_buffer_destroy(old)
+	}
+}
+
+func (w *window) SetCursorImage(img *pointer.CursorImage) {
+	buf, err := w.disp.createShmBuffer(img.Image())
+	if err != nil {
+		return
+	}
+	old := w.cursor.custom.buf
+	w.cursor.custom.buf = buf
+	w.cursor.custom.size = img.Image().Bounds().Size()
+	w.cursor.custom.hotspot = img.Hotspot()
+	w.cursor.hidden = false
+	w.setCursor(w.disp.seat.pointer, w.serial)
+	// Destroy the previous buffer after the new buffer is attached.
+	if old != nil {
+		C.wl_buffer_destroy(old)
+	}
+}
+
+func (w *window) loadCursor(name string) *C.struct_wl_cursor {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	return C.wl_cursor_theme_get_cursor(w.cursor.theme, cname)
+}
+
+func (w *window) SetPointerLock(mode pointer.LockMode) {
+	if mode == w.lock.mode {
+		return
+	}
+	wasRelative := w.lock.mode == pointer.LockRelative
+	w.destroyPointerLock()
+	s := w.disp.seat
+	if wasRelative && s != nil && s.pointer != nil {
+		// Show the cursor again.
+		w.setCursor(s.pointer, w.serial)
+	}
+	if mode == pointer.LockNone || w.disp.constraints == nil || s == nil || s.pointer == nil {
+		return
+	}
+	if mode == pointer.LockRelative && w.disp.relPointers == nil {
+		// Relative motion is unavailable; confine instead.
+		mode = pointer.LockConfine
+	}
+	w.lock.mode = mode
+	switch mode {
+	case pointer.LockConfine:
+		w.lock.confined = C.zwp_pointer_constraints_v1_confine_pointer(w.disp.constraints, w.surf, s.pointer, nil, C.ZWP_POINTER_CONSTRAINTS_V1_LIFETIME_PERSISTENT)
+	case pointer.LockRelative:
+		w.lock.locked = C.zwp_pointer_constraints_v1_lock_pointer(w.disp.constraints, w.surf, s.pointer, nil, C.ZWP_POINTER_CONSTRAINTS_V1_LIFETIME_PERSISTENT)
+		w.lock.relative = C.zwp_relative_pointer_manager_v1_get_relative_pointer(w.disp.relPointers, s.pointer)
+		callbackStore(unsafe.Pointer(w.lock.relative), w)
+		C.zwp_relative_pointer_v1_add_listener(w.lock.relative, &C.gio_relative_pointer_listener, unsafe.Pointer(w.lock.relative))
+		// Hide the cursor while locked. setCursor keeps it hidden
+		// until the lock is released.
+		C.wl_pointer_set_cursor(s.pointer, w.serial, nil, 0, 0)
+	}
+}
+
+func (w *window) destroyPointerLock() {
+	if w.lock.relative != nil {
+		callbackDelete(unsafe.Pointer(w.lock.relative))
+		C.zwp_relative_pointer_v1_destroy(w.lock.relative)
+		w.lock.relative = nil
+	}
+	if w.lock.locked != nil {
+		// Leave the cursor where the application believes it is.
+		C.zwp_locked_pointer_v1_set_cursor_position_hint(w.lock.locked, C.wl_fixed_from_double(C.double(w.lastPos.X/float32(w.scale))), C.wl_fixed_from_double(C.double(w.lastPos.Y/float32(w.scale))))
+		C.zwp_locked_pointer_v1_destroy(w.lock.locked)
+		w.lock.locked = nil
+	}
+	if w.lock.confined != nil {
+		C.zwp_confined_pointer_v1_destroy(w.lock.confined)
+		w.lock.confined = nil
+	}
+	w.lock.mode = pointer.LockNone
+}
+
+func (w *window) destroyCustomCursor() {
+	if w.cursor.custom.buf != nil {
+		C.wl_buffer_destroy(w.cursor.custom.buf)
+		w.cursor.custom.buf = nil
+	}
 }
 
-func (w *window) setCursor(pointer *C.struct_wl_pointer, serial C.uint32_t) {
+func (w *window) setCursor(ptr *C.struct_wl_pointer, serial C.uint32_t) {
+	if w.cursor.hidden || w.lock.mode == pointer.LockRelative {
+		C.wl_pointer_set_cursor(ptr, serial, nil, 0, 0)
+		return
+	}
+	if c := w.cursor.custom; c.buf != nil {
+		C.wl_pointer_set_cursor(ptr, serial, w.cursor.surf, C.int32_t(c.hotspot.X), C.int32_t(c.hotspot.Y))
+		C.wl_surface_attach(w.cursor.surf, c.buf, 0, 0)
+		C.wl_surface_damage(w.cursor.surf, 0, 0, C.int32_t(c.size.X), C.int32_t(c.size.Y))
+		C.wl_surface_commit(w.cursor.surf)
+		return
+	}
 	// Get images[0].
 	img := *w.cursor.cursor.images
 	buf := C.wl_cursor_image_get_buffer(img)
 	if buf == nil {
 		return
 	}
-	C.wl_pointer_set_cursor(pointer, serial, w.cursor.surf, C.int32_t(img.hotspot_x), C.int32_t(img.hotspot_y))
+	C.wl_pointer_set_cursor(ptr, serial, w.cursor.surf, C.int32_t(img.hotspot_x), C.int32_t(img.hotspot_y))
 	C.wl_surface_attach(w.cursor.surf, buf, 0, 0)
 	C.wl_surface_damage(w.cursor.surf, 0, 0, C.int32_t(img.width), C.int32_t(img.height))
 	C.wl_surface_commit(w.cursor.surf)
 }
 
-func (w *window) resetFling() {
-	w.fling.start = false
-	w.fling.anim = fling.Animation{}
+// createShmBuffer copies the premultiplied RGBA image into a new
+// shared memory buffer of format ARGB8888.
+func (d *wlDisplay) createShmBuffer(img *image.RGBA) (*C.struct_wl_buffer, error) {
+	size := img.Bounds().Size()
+	stride := size.X * 4
+	length := stride * size.Y
+	f, err := ioutil.TempFile(os.Getenv("XDG_RUNTIME_DIR"), "gio-shm-")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// The compositor receives the file through its descriptor.
+	os.Remove(f.Name())
+	if err := f.Truncate(int64(length)); err != nil {
+		return nil, err
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, length, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	// ARGB8888 is little endian.
+	for i, p := range cursorPixels(img) {
+		binary.LittleEndian.PutUint32(data[i*4:], p)
+	}
+	if err := syscall.Munmap(data); err != nil {
+		return nil, err
+	}
+	pool := C.wl_shm_create_pool(d.shm, C.int32_t(f.Fd()), C.int32_t(length))
+	if pool == nil {
+		return nil, errors.New("wayland: wl_shm_create_pool failed")
+	}
+	defer C.wl_shm_pool_destroy(pool)
+	buf := C.wl_shm_pool_create_buffer(pool, 0, C.int32_t(size.X), C.int32_t(size.Y), C.int32_t(stride), C.WL_SHM_FORMAT_ARGB8888)
+	if buf == nil {
+		return nil, errors.New("wayland: wl_shm_pool_create_buffer failed")
+	}
+	return buf, nil
 }
 
 //export gio_onKeyboardKeymap
@@ -1016,7 +1268,6 @@ func gio_onKeyboardKey(data unsafe.Pointer, keyboard *C.struct_wl_keyboard, seri
 	w := s.keyboardFocus
 	t := time.Duration(timestamp) * time.Millisecond
 	s.disp.repeat.Stop(t)
-	w.resetFling()
 	kc := mapXKBKeycode(uint32(keyCode))
 	ks := mapXKBKeyState(uint32(state))
 	for _, e := range w.disp.xkb.DispatchKey(kc, ks) {
@@ -1210,6 +1461,8 @@ func (d *wlDisplay) wakeup() {
 }
 
 func (w *window) destroy() {
+	w.destroyPointerLock()
+	w.destroyCustomCursor()
 	if w.cursor.surf != nil {
 		C.wl_surface_destroy(w.cursor.surf)
 	}
@@ -1315,11 +1568,6 @@ func gio_onDataSourceAction(data unsafe.Pointer, source *C.struct_wl_data_source
 }
 
 func (w *window) flushScroll() {
-	var fling f32.Point
-	if w.fling.anim.Active() {
-		dist := float32(w.fling.anim.Tick(time.Now()))
-		fling = w.fling.dir.Mul(dist)
-	}
 	// The Wayland reported scroll distance for
 	// discrete scroll axes is only 10 pixels, where
 	// 100 seems more appropriate.
@@ -1330,25 +1578,86 @@ func (w *window) flushScroll() {
 	if w.scroll.steps.Y != 0 {
 		w.scroll.dist.Y *= discreteScale
 	}
-	total := w.scroll.dist.Add(fling)
-	if total == (f32.Point{}) {
+	src := w.scroll.source
+	switch {
+	case src != pointer.ScrollSourceUnknown:
+	case w.scroll.steps != (image.Point{}):
+		// Only discrete axes report steps.
+		src = pointer.ScrollSourceWheel
+	default:
+		// The source may be omitted for the stop of a sequence.
+		src = w.scroll.seq
+	}
+	phases, seq := scrollPhases(w.scroll.seq, src, w.scroll.stop)
+	total := w.scroll.dist
+	steps := w.scroll.steps
+	w.scroll.dist = f32.Point{}
+	w.scroll.steps = image.Point{}
+	w.scroll.source = pointer.ScrollSourceUnknown
+	w.scroll.stop = false
+	if total == (f32.Point{}) && phases[0] != pointer.ScrollPhaseEnd {
 		return
 	}
+	w.scroll.seq = seq
+	for _, phase := range phases {
+		w.w.Event(pointer.Event{
+			Type:         pointer.Scroll,
+			Source:       pointer.Mouse,
+			Buttons:      w.pointerBtns,
+			Position:     w.lastPos,
+			Scroll:       total,
+			ScrollSource: src,
+			ScrollSteps:  steps,
+			ScrollPhase:  phase,
+			Time:         w.scroll.time,
+			Modifiers:    w.disp.xkb.Modifiers(),
+		})
+		// The scroll amount belongs to the first event.
+		total = f32.Point{}
+		steps = image.Point{}
+	}
+}
+
+// scrollPhases returns the phases of the scroll events of a pointer
+// frame from src, given the source seq of the sequence in progress and
+// whether the frame stops the sequence. It also returns the source of
+// the sequence in progress after the frame.
+//
+// A sequence that begins and stops in the same frame results in a
+// ScrollPhaseBegin event followed by a ScrollPhaseEnd event.
+func scrollPhases(seq, src pointer.ScrollSource, stop bool) ([]pointer.ScrollPhase, pointer.ScrollSource) {
+	if src != pointer.ScrollSourceFinger && src != pointer.ScrollSourceContinuous {
+		return []pointer.ScrollPhase{pointer.ScrollPhaseNone}, pointer.ScrollSourceUnknown
+	}
+	switch {
+	case seq != src && stop:
+		return []pointer.ScrollPhase{pointer.ScrollPhaseBegin, pointer.ScrollPhaseEnd}, pointer.ScrollSourceUnknown
+	case seq != src:
+		return []pointer.ScrollPhase{pointer.ScrollPhaseBegin}, src
+	case stop:
+		return []pointer.ScrollPhase{pointer.ScrollPhaseEnd}, pointer.ScrollSourceUnknown
+	default:
+		return []pointer.ScrollPhase{pointer.ScrollPhaseUpdate}, src
+	}
+}
+
+//export gio_onRelativeMotion
+func gio_onRelativeMotion(data unsafe.Pointer, p *C.struct_zwp_relative_pointer_v1, utimeHi, utimeLo C.uint32_t, dx, dy, dxUnaccel, dyUnaccel C.wl_fixed_t) {
+	w := callbackLoad(data).(*window)
+	utime := uint64(utimeHi)<<32 | uint64(utimeLo)
+	w.flushScroll()
 	w.w.Event(pointer.Event{
-		Type:      pointer.Scroll,
-		Source:    pointer.Mouse,
+		Type:     pointer.Move,
+		Position: w.lastPos,
+		Delta: f32.Point{
+			X: fromFixed(dxUnaccel) * float32(w.scale),
+			Y: fromFixed(dyUnaccel) * float32(w.scale),
+		},
 		Buttons:   w.pointerBtns,
-		Position:  w.lastPos,
-		Scroll:    total,
-		Time:      w.scroll.time,
+		Source:    pointer.Mouse,
+		Time:      time.Duration(utime) * time.Microsecond,
 		Modifiers: w.disp.xkb.Modifiers(),
 	})
-	if w.scroll.steps == (image.Point{}) {
-		w.fling.xExtrapolation.SampleDelta(w.scroll.time, -w.scroll.dist.X)
-		w.fling.yExtrapolation.SampleDelta(w.scroll.time, -w.scroll.dist.Y)
-	}
-	w.scroll.dist = f32.Point{}
-	w.scroll.steps = image.Point{}
 }
 
 func (w *window) onPointerMotion(x, y C.wl_fixed_t, t C.uint32_t) {
@@ -1409,7 +1718,7 @@ func (w *window) config() (int, int, unit.Metric) {
 
 func (w *window) draw(sync bool) {
 	w.flushScroll()
-	anim := w.animating || w.fling.anim.Active()
+	anim := w.animating
 	dead := w.dead
 	if dead || (!anim && !sync) {
 		return
@@ -1545,6 +1854,15 @@ func (d *wlDisplay) destroy() {
 	if d.decor != nil {
 		C.zxdg_decoration_manager_v1_destroy(d.decor)
 	}
+	if d.constraints != nil {
+		C.zwp_pointer_constraints_v1_destroy(d.constraints)
+	}
+	if d.relPointers != nil {
+		C.zwp_relative_pointer_manager_v1_destroy(d.relPointers)
+	}
+	if d.gestures != nil {
+		C.zwp_pointer_gestures_v1_destroy(d.gestures)
+	}
 	if d.shm != nil {
 		C.wl_shm_destroy(d.shm)
 	}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build (linux && !android && !nowayland) || freebsd
+// +build linux,!android,!nowayland freebsd
+
+package wm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cybriq/giocore/io/pointer"
+)
+
+func TestScrollPhases(t *testing.T) {
+	const (
+		finger = pointer.ScrollSourceFinger
+		wheel  = pointer.ScrollSourceWheel
+	)
+	var (
+		none   = pointer.ScrollPhaseNone
+		begin  = pointer.ScrollPhaseBegin
+		update = pointer.ScrollPhaseUpdate
+		end    = pointer.ScrollPhaseEnd
+	)
+	frames := []struct {
+		src    pointer.ScrollSource
+		stop   bool
+		phases []pointer.ScrollPhase
+	}{
+		// A sequence that begins and stops in the same frame.
+		{src: finger, stop: true, phases: []pointer.ScrollPhase{begin, end}},
+		// The next sequence begins anew.
+		{src: finger, phases: []pointer.ScrollPhase{begin}},
+		{src: finger, phases: []pointer.ScrollPhase{update}},
+		{src: finger, stop: true, phases: []pointer.ScrollPhase{end}},
+		{src: wheel, phases: []pointer.ScrollPhase{none}},
+		{src: finger, phases: []pointer.ScrollPhase{begin}},
+	}
+	seq := pointer.ScrollSourceUnknown
+	for i, f := range frames {
+		var phases []pointer.ScrollPhase
+		phases, seq = scrollPhases(seq, f.src, f.stop)
+		if !reflect.DeepEqual(phases, f.phases) {
+			t.Errorf("frame %d: got phases %v, expected %v", i, phases, f.phases)
+		}
+	}
+	if seq != finger {
+		t.Errorf("got sequence source %v after the last frame, expected %v", seq, finger)
+	}
+}
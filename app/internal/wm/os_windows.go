@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"reflect"
 	"runtime"
 	"sort"
@@ -22,6 +23,7 @@ import (
 	gowindows "golang.org/x/sys/windows"
 
 	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/internal/f32color"
 	"github.com/cybriq/giocore/io/clipboard"
 	"github.com/cybriq/giocore/io/key"
 	"github.com/cybriq/giocore/io/pointer"
@@ -55,6 +57,16 @@ type window struct {
 	// to the most recent WM_SETCURSOR.
 	cursorIn bool
 	cursor   syscall.Handle
+	// customCursor is the cursor created by SetCursorImage, if any.
+	customCursor syscall.Handle
+
+	lastPos f32.Point
+	// lock is the active pointer lock mode, and pos the pointer
+	// position when the lock was taken.
+	lock struct {
+		mode pointer.LockMode
+		pos  f32.Point
+	}
 
 	// placement saves the previous window position when in full screen mode.
 	placement *windows.WindowPlacement
@@ -273,19 +285,34 @@ func windowProc(hwnd syscall.Handle, msg uint32, wParam, lParam uintptr) uintptr
 			Type: pointer.Cancel,
 		})
 	case windows.WM_SETFOCUS:
+		// The system releases cursor clipping when focus is lost.
+		w.clipCursor()
 		w.w.Event(key.FocusEvent{Focus: true})
 	case windows.WM_KILLFOCUS:
 		w.w.Event(key.FocusEvent{Focus: false})
 	case windows.WM_MOUSEMOVE:
 		x, y := coordsFromlParam(lParam)
 		p := f32.Point{X: float32(x), Y: float32(y)}
-		w.w.Event(pointer.Event{
+		ev := pointer.Event{
 			Type:     pointer.Move,
 			Source:   pointer.Mouse,
 			Position: p,
 			Buttons:  w.pointerBtns,
 			Time:     windows.GetMessageTime(),
-		})
+		}
+		if w.lock.mode == pointer.LockRelative {
+			center := f32.Point{X: float32(w.width / 2), Y: float32(w.height / 2)}
+			if p == center {
+				// Ignore the motion caused by warpToCenter.
+				break
+			}
+			ev.Position = w.lock.pos
+			ev.Delta = p.Sub(center)
+			w.warpToCenter()
+		} else {
+			w.lastPos = p
+		}
+		w.w.Event(ev)
 	case windows.WM_MOUSEWHEEL:
 		w.scrollEvent(wParam, lParam, false)
 	case windows.WM_MOUSEHWHEEL:
@@ -297,6 +324,10 @@ func windowProc(hwnd syscall.Handle, msg uint32, wParam, lParam uintptr) uintptr
 			windows.ReleaseDC(w.hdc)
 			w.hdc = 0
 		}
+		if w.customCursor != 0 {
+			windows.DestroyIcon(w.customCursor)
+			w.customCursor = 0
+		}
 		// The system destroys the HWND for us.
 		w.hwnd = 0
 		windows.PostQuitMessage(0)
@@ -309,6 +340,7 @@ func windowProc(hwnd syscall.Handle, msg uint32, wParam, lParam uintptr) uintptr
 		case windows.SIZE_MAXIMIZED, windows.SIZE_RESTORED:
 			w.setStage(system.StageRunning)
 		}
+		w.clipCursor()
 	case windows.WM_GETMINMAXINFO:
 		mm := (*windows.MinMaxInfo)(unsafe.Pointer(uintptr(lParam)))
 		if w.minmax.minWidth > 0 || w.minmax.minHeight > 0 {
@@ -326,7 +358,12 @@ func windowProc(hwnd syscall.Handle, msg uint32, wParam, lParam uintptr) uintptr
 	case windows.WM_SETCURSOR:
 		w.cursorIn = (lParam & 0xffff) == windows.HTCLIENT
 		if w.cursorIn {
-			windows.SetCursor(w.cursor)
+			if w.lock.mode == pointer.LockRelative {
+				// Hide the cursor.
+				windows.SetCursor(0)
+			} else {
+				windows.SetCursor(w.cursor)
+			}
 			return windows.TRUE
 		}
 	case _WM_WAKEUP:
@@ -632,10 +669,116 @@ func (w *window) SetCursor(name pointer.CursorName) {
 	if err != nil {
 		c = resources.cursor
 	}
+	w.setCursor(c, 0)
+}
+
+func (w *window) SetCursorImage(img *pointer.CursorImage) {
+	c, err := createCursor(img)
+	if err != nil {
+		return
+	}
+	w.setCursor(c, c)
+}
+
+func (w *window) SetPointerLock(mode pointer.LockMode) {
+	if mode == w.lock.mode {
+		return
+	}
+	if w.lock.mode == pointer.LockRelative {
+		// Restore the pointer to where it was locked.
+		w.lastPos = w.lock.pos
+		w.setCursorPos(w.lock.pos)
+	}
+	w.lock.mode = mode
+	w.lock.pos = w.lastPos
+	if mode == pointer.LockNone {
+		windows.ClipCursor(nil)
+	}
+	w.clipCursor()
+	if w.cursorIn {
+		if mode == pointer.LockRelative {
+			windows.SetCursor(0)
+		} else {
+			windows.SetCursor(w.cursor)
+		}
+	}
+	if mode == pointer.LockRelative {
+		w.warpToCenter()
+	}
+}
+
+// clipCursor confines the cursor to the client area if the
+// pointer is locked.
+func (w *window) clipCursor() {
+	if w.lock.mode == pointer.LockNone {
+		return
+	}
+	var r windows.Rect
+	windows.GetClientRect(w.hwnd, &r)
+	tl := windows.Point{X: r.Left, Y: r.Top}
+	br := windows.Point{X: r.Right, Y: r.Bottom}
+	windows.ClientToScreen(w.hwnd, &tl)
+	windows.ClientToScreen(w.hwnd, &br)
+	windows.ClipCursor(&windows.Rect{Left: tl.X, Top: tl.Y, Right: br.X, Bottom: br.Y})
+}
+
+// warpToCenter moves the cursor to the center of the client area.
+// The cursor is kept there while in relative pointer mode.
+func (w *window) warpToCenter() {
+	w.setCursorPos(f32.Point{X: float32(w.width / 2), Y: float32(w.height / 2)})
+}
+
+// setCursorPos moves the cursor to p in client coordinates.
+func (w *window) setCursorPos(p f32.Point) {
+	sp := windows.Point{X: int32(p.X), Y: int32(p.Y)}
+	windows.ClientToScreen(w.hwnd, &sp)
+	windows.SetCursorPos(sp.X, sp.Y)
+}
+
+// setCursor updates the window cursor to c and replaces the
+// custom cursor, if any, with custom.
+func (w *window) setCursor(c, custom syscall.Handle) {
 	w.cursor = c
 	if w.cursorIn {
 		windows.SetCursor(w.cursor)
 	}
+	if w.customCursor != 0 {
+		windows.DestroyIcon(w.customCursor)
+	}
+	w.customCursor = custom
+}
+
+// createCursor creates a cursor from the premultiplied bitmap of img.
+func createCursor(img *pointer.CursorImage) (syscall.Handle, error) {
+	src := img.Image()
+	size := src.Bounds().Size()
+	// Convert to the BGRA format with straight alpha expected by
+	// CreateIconIndirect.
+	bgra := make([]byte, 0, size.X*size.Y*4)
+	for y := 0; y < size.Y; y++ {
+		row := src.Pix[y*src.Stride : y*src.Stride+size.X*4]
+		for x := 0; x < len(row); x += 4 {
+			c := f32color.RGBAToNRGBA(color.RGBA{R: row[x], G: row[x+1], B: row[x+2], A: row[x+3]})
+			bgra = append(bgra, c.B, c.G, c.R, c.A)
+		}
+	}
+	color, err := windows.CreateBitmap(int32(size.X), int32(size.Y), 1, 32, unsafe.Pointer(&bgra[0]))
+	if err != nil {
+		return 0, err
+	}
+	defer windows.DeleteObject(color)
+	mask, err := windows.CreateBitmap(int32(size.X), int32(size.Y), 1, 1, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.DeleteObject(mask)
+	hot := img.Hotspot()
+	return windows.CreateIconIndirect(&windows.IconInfo{
+		XHotspot: uint32(hot.X),
+		YHotspot: uint32(hot.Y),
+		HbmMask:  mask,
+		HbmColor: color,
+	})
 }
 
 func loadCursor(name pointer.CursorName) (syscall.Handle, error) {
@@ -655,8 +798,18 @@ func loadCursor(name pointer.CursorName) (syscall.Handle, error) {
 		curID = windows.IDC_SIZEWE
 	case pointer.CursorRowResize:
 		curID = windows.IDC_SIZENS
-	case pointer.CursorGrab:
+	case pointer.CursorGrab, pointer.CursorMove:
 		curID = windows.IDC_SIZEALL
+	case pointer.CursorWait:
+		curID = windows.IDC_WAIT
+	case pointer.CursorProgress:
+		curID = windows.IDC_APPSTARTING
+	case pointer.CursorNotAllowed:
+		curID = windows.IDC_NO
+	case pointer.CursorNorthEastResize, pointer.CursorSouthWestResize, pointer.CursorNorthEastSouthWestResize:
+		curID = windows.IDC_SIZENESW
+	case pointer.CursorNorthWestResize, pointer.CursorSouthEastResize, pointer.CursorNorthWestSouthEastResize:
+		curID = windows.IDC_SIZENWSE
 	case pointer.CursorNone:
 		return 0, nil
 	}
@@ -8,8 +8,8 @@ package wm
 /*
 #cgo openbsd CFLAGS: -I/usr/X11R6/include -I/usr/local/include
 #cgo openbsd LDFLAGS: -L/usr/X11R6/lib -L/usr/local/lib
-#cgo freebsd openbsd LDFLAGS: -lX11 -lxkbcommon -lxkbcommon-x11 -lX11-xcb -lXcursor -lXfixes
-#cgo linux pkg-config: x11 xkbcommon xkbcommon-x11 x11-xcb xcursor xfixes
+#cgo freebsd openbsd LDFLAGS: -lX11 -lxkbcommon -lxkbcommon-x11 -lX11-xcb -lXcursor -lXfixes -lXi
+#cgo linux pkg-config: x11 xkbcommon xkbcommon-x11 x11-xcb xcursor xfixes xi
 
 #include <stdlib.h>
 #include <locale.h>
@@ -20,6 +20,7 @@ package wm
 #include <X11/XKBlib.h>
 #include <X11/Xlib-xcb.h>
 #include <X11/extensions/Xfixes.h>
+#include <X11/extensions/XInput2.h>
 #include <X11/Xcursor/Xcursor.h>
 #include <xkbcommon/xkbcommon-x11.h>
 
@@ -29,6 +30,7 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -91,12 +93,39 @@ type x11Window struct {
 	animating bool
 
 	pointerBtns pointer.Buttons
+	lastPos     f32.Point
+	// xi is the state of the XInput2 extension, used for smooth
+	// scrolling and relative pointer motion.
+	xi struct {
+		opcode  C.int
+		scrolls []xiScroll
+		// smoothTime is the time of the most recent smooth scroll
+		// event. Core wheel button events with the same time are
+		// emulated from it.
+		smoothTime C.Time
+		// raw is set while relative motion is taken from raw
+		// motion events.
+		raw bool
+	}
+	// lock is the active pointer lock mode, and pos the pointer
+	// position when the lock was taken.
+	lock struct {
+		mode pointer.LockMode
+		pos  f32.Point
+	}
 
 	clipboard struct {
 		content []byte
 	}
 	cursor pointer.CursorName
-	mode   WindowMode
+	// xcursor is the most recently defined cursor, if any.
+	xcursor C.Cursor
+	mode    WindowMode
+	// cursorHidden tracks whether the cursor is hidden by XFixes.
+	cursorHidden bool
+	// blankCursor is an invisible cursor for grabbing the pointer
+	// in relative mode.
+	blankCursor C.Cursor
 
 	wakeups chan struct{}
 }
@@ -158,27 +187,157 @@ func (w *x11Window) Option(opts *Options) {
 }
 
 func (w *x11Window) SetCursor(name pointer.CursorName) {
-	switch name {
-	case pointer.CursorNone:
+	if name == pointer.CursorNone {
 		w.cursor = name
-		C.XFixesHideCursor(w.x, w.xw)
+		w.updateCursorHidden()
 		return
-	case pointer.CursorGrab:
-		name = "hand1"
 	}
-	if w.cursor == pointer.CursorNone {
-		C.XFixesShowCursor(w.x, w.xw)
+	c := w.loadCursor(string(name))
+	if fallback, ok := xCursorFallbacks[name]; c == 0 && ok {
+		c = w.loadCursor(fallback)
 	}
-	cname := C.CString(string(name))
-	defer C.free(unsafe.Pointer(cname))
-	c := C.XcursorLibraryLoadCursor(w.x, cname)
 	if c == 0 {
 		name = pointer.CursorDefault
 	}
 	w.cursor = name
+	w.updateCursorHidden()
 	// If c if null (i.e. name was not found),
 	// XDefineCursor will use the default cursor.
+	w.defineCursor(c)
+}
+
+func (w *x11Window) SetCursorImage(img *pointer.CursorImage) {
+	src := img.Image()
+	size := src.Bounds().Size()
+	ximg := C.XcursorImageCreate(C.int(size.X), C.int(size.Y))
+	if ximg == nil {
+		return
+	}
+	defer C.XcursorImageDestroy(ximg)
+	hot := img.Hotspot()
+	ximg.xhot = C.XcursorDim(hot.X)
+	ximg.yhot = C.XcursorDim(hot.Y)
+	n := size.X * size.Y
+	pixels := (*[1 << 28]C.XcursorPixel)(unsafe.Pointer(ximg.pixels))[:n:n]
+	for i, p := range cursorPixels(src) {
+		pixels[i] = C.XcursorPixel(p)
+	}
+	c := C.XcursorImageLoadCursor(w.x, ximg)
+	if c == 0 {
+		return
+	}
+	w.cursor = pointer.CursorDefault
+	w.updateCursorHidden()
+	w.defineCursor(c)
+}
+
+// updateCursorHidden hides the cursor for CursorNone and while the
+// pointer is locked in relative mode, and shows it otherwise.
+func (w *x11Window) updateCursorHidden() {
+	hide := w.cursor == pointer.CursorNone || w.lock.mode == pointer.LockRelative
+	if hide == w.cursorHidden {
+		return
+	}
+	w.cursorHidden = hide
+	if hide {
+		C.XFixesHideCursor(w.x, w.xw)
+	} else {
+		C.XFixesShowCursor(w.x, w.xw)
+	}
+}
+
+func (w *x11Window) SetPointerLock(mode pointer.LockMode) {
+	if mode == w.lock.mode {
+		return
+	}
+	if w.lock.mode != pointer.LockNone {
+		C.XUngrabPointer(w.x, C.CurrentTime)
+		if w.lock.mode == pointer.LockRelative {
+			w.selectRawMotion(false)
+			// Restore the pointer to where it was locked.
+			C.XWarpPointer(w.x, C.None, w.xw, 0, 0, 0, 0, C.int(w.lock.pos.X), C.int(w.lock.pos.Y))
+			w.lastPos = w.lock.pos
+		}
+	}
+	w.lock.mode = pointer.LockNone
+	w.updateCursorHidden()
+	if mode == pointer.LockNone {
+		return
+	}
+	const mask = C.ButtonPressMask | C.ButtonReleaseMask | C.PointerMotionMask
+	// Hide the cursor during relative grabs; XFixes may not hide
+	// the cursor of a grab.
+	cursor := C.Cursor(C.None)
+	if mode == pointer.LockRelative {
+		cursor = w.invisibleCursor()
+	}
+	if C.XGrabPointer(w.x, w.xw, C.True, mask, C.GrabModeAsync, C.GrabModeAsync, w.xw, cursor, C.CurrentTime) != C.GrabSuccess {
+		return
+	}
+	w.lock.mode = mode
+	w.lock.pos = w.lastPos
+	if mode == pointer.LockRelative {
+		w.updateCursorHidden()
+		if !w.selectRawMotion(true) {
+			w.warpToCenter()
+		}
+	}
+}
+
+// invisibleCursor returns a cursor with no visible pixels.
+func (w *x11Window) invisibleCursor() C.Cursor {
+	if w.blankCursor == 0 {
+		var data C.char
+		pix := C.XCreateBitmapFromData(w.x, w.xw, &data, 1, 1)
+		var black C.XColor
+		w.blankCursor = C.XCreatePixmapCursor(w.x, pix, pix, &black, &black, 0, 0)
+		C.XFreePixmap(w.x, pix)
+	}
+	return w.blankCursor
+}
+
+// selectRawMotion enables or disables XInput2 raw motion events,
+// which carry the unaccelerated motion of the pointer regardless of
+// its position. It reports whether raw motion is available.
+func (w *x11Window) selectRawMotion(enable bool) bool {
+	if w.xi.opcode == 0 {
+		return false
+	}
+	var mask [(C.XI_LASTEVENT + 7) / 8]C.uchar
+	if enable {
+		mask[C.XI_RawMotion>>3] |= 1 << (C.XI_RawMotion & 7)
+	}
+	em := C.XIEventMask{
+		deviceid: C.XIAllMasterDevices,
+		mask_len: C.int(len(mask)),
+		mask:     &mask[0],
+	}
+	// Raw events are only delivered to the root window.
+	C.XISelectEvents(w.x, C.XDefaultRootWindow(w.x), &em, 1)
+	w.xi.raw = enable
+	return true
+}
+
+// warpToCenter moves the pointer to the center of the window. The
+// pointer is kept there while in relative pointer mode.
+func (w *x11Window) warpToCenter() {
+	C.XWarpPointer(w.x, C.None, w.xw, 0, 0, 0, 0, C.int(w.width/2), C.int(w.height/2))
+}
+
+func (w *x11Window) loadCursor(name string) C.Cursor {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	return C.XcursorLibraryLoadCursor(w.x, cname)
+}
+
+// defineCursor sets the window cursor to c and frees the
+// previous cursor, if any.
+func (w *x11Window) defineCursor(c C.Cursor) {
 	C.XDefineCursor(w.x, w.xw, c)
+	if w.xcursor != 0 {
+		C.XFreeCursor(w.x, w.xcursor)
+	}
+	w.xcursor = c
 }
 
 func (w *x11Window) SetWindowMode(mode WindowMode) {
@@ -373,6 +532,238 @@ func (w *x11Window) atom(name string, onlyIfExists bool) C.Atom {
 	return C.XInternAtom(w.x, cname, flag)
 }
 
+// xiScroll is a scroll valuator of an XInput2 device.
+type xiScroll struct {
+	device    C.int
+	number    C.int
+	vertical  bool
+	increment float64
+	// value is the most recent valuator value, if valid.
+	value float64
+	valid bool
+}
+
+// initXInput enables smooth scrolling if XInput 2.1 is available.
+func (w *x11Window) initXInput() {
+	name := C.CString("XInputExtension")
+	defer C.free(unsafe.Pointer(name))
+	var opcode, event, errBase C.int
+	if C.XQueryExtension(w.x, name, &opcode, &event, &errBase) == C.False {
+		return
+	}
+	major, minor := C.int(2), C.int(1)
+	if C.XIQueryVersion(w.x, &major, &minor) != C.Success || major < 2 || major == 2 && minor < 1 {
+		return
+	}
+	var mask [(C.XI_LASTEVENT + 7) / 8]C.uchar
+	for _, e := range []C.int{C.XI_Motion, C.XI_Enter, C.XI_DeviceChanged} {
+		mask[e>>3] |= 1 << (e & 7)
+	}
+	em := C.XIEventMask{
+		deviceid: C.XIAllMasterDevices,
+		mask_len: C.int(len(mask)),
+		mask:     &mask[0],
+	}
+	C.XISelectEvents(w.x, w.xw, &em, 1)
+	w.xi.opcode = opcode
+	w.updateXIScrolls()
+}
+
+// updateXIScrolls queries the scroll valuators of all devices.
+func (w *x11Window) updateXIScrolls() {
+	w.xi.scrolls = w.xi.scrolls[:0]
+	var n C.int
+	infos := C.XIQueryDevice(w.x, C.XIAllDevices, &n)
+	if infos == nil {
+		return
+	}
+	defer C.XIFreeDeviceInfo(infos)
+	for _, info := range (*[1 << 16]C.XIDeviceInfo)(unsafe.Pointer(infos))[:n:n] {
+		classes := (*[1 << 16]*C.XIAnyClassInfo)(unsafe.Pointer(info.classes))[:info.num_classes:info.num_classes]
+		for _, c := range classes {
+			if c._type != C.XIScrollClass {
+				continue
+			}
+			sc := (*C.XIScrollClassInfo)(unsafe.Pointer(c))
+			if sc.increment == 0 {
+				continue
+			}
+			w.xi.scrolls = append(w.xi.scrolls, xiScroll{
+				device:    info.deviceid,
+				number:    sc.number,
+				vertical:  sc.scroll_type == C.XIScrollTypeVertical,
+				increment: float64(sc.increment),
+			})
+		}
+	}
+}
+
+// scrollValuator returns the scroll valuator number of device, or nil.
+func (w *x11Window) scrollValuator(device, number C.int) *xiScroll {
+	for i := range w.xi.scrolls {
+		if s := &w.xi.scrolls[i]; s.device == device && s.number == number {
+			return s
+		}
+	}
+	return nil
+}
+
+// handleXIEvent handles XInput2 events and ignores other generic
+// events.
+func (w *x11Window) handleXIEvent(xev *C.XEvent) {
+	cookie := (*C.XGenericEventCookie)(unsafe.Pointer(xev))
+	if w.xi.opcode == 0 || cookie.extension != w.xi.opcode {
+		return
+	}
+	if C.XGetEventData(w.x, cookie) == C.False {
+		return
+	}
+	defer C.XFreeEventData(w.x, cookie)
+	switch cookie.evtype {
+	case C.XI_Enter:
+		// Valuator values may have changed while the pointer was
+		// outside the window.
+		for i := range w.xi.scrolls {
+			w.xi.scrolls[i].valid = false
+		}
+	case C.XI_DeviceChanged:
+		w.updateXIScrolls()
+	case C.XI_RawMotion:
+		if !w.xi.raw || w.lock.mode != pointer.LockRelative {
+			break
+		}
+		ev := (*C.XIRawEvent)(cookie.data)
+		mask := (*[1 << 16]C.uchar)(unsafe.Pointer(ev.valuators.mask))[:ev.valuators.mask_len:ev.valuators.mask_len]
+		values := (*[1 << 16]C.double)(unsafe.Pointer(ev.raw_values))
+		var delta f32.Point
+		idx := 0
+		for i := 0; i < len(mask)*8; i++ {
+			if mask[i>>3]&(1<<(i&7)) == 0 {
+				continue
+			}
+			v := float32(values[idx])
+			idx++
+			// Valuators 0 and 1 are the horizontal and vertical
+			// motion.
+			switch i {
+			case 0:
+				delta.X = v
+			case 1:
+				delta.Y = v
+			}
+		}
+		if delta == (f32.Point{}) {
+			break
+		}
+		w.w.Event(pointer.Event{
+			Type:      pointer.Move,
+			Source:    pointer.Mouse,
+			Buttons:   w.pointerBtns,
+			Position:  w.lock.pos,
+			Delta:     delta,
+			Time:      time.Duration(ev.time) * time.Millisecond,
+			Modifiers: w.xkb.Modifiers(),
+		})
+	case C.XI_Motion:
+		ev := (*C.XIDeviceEvent)(cookie.data)
+		t := time.Duration(ev.time) * time.Millisecond
+		mask := (*[1 << 16]C.uchar)(unsafe.Pointer(ev.valuators.mask))[:ev.valuators.mask_len:ev.valuators.mask_len]
+		values := (*[1 << 16]C.double)(unsafe.Pointer(ev.valuators.values))
+		var steps f32.Point
+		moved := false
+		idx := 0
+		for i := 0; i < len(mask)*8; i++ {
+			if mask[i>>3]&(1<<(i&7)) == 0 {
+				continue
+			}
+			v := float64(values[idx])
+			idx++
+			s := w.scrollValuator(ev.sourceid, C.int(i))
+			if s == nil {
+				moved = true
+				continue
+			}
+			if s.valid {
+				d := float32((v - s.value) / s.increment)
+				if s.vertical {
+					steps.Y += d
+				} else {
+					steps.X += d
+				}
+			}
+			s.value, s.valid = v, true
+		}
+		if moved || idx == 0 {
+			w.pointerMotion(f32.Point{X: float32(ev.event_x), Y: float32(ev.event_y)}, t)
+		}
+		if steps != (f32.Point{}) {
+			w.xi.smoothTime = ev.time
+			w.smoothScroll(steps, t)
+		}
+	}
+}
+
+// smoothScroll sends a scroll event for an XInput2 valuator change of
+// steps scroll increments.
+func (w *x11Window) smoothScroll(steps f32.Point, t time.Duration) {
+	// Match the scroll distance of wheel buttons.
+	const scrollScale = 10
+	ev := pointer.Event{
+		Type:     pointer.Scroll,
+		Source:   pointer.Mouse,
+		Buttons:  w.pointerBtns,
+		Position: w.lastPos,
+		Scroll: f32.Point{
+			X: steps.X * scrollScale * 2,
+			Y: steps.Y * scrollScale,
+		},
+		// X11 doesn't distinguish touchpads from other
+		// continuous devices, nor report the end of scrolling.
+		ScrollSource: pointer.ScrollSourceContinuous,
+		Time:         t,
+		Modifiers:    w.xkb.Modifiers(),
+	}
+	sx, sy := math.Round(float64(steps.X)), math.Round(float64(steps.Y))
+	if float64(steps.X) == sx && float64(steps.Y) == sy {
+		// Whole increments are most likely from wheel notches.
+		ev.ScrollSource = pointer.ScrollSourceWheel
+		ev.ScrollSteps = image.Pt(int(sx), int(sy))
+	}
+	if w.lock.mode == pointer.LockRelative {
+		ev.Position = w.lock.pos
+	}
+	w.w.Event(ev)
+}
+
+// pointerMotion sends a move event for the pointer at pos.
+func (w *x11Window) pointerMotion(pos f32.Point, t time.Duration) {
+	ev := pointer.Event{
+		Type:      pointer.Move,
+		Source:    pointer.Mouse,
+		Buttons:   w.pointerBtns,
+		Position:  pos,
+		Time:      t,
+		Modifiers: w.xkb.Modifiers(),
+	}
+	if w.lock.mode == pointer.LockRelative {
+		if w.xi.raw {
+			// Relative motion is reported by raw motion events.
+			return
+		}
+		center := f32.Point{X: float32(w.width / 2), Y: float32(w.height / 2)}
+		if pos == center {
+			// Ignore the motion caused by warpToCenter.
+			return
+		}
+		ev.Position = w.lock.pos
+		ev.Delta = pos.Sub(center)
+		w.warpToCenter()
+	} else {
+		w.lastPos = pos
+	}
+	w.w.Event(ev)
+}
+
 // x11EventHandler wraps static variables for the main event loop.
 // Its sole purpose is to prevent heap allocation and reduce clutter
 // in x11window.loop.
@@ -395,6 +786,8 @@ func (h *x11EventHandler) handleEvents() bool {
 			continue
 		}
 		switch _type := (*C.XAnyEvent)(unsafe.Pointer(xev))._type; _type {
+		case C.GenericEvent:
+			w.handleXIEvent(xev)
 		case h.w.xkbEventBase:
 			xkbEvent := (*C.XkbAnyEvent)(unsafe.Pointer(xev))
 			switch xkbEvent.xkb_type {
@@ -444,22 +837,33 @@ func (h *x11EventHandler) handleEvents() bool {
 				// scroll up
 				ev.Type = pointer.Scroll
 				ev.Scroll.Y = -scrollScale
+				ev.ScrollSteps.Y = -1
 			case C.Button5:
 				// scroll down
 				ev.Type = pointer.Scroll
 				ev.Scroll.Y = +scrollScale
+				ev.ScrollSteps.Y = +1
 			case 6:
 				// http://xahlee.info/linux/linux_x11_mouse_button_number.html
 				// scroll left
 				ev.Type = pointer.Scroll
 				ev.Scroll.X = -scrollScale * 2
+				ev.ScrollSteps.X = -1
 			case 7:
 				// scroll right
 				ev.Type = pointer.Scroll
 				ev.Scroll.X = +scrollScale * 2
+				ev.ScrollSteps.X = +1
 			default:
 				continue
 			}
+			if ev.Type == pointer.Scroll {
+				if bevt.time == w.xi.smoothTime {
+					// Emulated from XInput2 smooth scrolling.
+					continue
+				}
+				ev.ScrollSource = pointer.ScrollSourceWheel
+			}
 			switch _type {
 			case C.ButtonPress:
 				w.pointerBtns |= btn
@@ -470,17 +874,11 @@ func (h *x11EventHandler) handleEvents() bool {
 			w.w.Event(ev)
 		case C.MotionNotify:
 			mevt := (*C.XMotionEvent)(unsafe.Pointer(xev))
-			w.w.Event(pointer.Event{
-				Type:    pointer.Move,
-				Source:  pointer.Mouse,
-				Buttons: w.pointerBtns,
-				Position: f32.Point{
-					X: float32(mevt.x),
-					Y: float32(mevt.y),
-				},
-				Time:      time.Duration(mevt.time) * time.Millisecond,
-				Modifiers: w.xkb.Modifiers(),
-			})
+			pos := f32.Point{
+				X: float32(mevt.x),
+				Y: float32(mevt.y),
+			}
+			w.pointerMotion(pos, time.Duration(mevt.time)*time.Millisecond)
 		case C.Expose: // update
 			// redraw only on the last expose event
 			redraw = (*C.XExposeEvent)(unsafe.Pointer(xev)).count == 0
@@ -682,6 +1080,7 @@ func newX11Window(gioWin Callbacks, opts *Options) error {
 
 	// extensions
 	C.XSetWMProtocols(dpy, win, &w.atoms.evDelWindow, 1)
+	w.initXInput()
 
 	w.Option(opts)
 
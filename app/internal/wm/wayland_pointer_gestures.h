@@ -0,0 +1,218 @@
+/* Generated by wayland-scanner 1.17.0 */
+
+#ifndef POINTER_GESTURES_UNSTABLE_V1_CLIENT_PROTOCOL_H
+#define POINTER_GESTURES_UNSTABLE_V1_CLIENT_PROTOCOL_H
+
+#include <stdint.h>
+#include <stddef.h>
+#include "wayland-client.h"
+
+#ifdef  __cplusplus
+extern "C" {
+#endif
+
+struct wl_pointer;
+struct wl_surface;
+struct zwp_pointer_gesture_pinch_v1;
+struct zwp_pointer_gesture_swipe_v1;
+struct zwp_pointer_gestures_v1;
+
+extern const struct wl_interface zwp_pointer_gestures_v1_interface;
+extern const struct wl_interface zwp_pointer_gesture_swipe_v1_interface;
+extern const struct wl_interface zwp_pointer_gesture_pinch_v1_interface;
+
+#define ZWP_POINTER_GESTURES_V1_GET_SWIPE_GESTURE 0
+#define ZWP_POINTER_GESTURES_V1_GET_PINCH_GESTURE 1
+#define ZWP_POINTER_GESTURES_V1_RELEASE 2
+
+
+#define ZWP_POINTER_GESTURES_V1_GET_SWIPE_GESTURE_SINCE_VERSION 1
+#define ZWP_POINTER_GESTURES_V1_GET_PINCH_GESTURE_SINCE_VERSION 1
+#define ZWP_POINTER_GESTURES_V1_RELEASE_SINCE_VERSION 2
+
+static inline void
+zwp_pointer_gestures_v1_set_user_data(struct zwp_pointer_gestures_v1 *zwp_pointer_gestures_v1, void *user_data)
+{
+	wl_proxy_set_user_data((struct wl_proxy *) zwp_pointer_gestures_v1, user_data);
+}
+
+static inline void *
+zwp_pointer_gestures_v1_get_user_data(struct zwp_pointer_gestures_v1 *zwp_pointer_gestures_v1)
+{
+	return wl_proxy_get_user_data((struct wl_proxy *) zwp_pointer_gestures_v1);
+}
+
+static inline uint32_t
+zwp_pointer_gestures_v1_get_version(struct zwp_pointer_gestures_v1 *zwp_pointer_gestures_v1)
+{
+	return wl_proxy_get_version((struct wl_proxy *) zwp_pointer_gestures_v1);
+}
+
+static inline struct zwp_pointer_gesture_swipe_v1 *
+zwp_pointer_gestures_v1_get_swipe_gesture(struct zwp_pointer_gestures_v1 *zwp_pointer_gestures_v1, struct wl_pointer *pointer)
+{
+	struct wl_proxy *id;
+
+	id = wl_proxy_marshal_constructor((struct wl_proxy *) zwp_pointer_gestures_v1,
+			 ZWP_POINTER_GESTURES_V1_GET_SWIPE_GESTURE, &zwp_pointer_gesture_swipe_v1_interface, NULL, pointer);
+
+	return (struct zwp_pointer_gesture_swipe_v1 *) id;
+}
+
+static inline struct zwp_pointer_gesture_pinch_v1 *
+zwp_pointer_gestures_v1_get_pinch_gesture(struct zwp_pointer_gestures_v1 *zwp_pointer_gestures_v1, struct wl_pointer *pointer)
+{
+	struct wl_proxy *id;
+
+	id = wl_proxy_marshal_constructor((struct wl_proxy *) zwp_pointer_gestures_v1,
+			 ZWP_POINTER_GESTURES_V1_GET_PINCH_GESTURE, &zwp_pointer_gesture_pinch_v1_interface, NULL, pointer);
+
+	return (struct zwp_pointer_gesture_pinch_v1 *) id;
+}
+
+static inline void
+zwp_pointer_gestures_v1_release(struct zwp_pointer_gestures_v1 *zwp_pointer_gestures_v1)
+{
+	wl_proxy_marshal((struct wl_proxy *) zwp_pointer_gestures_v1,
+			 ZWP_POINTER_GESTURES_V1_RELEASE);
+
+	wl_proxy_destroy((struct wl_proxy *) zwp_pointer_gestures_v1);
+}
+
+static inline void
+zwp_pointer_gestures_v1_destroy(struct zwp_pointer_gestures_v1 *zwp_pointer_gestures_v1)
+{
+	wl_proxy_destroy((struct wl_proxy *) zwp_pointer_gestures_v1);
+}
+
+struct zwp_pointer_gesture_swipe_v1_listener {
+	void (*begin)(void *data,
+		      struct zwp_pointer_gesture_swipe_v1 *zwp_pointer_gesture_swipe_v1,
+		      uint32_t serial,
+		      uint32_t time,
+		      struct wl_surface *surface,
+		      uint32_t fingers);
+	void (*update)(void *data,
+		       struct zwp_pointer_gesture_swipe_v1 *zwp_pointer_gesture_swipe_v1,
+		       uint32_t time,
+		       wl_fixed_t dx,
+		       wl_fixed_t dy);
+	void (*end)(void *data,
+		    struct zwp_pointer_gesture_swipe_v1 *zwp_pointer_gesture_swipe_v1,
+		    uint32_t serial,
+		    uint32_t time,
+		    int32_t cancelled);
+};
+
+static inline int
+zwp_pointer_gesture_swipe_v1_add_listener(struct zwp_pointer_gesture_swipe_v1 *zwp_pointer_gesture_swipe_v1,
+					   const struct zwp_pointer_gesture_swipe_v1_listener *listener, void *data)
+{
+	return wl_proxy_add_listener((struct wl_proxy *) zwp_pointer_gesture_swipe_v1,
+				     (void (**)(void)) listener, data);
+}
+
+#define ZWP_POINTER_GESTURE_SWIPE_V1_DESTROY 0
+
+#define ZWP_POINTER_GESTURE_SWIPE_V1_BEGIN_SINCE_VERSION 1
+#define ZWP_POINTER_GESTURE_SWIPE_V1_UPDATE_SINCE_VERSION 1
+#define ZWP_POINTER_GESTURE_SWIPE_V1_END_SINCE_VERSION 1
+
+#define ZWP_POINTER_GESTURE_SWIPE_V1_DESTROY_SINCE_VERSION 1
+
+static inline void
+zwp_pointer_gesture_swipe_v1_set_user_data(struct zwp_pointer_gesture_swipe_v1 *zwp_pointer_gesture_swipe_v1, void *user_data)
+{
+	wl_proxy_set_user_data((struct wl_proxy *) zwp_pointer_gesture_swipe_v1, user_data);
+}
+
+static inline void *
+zwp_pointer_gesture_swipe_v1_get_user_data(struct zwp_pointer_gesture_swipe_v1 *zwp_pointer_gesture_swipe_v1)
+{
+	return wl_proxy_get_user_data((struct wl_proxy *) zwp_pointer_gesture_swipe_v1);
+}
+
+static inline uint32_t
+zwp_pointer_gesture_swipe_v1_get_version(struct zwp_pointer_gesture_swipe_v1 *zwp_pointer_gesture_swipe_v1)
+{
+	return wl_proxy_get_version((struct wl_proxy *) zwp_pointer_gesture_swipe_v1);
+}
+
+static inline void
+zwp_pointer_gesture_swipe_v1_destroy(struct zwp_pointer_gesture_swipe_v1 *zwp_pointer_gesture_swipe_v1)
+{
+	wl_proxy_marshal((struct wl_proxy *) zwp_pointer_gesture_swipe_v1,
+			 ZWP_POINTER_GESTURE_SWIPE_V1_DESTROY);
+
+	wl_proxy_destroy((struct wl_proxy *) zwp_pointer_gesture_swipe_v1);
+}
+
+struct zwp_pointer_gesture_pinch_v1_listener {
+	void (*begin)(void *data,
+		      struct zwp_pointer_gesture_pinch_v1 *zwp_pointer_gesture_pinch_v1,
+		      uint32_t serial,
+		      uint32_t time,
+		      struct wl_surface *surface,
+		      uint32_t fingers);
+	void (*update)(void *data,
+		       struct zwp_pointer_gesture_pinch_v1 *zwp_pointer_gesture_pinch_v1,
+		       uint32_t time,
+		       wl_fixed_t dx,
+		       wl_fixed_t dy,
+		       wl_fixed_t scale,
+		       wl_fixed_t rotation);
+	void (*end)(void *data,
+		    struct zwp_pointer_gesture_pinch_v1 *zwp_pointer_gesture_pinch_v1,
+		    uint32_t serial,
+		    uint32_t time,
+		    int32_t cancelled);
+};
+
+static inline int
+zwp_pointer_gesture_pinch_v1_add_listener(struct zwp_pointer_gesture_pinch_v1 *zwp_pointer_gesture_pinch_v1,
+					   const struct zwp_pointer_gesture_pinch_v1_listener *listener, void *data)
+{
+	return wl_proxy_add_listener((struct wl_proxy *) zwp_pointer_gesture_pinch_v1,
+				     (void (**)(void)) listener, data);
+}
+
+#define ZWP_POINTER_GESTURE_PINCH_V1_DESTROY 0
+
+#define ZWP_POINTER_GESTURE_PINCH_V1_BEGIN_SINCE_VERSION 1
+#define ZWP_POINTER_GESTURE_PINCH_V1_UPDATE_SINCE_VERSION 1
+#define ZWP_POINTER_GESTURE_PINCH_V1_END_SINCE_VERSION 1
+
+#define ZWP_POINTER_GESTURE_PINCH_V1_DESTROY_SINCE_VERSION 1
+
+static inline void
+zwp_pointer_gesture_pinch_v1_set_user_data(struct zwp_pointer_gesture_pinch_v1 *zwp_pointer_gesture_pinch_v1, void *user_data)
+{
+	wl_proxy_set_user_data((struct wl_proxy *) zwp_pointer_gesture_pinch_v1, user_data);
+}
+
+static inline void *
+zwp_pointer_gesture_pinch_v1_get_user_data(struct zwp_pointer_gesture_pinch_v1 *zwp_pointer_gesture_pinch_v1)
+{
+	return wl_proxy_get_user_data((struct wl_proxy *) zwp_pointer_gesture_pinch_v1);
+}
+
+static inline uint32_t
+zwp_pointer_gesture_pinch_v1_get_version(struct zwp_pointer_gesture_pinch_v1 *zwp_pointer_gesture_pinch_v1)
+{
+	return wl_proxy_get_version((struct wl_proxy *) zwp_pointer_gesture_pinch_v1);
+}
+
+static inline void
+zwp_pointer_gesture_pinch_v1_destroy(struct zwp_pointer_gesture_pinch_v1 *zwp_pointer_gesture_pinch_v1)
+{
+	wl_proxy_marshal((struct wl_proxy *) zwp_pointer_gesture_pinch_v1,
+			 ZWP_POINTER_GESTURE_PINCH_V1_DESTROY);
+
+	wl_proxy_destroy((struct wl_proxy *) zwp_pointer_gesture_pinch_v1);
+}
+
+#ifdef  __cplusplus
+}
+#endif
+
+#endif
@@ -7,6 +7,7 @@ package wm
 import (
 	"errors"
 	"github.com/cybriq/giocore/io/key"
+	"image"
 	"image/color"
 
 	"github.com/cybriq/giocore/gpu"
@@ -71,6 +72,18 @@ type Context interface {
 	Unlock()
 }
 
+// DamageContext is implemented by contexts that can present
+// partially redrawn frames.
+type DamageContext interface {
+	Context
+	// BufferAge returns the number of frames since the contents of
+	// the back buffer were presented, or 0 if they are undefined.
+	BufferAge() int
+	// PresentDamage is like Present but only the damage area
+	// changed since the previous frame.
+	PresentDamage(damage image.Rectangle) error
+}
+
 // ErrDeviceLost is returned from Context.Present when
 // the underlying GPU device is gone and should be
 // recreated.
@@ -100,6 +113,11 @@ type Driver interface {
 
 	// SetCursor updates the current cursor to name.
 	SetCursor(name pointer.CursorName)
+	// SetCursorImage updates the current cursor to a custom bitmap.
+	SetCursorImage(img *pointer.CursorImage)
+	// SetPointerLock confines or locks the platform pointer. Drivers
+	// that don't support LockRelative fall back to LockConfine.
+	SetPointerLock(mode pointer.LockMode)
 
 	// Close the window.
 	Close()
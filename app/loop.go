@@ -100,9 +100,17 @@ func (l *renderLoop) renderLoop(ctx wm.Context) error {
 				g.Collect(frame.viewport, frame.ops)
 				// Signal that we're done with the frame ops.
 				l.ack <- struct{}{}
+				dctx, partial := ctx.(wm.DamageContext)
+				if partial {
+					g.SetBufferAge(dctx.BufferAge())
+				}
 				res.err = g.Frame(ctx.RenderTarget())
 				if res.err == nil {
-					res.err = ctx.Present()
+					if partial {
+						res.err = dctx.PresentDamage(g.Damage())
+					} else {
+						res.err = ctx.Present()
+					}
 				}
 				res.profile = g.Profile()
 				ctx.Unlock()
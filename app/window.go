@@ -52,8 +52,10 @@ type Window struct {
 	nextFrame    time.Time
 	delayedDraw  *time.Timer
 
-	queue  queue
-	cursor pointer.CursorName
+	queue       queue
+	cursor      pointer.CursorName
+	cursorImage *pointer.CursorImage
+	pointerLock pointer.LockMode
 
 	callbacks callbacks
 
@@ -274,6 +276,14 @@ func (w *Window) SetCursorName(name pointer.CursorName) {
 	})
 }
 
+// SetCursorImage changes the current window cursor to the custom
+// bitmap cursor img.
+func (w *Window) SetCursorImage(img *pointer.CursorImage) {
+	go w.driverRun(func(d wm.Driver) {
+		d.SetCursorImage(img)
+	})
+}
+
 // Close the wm. The window's event loop should exit when it receives
 // system.DestroyEvent.
 //
@@ -512,6 +522,7 @@ func (w *Window) run(opts *wm.Options) {
 					return
 				}
 				w.updateCursor()
+				w.updatePointerLock()
 			case *system.CommandEvent:
 				w.out <- e
 				w.waitAck()
@@ -540,12 +551,28 @@ func (w *Window) run(opts *wm.Options) {
 }
 
 func (w *Window) updateCursor() {
-	if c := w.queue.q.Cursor(); c != w.cursor {
-		w.cursor = c
+	c, img := w.queue.q.Cursor(), w.queue.q.CursorImage()
+	if c == w.cursor && img == w.cursorImage {
+		return
+	}
+	w.cursor = c
+	w.cursorImage = img
+	if img != nil {
+		w.SetCursorImage(img)
+	} else {
 		w.SetCursorName(c)
 	}
 }
 
+func (w *Window) updatePointerLock() {
+	if m := w.queue.q.PointerLock(); m != w.pointerLock {
+		w.pointerLock = m
+		go w.driverRun(func(d wm.Driver) {
+			d.SetPointerLock(m)
+		})
+	}
+}
+
 func (q *queue) Events(k event.Tag) []event.Event {
 	return q.q.Events(k)
 }
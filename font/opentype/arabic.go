@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"unicode"
+)
+
+// joiningType is the Unicode joining type of a rune.
+type joiningType uint8
+
+const (
+	joinNone joiningType = iota
+	joinRight
+	joinDual
+	joinCausing
+	joinTransparent
+)
+
+// rightJoining lists the right joining letters of the Arabic
+// blocks.
+var rightJoining = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x0622, Hi: 0x0625, Stride: 1},
+		{Lo: 0x0627, Hi: 0x0629, Stride: 2},
+		{Lo: 0x062f, Hi: 0x0632, Stride: 1},
+		{Lo: 0x0648, Hi: 0x0648, Stride: 1},
+		{Lo: 0x0671, Hi: 0x0673, Stride: 1},
+		{Lo: 0x0675, Hi: 0x0677, Stride: 1},
+		{Lo: 0x0688, Hi: 0x0699, Stride: 1},
+		{Lo: 0x06c0, Hi: 0x06c0, Stride: 1},
+		{Lo: 0x06c3, Hi: 0x06cb, Stride: 1},
+		{Lo: 0x06cd, Hi: 0x06cf, Stride: 2},
+		{Lo: 0x06d2, Hi: 0x06d3, Stride: 1},
+		{Lo: 0x06d5, Hi: 0x06d5, Stride: 1},
+		{Lo: 0x06ee, Hi: 0x06ef, Stride: 1},
+		{Lo: 0x0759, Hi: 0x075b, Stride: 1},
+		{Lo: 0x076b, Hi: 0x076c, Stride: 1},
+		{Lo: 0x0771, Hi: 0x0771, Stride: 1},
+		{Lo: 0x0773, Hi: 0x0774, Stride: 1},
+		{Lo: 0x0778, Hi: 0x0779, Stride: 1},
+	},
+}
+
+// dualJoining lists the dual joining letters of the Arabic
+// blocks.
+var dualJoining = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x0620, Hi: 0x0620, Stride: 1},
+		{Lo: 0x0626, Hi: 0x0628, Stride: 2},
+		{Lo: 0x062a, Hi: 0x062e, Stride: 1},
+		{Lo: 0x0633, Hi: 0x063f, Stride: 1},
+		{Lo: 0x0641, Hi: 0x0647, Stride: 1},
+		{Lo: 0x0649, Hi: 0x064a, Stride: 1},
+		{Lo: 0x066e, Hi: 0x066f, Stride: 1},
+		{Lo: 0x0678, Hi: 0x0687, Stride: 1},
+		{Lo: 0x069a, Hi: 0x06bf, Stride: 1},
+		{Lo: 0x06c1, Hi: 0x06c2, Stride: 1},
+		{Lo: 0x06cc, Hi: 0x06ce, Stride: 2},
+		{Lo: 0x06d0, Hi: 0x06d1, Stride: 1},
+		{Lo: 0x06fa, Hi: 0x06fc, Stride: 1},
+		{Lo: 0x06ff, Hi: 0x06ff, Stride: 1},
+		{Lo: 0x0750, Hi: 0x0758, Stride: 1},
+		{Lo: 0x075c, Hi: 0x076a, Stride: 1},
+		{Lo: 0x076d, Hi: 0x0770, Stride: 1},
+		{Lo: 0x0772, Hi: 0x0772, Stride: 1},
+		{Lo: 0x0775, Hi: 0x0777, Stride: 1},
+		{Lo: 0x077a, Hi: 0x077f, Stride: 1},
+	},
+}
+
+func joiningTypeOf(r rune) joiningType {
+	switch {
+	case r == 0x200d || r == 0x0640: // ZWJ and tatweel.
+		return joinCausing
+	case r == 0x200c: // ZWNJ.
+		return joinNone
+	case unicode.Is(dualJoining, r):
+		return joinDual
+	case unicode.Is(rightJoining, r):
+		return joinRight
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return joinTransparent
+	}
+	return joinNone
+}
+
+// prepareArabic selects the isol, init, medi and fina forms
+// of the glyphs of an Arabic run.
+func prepareArabic(s *shaper, runes []rune) {
+	isol, init, medi, fina := otTag("isol"), otTag("init"), otTag("medi"), otTag("fina")
+	forms := make([]uint32, len(runes))
+	types := make([]joiningType, len(runes))
+	prev := -1
+	for i, r := range runes {
+		t := joiningTypeOf(r)
+		types[i] = t
+		if t == joinTransparent {
+			continue
+		}
+		// Dual joining and join causing runes connect to the rune
+		// that follows them.
+		if prev >= 0 && (types[prev] == joinDual || types[prev] == joinCausing) &&
+			(t == joinDual || t == joinRight || t == joinCausing) {
+			switch forms[prev] {
+			case isol:
+				forms[prev] = init
+			case fina:
+				forms[prev] = medi
+			}
+			forms[i] = fina
+		} else {
+			forms[i] = isol
+		}
+		prev = i
+	}
+	for i, t := range types {
+		if t == joinDual || t == joinRight {
+			s.glyphs[i].mask |= s.plan.masks[forms[i]]
+		}
+	}
+}
@@ -0,0 +1,374 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"sync"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/op/clip"
+	"github.com/cybriq/giocore/op/paint"
+	"github.com/cybriq/giocore/text"
+)
+
+// colorLayer is a layer of a COLR color glyph.
+type colorLayer struct {
+	id    sfnt.GlyphIndex
+	color color.NRGBA
+	// foreground reports whether the layer is painted with the
+	// current color instead of a palette color.
+	foreground bool
+}
+
+// bitmap is an embedded bitmap glyph from a CBDT or sbix table.
+type bitmap struct {
+	// data contains the encoded PNG or JPEG image.
+	data lazyTable
+	// ppem is the size in pixels per em of the strike of the
+	// bitmap.
+	ppem int
+	// x and y are the position of the left and top edges of the
+	// bitmap relative to the pen position, in strike pixels with y
+	// pointing down. If bottom is set, y is the position of the
+	// bottom edge.
+	x, y   int
+	bottom bool
+}
+
+// bitmapCache caches decoded bitmap glyphs.
+type bitmapCache struct {
+	mu     sync.Mutex
+	images map[bitmapKey]bitmapImage
+}
+
+type bitmapKey struct {
+	id   sfnt.GlyphIndex
+	ppem int
+}
+
+type bitmapImage struct {
+	img paint.ImageOp
+	// origin is the position of the top left corner of the image
+	// relative to the pen position, in strike pixels.
+	origin image.Point
+	ok     bool
+}
+
+// colorLayers returns the COLR layers of a glyph, or nil if the glyph
+// is not a color glyph.
+func (t *tables) colorLayers(id sfnt.GlyphIndex) []colorLayer {
+	colr := t.colr
+	if colr == nil || colr.u16(0) > 1 {
+		return nil
+	}
+	n := int(colr.u16(2))
+	bases := colr.sub(int(colr.u32(4)))
+	layers := colr.sub(int(colr.u32(8)))
+	numLayers := int(colr.u16(12))
+	lo, hi := 0, n
+	for lo < hi {
+		m := (lo + hi) / 2
+		switch gid := sfnt.GlyphIndex(bases.u16(6 * m)); {
+		case gid < id:
+			lo = m + 1
+		case gid > id:
+			hi = m
+		default:
+			first, count := int(bases.u16(6*m+2)), int(bases.u16(6*m+4))
+			if count == 0 || first+count > numLayers {
+				return nil
+			}
+			res := make([]colorLayer, count)
+			for i := range res {
+				rec := 4 * (first + i)
+				l := colorLayer{id: sfnt.GlyphIndex(layers.u16(rec))}
+				if idx := layers.u16(rec + 2); idx == 0xffff {
+					l.foreground = true
+				} else {
+					l.color = t.paletteColor(int(idx))
+				}
+				res[i] = l
+			}
+			return res
+		}
+	}
+	return nil
+}
+
+// paletteColor returns a color from the first CPAL palette.
+func (t *tables) paletteColor(idx int) color.NRGBA {
+	cpal := t.cpal
+	if idx >= int(cpal.u16(2)) {
+		return color.NRGBA{A: 0xff}
+	}
+	records := cpal.sub(int(cpal.u32(8)))
+	rec := 4 * (int(cpal.u16(12)) + idx)
+	return color.NRGBA{
+		B: records.u8(rec),
+		G: records.u8(rec + 1),
+		R: records.u8(rec + 2),
+		A: records.u8(rec + 3),
+	}
+}
+
+// hasColorGlyph reports whether a glyph is drawn from color layers
+// or bitmaps at the size ppem.
+func (t *tables) hasColorGlyph(id sfnt.GlyphIndex, ppem fixed.Int26_6) bool {
+	if t.colorLayers(id) != nil {
+		return true
+	}
+	_, ok := t.bitmapGlyph(id, ppem)
+	return ok
+}
+
+// bitmapGlyph returns the bitmap of a glyph from the strike closest
+// to ppem.
+func (t *tables) bitmapGlyph(id sfnt.GlyphIndex, ppem fixed.Int26_6) (bitmap, bool) {
+	if t.sbix != nil {
+		if b, ok := t.sbixGlyph(id, ppem); ok {
+			return b, true
+		}
+	}
+	if t.cblc != nil {
+		return t.cbdtGlyph(id, ppem)
+	}
+	return bitmap{}, false
+}
+
+// pickStrike returns the index of the smallest strike size not
+// smaller than ppem, or the largest size.
+func pickStrike(sizes []int, ppem fixed.Int26_6) int {
+	px := ppem.Ceil()
+	best := -1
+	for i, s := range sizes {
+		switch {
+		case best == -1:
+			best = i
+		case sizes[best] < px && s > sizes[best]:
+			best = i
+		case s >= px && s < sizes[best]:
+			best = i
+		}
+	}
+	return best
+}
+
+func (t *tables) sbixGlyph(id sfnt.GlyphIndex, ppem fixed.Int26_6) (bitmap, bool) {
+	sbix := t.sbix
+	s := pickStrike(sbix.sizes, ppem)
+	if s == -1 {
+		return bitmap{}, false
+	}
+	strike := sbix.data.slice(sbix.offsets[s], sbix.data.length-sbix.offsets[s])
+	// Follow at most one 'dupe' reference.
+	for i := 0; i < 2; i++ {
+		offs, err := strike.read(4+4*int(id), 8)
+		if err != nil {
+			return bitmap{}, false
+		}
+		start, end := int(offs.u32(0)), int(offs.u32(4))
+		if end-start <= 8 {
+			return bitmap{}, false
+		}
+		n := end - start
+		if n > 10 {
+			n = 10
+		}
+		g, err := strike.read(start, n)
+		if err != nil {
+			return bitmap{}, false
+		}
+		switch string(g[4:8]) {
+		case "png ", "jpg ":
+			return bitmap{
+				data:   strike.slice(start+8, end-start-8),
+				ppem:   sbix.sizes[s],
+				x:      int(g.i16(0)),
+				y:      -int(g.i16(2)),
+				bottom: true,
+			}, true
+		case "dupe":
+			id = sfnt.GlyphIndex(g.u16(8))
+		default:
+			return bitmap{}, false
+		}
+	}
+	return bitmap{}, false
+}
+
+func (t *tables) cbdtGlyph(id sfnt.GlyphIndex, ppem fixed.Int26_6) (bitmap, bool) {
+	cblc, cbdt := t.cblc, t.cbdt
+	n := int(cblc.u32(4))
+	sizes := make([]int, n)
+	for i := range sizes {
+		sizes[i] = int(cblc.u8(8 + 48*i + 45))
+	}
+	s := pickStrike(sizes, ppem)
+	if s == -1 {
+		return bitmap{}, false
+	}
+	rec := 8 + 48*s
+	if id < sfnt.GlyphIndex(cblc.u16(rec+40)) || id > sfnt.GlyphIndex(cblc.u16(rec+42)) {
+		return bitmap{}, false
+	}
+	subtables := cblc.sub(int(cblc.u32(rec)))
+	for i := 0; i < int(cblc.u32(rec+8)); i++ {
+		first, last := sfnt.GlyphIndex(subtables.u16(8*i)), sfnt.GlyphIndex(subtables.u16(8*i+2))
+		if id < first || id > last {
+			continue
+		}
+		sub := subtables.sub(int(subtables.u32(8*i + 4)))
+		imageFormat, imageOff := sub.u16(2), int(sub.u32(4))
+		k := int(id - first)
+		var start, end int
+		// metrics are the big glyph metrics shared by the glyphs
+		// of the subtable, if any.
+		var metrics table
+		switch sub.u16(0) {
+		case 1:
+			start, end = int(sub.u32(8+4*k)), int(sub.u32(12+4*k))
+		case 2:
+			size := int(sub.u32(8))
+			metrics = sub.sub(12)
+			start, end = size*k, size*(k+1)
+		case 3:
+			start, end = int(sub.u16(8+2*k)), int(sub.u16(10+2*k))
+		case 4:
+			for m := 0; m < int(sub.u32(8)); m++ {
+				if sfnt.GlyphIndex(sub.u16(12+4*m)) == id {
+					start, end = int(sub.u16(14+4*m)), int(sub.u16(18+4*m))
+					break
+				}
+			}
+		case 5:
+			size := int(sub.u32(8))
+			metrics = sub.sub(12)
+			for m := 0; m < int(sub.u32(20)); m++ {
+				if sfnt.GlyphIndex(sub.u16(24+2*m)) == id {
+					start, end = size*m, size*(m+1)
+					break
+				}
+			}
+		}
+		start, end = imageOff+start, imageOff+end
+		if end <= start || start < 0 {
+			return bitmap{}, false
+		}
+		// hdr is the length of the glyph metrics and image length
+		// preceding the image data.
+		var hdr int
+		switch imageFormat {
+		case 17:
+			hdr = 9
+		case 18:
+			hdr = 12
+		case 19:
+			hdr = 4
+		default:
+			return bitmap{}, false
+		}
+		g, err := cbdt.read(start, hdr)
+		if err != nil {
+			return bitmap{}, false
+		}
+		if imageFormat != 19 {
+			metrics = g
+		}
+		b := bitmap{
+			data: cbdt.slice(start+hdr, end-start-hdr),
+			ppem: sizes[s],
+			x:    int(int8(metrics.u8(2))),
+			y:    -int(int8(metrics.u8(3))),
+		}
+		return b, b.data.length > 0
+	}
+	return bitmap{}, false
+}
+
+// bitmapImage returns the decoded image of a bitmap glyph.
+func (t *tables) bitmapImage(id sfnt.GlyphIndex, ppem fixed.Int26_6) (bitmapImage, int, bool) {
+	b, ok := t.bitmapGlyph(id, ppem)
+	if !ok {
+		return bitmapImage{}, 0, false
+	}
+	c := &t.bitmaps
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := bitmapKey{id: id, ppem: b.ppem}
+	if img, ok := c.images[key]; ok {
+		return img, b.ppem, img.ok
+	}
+	var img bitmapImage
+	if src, _, err := image.Decode(bytes.NewReader(b.data.bytes())); err == nil {
+		rgba, ok := src.(*image.RGBA)
+		if !ok {
+			rgba = image.NewRGBA(src.Bounds())
+			draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+		}
+		img.img = paint.NewImageOp(rgba)
+		img.origin = image.Pt(b.x, b.y)
+		if b.bottom {
+			img.origin.Y -= rgba.Bounds().Dy()
+		}
+		img.ok = true
+	}
+	if c.images == nil {
+		c.images = make(map[bitmapKey]bitmapImage)
+	}
+	c.images[key] = img
+	return img, b.ppem, img.ok
+}
+
+// colorPath returns the operations for painting the color glyphs of
+// a layout.
+func colorPath(buf *sfnt.Buffer, ppem fixed.Int26_6, fonts []*opentype, str text.Layout) op.CallOp {
+	ops := new(op.Ops)
+	m := op.Record(ops)
+	for _, g := range visibleGlyphs(buf, fonts, str) {
+		t := g.font.Tables
+		if layers := t.colorLayers(g.id); layers != nil {
+			for _, l := range layers {
+				segs, err := g.font.loadGlyph(buf, l.id, ppem)
+				if err != nil {
+					continue
+				}
+				stack := op.Save(ops)
+				var builder clip.Path
+				builder.Begin(ops)
+				addGlyph(&builder, segs, f32.Point{}, g.pos)
+				clip.Outline{Path: builder.End()}.Op().Add(ops)
+				if !l.foreground {
+					paint.ColorOp{Color: l.color}.Add(ops)
+				}
+				paint.PaintOp{}.Add(ops)
+				stack.Load()
+			}
+			continue
+		}
+		img, strike, ok := t.bitmapImage(g.id, ppem)
+		if !ok {
+			continue
+		}
+		scale := float32(ppem) / 64 / float32(strike)
+		origin := f32.Point{X: float32(img.origin.X), Y: float32(img.origin.Y)}
+		stack := op.Save(ops)
+		op.Affine(f32.Affine2D{}.
+			Scale(f32.Point{}, f32.Point{X: scale, Y: scale}).
+			Offset(g.pos.Add(origin.Mul(scale)))).Add(ops)
+		clip.Rect{Max: img.img.Size()}.Add(ops)
+		img.img.Add(ops)
+		paint.PaintOp{}.Add(ops)
+		stack.Load()
+	}
+	return m.Stop()
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// be encodes big endian values.
+func be(vals ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range vals {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	return buf.Bytes()
+}
+
+func TestColorLayers(t *testing.T) {
+	colr := be(
+		// Version, 2 base glyphs, base records at 14, layer records
+		// at 26, 3 layers.
+		uint16(0), uint16(2), uint32(14), uint32(26), uint16(3),
+		// Glyph 5 has layers 0 and 1, glyph 9 has layer 2.
+		uint16(5), uint16(0), uint16(2),
+		uint16(9), uint16(2), uint16(1),
+		// Layers.
+		uint16(20), uint16(1),
+		uint16(21), uint16(0xffff),
+		uint16(22), uint16(0),
+	)
+	cpal := be(
+		// Version, 2 entries, 1 palette, 2 records at offset 14.
+		uint16(0), uint16(2), uint16(1), uint16(2), uint32(14),
+		uint16(0),
+		// BGRA records.
+		uint8(1), uint8(2), uint8(3), uint8(4),
+		uint8(5), uint8(6), uint8(7), uint8(8),
+	)
+	tab := &tables{colr: colr, cpal: cpal}
+	layers := tab.colorLayers(5)
+	want := []colorLayer{
+		{id: 20, color: color.NRGBA{R: 7, G: 6, B: 5, A: 8}},
+		{id: 21, foreground: true},
+	}
+	if len(layers) != len(want) {
+		t.Fatalf("got %d layers, expected %d", len(layers), len(want))
+	}
+	for i := range want {
+		if layers[i] != want[i] {
+			t.Errorf("layer %d: got %+v, expected %+v", i, layers[i], want[i])
+		}
+	}
+	if l := tab.colorLayers(9); len(l) != 1 || l[0].id != 22 {
+		t.Errorf("got layers %+v for glyph 9", l)
+	}
+	if l := tab.colorLayers(6); l != nil {
+		t.Errorf("got layers %+v for glyph without color", l)
+	}
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCBDTGlyph(t *testing.T) {
+	data := encodePNG(t, 4, 6)
+	// Format 17 glyph: small metrics, length and PNG data.
+	glyph := append(be(uint8(6), uint8(4), int8(1), int8(5), uint8(4), uint32(len(data))), data...)
+	cbdt := append(be(uint16(3), uint16(0)), glyph...)
+	size := make([]byte, 48)
+	// Index subtable array at 56, 1 subtable.
+	copy(size, be(uint32(56), uint32(0), uint32(1)))
+	copy(size[40:], be(uint16(7), uint16(7), uint8(32), uint8(32), uint8(32), uint8(1)))
+	cblc := be(uint16(3), uint16(0), uint32(1))
+	cblc = append(cblc, size...)
+	// Glyph 7 in the subtable at offset 8 from the array.
+	cblc = append(cblc, be(uint16(7), uint16(7), uint32(8))...)
+	// Index format 1, image format 17, image data at 4.
+	cblc = append(cblc, be(uint16(1), uint16(17), uint32(4), uint32(0), uint32(len(glyph)))...)
+	tab := &tables{cblc: cblc, cbdt: bytesTable(cbdt)}
+	b, ok := tab.bitmapGlyph(7, fixed.I(16))
+	if !ok {
+		t.Fatal("bitmap glyph not found")
+	}
+	if b.ppem != 32 || b.x != 1 || b.y != -5 || !bytes.Equal(b.data.bytes(), data) {
+		t.Errorf("got bitmap ppem %d origin (%d,%d), expected 32 (1,-5)", b.ppem, b.x, b.y)
+	}
+	img, strike, ok := tab.bitmapImage(7, fixed.I(16))
+	if !ok || strike != 32 {
+		t.Fatal("bitmap glyph not decoded")
+	}
+	if got := img.img.Size(); got != image.Pt(4, 6) {
+		t.Errorf("got image size %v, expected (4,6)", got)
+	}
+	if _, ok := tab.bitmapGlyph(8, fixed.I(16)); ok {
+		t.Error("found bitmap for glyph without bitmap")
+	}
+}
+
+func TestSbixGlyph(t *testing.T) {
+	data := encodePNG(t, 4, 6)
+	glyph := append(be(int16(1), int16(-2)), []byte("png ")...)
+	glyph = append(glyph, data...)
+	dupe := append(be(int16(0), int16(0)), []byte("dupe")...)
+	dupe = append(dupe, be(uint16(0))...)
+	// Strike with 2 glyphs: glyph 0 has the image, glyph 1 is a
+	// duplicate of it.
+	strike := be(uint16(20), uint16(72), uint32(16), uint32(16+len(glyph)), uint32(16+len(glyph)+len(dupe)))
+	strike = append(strike, glyph...)
+	strike = append(strike, dupe...)
+	sbix := append(be(uint16(1), uint16(0), uint32(1), uint32(12)), strike...)
+	s, err := readSbix(bytesTable(sbix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tab := &tables{sbix: s}
+	for id := sfnt.GlyphIndex(0); id < 2; id++ {
+		img, strike, ok := tab.bitmapImage(id, fixed.I(40))
+		if !ok || strike != 20 {
+			t.Fatalf("glyph %d: bitmap not found", id)
+		}
+		// The origin offset is the bottom left corner.
+		if got, want := img.origin, image.Pt(1, 2-6); got != want {
+			t.Errorf("glyph %d: got origin %v, expected %v", id, got, want)
+		}
+	}
+}
+
+func TestPickStrike(t *testing.T) {
+	sizes := []int{20, 64, 40, 160}
+	tests := []struct {
+		ppem int
+		want int
+	}{
+		{10, 0},
+		{30, 2},
+		{40, 2},
+		{50, 1},
+		{200, 3},
+	}
+	for _, test := range tests {
+		if got := pickStrike(sizes, fixed.I(test.ppem)); got != test.want {
+			t.Errorf("ppem %d: got strike %d, expected %d", test.ppem, got, test.want)
+		}
+	}
+}
+
+func TestShapeColorWithoutColorGlyphs(t *testing.T) {
+	face, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppem := fixed.I(20)
+	lines, err := face.Layout(ppem, 1e6, strings.NewReader("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A font without color tables has no color glyphs.
+	face.ShapeColor(ppem, lines[0].Layout)
+	for _, g := range lines[0].Layout.Glyphs {
+		if face.tables.hasColorGlyph(sfnt.GlyphIndex(g.ID&0xffff), ppem) {
+			t.Errorf("glyph %d has color", g.ID)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"golang.org/x/image/font/sfnt"
+)
+
+// matchFunc reports whether the glyph id matches the value v of a
+// contextual rule.
+type matchFunc func(id sfnt.GlyphIndex, v uint16) bool
+
+func matchGlyph(id sfnt.GlyphIndex, v uint16) bool {
+	return id == sfnt.GlyphIndex(v)
+}
+
+// matchClass matches glyphs by their class in classes.
+func matchClass(classes table) matchFunc {
+	return func(id sfnt.GlyphIndex, v uint16) bool {
+		return classes.class(id) == v
+	}
+}
+
+// matchCoverage matches glyphs by coverage tables at offsets
+// from st.
+func matchCoverage(st table) matchFunc {
+	return func(id sfnt.GlyphIndex, v uint16) bool {
+		return st.sub(int(v)).coverage(id) >= 0
+	}
+}
+
+// matchInput matches the n glyphs following the glyph at index
+// i against the values in vals. It returns the indices of the
+// matched glyphs, starting with i.
+func (s *shaper) matchInput(l lookup, i, n int, vals table, match matchFunc) ([]int, bool) {
+	positions := []int{i}
+	for k := 0; k < n; k++ {
+		i = s.next(l, i)
+		if i == -1 || !match(s.glyphs[i].id, vals.u16(2*k)) {
+			return nil, false
+		}
+		positions = append(positions, i)
+	}
+	return positions, true
+}
+
+// matchBacktrack matches the n glyphs preceding the glyph at
+// index i, in reverse order, against vals.
+func (s *shaper) matchBacktrack(l lookup, i, n int, vals table, match matchFunc) bool {
+	for k := 0; k < n; k++ {
+		i = s.prev(l, i)
+		if i == -1 || !match(s.glyphs[i].id, vals.u16(2*k)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLookahead matches the n glyphs following the glyph at
+// index i against vals.
+func (s *shaper) matchLookahead(l lookup, i, n int, vals table, match matchFunc) bool {
+	for k := 0; k < n; k++ {
+		i = s.next(l, i)
+		if i == -1 || !match(s.glyphs[i].id, vals.u16(2*k)) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyContext applies a contextual substitution or positioning
+// subtable.
+func (s *shaper) applyContext(t *layoutTable, l lookup, st table, i int) (int, bool) {
+	id := s.glyphs[i].id
+	var rules table
+	var match matchFunc
+	switch st.u16(0) {
+	case 1:
+		idx := st.offset16(2).coverage(id)
+		if idx == -1 || idx >= int(st.u16(4)) {
+			return 0, false
+		}
+		rules = st.offset16(6 + 2*idx)
+		match = matchGlyph
+	case 2:
+		if st.offset16(2).coverage(id) == -1 {
+			return 0, false
+		}
+		classes := st.offset16(4)
+		c := int(classes.class(id))
+		if c >= int(st.u16(6)) {
+			return 0, false
+		}
+		rules = st.offset16(8 + 2*c)
+		match = matchClass(classes)
+	case 3:
+		n := int(st.u16(2))
+		if n == 0 || st.offset16(6).coverage(id) == -1 {
+			return 0, false
+		}
+		positions, ok := s.matchInput(l, i, n-1, st.sub(8), matchCoverage(st))
+		if !ok {
+			return 0, false
+		}
+		return s.applyNested(t, positions, st.sub(6+2*n), int(st.u16(4))), true
+	default:
+		return 0, false
+	}
+	n := int(rules.u16(0))
+	for k := 0; k < n; k++ {
+		rule := rules.offset16(2 + 2*k)
+		count := int(rule.u16(0))
+		if count == 0 {
+			continue
+		}
+		positions, ok := s.matchInput(l, i, count-1, rule.sub(4), match)
+		if !ok {
+			continue
+		}
+		return s.applyNested(t, positions, rule.sub(4+2*(count-1)), int(rule.u16(2))), true
+	}
+	return 0, false
+}
+
+// applyChainContext applies a chained contextual substitution or
+// positioning subtable.
+func (s *shaper) applyChainContext(t *layoutTable, l lookup, st table, i int) (int, bool) {
+	id := s.glyphs[i].id
+	var rules table
+	var back, input, ahead matchFunc
+	switch st.u16(0) {
+	case 1:
+		idx := st.offset16(2).coverage(id)
+		if idx == -1 || idx >= int(st.u16(4)) {
+			return 0, false
+		}
+		rules = st.offset16(6 + 2*idx)
+		back, input, ahead = matchGlyph, matchGlyph, matchGlyph
+	case 2:
+		if st.offset16(2).coverage(id) == -1 {
+			return 0, false
+		}
+		inputClasses := st.offset16(6)
+		c := int(inputClasses.class(id))
+		if c >= int(st.u16(10)) {
+			return 0, false
+		}
+		rules = st.offset16(12 + 2*c)
+		back = matchClass(st.offset16(4))
+		input = matchClass(inputClasses)
+		ahead = matchClass(st.offset16(8))
+	case 3:
+		match := matchCoverage(st)
+		return s.applyChainRule(t, l, st.sub(2), i, true, match, match, match)
+	default:
+		return 0, false
+	}
+	n := int(rules.u16(0))
+	for k := 0; k < n; k++ {
+		rule := rules.offset16(2 + 2*k)
+		if next, ok := s.applyChainRule(t, l, rule, i, false, back, input, ahead); ok {
+			return next, true
+		}
+	}
+	return 0, false
+}
+
+// applyChainRule applies a chained rule. If coverage is set, the
+// rule is the body of a format 3 subtable whose input includes the
+// glyph at index i.
+func (s *shaper) applyChainRule(t *layoutTable, l lookup, rule table, i int, coverage bool, back, input, ahead matchFunc) (int, bool) {
+	nback := int(rule.u16(0))
+	if !s.matchBacktrack(l, i, nback, rule.sub(2), back) {
+		return 0, false
+	}
+	off := 2 + 2*nback
+	ninput := int(rule.u16(off))
+	if ninput == 0 {
+		return 0, false
+	}
+	inputs := rule.sub(off + 2)
+	if coverage {
+		if !input(s.glyphs[i].id, inputs.u16(0)) {
+			return 0, false
+		}
+		inputs = rule.sub(off + 4)
+	}
+	positions, ok := s.matchInput(l, i, ninput-1, inputs, input)
+	if !ok {
+		return 0, false
+	}
+	off += 2 + 2*(ninput-1)
+	if coverage {
+		off += 2
+	}
+	nahead := int(rule.u16(off))
+	if !s.matchLookahead(l, positions[len(positions)-1], nahead, rule.sub(off+2), ahead) {
+		return 0, false
+	}
+	off += 2 + 2*nahead
+	return s.applyNested(t, positions, rule.sub(off+2), int(rule.u16(off))), true
+}
+
+// applyNested applies the n lookup records in records to the matched
+// glyphs at positions. It returns the index following the matched
+// glyphs.
+func (s *shaper) applyNested(t *layoutTable, positions []int, records table, n int) int {
+	if s.nesting < maxNesting {
+		s.nesting++
+		for k := 0; k < n; k++ {
+			seq := int(records.u16(4 * k))
+			if seq >= len(positions) {
+				continue
+			}
+			p := positions[seq]
+			if p >= len(s.glyphs) {
+				continue
+			}
+			before := len(s.glyphs)
+			s.apply(t, t.lookup(records.u16(4*k+2)), p)
+			if d := len(s.glyphs) - before; d != 0 {
+				for m := seq + 1; m < len(positions); m++ {
+					positions[m] += d
+				}
+			}
+		}
+		s.nesting--
+	}
+	return positions[len(positions)-1] + 1
+}
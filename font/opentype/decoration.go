@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"math"
+	"sort"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/text"
+)
+
+// flattenSteps is the number of lines that approximate a curve when
+// intersecting glyph outlines.
+const flattenSteps = 8
+
+// Decorations implements text.DecorationFace.
+func (f *Font) Decorations(ppem fixed.Int26_6) text.DecorationMetrics {
+	return f.opentype().decorations(ppem)
+}
+
+// InkExtents implements text.DecorationFace.
+func (f *Font) InkExtents(ppem fixed.Int26_6, str text.Layout, top, bottom fixed.Int26_6) []text.Extent {
+	var buf sfnt.Buffer
+	return inkExtents(&buf, ppem, []*opentype{f.opentype()}, str, top, bottom)
+}
+
+// Decorations implements text.DecorationFace. The metrics are the
+// metrics of the first font of the collection.
+func (c *Collection) Decorations(ppem fixed.Int26_6) text.DecorationMetrics {
+	if len(c.fonts) == 0 {
+		return text.DecorationMetrics{}
+	}
+	return c.fonts[0].decorations(ppem)
+}
+
+// InkExtents implements text.DecorationFace.
+func (c *Collection) InkExtents(ppem fixed.Int26_6, str text.Layout, top, bottom fixed.Int26_6) []text.Extent {
+	var buf sfnt.Buffer
+	return inkExtents(&buf, ppem, c.fonts, str, top, bottom)
+}
+
+func (f *opentype) decorations(ppem fixed.Int26_6) text.DecorationMetrics {
+	upem := int64(f.Font.UnitsPerEm())
+	scale := func(v int16) fixed.Int26_6 {
+		return fixed.Int26_6(int64(v) * int64(ppem) / upem)
+	}
+	t := f.Tables
+	return text.DecorationMetrics{
+		UnderlinePosition:  -scale(t.underline[0]),
+		UnderlineThickness: scale(t.underline[1]),
+		StrikeoutPosition:  -scale(t.strikeout[0]),
+		StrikeoutThickness: scale(t.strikeout[1]),
+	}
+}
+
+// inkExtents returns the sorted horizontal extents of the glyph
+// outlines of a layout between top and bottom.
+func inkExtents(buf *sfnt.Buffer, ppem fixed.Int26_6, fonts []*opentype, str text.Layout, top, bottom fixed.Int26_6) []text.Extent {
+	y0, y1 := float32(top)/64, float32(bottom)/64
+	var extents []text.Extent
+	for _, g := range visibleGlyphs(buf, fonts, str) {
+		segs, err := g.font.loadGlyph(buf, g.id, ppem)
+		if err != nil {
+			continue
+		}
+		min, max, ok := outlineExtent(segs, g.pos.Y, y0, y1)
+		if !ok {
+			continue
+		}
+		extents = append(extents, text.Extent{
+			Start: fixed.Int26_6(math.Floor(float64((g.pos.X + min) * 64))),
+			End:   fixed.Int26_6(math.Ceil(float64((g.pos.X + max) * 64))),
+		})
+	}
+	sort.Slice(extents, func(i, j int) bool {
+		return extents[i].Start < extents[j].Start
+	})
+	// Merge overlapping extents.
+	var merged []text.Extent
+	for _, e := range extents {
+		if n := len(merged); n > 0 && e.Start <= merged[n-1].End {
+			if e.End > merged[n-1].End {
+				merged[n-1].End = e.End
+			}
+			continue
+		}
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// outlineExtent returns the horizontal extent of the parts of a glyph
+// outline offset vertically by dy between y0 and y1. It reports false
+// if the outline doesn't cross the band.
+func outlineExtent(segs []sfnt.Segment, dy, y0, y1 float32) (min, max float32, ok bool) {
+	min, max = float32(math.Inf(1)), float32(math.Inf(-1))
+	pt := func(p fixed.Point26_6) f32.Point {
+		return f32.Point{X: float32(p.X) / 64, Y: float32(p.Y)/64 + dy}
+	}
+	line := func(a, b f32.Point) {
+		t0, t1 := float32(0), float32(1)
+		if a.Y == b.Y {
+			if a.Y < y0 || a.Y > y1 {
+				return
+			}
+		} else {
+			t0, t1 = (y0-a.Y)/(b.Y-a.Y), (y1-a.Y)/(b.Y-a.Y)
+			if t0 > t1 {
+				t0, t1 = t1, t0
+			}
+			if t0 < 0 {
+				t0 = 0
+			}
+			if t1 > 1 {
+				t1 = 1
+			}
+			if t0 > t1 {
+				return
+			}
+		}
+		for _, t := range [2]float32{t0, t1} {
+			x := a.X + (b.X-a.X)*t
+			if x < min {
+				min = x
+			}
+			if x > max {
+				max = x
+			}
+			ok = true
+		}
+	}
+	var start, pen f32.Point
+	for i, seg := range segs {
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			if i > 0 {
+				// Close the previous contour.
+				line(pen, start)
+			}
+			start, pen = pt(seg.Args[0]), pt(seg.Args[0])
+		case sfnt.SegmentOpLineTo:
+			p := pt(seg.Args[0])
+			line(pen, p)
+			pen = p
+		case sfnt.SegmentOpQuadTo:
+			c, p := pt(seg.Args[0]), pt(seg.Args[1])
+			from := pen
+			for i := 1; i <= flattenSteps; i++ {
+				t := float32(i) / flattenSteps
+				u := 1 - t
+				q := from.Mul(u * u).Add(c.Mul(2 * u * t)).Add(p.Mul(t * t))
+				line(pen, q)
+				pen = q
+			}
+		case sfnt.SegmentOpCubeTo:
+			c0, c1, p := pt(seg.Args[0]), pt(seg.Args[1]), pt(seg.Args[2])
+			from := pen
+			for i := 1; i <= flattenSteps; i++ {
+				t := float32(i) / flattenSteps
+				u := 1 - t
+				q := from.Mul(u * u * u).Add(c0.Mul(3 * u * u * t)).Add(c1.Mul(3 * u * t * t)).Add(p.Mul(t * t * t))
+				line(pen, q)
+				pen = q
+			}
+		}
+	}
+	if len(segs) > 0 {
+		line(pen, start)
+	}
+	return min, max, ok
+}
@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"math/bits"
+)
+
+// Value record format flags.
+const (
+	valueXPlacement = 0x0001
+	valueYPlacement = 0x0002
+	valueXAdvance   = 0x0004
+	valueYAdvance   = 0x0008
+)
+
+// applyPos applies a GPOS subtable to the glyph at index i.
+func (s *shaper) applyPos(t *layoutTable, l lookup, st table, i int) (int, bool) {
+	id := s.glyphs[i].id
+	switch l.typ {
+	case 1: // Single adjustment.
+		idx := st.offset16(2).coverage(id)
+		if idx == -1 {
+			return 0, false
+		}
+		format := st.u16(4)
+		switch st.u16(0) {
+		case 1:
+			s.adjust(i, st.sub(6), format)
+		case 2:
+			if idx >= int(st.u16(6)) {
+				return 0, false
+			}
+			s.adjust(i, st.sub(8+idx*valueSize(format)), format)
+		default:
+			return 0, false
+		}
+		return i + 1, true
+	case 2: // Pair adjustment.
+		return s.applyPair(l, st, i)
+	case 4: // Mark to base attachment.
+		return s.applyMarkBase(st, i)
+	case 5: // Mark to ligature attachment.
+		return s.applyMarkLigature(st, i)
+	case 6: // Mark to mark attachment.
+		return s.applyMarkMark(l, st, i)
+	case 7:
+		return s.applyContext(t, l, st, i)
+	case 8:
+		return s.applyChainContext(t, l, st, i)
+	}
+	// Cursive attachment is not supported.
+	return 0, false
+}
+
+func (s *shaper) applyPair(l lookup, st table, i int) (int, bool) {
+	idx := st.offset16(2).coverage(s.glyphs[i].id)
+	if idx == -1 {
+		return 0, false
+	}
+	j := s.next(l, i)
+	if j == -1 {
+		return 0, false
+	}
+	second := s.glyphs[j].id
+	format1, format2 := st.u16(4), st.u16(6)
+	size1, size2 := valueSize(format1), valueSize(format2)
+	var values table
+	switch st.u16(0) {
+	case 1:
+		if idx >= int(st.u16(8)) {
+			return 0, false
+		}
+		set := st.offset16(10 + 2*idx)
+		size := 2 + size1 + size2
+		lo, hi := 0, int(set.u16(0))
+		for lo < hi {
+			m := (lo + hi) / 2
+			g := set.u16(2 + m*size)
+			switch {
+			case uint16(second) < g:
+				hi = m
+			case uint16(second) > g:
+				lo = m + 1
+			default:
+				values = set.sub(2 + m*size + 2)
+				lo, hi = m, m
+			}
+		}
+		if values == nil {
+			return 0, false
+		}
+	case 2:
+		c1 := int(st.offset16(8).class(s.glyphs[i].id))
+		c2 := int(st.offset16(10).class(second))
+		n1, n2 := int(st.u16(12)), int(st.u16(14))
+		if c1 >= n1 || c2 >= n2 {
+			return 0, false
+		}
+		values = st.sub(16 + (c1*n2+c2)*(size1+size2))
+	default:
+		return 0, false
+	}
+	s.adjust(i, values, format1)
+	s.adjust(j, values.sub(size1), format2)
+	if format2 != 0 {
+		return j + 1, true
+	}
+	return j, true
+}
+
+func (s *shaper) applyMarkBase(st table, i int) (int, bool) {
+	markIdx := st.offset16(2).coverage(s.glyphs[i].id)
+	if markIdx == -1 {
+		return 0, false
+	}
+	j := s.prevBase(i)
+	if j == -1 {
+		return 0, false
+	}
+	baseIdx := st.offset16(4).coverage(s.glyphs[j].id)
+	if baseIdx == -1 {
+		return 0, false
+	}
+	classes := int(st.u16(6))
+	marks := st.offset16(8)
+	class := int(marks.u16(2 + 4*markIdx))
+	if class >= classes {
+		return 0, false
+	}
+	base := st.offset16(10).offset16(2 + 2*(baseIdx*classes+class))
+	if base == nil {
+		return 0, false
+	}
+	s.attach(i, j, marks.offset16(2+4*markIdx+2), base)
+	return i + 1, true
+}
+
+func (s *shaper) applyMarkLigature(st table, i int) (int, bool) {
+	markIdx := st.offset16(2).coverage(s.glyphs[i].id)
+	if markIdx == -1 {
+		return 0, false
+	}
+	j := s.prevBase(i)
+	if j == -1 {
+		return 0, false
+	}
+	ligIdx := st.offset16(4).coverage(s.glyphs[j].id)
+	if ligIdx == -1 {
+		return 0, false
+	}
+	classes := int(st.u16(6))
+	marks := st.offset16(8)
+	class := int(marks.u16(2 + 4*markIdx))
+	if class >= classes {
+		return 0, false
+	}
+	lig := st.offset16(10).offset16(2 + 2*ligIdx)
+	comps := int(lig.u16(0))
+	if comps == 0 {
+		return 0, false
+	}
+	comp := s.glyphs[i].ligComp
+	if comp < 1 || comp > comps {
+		comp = comps
+	}
+	base := lig.offset16(2 + 2*((comp-1)*classes+class))
+	if base == nil {
+		return 0, false
+	}
+	s.attach(i, j, marks.offset16(2+4*markIdx+2), base)
+	return i + 1, true
+}
+
+func (s *shaper) applyMarkMark(l lookup, st table, i int) (int, bool) {
+	markIdx := st.offset16(2).coverage(s.glyphs[i].id)
+	if markIdx == -1 {
+		return 0, false
+	}
+	j := s.prev(l, i)
+	if j == -1 || s.glyphs[j].class != classMark {
+		return 0, false
+	}
+	mark2Idx := st.offset16(4).coverage(s.glyphs[j].id)
+	if mark2Idx == -1 {
+		return 0, false
+	}
+	classes := int(st.u16(6))
+	marks := st.offset16(8)
+	class := int(marks.u16(2 + 4*markIdx))
+	if class >= classes {
+		return 0, false
+	}
+	base := st.offset16(10).offset16(2 + 2*(mark2Idx*classes+class))
+	if base == nil {
+		return 0, false
+	}
+	s.attach(i, j, marks.offset16(2+4*markIdx+2), base)
+	return i + 1, true
+}
+
+// prevBase returns the index of the glyph before i that is not a
+// mark, or -1.
+func (s *shaper) prevBase(i int) int {
+	for i--; i >= 0; i-- {
+		if s.glyphs[i].class != classMark {
+			return i
+		}
+	}
+	return -1
+}
+
+// attach the mark at index i to the glyph at index j by aligning
+// their anchors.
+func (s *shaper) attach(i, j int, markAnchor, baseAnchor table) {
+	mx, my := markAnchor.anchor()
+	bx, by := baseAnchor.anchor()
+	p := &s.pos[i]
+	p.off.X = s.scale(int(bx) - int(mx))
+	p.off.Y = s.scale(int(by) - int(my))
+	p.attach = j - i
+}
+
+// adjust applies the value record v with the given format to the
+// glyph at index i.
+func (s *shaper) adjust(i int, v table, format uint16) {
+	p := &s.pos[i]
+	off := 0
+	next := func() int {
+		x := int(v.i16(off))
+		off += 2
+		return x
+	}
+	if format&valueXPlacement != 0 {
+		p.off.X += s.scale(next())
+	}
+	if format&valueYPlacement != 0 {
+		p.off.Y += s.scale(next())
+	}
+	if format&valueXAdvance != 0 {
+		p.adv += s.scale(next())
+	}
+	// Vertical advances and device tables are not supported.
+}
+
+// valueSize returns the size of a value record with the given
+// format.
+func valueSize(format uint16) int {
+	return 2 * bits.OnesCount16(format)
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"golang.org/x/image/font/sfnt"
+)
+
+// applySubst applies a GSUB subtable to the glyph at index i.
+func (s *shaper) applySubst(t *layoutTable, l lookup, st table, i int) (int, bool) {
+	id := s.glyphs[i].id
+	switch l.typ {
+	case 1: // Single substitution.
+		idx := st.offset16(2).coverage(id)
+		if idx == -1 {
+			return 0, false
+		}
+		switch st.u16(0) {
+		case 1:
+			s.substitute(i, sfnt.GlyphIndex(uint16(int(id)+int(st.i16(4)))))
+		case 2:
+			if idx >= int(st.u16(4)) {
+				return 0, false
+			}
+			s.substitute(i, sfnt.GlyphIndex(st.u16(6+2*idx)))
+		default:
+			return 0, false
+		}
+		return i + 1, true
+	case 2: // Multiple substitution.
+		idx := st.offset16(2).coverage(id)
+		if idx == -1 || idx >= int(st.u16(4)) {
+			return 0, false
+		}
+		seq := st.offset16(6 + 2*idx)
+		n := int(seq.u16(0))
+		ids := make([]sfnt.GlyphIndex, n)
+		for k := range ids {
+			ids[k] = sfnt.GlyphIndex(seq.u16(2 + 2*k))
+		}
+		s.replace(i, ids)
+		return i + n, true
+	case 3: // Alternate substitution. Use the first alternate.
+		idx := st.offset16(2).coverage(id)
+		if idx == -1 || idx >= int(st.u16(4)) {
+			return 0, false
+		}
+		set := st.offset16(6 + 2*idx)
+		if set.u16(0) == 0 {
+			return 0, false
+		}
+		s.substitute(i, sfnt.GlyphIndex(set.u16(2)))
+		return i + 1, true
+	case 4: // Ligature substitution.
+		idx := st.offset16(2).coverage(id)
+		if idx == -1 || idx >= int(st.u16(4)) {
+			return 0, false
+		}
+		set := st.offset16(6 + 2*idx)
+		n := int(set.u16(0))
+		for k := 0; k < n; k++ {
+			lig := set.offset16(2 + 2*k)
+			count := int(lig.u16(2))
+			if count == 0 {
+				continue
+			}
+			positions, ok := s.matchInput(l, i, count-1, lig.sub(4), matchGlyph)
+			if !ok {
+				continue
+			}
+			s.ligate(positions, sfnt.GlyphIndex(lig.u16(0)))
+			return i + 1, true
+		}
+		return 0, false
+	case 5:
+		return s.applyContext(t, l, st, i)
+	case 6:
+		return s.applyChainContext(t, l, st, i)
+	}
+	return 0, false
+}
+
+// substitute replaces the glyph at index i with id.
+func (s *shaper) substitute(i int, id sfnt.GlyphIndex) {
+	g := &s.glyphs[i]
+	g.id = id
+	if c := s.font.Tables.gdef.glyphClass(id); c != classUnknown {
+		g.class = c
+	}
+}
+
+// replace the glyph at index i with the sequence ids.
+func (s *shaper) replace(i int, ids []sfnt.GlyphIndex) {
+	g := s.glyphs[i]
+	if len(ids) == 0 {
+		s.glyphs = append(s.glyphs[:i], s.glyphs[i+1:]...)
+		return
+	}
+	seq := make([]glyphInfo, len(ids))
+	for k := range seq {
+		seq[k] = g
+	}
+	s.glyphs = append(s.glyphs[:i], append(seq, s.glyphs[i+1:]...)...)
+	for k, id := range ids {
+		s.substitute(i+k, id)
+	}
+}
+
+// ligate replaces the glyphs at positions with the ligature id. Glyphs
+// skipped between the components are kept after the ligature.
+func (s *shaper) ligate(positions []int, id sfnt.GlyphIndex) {
+	first, last := positions[0], positions[len(positions)-1]
+	lig := &s.glyphs[first]
+	lig.id = id
+	lig.ligated = true
+	lig.class = classLigature
+	if c := s.font.Tables.gdef.glyphClass(id); c != classUnknown {
+		lig.class = c
+	}
+	out := first + 1
+	comp, p := 1, 1
+	for k := first + 1; k <= last; k++ {
+		if p < len(positions) && k == positions[p] {
+			p++
+			comp++
+			continue
+		}
+		g := s.glyphs[k]
+		g.ligComp = comp
+		g.cluster = lig.cluster
+		s.glyphs[out] = g
+		out++
+	}
+	s.glyphs = append(s.glyphs[:out], s.glyphs[last+1:]...)
+}
@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+// indicCategory is the shaping category of a rune of an Indic
+// script.
+type indicCategory uint8
+
+// indicPosition is the position of a glyph relative to the base
+// consonant of its syllable.
+type indicPosition uint8
+
+const (
+	catOther indicCategory = iota
+	catConsonant
+	catRa
+	catVowel
+	catMatra
+	catPreMatra
+	catVirama
+	catNukta
+	catModifier
+	catJoiner
+)
+
+const (
+	posOther indicPosition = iota
+	posReph
+	posPreMatra
+	posPreBase
+	posBase
+	posPostBase
+)
+
+// Offsets of the Indic script blocks, each 128 runes long.
+const (
+	indicFirst      = 0x0900
+	indicLast       = 0x0d7f
+	blockDevanagari = 0x0900
+	blockBengali    = 0x0980
+	blockGurmukhi   = 0x0a00
+	blockGujarati   = 0x0a80
+	blockOriya      = 0x0b00
+	blockTamil      = 0x0b80
+	blockMalayalam  = 0x0d00
+)
+
+// indicCategoryOf returns the category of r. The Indic blocks share
+// their layout, so the category is derived from the offset of r in
+// its block.
+func indicCategoryOf(r rune) indicCategory {
+	switch r {
+	case 0x200c, 0x200d:
+		return catJoiner
+	}
+	if r < indicFirst || r > indicLast {
+		return catOther
+	}
+	block, off := r&^0x7f, r&0x7f
+	switch {
+	case off == 0x30:
+		return catRa
+	case off >= 0x01 && off <= 0x03:
+		return catModifier
+	case off >= 0x04 && off <= 0x14, off >= 0x60 && off <= 0x61:
+		return catVowel
+	case off >= 0x15 && off <= 0x39, off >= 0x58 && off <= 0x5f:
+		return catConsonant
+	case off == 0x3c:
+		return catNukta
+	case off == 0x4d:
+		return catVirama
+	case off == 0x3a, off == 0x3b, off >= 0x3e && off <= 0x4c,
+		off >= 0x4e && off <= 0x4f, off >= 0x55 && off <= 0x57,
+		off >= 0x62 && off <= 0x63:
+		if isPreMatra(block, off) {
+			return catPreMatra
+		}
+		return catMatra
+	}
+	return catOther
+}
+
+// isPreMatra reports whether the matra at offset off of block is
+// displayed before its base consonant.
+func isPreMatra(block, off rune) bool {
+	switch block {
+	case blockDevanagari, blockGurmukhi, blockGujarati:
+		return off == 0x3f
+	case blockBengali:
+		return off == 0x3f || off == 0x47 || off == 0x48
+	case blockOriya:
+		return off == 0x47
+	case blockTamil, blockMalayalam:
+		return off >= 0x46 && off <= 0x48
+	}
+	return false
+}
+
+// prepareIndic splits an Indic run into syllables, selects the
+// features that apply to each glyph and moves pre-base matras
+// before their consonant clusters.
+func prepareIndic(s *shaper, runes []rune) {
+	cats := make([]indicCategory, len(runes))
+	for i, r := range runes {
+		cats[i] = indicCategoryOf(r)
+	}
+	syllable := 0
+	for start := 0; start < len(runes); {
+		end := indicSyllable(cats, start)
+		syllable++
+		s.prepareSyllable(cats, start, end, syllable)
+		start = end
+	}
+}
+
+// indicSyllable returns the end of the syllable starting at start.
+func indicSyllable(cats []indicCategory, start int) int {
+	i := start
+	isConsonant := func(i int) bool {
+		return i < len(cats) && (cats[i] == catConsonant || cats[i] == catRa)
+	}
+	is := func(i int, c indicCategory) bool {
+		return i < len(cats) && cats[i] == c
+	}
+	switch {
+	case isConsonant(i):
+		for {
+			i++
+			if is(i, catNukta) {
+				i++
+			}
+			if !is(i, catVirama) {
+				break
+			}
+			// A virama followed by a consonant, optionally through
+			// a joiner, continues the consonant cluster.
+			j := i + 1
+			if is(j, catJoiner) {
+				j++
+			}
+			if !isConsonant(j) {
+				i++
+				if is(i, catJoiner) {
+					i++
+				}
+				return i
+			}
+			i = j
+		}
+	case is(i, catVowel):
+		i++
+		if is(i, catNukta) {
+			i++
+		}
+	default:
+		return i + 1
+	}
+	for is(i, catMatra) || is(i, catPreMatra) || is(i, catNukta) {
+		i++
+	}
+	for is(i, catModifier) {
+		i++
+	}
+	return i
+}
+
+// prepareSyllable sets the masks and positions of the glyphs
+// of the syllable between start and end.
+func (s *shaper) prepareSyllable(cats []indicCategory, start, end, syllable int) {
+	glyphs := s.glyphs[start:end]
+	base := -1
+	consonants := 0
+	for i, c := range cats[start:end] {
+		if c == catConsonant || c == catRa {
+			base = i
+			consonants++
+		}
+	}
+	for i := range glyphs {
+		g := &glyphs[i]
+		g.syllable = syllable
+		g.cluster = glyphs[0].cluster
+	}
+	if base == -1 {
+		return
+	}
+	masks := s.plan.masks
+	first := 0
+	// A syllable starting with Ra and virama forms a reph if the
+	// Ra isn't the only consonant.
+	if consonants > 1 && cats[start] == catRa && end-start > 1 && cats[start+1] == catVirama {
+		for i := 0; i < 2; i++ {
+			glyphs[i].mask |= masks[otTag("rphf")]
+			glyphs[i].indicPos = posReph
+		}
+		first = 2
+	}
+	below := masks[otTag("blwf")] | masks[otTag("abvf")] | masks[otTag("pstf")]
+	for i := first; i < len(glyphs); i++ {
+		g := &glyphs[i]
+		switch {
+		case i < base:
+			g.mask |= masks[otTag("half")]
+			g.indicPos = posPreBase
+		case i == base:
+			g.indicPos = posBase
+		default:
+			g.mask |= below
+			g.indicPos = posPostBase
+			if cats[start+i] == catPreMatra {
+				g.indicPos = posPreMatra
+			}
+		}
+	}
+	// Move pre-base matras to the start of the consonant cluster.
+	for i := base + 1; i < len(glyphs); i++ {
+		if glyphs[i].indicPos != posPreMatra {
+			continue
+		}
+		m := glyphs[i]
+		copy(glyphs[first+1:i+1], glyphs[first:i])
+		glyphs[first] = m
+		first++
+	}
+}
+
+// reorderIndic moves the reph glyphs formed by the rphf feature
+// after the base consonants of their syllables.
+func reorderIndic(s *shaper) {
+	for i := 0; i < len(s.glyphs); i++ {
+		g := s.glyphs[i]
+		if g.indicPos != posReph || !g.ligated {
+			continue
+		}
+		last := i
+		for j := i + 1; j < len(s.glyphs) && s.glyphs[j].syllable == g.syllable; j++ {
+			if p := s.glyphs[j].indicPos; p == posBase || p == posPreBase {
+				last = j
+			}
+		}
+		if last == i {
+			continue
+		}
+		copy(s.glyphs[i:last], s.glyphs[i+1:last+1])
+		s.glyphs[last] = g
+		// The reph is no longer a candidate for reordering.
+		s.glyphs[last].indicPos = posOther
+	}
+}
@@ -15,6 +15,9 @@ import (
 	"golang.org/x/image/math/fixed"
 
 	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/internal/bidi"
+	"github.com/cybriq/giocore/internal/grapheme"
+	"github.com/cybriq/giocore/internal/linebreak"
 	"github.com/cybriq/giocore/op"
 	"github.com/cybriq/giocore/op/clip"
 	"github.com/cybriq/giocore/text"
@@ -23,7 +26,11 @@ import (
 // Font implements text.Face. Its methods are safe to use
 // concurrently.
 type Font struct {
-	font *sfnt.Font
+	font   *sfnt.Font
+	tables *tables
+	// vary is the variation of an instance of a variable font, or
+	// nil for the default instance.
+	vary *variation
 }
 
 // Collection is a collection of one or more fonts. When used as a text.Face,
@@ -36,6 +43,21 @@ type Collection struct {
 type opentype struct {
 	Font    *sfnt.Font
 	Hinting font.Hinting
+	// Tables contains the layout tables used for shaping.
+	Tables *tables
+	// Vary is the variation of the font instance, if any.
+	Vary *variation
+	// Script restricts the font to the runes of a script, if not nil.
+	Script *unicode.RangeTable
+}
+
+// Fallback is a font of a fallback chain.
+type Fallback struct {
+	Font *Font
+	// Script restricts the font to the runes of a script, such as
+	// unicode.Han, and the runes common to all scripts. If nil, the
+	// font is used for any rune.
+	Script *unicode.RangeTable
 }
 
 // a glyph represents a rune and its advance according to a Font.
@@ -43,59 +65,140 @@ type opentype struct {
 type glyph struct {
 	Rune    rune
 	Advance fixed.Int26_6
+	// Font is the index of the font that shapes the rune.
+	Font int
+	// Level is the bidirectional embedding level of the rune.
+	Level bidi.Level
 }
 
 // NewFont parses an SFNT font, such as TTF or OTF data, from a []byte
-// data source.
+// data source. WOFF and WOFF2 data is decoded to SFNT data.
 func Parse(src []byte) (*Font, error) {
+	if isWOFF(src) {
+		var err error
+		if src, err = decodeWOFF(src); err != nil {
+			return nil, err
+		}
+	}
 	fnt, err := sfnt.Parse(src)
 	if err != nil {
 		return nil, err
 	}
-	return &Font{font: fnt}, nil
+	return newFontFrom(fnt, bytes.NewReader(src)), nil
+}
+
+// ParseReaderAt parses an SFNT font, such as TTF or OTF data, from an
+// io.ReaderAt data source. Glyph outlines are read from src when
+// needed, so src must remain valid and safe for concurrent use for
+// the lifetime of the Font; an *os.File is suitable. So are bitmap
+// glyphs and the outlines and glyph variations of variable fonts.
+// Only the layout tables, the COLR and CPAL color tables and the
+// indexes of the bitmap and variation tables are kept in memory.
+//
+// WOFF and WOFF2 data is compressed and is decoded into memory.
+func ParseReaderAt(src io.ReaderAt) (*Font, error) {
+	if data, ok, err := readWOFF(src); ok || err != nil {
+		if err != nil {
+			return nil, err
+		}
+		return Parse(data)
+	}
+	fnt, err := sfnt.ParseReaderAt(src)
+	if err != nil {
+		return nil, err
+	}
+	return newFontFrom(fnt, src), nil
+}
+
+func newFontFrom(fnt *sfnt.Font, src io.ReaderAt) *Font {
+	t, err := readTables(src, 0)
+	if err != nil {
+		// Lay out the font without shaping.
+		t = new(tables)
+	}
+	return &Font{font: fnt, tables: t}
 }
 
 // ParseCollection parses an SFNT font collection, such as TTC or OTC data,
 // from a []byte data source.
 //
 // If passed data for a single font, a TTF or OTF instead of a TTC or OTC,
-// it will return a collection containing 1 font.
+// it will return a collection containing 1 font. WOFF and WOFF2 data is
+// decoded to SFNT data.
 func ParseCollection(src []byte) (*Collection, error) {
+	if isWOFF(src) {
+		var err error
+		if src, err = decodeWOFF(src); err != nil {
+			return nil, err
+		}
+	}
 	c, err := sfnt.ParseCollection(src)
 	if err != nil {
 		return nil, err
 	}
-	return newCollectionFrom(c)
+	return newCollectionFrom(c, bytes.NewReader(src))
 }
 
 // ParseCollectionReaderAt parses an SFNT collection, such as TTC or OTC data,
 // from an io.ReaderAt data source.
 //
 // If passed data for a single font, a TTF or OTF instead of a TTC or OTC, it
-// will return a collection containing 1 font.
+// will return a collection containing 1 font. WOFF and WOFF2 data is
+// decoded into memory.
 func ParseCollectionReaderAt(src io.ReaderAt) (*Collection, error) {
+	if data, ok, err := readWOFF(src); ok || err != nil {
+		if err != nil {
+			return nil, err
+		}
+		return ParseCollection(data)
+	}
 	c, err := sfnt.ParseCollectionReaderAt(src)
 	if err != nil {
 		return nil, err
 	}
-	return newCollectionFrom(c)
+	return newCollectionFrom(c, src)
 }
 
-func newCollectionFrom(coll *sfnt.Collection) (*Collection, error) {
+func newCollectionFrom(coll *sfnt.Collection, src io.ReaderAt) (*Collection, error) {
 	fonts := make([]*opentype, coll.NumFonts())
+	offsets, err := fontOffsets(src)
+	if err != nil || len(offsets) != len(fonts) {
+		offsets = nil
+	}
 	for i := range fonts {
 		fnt, err := coll.Font(i)
 		if err != nil {
 			return nil, err
 		}
+		t := new(tables)
+		if offsets != nil {
+			if ft, err := readTables(src, offsets[i]); err == nil {
+				t = ft
+			}
+		}
 		fonts[i] = &opentype{
 			Font:    fnt,
 			Hinting: font.HintingFull,
+			Tables:  t,
 		}
 	}
 	return &Collection{fonts: fonts}, nil
 }
 
+// NewCollection returns a collection that shapes every rune with the
+// first font of chain that supports the rune and its script. A font
+// may appear more than once in chain to take part in the fallback of
+// several scripts.
+func NewCollection(chain []Fallback) *Collection {
+	fonts := make([]*opentype, len(chain))
+	for i, fb := range chain {
+		f := fb.Font.opentype()
+		f.Script = fb.Script
+		fonts[i] = f
+	}
+	return &Collection{fonts: fonts}
+}
+
 // NumFonts returns the number of fonts in the collection.
 func (c *Collection) NumFonts() int {
 	return len(c.fonts)
@@ -106,37 +209,113 @@ func (c *Collection) Font(i int) (*Font, error) {
 	if i < 0 || len(c.fonts) <= i {
 		return nil, sfnt.ErrNotFound
 	}
-	return &Font{font: c.fonts[i].Font}, nil
+	return &Font{font: c.fonts[i].Font, tables: c.fonts[i].Tables}, nil
 }
 
 func (f *Font) Layout(ppem fixed.Int26_6, maxWidth int, txt io.Reader) ([]text.Line, error) {
+	return f.LayoutWithOptions(ppem, maxWidth, text.LayoutOptions{}, txt)
+}
+
+// LayoutWithOptions implements text.OptionsFace.
+func (f *Font) LayoutWithOptions(ppem fixed.Int26_6, maxWidth int, opts text.LayoutOptions, txt io.Reader) ([]text.Line, error) {
 	glyphs, err := readGlyphs(txt)
 	if err != nil {
 		return nil, err
 	}
-	fonts := []*opentype{{Font: f.font, Hinting: font.HintingFull}}
+	fonts := []*opentype{f.opentype()}
+	var buf sfnt.Buffer
+	return layoutText(&buf, ppem, maxWidth, fonts, opts, glyphs, nil), nil
+}
+
+// Measure implements text.MeasureFace.
+func (f *Font) Measure(ppem fixed.Int26_6, maxWidth int, txt io.Reader) (text.Measurement, error) {
+	glyphs, err := readGlyphs(txt)
+	if err != nil {
+		return text.Measurement{}, err
+	}
+	fonts := []*opentype{f.opentype()}
 	var buf sfnt.Buffer
-	return layoutText(&buf, ppem, maxWidth, fonts, glyphs)
+	var m text.Measurement
+	layoutText(&buf, ppem, maxWidth, fonts, text.LayoutOptions{}, glyphs, &m)
+	return m, nil
 }
 
 func (f *Font) Shape(ppem fixed.Int26_6, str text.Layout) op.CallOp {
 	var buf sfnt.Buffer
-	return textPath(&buf, ppem, []*opentype{{Font: f.font, Hinting: font.HintingFull}}, str)
+	return textPath(&buf, ppem, []*opentype{f.opentype()}, str)
+}
+
+// ShapeColor implements text.ColorFace.
+func (f *Font) ShapeColor(ppem fixed.Int26_6, str text.Layout) op.CallOp {
+	var buf sfnt.Buffer
+	return colorPath(&buf, ppem, []*opentype{f.opentype()}, str)
 }
 
 func (f *Font) Metrics(ppem fixed.Int26_6) font.Metrics {
-	o := &opentype{Font: f.font, Hinting: font.HintingFull}
 	var buf sfnt.Buffer
-	return o.Metrics(&buf, ppem)
+	return f.opentype().Metrics(&buf, ppem)
+}
+
+func (f *Font) opentype() *opentype {
+	t := f.tables
+	if t == nil {
+		t = new(tables)
+	}
+	return &opentype{Font: f.font, Hinting: font.HintingFull, Tables: t, Vary: f.vary}
+}
+
+// Axes implements text.VariableFace. It returns nil if the font is
+// not a variable font.
+func (f *Font) Axes() []text.Axis {
+	if f.tables == nil {
+		return nil
+	}
+	return f.tables.axes()
+}
+
+// Vary implements text.VariableFace. Only the outlines and advances of
+// fonts with TrueType outlines are varied; other fonts are returned
+// unchanged.
+func (f *Font) Vary(values []text.Variation) text.Face {
+	axes := f.Axes()
+	if axes == nil || f.tables.glyf.src == nil || f.tables.gvar == nil && f.tables.hvar == nil {
+		return f
+	}
+	coords := f.tables.normalize(axes, values)
+	inst := &Font{font: f.font, tables: f.tables}
+	for _, c := range coords {
+		if c != 0 {
+			inst.vary = &variation{t: f.tables, coords: coords, upem: float32(f.font.UnitsPerEm())}
+			break
+		}
+	}
+	return inst
 }
 
 func (c *Collection) Layout(ppem fixed.Int26_6, maxWidth int, txt io.Reader) ([]text.Line, error) {
+	return c.LayoutWithOptions(ppem, maxWidth, text.LayoutOptions{}, txt)
+}
+
+// LayoutWithOptions implements text.OptionsFace.
+func (c *Collection) LayoutWithOptions(ppem fixed.Int26_6, maxWidth int, opts text.LayoutOptions, txt io.Reader) ([]text.Line, error) {
 	glyphs, err := readGlyphs(txt)
 	if err != nil {
 		return nil, err
 	}
 	var buf sfnt.Buffer
-	return layoutText(&buf, ppem, maxWidth, c.fonts, glyphs)
+	return layoutText(&buf, ppem, maxWidth, c.fonts, opts, glyphs, nil), nil
+}
+
+// Measure implements text.MeasureFace.
+func (c *Collection) Measure(ppem fixed.Int26_6, maxWidth int, txt io.Reader) (text.Measurement, error) {
+	glyphs, err := readGlyphs(txt)
+	if err != nil {
+		return text.Measurement{}, err
+	}
+	var buf sfnt.Buffer
+	var m text.Measurement
+	layoutText(&buf, ppem, maxWidth, c.fonts, text.LayoutOptions{}, glyphs, &m)
+	return m, nil
 }
 
 func (c *Collection) Shape(ppem fixed.Int26_6, str text.Layout) op.CallOp {
@@ -144,19 +323,43 @@ func (c *Collection) Shape(ppem fixed.Int26_6, str text.Layout) op.CallOp {
 	return textPath(&buf, ppem, c.fonts, str)
 }
 
-func fontForGlyph(buf *sfnt.Buffer, fonts []*opentype, r rune) *opentype {
-	if len(fonts) < 1 {
-		return nil
-	}
-	for _, f := range fonts {
-		if f.HasGlyph(buf, r) {
-			return f
+// ShapeColor implements text.ColorFace.
+func (c *Collection) ShapeColor(ppem fixed.Int26_6, str text.Layout) op.CallOp {
+	var buf sfnt.Buffer
+	return colorPath(&buf, ppem, c.fonts, str)
+}
+
+// fontForGlyph returns the index of the first font that supports r.
+func fontForGlyph(buf *sfnt.Buffer, fonts []*opentype, r rune) int {
+	for i, f := range fonts {
+		if f.supports(r) && f.HasGlyph(buf, r) {
+			return i
 		}
 	}
-	return fonts[0] // Use replacement character from the first font if necessary
+	return 0 // Use replacement character from the first font if necessary
 }
 
-func layoutText(sbuf *sfnt.Buffer, ppem fixed.Int26_6, maxWidth int, fonts []*opentype, glyphs []glyph) ([]text.Line, error) {
+// layoutText breaks glyphs into lines. If m is not nil, layoutText
+// accumulates the measurements of the lines in m and returns no lines.
+func layoutText(sbuf *sfnt.Buffer, ppem fixed.Int26_6, maxWidth int, fonts []*opentype, opts text.LayoutOptions, glyphs []glyph, m *text.Measurement) []text.Line {
+	bases := resolveLevels(glyphs)
+	shaped := shapeText(sbuf, ppem, fonts, glyphs)
+	runes := make([]rune, len(glyphs))
+	for i, g := range glyphs {
+		runes[i] = g.Rune
+	}
+	breaks := linebreak.Breaks(runes)
+	graphemes := grapheme.Breaks(runes)
+	addSpacing(glyphs, shaped, graphemes, opts)
+	// Without tab options, tabs have the advance of their glyph.
+	tabs := len(opts.TabStops) > 0 || opts.TabWidth > 0
+	tabWidth := opts.TabWidth
+	if tabs && tabWidth <= 0 && len(fonts) > 0 {
+		if gid, err := fonts[0].Font.GlyphIndex(sbuf, ' '); err == nil {
+			adv, _ := fonts[0].glyphAdvance(sbuf, gid, ppem)
+			tabWidth = 8 * adv
+		}
+	}
 	var lines []text.Line
 	var nextLine text.Line
 	updateBounds := func(f *opentype) {
@@ -175,43 +378,156 @@ func layoutText(sbuf *sfnt.Buffer, ppem fixed.Int26_6, maxWidth int, fonts []*op
 	}
 	maxDotX := fixed.I(maxWidth)
 	type state struct {
-		r     rune
-		f     *opentype
-		adv   fixed.Int26_6
-		x     fixed.Int26_6
-		idx   int
-		len   int
-		valid bool
-	}
-	var prev, word state
+		f   *opentype
+		adv fixed.Int26_6
+		x   fixed.Int26_6
+		idx int
+		len int
+	}
+	var prev, word, cluster state
+	// offset is the index of the first rune of the line.
+	offset := 0
+	// tab sets the advance of the tab at the line position of s to
+	// reach the next tab stop. If clamp is set, the advance is
+	// clamped to the line width.
+	tab := func(s *state, clamp bool) {
+		i := s.idx - 1
+		adv := tabAdvance(opts.TabStops, tabWidth, s.x)
+		if clamp && s.x+adv > maxDotX {
+			adv = maxDotX - s.x
+			if adv < 0 {
+				adv = 0
+			}
+		}
+		s.adv = adv
+		glyphs[i].Advance = adv
+		for j := range shaped {
+			if shaped[j].cluster == offset+i {
+				shaped[j].adv = adv
+			}
+		}
+	}
+	// hyphen is the hyphen glyph of a hyphenated line, if any.
+	var hyphen *text.Glyph
+	// hyphenate finds the longest hyphenated prefix of the word
+	// starting at word.idx that fits the line.
+	hyphenate := func() (state, *text.Glyph, bool) {
+		// Find the end of the word.
+		end := word.idx
+		for end < len(glyphs) && breaks[offset+end] == linebreak.NoBreak {
+			end++
+		}
+		if end == len(glyphs) {
+			end--
+		}
+		for end > word.idx && unicode.IsSpace(glyphs[end].Rune) {
+			end--
+		}
+		var buf bytes.Buffer
+		for _, g := range glyphs[word.idx : end+1] {
+			buf.WriteRune(g.Rune)
+		}
+		var best state
+		var bestHyphen *text.Glyph
+		for _, off := range opts.Hyphenator.Hyphenate(buf.String()) {
+			if off <= 0 || off >= buf.Len() {
+				continue
+			}
+			h := word
+			for h.len-word.len < off && h.idx < prev.idx {
+				g := glyphs[h.idx]
+				h.x += h.adv
+				h.adv = g.Advance
+				h.len += utf8.RuneLen(g.Rune)
+				h.idx++
+			}
+			if h.len-word.len != off || h.idx == 0 || len(fonts) == 0 {
+				continue
+			}
+			f := glyphs[h.idx-1].Font
+			gid, err := fonts[f].Font.GlyphIndex(sbuf, '-')
+			if err != nil || gid == 0 {
+				continue
+			}
+			adv, err := fonts[f].glyphAdvance(sbuf, gid, ppem)
+			if err != nil || h.x+h.adv+adv > maxDotX {
+				continue
+			}
+			best = h
+			bestHyphen = &text.Glyph{ID: text.NewGlyphID(f, uint16(gid)), Advance: adv}
+		}
+		return best, bestHyphen, bestHyphen != nil
+	}
 	endLine := func() {
 		if prev.f == nil && len(fonts) > 0 {
 			prev.f = fonts[0]
 		}
 		updateBounds(prev.f)
-		nextLine.Layout = toLayout(glyphs[:prev.idx:prev.idx])
+		n := 0
+		for n < len(shaped) && shaped[n].cluster < offset+prev.idx {
+			n++
+		}
 		nextLine.Width = prev.x + prev.adv
-		nextLine.Bounds.Max.X += prev.x
-		lines = append(lines, nextLine)
+		if hyphen != nil {
+			nextLine.Width += hyphen.Advance
+		}
+		if m != nil {
+			if m.Lines == 0 {
+				m.Baseline = nextLine.Ascent
+			}
+			m.Lines++
+			if nextLine.Width > m.Width {
+				m.Width = nextLine.Width
+			}
+			m.Height += nextLine.Ascent + nextLine.Descent
+		} else {
+			line := glyphs[:prev.idx:prev.idx]
+			base := bidi.Level(0)
+			if len(line) > 0 {
+				base = bases[offset]
+			}
+			nextLine.Layout, nextLine.Runs = toLayout(line, shaped[:n], graphemes[offset:offset+prev.idx], offset, base)
+			if base.RTL() {
+				nextLine.Direction = text.RTL
+			}
+			if hyphen != nil {
+				l := &nextLine.Layout
+				hyphen.Cluster = len(l.Text)
+				if base.RTL() {
+					l.Glyphs = append([]text.Glyph{*hyphen}, l.Glyphs...)
+				} else {
+					l.Glyphs = append(l.Glyphs, *hyphen)
+				}
+			}
+			nextLine.Bounds.Max.X += prev.x
+			lines = append(lines, nextLine)
+		}
+		hyphen = nil
+		shaped = shaped[n:]
 		glyphs = glyphs[prev.idx:]
+		offset += prev.idx
 		nextLine = text.Line{}
 		prev = state{}
 		word = state{}
+		cluster = state{}
 	}
 	for prev.idx < len(glyphs) {
 		g := &glyphs[prev.idx]
 		next := state{
-			r:   g.Rune,
-			f:   fontForGlyph(sbuf, fonts, g.Rune),
+			adv: g.Advance,
 			idx: prev.idx + 1,
 			len: prev.len + utf8.RuneLen(g.Rune),
 			x:   prev.x + prev.adv,
 		}
-		if next.f != nil {
+		if len(fonts) > 0 {
+			next.f = fonts[g.Font]
 			if next.f != prev.f {
 				updateBounds(next.f)
 			}
-			next.adv, next.valid = next.f.GlyphAdvance(sbuf, ppem, g.Rune)
+		}
+		if tabs && g.Rune == '\t' {
+			// A tab starting the line can't move to the next.
+			tab(&next, prev.idx == 0)
 		}
 		if g.Rune == '\n' {
 			// The newline is zero width; use the previous
@@ -221,13 +537,18 @@ func layoutText(sbuf *sfnt.Buffer, ppem fixed.Int26_6, maxWidth int, fonts []*op
 			endLine()
 			continue
 		}
-		var k fixed.Int26_6
-		if prev.valid && next.f != nil {
-			k = next.f.Kern(sbuf, ppem, prev.r, next.r)
-		}
 		// Break the line if we're out of space.
-		if prev.idx > 0 && next.x+next.adv+k > maxDotX {
-			// If the line contains no word breaks, break off the last rune.
+		if prev.idx > 0 && next.x+next.adv > maxDotX {
+			if opts.Hyphenator != nil && !unicode.IsSpace(g.Rune) {
+				if h, g, ok := hyphenate(); ok {
+					word, hyphen = h, g
+				}
+			}
+			// If the line contains no word breaks, break off the last
+			// grapheme cluster, or the last rune if there is only one.
+			if word.idx == 0 {
+				word = cluster
+			}
 			if word.idx == 0 {
 				word = prev
 			}
@@ -236,29 +557,183 @@ func layoutText(sbuf *sfnt.Buffer, ppem fixed.Int26_6, maxWidth int, fonts []*op
 			next.len -= word.len
 			prev = word
 			endLine()
-		} else if k != 0 {
-			glyphs[prev.idx-1].Advance += k
-			next.x += k
-		}
-		g.Advance = next.adv
-		if unicode.IsSpace(g.Rune) {
-			word = next
+			if tabs && g.Rune == '\t' {
+				// The tab moved to the next line, where it
+				// may not fit either.
+				tab(&next, true)
+			}
 		}
 		prev = next
+		if graphemes[offset+prev.idx-1] {
+			cluster = prev
+		}
+		switch breaks[offset+prev.idx-1] {
+		case linebreak.Allowed:
+			word = prev
+		case linebreak.Mandatory:
+			endLine()
+		}
 	}
 	endLine()
-	return lines, nil
+	return lines
+}
+
+// addSpacing adds the letter and word spacing of opts to the advances
+// of glyphs and their shaped glyphs. graphemes reports the grapheme
+// cluster boundaries after each glyph.
+func addSpacing(glyphs []glyph, shaped []shapedGlyph, graphemes []bool, opts text.LayoutOptions) {
+	if opts.LetterSpacing == 0 && opts.WordSpacing == 0 {
+		return
+	}
+	// j is the index of the last shaped glyph of the glyphs up to
+	// and including the current glyph.
+	j := 0
+	for i := range glyphs {
+		g := &glyphs[i]
+		var extra fixed.Int26_6
+		if graphemes[i] && g.Rune != '\n' {
+			extra += opts.LetterSpacing
+		}
+		if g.Rune == ' ' || g.Rune == '\u00a0' {
+			extra += opts.WordSpacing
+		}
+		if extra == 0 {
+			continue
+		}
+		g.Advance += extra
+		for j+1 < len(shaped) && shaped[j+1].cluster <= i {
+			j++
+		}
+		if j < len(shaped) && shaped[j].cluster <= i {
+			shaped[j].adv += extra
+		}
+	}
+}
+
+// tabAdvance returns the distance from x to the first tab stop after
+// x. Stops after the last of stops are width apart.
+func tabAdvance(stops []fixed.Int26_6, width, x fixed.Int26_6) fixed.Int26_6 {
+	var last fixed.Int26_6
+	for _, s := range stops {
+		if s > x {
+			return s - x
+		}
+		last = s
+	}
+	if width <= 0 {
+		return 0
+	}
+	n := (x-last)/width + 1
+	return last + n*width - x
+}
+
+// resolveLevels resolves the bidirectional levels of the paragraphs
+// of a text and returns the paragraph level of every glyph.
+func resolveLevels(glyphs []glyph) []bidi.Level {
+	bases := make([]bidi.Level, len(glyphs))
+	var runes []rune
+	for start := 0; start < len(glyphs); {
+		end := start
+		for end < len(glyphs) && glyphs[end].Rune != '\n' {
+			end++
+		}
+		runes = runes[:0]
+		for _, g := range glyphs[start:end] {
+			runes = append(runes, g.Rune)
+		}
+		levels, base := bidi.Levels(runes, bidi.Auto)
+		for i, l := range levels {
+			glyphs[start+i].Level = l
+		}
+		// Include the newline in the paragraph.
+		if end < len(glyphs) {
+			glyphs[end].Level = base
+			end++
+		}
+		for i := start; i < end; i++ {
+			bases[i] = base
+		}
+		start = end
+	}
+	return bases
 }
 
-// toLayout converts a slice of glyphs to a text.Layout.
-func toLayout(glyphs []glyph) text.Layout {
+// toLayout converts a slice of glyphs and their shaped glyphs to a
+// text.Layout and its directional runs. graphemes reports the grapheme
+// cluster boundaries after each glyph. The first glyph is at index
+// offset in the text, and base is its paragraph level.
+func toLayout(glyphs []glyph, shaped []shapedGlyph, graphemes []bool, offset int, base bidi.Level) (text.Layout, []text.Run) {
 	var buf bytes.Buffer
 	advs := make([]fixed.Int26_6, len(glyphs))
+	runes := make([]rune, len(glyphs))
+	levels := make([]bidi.Level, len(glyphs))
+	// clusters maps rune indices to byte offsets.
+	clusters := make([]int, len(glyphs)+1)
 	for i, g := range glyphs {
+		clusters[i] = buf.Len()
 		buf.WriteRune(g.Rune)
 		advs[i] = glyphs[i].Advance
+		runes[i] = g.Rune
+		levels[i] = g.Level
+	}
+	clusters[len(glyphs)] = buf.Len()
+	var graphemeClusters []text.Cluster
+	var c text.Cluster
+	for i := range glyphs {
+		c.Advance += advs[i]
+		// The line always ends a cluster.
+		if graphemes[i] || i == len(glyphs)-1 {
+			c.End = clusters[i+1]
+			graphemeClusters = append(graphemeClusters, c)
+			c = text.Cluster{Start: c.End}
+		}
+	}
+	var runs []text.Run
+	var shapedGlyphs []text.Glyph
+	for _, r := range bidi.Runs(runes, levels, base) {
+		run := text.Run{Start: clusters[r.Start], End: clusters[r.End]}
+		// Find the shaped glyphs of the run.
+		start := 0
+		for start < len(shaped) && shaped[start].cluster-offset < r.Start {
+			start++
+		}
+		end := start
+		for end < len(shaped) && shaped[end].cluster-offset < r.End {
+			end++
+		}
+		runGlyphs := shaped[start:end]
+		if r.Level.RTL() {
+			run.Direction = text.RTL
+			runGlyphs = reverseClusters(runGlyphs)
+		}
+		for _, g := range runGlyphs {
+			shapedGlyphs = append(shapedGlyphs, text.Glyph{
+				ID:      g.id,
+				Cluster: clusters[g.cluster-offset],
+				Advance: g.adv,
+				Offset:  g.off,
+			})
+		}
+		runs = append(runs, run)
 	}
-	return text.Layout{Text: buf.String(), Advances: advs}
+	l := text.Layout{Text: buf.String(), Advances: advs, Glyphs: shapedGlyphs, Clusters: graphemeClusters}
+	return l, runs
+}
+
+// reverseClusters returns the shaped glyphs in reverse order,
+// keeping the glyphs of each cluster and their marks in logical
+// order.
+func reverseClusters(glyphs []shapedGlyph) []shapedGlyph {
+	rev := make([]shapedGlyph, 0, len(glyphs))
+	for end := len(glyphs); end > 0; {
+		start := end - 1
+		for start > 0 && (glyphs[start].mark || glyphs[start].cluster == glyphs[start-1].cluster) {
+			start--
+		}
+		rev = append(rev, glyphs[start:end]...)
+		end = start
+	}
+	return rev
 }
 
 func textPath(buf *sfnt.Buffer, ppem fixed.Int26_6, fonts []*opentype, str text.Layout) op.CallOp {
@@ -266,68 +741,112 @@ func textPath(buf *sfnt.Buffer, ppem fixed.Int26_6, fonts []*opentype, str text.
 	var builder clip.Path
 	ops := new(op.Ops)
 	m := op.Record(ops)
-	var x fixed.Int26_6
 	builder.Begin(ops)
+	for _, g := range visibleGlyphs(buf, fonts, str) {
+		// Color glyphs are drawn by colorPath.
+		if g.font.Tables.hasColorGlyph(g.id, ppem) {
+			continue
+		}
+		segs, err := g.font.loadGlyph(buf, g.id, ppem)
+		if err == nil {
+			lastPos = addGlyph(&builder, segs, lastPos, g.pos)
+		}
+	}
+	clip.Outline{
+		Path: builder.End(),
+	}.Op().Add(ops)
+	return m.Stop()
+}
+
+// layoutGlyph is a glyph of a layout at its pen position.
+type layoutGlyph struct {
+	font *opentype
+	id   sfnt.GlyphIndex
+	pos  f32.Point
+}
+
+// visibleGlyphs returns the glyphs of a layout, except for the glyphs
+// of spaces.
+func visibleGlyphs(buf *sfnt.Buffer, fonts []*opentype, str text.Layout) []layoutGlyph {
+	var glyphs []layoutGlyph
+	var x fixed.Int26_6
+	if str.Glyphs != nil {
+		for _, g := range str.Glyphs {
+			r, _ := utf8.DecodeRuneInString(str.Text[g.Cluster:])
+			if f := g.ID.Font(); !unicode.IsSpace(r) && f < len(fonts) {
+				glyphs = append(glyphs, layoutGlyph{
+					font: fonts[f],
+					id:   sfnt.GlyphIndex(g.ID.Index()),
+					pos: f32.Point{
+						X: float32(x+g.Offset.X) / 64,
+						Y: float32(g.Offset.Y) / 64,
+					},
+				})
+			}
+			x += g.Advance
+		}
+		return glyphs
+	}
+	// Draw layouts without glyphs rune by rune.
 	rune := 0
 	for _, r := range str.Text {
-		if !unicode.IsSpace(r) {
-			f := fontForGlyph(buf, fonts, r)
-			if f == nil {
-				continue
-			}
-			segs, ok := f.LoadGlyph(buf, ppem, r)
-			if !ok {
-				continue
-			}
-			// Move to glyph position.
-			pos := f32.Point{
-				X: float32(x) / 64,
-			}
-			builder.Move(pos.Sub(lastPos))
-			lastPos = pos
-			var lastArg f32.Point
-			// Convert sfnt.Segments to relative segments.
-			for _, fseg := range segs {
-				nargs := 1
-				switch fseg.Op {
-				case sfnt.SegmentOpQuadTo:
-					nargs = 2
-				case sfnt.SegmentOpCubeTo:
-					nargs = 3
-				}
-				var args [3]f32.Point
-				for i := 0; i < nargs; i++ {
-					a := f32.Point{
-						X: float32(fseg.Args[i].X) / 64,
-						Y: float32(fseg.Args[i].Y) / 64,
-					}
-					args[i] = a.Sub(lastArg)
-					if i == nargs-1 {
-						lastArg = a
-					}
-				}
-				switch fseg.Op {
-				case sfnt.SegmentOpMoveTo:
-					builder.Move(args[0])
-				case sfnt.SegmentOpLineTo:
-					builder.Line(args[0])
-				case sfnt.SegmentOpQuadTo:
-					builder.Quad(args[0], args[1])
-				case sfnt.SegmentOpCubeTo:
-					builder.Cube(args[0], args[1], args[2])
-				default:
-					panic("unsupported segment op")
-				}
+		if !unicode.IsSpace(r) && len(fonts) > 0 {
+			f := fonts[fontForGlyph(buf, fonts, r)]
+			if id, err := f.Font.GlyphIndex(buf, r); err == nil {
+				glyphs = append(glyphs, layoutGlyph{
+					font: f,
+					id:   id,
+					pos:  f32.Point{X: float32(x) / 64},
+				})
 			}
-			lastPos = lastPos.Add(lastArg)
 		}
 		x += str.Advances[rune]
 		rune++
 	}
-	clip.Outline{
-		Path: builder.End(),
-	}.Op().Add(ops)
-	return m.Stop()
+	return glyphs
+}
+
+// addGlyph adds the outline of a glyph at pos to a path with its pen
+// at lastPos, and returns the new pen position.
+func addGlyph(builder *clip.Path, segs []sfnt.Segment, lastPos, pos f32.Point) f32.Point {
+	// Move to glyph position.
+	builder.Move(pos.Sub(lastPos))
+	lastPos = pos
+	var lastArg f32.Point
+	// Convert sfnt.Segments to relative segments.
+	for _, fseg := range segs {
+		nargs := 1
+		switch fseg.Op {
+		case sfnt.SegmentOpQuadTo:
+			nargs = 2
+		case sfnt.SegmentOpCubeTo:
+			nargs = 3
+		}
+		var args [3]f32.Point
+		for i := 0; i < nargs; i++ {
+			a := f32.Point{
+				X: float32(fseg.Args[i].X) / 64,
+				Y: float32(fseg.Args[i].Y) / 64,
+			}
+			args[i] = a.Sub(lastArg)
+			if i == nargs-1 {
+				lastArg = a
+			}
+		}
+		switch fseg.Op {
+		case sfnt.SegmentOpMoveTo:
+			builder.Move(args[0])
+		case sfnt.SegmentOpLineTo:
+			builder.Line(args[0])
+		case sfnt.SegmentOpQuadTo:
+			builder.Quad(args[0], args[1])
+		case sfnt.SegmentOpCubeTo:
+			builder.Cube(args[0], args[1], args[2])
+		default:
+			panic("unsupported segment op")
+		}
+	}
+	return lastPos.Add(lastArg)
 }
 
 func readGlyphs(r io.Reader) ([]glyph, error) {
@@ -359,34 +878,14 @@ func readGlyphs(r io.Reader) ([]glyph, error) {
 	return glyphs, nil
 }
 
-func (f *opentype) HasGlyph(buf *sfnt.Buffer, r rune) bool {
-	g, err := f.Font.GlyphIndex(buf, r)
-	return g != 0 && err == nil
+// supports reports whether r is in the script of the font.
+func (f *opentype) supports(r rune) bool {
+	return f.Script == nil || unicode.In(r, f.Script, unicode.Common, unicode.Inherited)
 }
 
-func (f *opentype) GlyphAdvance(buf *sfnt.Buffer, ppem fixed.Int26_6, r rune) (advance fixed.Int26_6, ok bool) {
+func (f *opentype) HasGlyph(buf *sfnt.Buffer, r rune) bool {
 	g, err := f.Font.GlyphIndex(buf, r)
-	if err != nil {
-		return 0, false
-	}
-	adv, err := f.Font.GlyphAdvance(buf, g, ppem, f.Hinting)
-	return adv, err == nil
-}
-
-func (f *opentype) Kern(buf *sfnt.Buffer, ppem fixed.Int26_6, r0, r1 rune) fixed.Int26_6 {
-	g0, err := f.Font.GlyphIndex(buf, r0)
-	if err != nil {
-		return 0
-	}
-	g1, err := f.Font.GlyphIndex(buf, r1)
-	if err != nil {
-		return 0
-	}
-	adv, err := f.Font.Kern(buf, g0, g1, ppem, f.Hinting)
-	if err != nil {
-		return 0
-	}
-	return adv
+	return g != 0 && err == nil
 }
 
 func (f *opentype) Metrics(buf *sfnt.Buffer, ppem fixed.Int26_6) font.Metrics {
@@ -399,14 +898,26 @@ func (f *opentype) Bounds(buf *sfnt.Buffer, ppem fixed.Int26_6) fixed.Rectangle2
 	return r
 }
 
-func (f *opentype) LoadGlyph(buf *sfnt.Buffer, ppem fixed.Int26_6, r rune) ([]sfnt.Segment, bool) {
-	g, err := f.Font.GlyphIndex(buf, r)
-	if err != nil {
-		return nil, false
+// glyphAdvance returns the advance of a glyph of the font instance.
+func (f *opentype) glyphAdvance(buf *sfnt.Buffer, id sfnt.GlyphIndex, ppem fixed.Int26_6) (fixed.Int26_6, error) {
+	if f.Vary == nil {
+		return f.Font.GlyphAdvance(buf, id, ppem, f.Hinting)
 	}
-	segs, err := f.Font.LoadGlyph(buf, g, ppem, nil)
+	adv, err := f.Font.GlyphAdvance(buf, id, ppem, font.HintingNone)
 	if err != nil {
-		return nil, false
+		return 0, err
+	}
+	adv += fixed.Int26_6(f.Vary.advanceDelta(id) * float32(ppem) / f.Vary.upem)
+	if f.Hinting != font.HintingNone {
+		adv = (adv + 32) &^ 63
+	}
+	return adv, nil
+}
+
+// loadGlyph returns the outline of a glyph of the font instance.
+func (f *opentype) loadGlyph(buf *sfnt.Buffer, id sfnt.GlyphIndex, ppem fixed.Int26_6) ([]sfnt.Segment, error) {
+	if f.Vary == nil {
+		return f.Font.LoadGlyph(buf, id, ppem, nil)
 	}
-	return segs, true
+	return f.Vary.loadGlyph(id, ppem), nil
 }
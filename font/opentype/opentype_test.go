@@ -5,10 +5,12 @@ import (
 	"compress/gzip"
 	"encoding/binary"
 	"fmt"
+	"image"
 	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
+	"unicode"
 
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/gofont/goregular"
@@ -211,3 +213,627 @@ func areShapesEqual(shape1, shape2 op.CallOp) bool {
 	}
 	return true
 }
+
+func TestShaping(t *testing.T) {
+	fnt, _, err := decompressFontFile("testdata/shaping.ttf.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The test font has 1000 units per em, so a ppem of 1000
+	// maps font units to pixels.
+	ppem := fixed.I(1000)
+	tests := []struct {
+		name   string
+		txt    string
+		glyphs []text.GlyphID
+		advs   []int
+	}{
+		{name: "no features", txt: "if", glyphs: []text.GlyphID{3, 2}, advs: []int{250, 300}},
+		{name: "ligature", txt: "fi", glyphs: []text.GlyphID{4}, advs: []int{520}},
+		{name: "kerning", txt: "AV", glyphs: []text.GlyphID{5, 6}, advs: []int{520, 600}},
+		{name: "contextual alternate", txt: "VA", glyphs: []text.GlyphID{6, 8}, advs: []int{600, 620}},
+		{name: "mark", txt: "Á", glyphs: []text.GlyphID{5, 7}, advs: []int{600, 0}},
+		// Right-to-left glyphs are in visual order.
+		{name: "arabic isolated", txt: "ب", glyphs: []text.GlyphID{9}},
+		{name: "arabic joining", txt: "ببب", glyphs: []text.GlyphID{12, 11, 10}},
+		{name: "arabic right joining", txt: "باب", glyphs: []text.GlyphID{9, 14, 10}},
+		{name: "arabic transparent mark", txt: "بَب", glyphs: []text.GlyphID{12, 10, 22}},
+		{name: "indic pre-base matra", txt: "कि", glyphs: []text.GlyphID{18, 15}},
+		{name: "indic reph", txt: "र्क", glyphs: []text.GlyphID{15, 19}},
+		{name: "indic half form", txt: "क्त", glyphs: []text.GlyphID{20, 21}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines, err := fnt.Layout(ppem, 1e6, strings.NewReader(test.txt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 {
+				t.Fatalf("got %d lines, expected 1", len(lines))
+			}
+			glyphs := lines[0].Layout.Glyphs
+			var got []text.GlyphID
+			for _, g := range glyphs {
+				got = append(got, g.ID)
+			}
+			if !equalGlyphs(got, test.glyphs) {
+				t.Fatalf("got glyphs %v, expected %v", got, test.glyphs)
+			}
+			for i, adv := range test.advs {
+				if got := glyphs[i].Advance; got != fixed.I(adv) {
+					t.Errorf("glyph %d: got advance %v, expected %v", i, got, fixed.I(adv))
+				}
+			}
+		})
+	}
+}
+
+func TestShapingMarkOffset(t *testing.T) {
+	fnt, _, err := decompressFontFile("testdata/shaping.ttf.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err := fnt.Layout(fixed.I(1000), 1e6, strings.NewReader("Á"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := lines[0].Layout
+	// The anchors of the acute accent align it 200 units right
+	// of and 200 units above the origin of the A.
+	want := fixed.Point26_6{X: fixed.I(200 - 600), Y: fixed.I(-200)}
+	if got := l.Glyphs[1].Offset; got != want {
+		t.Errorf("got mark offset %v, expected %v", got, want)
+	}
+	if got, want := l.Glyphs[1].Cluster, 1; got != want {
+		t.Errorf("got mark cluster %d, expected %d", got, want)
+	}
+}
+
+func TestShapingClusters(t *testing.T) {
+	fnt, _, err := decompressFontFile("testdata/shaping.ttf.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err := fnt.Layout(fixed.I(1000), 1e6, strings.NewReader("fiकि"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := lines[0].Layout
+	var clusters []int
+	for _, g := range l.Glyphs {
+		clusters = append(clusters, g.Cluster)
+	}
+	// The ligature covers both runes and the reordered matra
+	// belongs to the syllable of its consonant.
+	if want := []int{0, 2, 2}; fmt.Sprint(clusters) != fmt.Sprint(want) {
+		t.Errorf("got clusters %v, expected %v", clusters, want)
+	}
+	// The advance of the ligature is split among its runes.
+	if got, want := l.Advances[0]+l.Advances[1], fixed.I(520); got != want {
+		t.Errorf("got ligature advances %v, expected %v", got, want)
+	}
+	if len(l.Advances) != 4 {
+		t.Errorf("got %d advances, expected 4", len(l.Advances))
+	}
+}
+
+func TestBidiRuns(t *testing.T) {
+	fnt, _, err := decompressFontFile("testdata/shaping.ttf.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		txt    string
+		dir    text.Direction
+		runs   []text.Run
+		glyphs []text.GlyphID
+	}{
+		{
+			txt:    "fA",
+			dir:    text.LTR,
+			runs:   []text.Run{{Direction: text.LTR, Start: 0, End: 2}},
+			glyphs: []text.GlyphID{2, 5},
+		},
+		{
+			txt: "A بب",
+			dir: text.LTR,
+			runs: []text.Run{
+				{Direction: text.LTR, Start: 0, End: 2},
+				{Direction: text.RTL, Start: 2, End: 6},
+			},
+			glyphs: []text.GlyphID{5, 1, 12, 10},
+		},
+		{
+			txt: "بب A",
+			dir: text.RTL,
+			runs: []text.Run{
+				{Direction: text.LTR, Start: 5, End: 6},
+				{Direction: text.RTL, Start: 0, End: 5},
+			},
+			glyphs: []text.GlyphID{5, 1, 12, 10},
+		},
+	}
+	for _, test := range tests {
+		lines, err := fnt.Layout(fixed.I(1000), 1e6, strings.NewReader(test.txt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := lines[0]
+		if l.Direction != test.dir {
+			t.Errorf("%q: got direction %v, expected %v", test.txt, l.Direction, test.dir)
+		}
+		if fmt.Sprint(l.Runs) != fmt.Sprint(test.runs) {
+			t.Errorf("%q: got runs %v, expected %v", test.txt, l.Runs, test.runs)
+		}
+		var glyphs []text.GlyphID
+		for _, g := range l.Layout.Glyphs {
+			glyphs = append(glyphs, g.ID)
+		}
+		if !equalGlyphs(glyphs, test.glyphs) {
+			t.Errorf("%q: got glyphs %v, expected %v", test.txt, glyphs, test.glyphs)
+		}
+	}
+}
+
+func equalGlyphs(a, b []text.GlyphID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLineBreaking(t *testing.T) {
+	face, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppem := fixed.I(20)
+	tests := []struct {
+		txt   string
+		width string
+		lines []string
+	}{
+		// Break between ideographs.
+		{txt: "日本語日本語", width: "日本語", lines: []string{"日本語", "日本語"}},
+		// Break after the solidus of URLs.
+		{txt: "https://example.com/path", width: "https://example", lines: []string{"https://", "example.com/", "path"}},
+		// Break after hyphens.
+		{txt: "well-known", width: "well-kn", lines: []string{"well-", "known"}},
+		// Don't break before closing punctuation.
+		{txt: "a (b)", width: "a (b", lines: []string{"a ", "(b)"}},
+		// Mandatory breaks.
+		{txt: "a\u2028b", width: "a\u2028b", lines: []string{"a\u2028", "b"}},
+	}
+	for _, test := range tests {
+		lines, err := face.Layout(ppem, 1e6, strings.NewReader(test.width))
+		if err != nil {
+			t.Fatal(err)
+		}
+		maxWidth := lines[0].Width.Ceil()
+		lines, err = face.Layout(ppem, maxWidth, strings.NewReader(test.txt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, l := range lines {
+			got = append(got, l.Layout.Text)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.lines) {
+			t.Errorf("%q: got lines %q, expected %q", test.txt, got, test.lines)
+		}
+	}
+}
+
+type testHyphenator []int
+
+func (h testHyphenator) Hyphenate(word string) []int {
+	return h
+}
+
+func TestHyphenation(t *testing.T) {
+	face, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppem := fixed.I(20)
+	lines, err := face.Layout(ppem, 1e6, strings.NewReader("a hyphen-"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxWidth := lines[0].Width.Ceil()
+	// "hyphenation" may be broken after "hy" and "hyphen".
+	opts := text.LayoutOptions{Hyphenator: &testHyphenator{2, 6}}
+	lines, err = face.LayoutWithOptions(ppem, maxWidth, opts, strings.NewReader("a hyphenation"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, expected 2", len(lines))
+	}
+	l := lines[0]
+	if got, want := l.Layout.Text, "a hyphen"; got != want {
+		t.Errorf("got first line %q, expected %q", got, want)
+	}
+	if got, want := lines[1].Layout.Text, "ation"; got != want {
+		t.Errorf("got second line %q, expected %q", got, want)
+	}
+	glyphs := l.Layout.Glyphs
+	last := glyphs[len(glyphs)-1]
+	if last.Cluster != len(l.Layout.Text) {
+		t.Errorf("got hyphen cluster %d, expected %d", last.Cluster, len(l.Layout.Text))
+	}
+	if l.Width > fixed.I(maxWidth) {
+		t.Errorf("hyphenated line width %v exceeds %v", l.Width, fixed.I(maxWidth))
+	}
+	// Without hyphenation, the word moves to the next line.
+	lines, err = face.Layout(ppem, maxWidth, strings.NewReader("a hyphenation"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lines[0].Layout.Text, "a "; got != want {
+		t.Errorf("got unhyphenated first line %q, expected %q", got, want)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	face, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppem := fixed.I(20)
+	for _, str := range []string{"", "a few words\nof text", "a\tb c", "abc אבג def"} {
+		for _, maxWidth := range []int{1e6, 60, 1} {
+			lines, err := face.Layout(ppem, maxWidth, strings.NewReader(str))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want text.Measurement
+			for i, l := range lines {
+				if i == 0 {
+					want.Baseline = l.Ascent
+				}
+				if l.Width > want.Width {
+					want.Width = l.Width
+				}
+				want.Height += l.Ascent + l.Descent
+			}
+			want.Lines = len(lines)
+			got, err := face.Measure(ppem, maxWidth, strings.NewReader(str))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("%q at width %d: got %+v, expected %+v", str, maxWidth, got, want)
+			}
+		}
+	}
+}
+
+func TestTabStops(t *testing.T) {
+	face, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppem := fixed.I(20)
+	// tabEnd returns the position of the text following the last
+	// tab of a line.
+	tabEnd := func(l text.Layout) fixed.Int26_6 {
+		var x fixed.Int26_6
+		for i, adv := range l.Advances {
+			x += adv
+			if strings.LastIndexByte(l.Text, '\t') == i {
+				break
+			}
+		}
+		// The glyph advances must agree with the rune advances.
+		var gx, ax fixed.Int26_6
+		for _, g := range l.Glyphs {
+			gx += g.Advance
+		}
+		for _, adv := range l.Advances {
+			ax += adv
+		}
+		if gx != ax {
+			t.Errorf("%q: glyph advances %v don't match rune advances %v", l.Text, gx, ax)
+		}
+		return x
+	}
+	tabX := func(opts text.LayoutOptions, str string) fixed.Int26_6 {
+		lines, err := face.LayoutWithOptions(ppem, 1e6, opts, strings.NewReader(str))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tabEnd(lines[0].Layout)
+	}
+	opts := text.LayoutOptions{
+		TabStops: []fixed.Int26_6{fixed.I(100), fixed.I(150)},
+		TabWidth: fixed.I(200),
+	}
+	tests := []struct {
+		str  string
+		want fixed.Int26_6
+	}{
+		{"\tx", fixed.I(100)},
+		{"ab\tx", fixed.I(100)},
+		{"\t\tx", fixed.I(150)},
+		{"\t\t\tx", fixed.I(350)},
+		{"\t\t\t\tx", fixed.I(550)},
+	}
+	for _, test := range tests {
+		if got := tabX(opts, test.str); got != test.want {
+			t.Errorf("%q: got tab position %v, expected %v", test.str, got, test.want)
+		}
+	}
+	// The default tab width is 8 spaces.
+	width := func(str string) fixed.Int26_6 {
+		lines, err := face.Layout(ppem, 1e6, strings.NewReader(str))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lines[0].Width
+	}
+	stop := text.LayoutOptions{TabStops: []fixed.Int26_6{fixed.I(1)}}
+	if got, want := tabX(stop, "a\tx"), fixed.I(1)+8*width(" "); got != want {
+		t.Errorf("got default tab position %v, expected %v", got, want)
+	}
+	// Without tab options, tabs have the advance of their glyph.
+	if got, want := tabX(text.LayoutOptions{}, "a\tx"), width("a")+width("\t"); got != want {
+		t.Errorf("got tab position %v without options, expected %v", got, want)
+	}
+	// Tabs that can't move to the next line are clamped to the line
+	// width.
+	for _, str := range []string{"\t", "aaaa\t"} {
+		lines, err := face.LayoutWithOptions(ppem, 90, opts, strings.NewReader(str))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range lines {
+			if l.Width > fixed.I(90) {
+				t.Errorf("%q: line %q is %v wide, expected at most %v", str, l.Layout.Text, l.Width, fixed.I(90))
+			}
+		}
+	}
+	// Tab stops are relative to the start of each line.
+	lines, err := face.LayoutWithOptions(ppem, 120, opts, strings.NewReader("a\tb\tc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, expected 2", len(lines))
+	}
+	if got, want := lines[1].Layout.Text, "b\tc"; got != want {
+		t.Errorf("got second line %q, expected %q", got, want)
+	}
+	if got, want := tabEnd(lines[1].Layout), fixed.I(100); got != want {
+		t.Errorf("got wrapped tab position %v, expected %v", got, want)
+	}
+}
+
+func TestSpacing(t *testing.T) {
+	face, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppem := fixed.I(20)
+	const str = "ab cd ef"
+	width := func(opts text.LayoutOptions, maxWidth int) []text.Line {
+		lines, err := face.LayoutWithOptions(ppem, maxWidth, opts, strings.NewReader(str))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lines
+	}
+	plain := width(text.LayoutOptions{}, 1e6)[0]
+	opts := text.LayoutOptions{LetterSpacing: fixed.I(2), WordSpacing: fixed.I(5)}
+	spaced := width(opts, 1e6)[0]
+	// 8 clusters and 2 spaces.
+	if got, want := spaced.Width, plain.Width+8*fixed.I(2)+2*fixed.I(5); got != want {
+		t.Errorf("got spaced width %v, expected %v", got, want)
+	}
+	var gx fixed.Int26_6
+	for _, g := range spaced.Layout.Glyphs {
+		gx += g.Advance
+	}
+	if gx != spaced.Width {
+		t.Errorf("got glyph advances %v, expected %v", gx, spaced.Width)
+	}
+	// Spacing is accounted for when breaking lines.
+	maxWidth := plain.Width.Ceil()
+	if n := len(width(text.LayoutOptions{}, maxWidth)); n != 1 {
+		t.Errorf("got %d unspaced lines, expected 1", n)
+	}
+	for _, l := range width(opts, maxWidth) {
+		if l.Width > fixed.I(maxWidth) && len(l.Layout.Text) > 3 {
+			t.Errorf("spaced line %q of width %v exceeds %v", l.Layout.Text, l.Width, fixed.I(maxWidth))
+		}
+	}
+	if n := len(width(opts, maxWidth)); n < 2 {
+		t.Errorf("got %d spaced lines, expected at least 2", n)
+	}
+}
+
+func TestGraphemeClusters(t *testing.T) {
+	face, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppem := fixed.I(20)
+	lines, err := face.Layout(ppem, 1e6, strings.NewReader("ae\u0301x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := lines[0].Layout
+	want := []text.Cluster{
+		{Start: 0, End: 1},
+		{Start: 1, End: 4},
+		{Start: 4, End: 5},
+	}
+	if len(l.Clusters) != len(want) {
+		t.Fatalf("got %d clusters, expected %d", len(l.Clusters), len(want))
+	}
+	for i, c := range l.Clusters {
+		if c.Start != want[i].Start || c.End != want[i].End {
+			t.Errorf("cluster %d: got [%d,%d), expected [%d,%d)", i, c.Start, c.End, want[i].Start, want[i].End)
+		}
+	}
+	if got, want := l.Clusters[1].Advance, l.Advances[1]+l.Advances[2]; got != want {
+		t.Errorf("got cluster advance %v, expected %v", got, want)
+	}
+	// Lines without break opportunities are broken between
+	// clusters.
+	lines, err = face.Layout(ppem, 1e6, strings.NewReader("ae\u0301"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxWidth := lines[0].Width.Ceil()
+	lines, err = face.Layout(ppem, maxWidth, strings.NewReader("ae\u0301e\u0301"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range lines {
+		got = append(got, l.Layout.Text)
+	}
+	if exp := []string{"ae\u0301", "e\u0301"}; fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Errorf("got lines %q, expected %q", got, exp)
+	}
+}
+
+func TestCollectionScriptFallback(t *testing.T) {
+	primary, _, err := decompressFontFile("testdata/shaping.ttf.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallback, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCollection([]Fallback{
+		{Font: primary},
+		{Font: fallback, Script: unicode.Greek},
+	})
+	var buf sfnt.Buffer
+	tests := []struct {
+		r    rune
+		font int
+	}{
+		{'A', 0},
+		{'α', 1},
+		// Cyrillic is not in the script of the fallback font.
+		{'д', 0},
+		// Digits are common to all scripts.
+		{'1', 1},
+	}
+	for _, test := range tests {
+		if got := fontForGlyph(&buf, c.fonts, test.r); got != test.font {
+			t.Errorf("%q: got font %d, expected %d", test.r, got, test.font)
+		}
+	}
+}
+
+func TestRasterize(t *testing.T) {
+	fnt, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := fnt.opentype()
+	var buf sfnt.Buffer
+	id, err := l.Font.GlyphIndex(&buf, 'l')
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppem := fixed.I(20)
+	mask, origin := fnt.Rasterize(ppem, text.GlyphID(id), fixed.Point26_6{})
+	if mask == nil {
+		t.Fatal("no mask for 'l'")
+	}
+	// The stem of 'l' stands on the baseline.
+	size := mask.Bounds().Size()
+	if bottom := origin.Y + size.Y; bottom < 0 || bottom > 1 || size.Y < 10 || size.X > 5 {
+		t.Errorf("got mask at %v of size %v", origin, size)
+	}
+	var coverage int
+	for _, a := range mask.Pix {
+		coverage += int(a)
+	}
+	if coverage == 0 {
+		t.Error("empty mask")
+	}
+	// A subpixel offset moves the coverage right.
+	shifted, sorigin := fnt.Rasterize(ppem, text.GlyphID(id), fixed.Point26_6{X: 32})
+	center := func(m *image.Alpha, o image.Point) float64 {
+		var sum, n float64
+		for y := 0; y < m.Rect.Dy(); y++ {
+			for x := 0; x < m.Rect.Dx(); x++ {
+				a := float64(m.AlphaAt(x, y).A)
+				sum += a * float64(x+o.X)
+				n += a
+			}
+		}
+		return sum / n
+	}
+	if d := center(shifted, sorigin) - center(mask, origin); d < .4 || d > .6 {
+		t.Errorf("offset moved coverage %v pixels, expected .5", d)
+	}
+	space, err := l.Font.GlyphIndex(&buf, ' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mask, _ := fnt.Rasterize(ppem, text.GlyphID(space), fixed.Point26_6{}); mask != nil {
+		t.Error("got mask for space")
+	}
+}
+
+func TestDecorations(t *testing.T) {
+	fnt, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post, os2 := findTable(goregular.TTF, "post"), findTable(goregular.TTF, "OS/2")
+	// Use one pixel per font unit.
+	ppem := fixed.I(int(fnt.font.UnitsPerEm()))
+	got := fnt.Decorations(ppem)
+	want := text.DecorationMetrics{
+		UnderlinePosition:  fixed.I(-int(post.i16(8))),
+		UnderlineThickness: fixed.I(int(post.i16(10))),
+		StrikeoutPosition:  fixed.I(-int(os2.i16(28))),
+		StrikeoutThickness: fixed.I(int(os2.i16(26))),
+	}
+	if got != want {
+		t.Errorf("got metrics %+v, expected %+v", got, want)
+	}
+	if got.UnderlinePosition <= 0 || got.UnderlineThickness <= 0 || got.StrikeoutPosition >= 0 {
+		t.Errorf("implausible metrics %+v", got)
+	}
+}
+
+func TestInkExtents(t *testing.T) {
+	fnt, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppem := fixed.I(20)
+	l, err := fnt.Layout(ppem, 1000, strings.NewReader("xgx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adv := l[0].Layout.Advances
+	// The band below the baseline only crosses the descender of 'g'.
+	got := fnt.InkExtents(ppem, l[0].Layout, fixed.I(2), fixed.I(3))
+	if len(got) != 1 {
+		t.Fatalf("got extents %v, expected 1", got)
+	}
+	if e := got[0]; e.Start < adv[0] || e.End > adv[0]+adv[1] || e.End-e.Start < fixed.I(4) {
+		t.Errorf("got extent %v outside of 'g' at [%v,%v]", e, adv[0], adv[0]+adv[1])
+	}
+	// The band at the x-height crosses every glyph.
+	got = fnt.InkExtents(ppem, l[0].Layout, -fixed.I(5), -fixed.I(4))
+	if len(got) != 3 {
+		t.Errorf("got extents %v, expected 3", got)
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"image"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+
+	"github.com/cybriq/giocore/text"
+)
+
+// Rasterize implements text.RasterFace.
+func (f *Font) Rasterize(ppem fixed.Int26_6, id text.GlyphID, offset fixed.Point26_6) (*image.Alpha, image.Point) {
+	var buf sfnt.Buffer
+	return rasterize(&buf, ppem, []*opentype{f.opentype()}, id, offset)
+}
+
+// Rasterize implements text.RasterFace.
+func (c *Collection) Rasterize(ppem fixed.Int26_6, id text.GlyphID, offset fixed.Point26_6) (*image.Alpha, image.Point) {
+	var buf sfnt.Buffer
+	return rasterize(&buf, ppem, c.fonts, id, offset)
+}
+
+// rasterize returns the coverage of a glyph drawn with its pen
+// position at offset, and the position of the mask relative to the
+// origin. Color glyphs and glyphs without outline have no mask.
+func rasterize(buf *sfnt.Buffer, ppem fixed.Int26_6, fonts []*opentype, id text.GlyphID, offset fixed.Point26_6) (*image.Alpha, image.Point) {
+	fi := id.Font()
+	if fi >= len(fonts) {
+		return nil, image.Point{}
+	}
+	f := fonts[fi]
+	gid := sfnt.GlyphIndex(id.Index())
+	if f.Tables.hasColorGlyph(gid, ppem) {
+		return nil, image.Point{}
+	}
+	segs, err := f.loadGlyph(buf, gid, ppem)
+	if err != nil || len(segs) == 0 {
+		return nil, image.Point{}
+	}
+	b := fixed.Rectangle26_6{Min: segs[0].Args[0], Max: segs[0].Args[0]}
+	for _, seg := range segs {
+		for _, p := range seg.Args[:segmentArgs(seg.Op)] {
+			if p.X < b.Min.X {
+				b.Min.X = p.X
+			}
+			if p.Y < b.Min.Y {
+				b.Min.Y = p.Y
+			}
+			if p.X > b.Max.X {
+				b.Max.X = p.X
+			}
+			if p.Y > b.Max.Y {
+				b.Max.Y = p.Y
+			}
+		}
+	}
+	b = b.Add(offset)
+	r := image.Rect(b.Min.X.Floor(), b.Min.Y.Floor(), b.Max.X.Ceil(), b.Max.Y.Ceil())
+	if r.Empty() {
+		return nil, image.Point{}
+	}
+	z := vector.NewRasterizer(r.Dx(), r.Dy())
+	// Translate the glyph to the mask.
+	origin := offset.Sub(fixed.Point26_6{X: fixed.I(r.Min.X), Y: fixed.I(r.Min.Y)})
+	pt := func(p fixed.Point26_6) (float32, float32) {
+		p = p.Add(origin)
+		return float32(p.X) / 64, float32(p.Y) / 64
+	}
+	for i, seg := range segs {
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			if i > 0 {
+				z.ClosePath()
+			}
+			z.MoveTo(pt(seg.Args[0]))
+		case sfnt.SegmentOpLineTo:
+			z.LineTo(pt(seg.Args[0]))
+		case sfnt.SegmentOpQuadTo:
+			bx, by := pt(seg.Args[0])
+			cx, cy := pt(seg.Args[1])
+			z.QuadTo(bx, by, cx, cy)
+		case sfnt.SegmentOpCubeTo:
+			bx, by := pt(seg.Args[0])
+			cx, cy := pt(seg.Args[1])
+			dx, dy := pt(seg.Args[2])
+			z.CubeTo(bx, by, cx, cy, dx, dy)
+		}
+	}
+	z.ClosePath()
+	mask := image.NewAlpha(image.Rectangle{Max: r.Size()})
+	z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	return mask, r.Min
+}
+
+// segmentArgs returns the number of points of a segment.
+func segmentArgs(op sfnt.SegmentOp) int {
+	switch op {
+	case sfnt.SegmentOpQuadTo:
+		return 2
+	case sfnt.SegmentOpCubeTo:
+		return 3
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,550 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"sort"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/internal/bidi"
+	"github.com/cybriq/giocore/text"
+)
+
+// shaper converts runs of runes to positioned glyphs, applying the
+// GSUB and GPOS features of a font.
+type shaper struct {
+	buf  *sfnt.Buffer
+	ppem fixed.Int26_6
+	font *opentype
+	plan *plan
+	// glyphs and pos are the glyphs of the run being shaped.
+	glyphs []glyphInfo
+	pos    []glyphPos
+	// nesting is the depth of nested contextual lookups.
+	nesting int
+}
+
+// glyphInfo is a glyph in the shaping buffer.
+type glyphInfo struct {
+	id sfnt.GlyphIndex
+	// cluster is the index of the first rune that maps to the glyph.
+	cluster int
+	// mask selects the features that apply to the glyph.
+	mask  uint32
+	class glyphClass
+	// ligated is set for glyphs formed by ligature substitution.
+	ligated bool
+	// ligComp is the ligature component a mark follows, counted
+	// from 1, or 0 for marks after the last component.
+	ligComp int
+	// syllable is the Indic syllable of the glyph, counted from 1.
+	syllable int
+	indicPos indicPosition
+}
+
+// glyphPos is the position of a glyph in the shaping buffer.
+type glyphPos struct {
+	adv fixed.Int26_6
+	// off is the displacement of the glyph from its pen position,
+	// with positive Y pointing up.
+	off fixed.Point26_6
+	// attach is the relative index of the glyph a mark is attached
+	// to, or zero.
+	attach int
+}
+
+// shapedGlyph is a positioned glyph resulting from shaping.
+type shapedGlyph struct {
+	id text.GlyphID
+	// cluster is the index of the first glyph that maps to the
+	// shaped glyph.
+	cluster int
+	adv     fixed.Int26_6
+	// off is the displacement of the glyph from its pen position,
+	// with positive Y pointing down.
+	off fixed.Point26_6
+	// mark is set for mark glyphs.
+	mark bool
+}
+
+// A feature is an OpenType feature applied during shaping.
+type feature struct {
+	tag uint32
+	// global features apply to every glyph. Other features
+	// apply only to glyphs selected by script specific shaping.
+	global bool
+}
+
+// featureLookup is a lookup and the mask of the features it
+// belongs to.
+type featureLookup struct {
+	index uint16
+	mask  uint32
+}
+
+// stage is a set of GSUB features applied together.
+type stage struct {
+	features []feature
+	// after, if set, is called after the features are applied.
+	after func(s *shaper)
+}
+
+// plan describes the shaping of a script.
+type plan struct {
+	// prepare, if set, prepares the glyphs mapped from the runes
+	// of a run for substitution.
+	prepare func(s *shaper, runes []rune)
+	gsub    []stage
+	gpos    []feature
+	// masks maps feature tags to glyph masks.
+	masks map[uint32]uint32
+}
+
+// scriptInfo describes the shaping of the runes of a Unicode script.
+type scriptInfo struct {
+	runes *unicode.RangeTable
+	// tags lists the OpenType script tags in order of preference.
+	tags []uint32
+	plan *plan
+}
+
+// globalMask is the mask of global features.
+const globalMask = 1
+
+// maxNesting is the maximum depth of nested contextual lookups.
+const maxNesting = 6
+
+var (
+	tagDFLT = otTag("DFLT")
+	tagDflt = otTag("dflt")
+	tagLatn = otTag("latn")
+)
+
+var defaultPlan = newPlan(nil,
+	[]stage{
+		{features: globalFeatures("ccmp", "locl")},
+		{features: globalFeatures("rlig", "rclt", "calt", "liga", "clig")},
+	},
+	globalFeatures("kern", "mark", "mkmk"),
+)
+
+var arabicPlan = newPlan(prepareArabic,
+	[]stage{
+		{features: globalFeatures("ccmp", "locl")},
+		{features: maskedFeatures("isol")},
+		{features: maskedFeatures("fina")},
+		{features: maskedFeatures("medi")},
+		{features: maskedFeatures("init")},
+		{features: globalFeatures("rlig")},
+		{features: globalFeatures("calt", "liga", "clig", "mset")},
+	},
+	globalFeatures("kern", "mark", "mkmk"),
+)
+
+var indicPlan = newPlan(prepareIndic,
+	[]stage{
+		{features: globalFeatures("locl", "ccmp")},
+		{features: globalFeatures("nukt")},
+		{features: globalFeatures("akhn")},
+		{features: maskedFeatures("rphf")},
+		{features: globalFeatures("rkrf")},
+		{features: maskedFeatures("blwf")},
+		{features: maskedFeatures("abvf")},
+		{features: maskedFeatures("half")},
+		{features: maskedFeatures("pstf")},
+		{features: globalFeatures("vatu")},
+		{features: globalFeatures("cjct"), after: reorderIndic},
+		{features: globalFeatures("pres", "abvs", "blws", "psts", "haln", "calt", "clig")},
+	},
+	globalFeatures("kern", "dist", "abvm", "blwm", "mark", "mkmk"),
+)
+
+// defaultScript is used for runes of scripts without specific
+// shaping.
+var defaultScript = &scriptInfo{plan: defaultPlan}
+
+var scripts = []*scriptInfo{
+	{runes: unicode.Latin, tags: otTags("latn"), plan: defaultPlan},
+	{runes: unicode.Greek, tags: otTags("grek"), plan: defaultPlan},
+	{runes: unicode.Cyrillic, tags: otTags("cyrl"), plan: defaultPlan},
+	{runes: unicode.Armenian, tags: otTags("armn"), plan: defaultPlan},
+	{runes: unicode.Hebrew, tags: otTags("hebr"), plan: defaultPlan},
+	{runes: unicode.Arabic, tags: otTags("arab"), plan: arabicPlan},
+	{runes: unicode.Devanagari, tags: otTags("dev2", "deva"), plan: indicPlan},
+	{runes: unicode.Bengali, tags: otTags("bng2", "beng"), plan: indicPlan},
+	{runes: unicode.Gurmukhi, tags: otTags("gur2", "guru"), plan: indicPlan},
+	{runes: unicode.Gujarati, tags: otTags("gjr2", "gujr"), plan: indicPlan},
+	{runes: unicode.Oriya, tags: otTags("ory2", "orya"), plan: indicPlan},
+	{runes: unicode.Tamil, tags: otTags("tml2", "taml"), plan: indicPlan},
+	{runes: unicode.Telugu, tags: otTags("tel2", "telu"), plan: indicPlan},
+	{runes: unicode.Kannada, tags: otTags("knd2", "knda"), plan: indicPlan},
+	{runes: unicode.Malayalam, tags: otTags("mlm2", "mlym"), plan: indicPlan},
+	{runes: unicode.Thai, tags: otTags("thai"), plan: defaultPlan},
+	{runes: unicode.Hangul, tags: otTags("hang"), plan: defaultPlan},
+	{runes: unicode.Hiragana, tags: otTags("kana"), plan: defaultPlan},
+	{runes: unicode.Katakana, tags: otTags("kana"), plan: defaultPlan},
+	{runes: unicode.Han, tags: otTags("hani"), plan: defaultPlan},
+}
+
+func newPlan(prepare func(s *shaper, runes []rune), gsub []stage, gpos []feature) *plan {
+	p := &plan{
+		prepare: prepare,
+		gsub:    gsub,
+		gpos:    gpos,
+		masks:   make(map[uint32]uint32),
+	}
+	bit := uint(1)
+	for _, st := range gsub {
+		for _, f := range st.features {
+			if f.global {
+				p.masks[f.tag] = globalMask
+			} else {
+				p.masks[f.tag] = 1 << bit
+				bit++
+			}
+		}
+	}
+	for _, f := range gpos {
+		p.masks[f.tag] = globalMask
+	}
+	return p
+}
+
+func globalFeatures(tags ...string) []feature {
+	var features []feature
+	for _, t := range tags {
+		features = append(features, feature{tag: otTag(t), global: true})
+	}
+	return features
+}
+
+func maskedFeatures(tags ...string) []feature {
+	var features []feature
+	for _, t := range tags {
+		features = append(features, feature{tag: otTag(t)})
+	}
+	return features
+}
+
+// otTag converts a 4 letter OpenType tag to its numeric form.
+func otTag(t string) uint32 {
+	return uint32(t[0])<<24 | uint32(t[1])<<16 | uint32(t[2])<<8 | uint32(t[3])
+}
+
+func otTags(tags ...string) []uint32 {
+	res := make([]uint32, len(tags))
+	for i, t := range tags {
+		res[i] = otTag(t)
+	}
+	return res
+}
+
+func sortLookups(lookups []featureLookup) {
+	sort.Slice(lookups, func(i, j int) bool {
+		return lookups[i].index < lookups[j].index
+	})
+}
+
+// scriptOf returns the script of r, or nil if r is common to
+// several scripts.
+func scriptOf(r rune) *scriptInfo {
+	if unicode.In(r, unicode.Common, unicode.Inherited) {
+		return nil
+	}
+	for _, s := range scripts {
+		if unicode.Is(s.runes, r) {
+			return s
+		}
+	}
+	return defaultScript
+}
+
+// shapeText shapes a text using the first font in fonts that
+// supports each rune. It sets the advances and fonts of glyphs
+// and returns the shaped glyphs in logical order. Runes in
+// right-to-left runs are mirrored.
+func shapeText(sbuf *sfnt.Buffer, ppem fixed.Int26_6, fonts []*opentype, glyphs []glyph) []shapedGlyph {
+	if len(fonts) == 0 {
+		return nil
+	}
+	runes := make([]rune, len(glyphs))
+	scripts := make([]*scriptInfo, len(glyphs))
+	var sc *scriptInfo
+	for i := range glyphs {
+		g := &glyphs[i]
+		runes[i] = g.Rune
+		if g.Level.RTL() {
+			runes[i] = bidi.Mirror(g.Rune)
+		}
+		g.Font = fontForGlyph(sbuf, fonts, g.Rune)
+		g.Advance = 0
+		if s := scriptOf(g.Rune); s != nil {
+			sc = s
+		}
+		scripts[i] = sc
+	}
+	// Leading common runes belong to the script that follows them.
+	sc = defaultScript
+	for i := len(scripts) - 1; i >= 0; i-- {
+		if scripts[i] == nil {
+			scripts[i] = sc
+		}
+		sc = scripts[i]
+	}
+	s := &shaper{buf: sbuf, ppem: ppem}
+	var shaped []shapedGlyph
+	for start := 0; start < len(glyphs); {
+		if glyphs[start].Rune == '\n' {
+			start++
+			continue
+		}
+		end := start + 1
+		for end < len(glyphs) && glyphs[end].Rune != '\n' &&
+			glyphs[end].Font == glyphs[start].Font && scripts[end] == scripts[start] &&
+			glyphs[end].Level == glyphs[start].Level {
+			end++
+		}
+		f := glyphs[start].Font
+		s.font = fonts[f]
+		s.shape(runes[start:end], start, scripts[start])
+		for i, g := range s.glyphs {
+			p := s.pos[i]
+			shaped = append(shaped, shapedGlyph{
+				id:      text.NewGlyphID(f, uint16(g.id)),
+				cluster: g.cluster,
+				adv:     p.adv,
+				off:     fixed.Point26_6{X: p.off.X, Y: -p.off.Y},
+				mark:    g.class == classMark,
+			})
+		}
+		s.distribute(glyphs[start:end], start)
+		start = end
+	}
+	return shaped
+}
+
+// distribute the advances of the shaped glyphs to the runes of
+// their clusters.
+func (s *shaper) distribute(glyphs []glyph, start int) {
+	clusters := make([]bool, len(glyphs))
+	for i, g := range s.glyphs {
+		c := g.cluster - start
+		clusters[c] = true
+		glyphs[c].Advance += s.pos[i].adv
+	}
+	for c := 0; c < len(glyphs); {
+		end := c + 1
+		for end < len(glyphs) && !clusters[end] {
+			end++
+		}
+		if n := fixed.Int26_6(end - c); n > 1 {
+			adv := glyphs[c].Advance
+			for i := c; i < end; i++ {
+				glyphs[i].Advance = adv / n
+			}
+			glyphs[c].Advance += adv - adv/n*n
+		}
+		c = end
+	}
+}
+
+// shape a run of runes from a single script. The first rune
+// is at index start in its text.
+func (s *shaper) shape(runes []rune, start int, sc *scriptInfo) {
+	t := s.font.Tables
+	s.plan = sc.plan
+	s.glyphs = s.glyphs[:0]
+	for i, r := range runes {
+		id, _ := s.font.Font.GlyphIndex(s.buf, r)
+		g := glyphInfo{
+			id:      id,
+			cluster: start + i,
+			mask:    globalMask,
+			class:   t.gdef.glyphClass(id),
+		}
+		if g.class == classUnknown {
+			g.class = classBase
+			if unicode.In(r, unicode.Mn, unicode.Me) {
+				g.class = classMark
+			}
+		}
+		s.glyphs = append(s.glyphs, g)
+	}
+	if s.plan.prepare != nil {
+		s.plan.prepare(s, runes)
+	}
+	var script table
+	if t.gsub != nil {
+		script = t.gsub.script(sc.tags)
+	}
+	for _, st := range s.plan.gsub {
+		if script != nil {
+			for _, l := range t.gsub.lookups(script, st.features, s.plan.masks) {
+				s.applyLookup(t.gsub, l)
+			}
+		}
+		if st.after != nil {
+			st.after(s)
+		}
+	}
+	s.position(sc)
+}
+
+// position computes the positions of the shaped glyphs.
+func (s *shaper) position(sc *scriptInfo) {
+	t := s.font.Tables
+	s.pos = s.pos[:0]
+	for _, g := range s.glyphs {
+		adv, err := s.font.glyphAdvance(s.buf, g.id, s.ppem)
+		if err != nil {
+			adv = 0
+		}
+		s.pos = append(s.pos, glyphPos{adv: adv})
+	}
+	if t.gpos != nil {
+		if script := t.gpos.script(sc.tags); script != nil {
+			for _, l := range t.gpos.lookups(script, s.plan.gpos, s.plan.masks) {
+				s.applyLookup(t.gpos, l)
+			}
+		}
+	} else {
+		s.kern()
+	}
+	for i, g := range s.glyphs {
+		if g.class == classMark {
+			s.pos[i].adv = 0
+		}
+	}
+	s.resolveAttachments()
+}
+
+// kern applies the kerning of fonts without a GPOS table.
+func (s *shaper) kern() {
+	prev := -1
+	for i, g := range s.glyphs {
+		if g.class == classMark {
+			continue
+		}
+		if prev >= 0 {
+			k, err := s.font.Font.Kern(s.buf, s.glyphs[prev].id, g.id, s.ppem, s.font.Hinting)
+			if err == nil {
+				s.pos[prev].adv += k
+			}
+		}
+		prev = i
+	}
+}
+
+// resolveAttachments converts the offsets of attached marks to
+// be relative to their own pen positions.
+func (s *shaper) resolveAttachments() {
+	for i := range s.pos {
+		p := &s.pos[i]
+		if p.attach == 0 {
+			continue
+		}
+		j := i + p.attach
+		p.off = p.off.Add(s.pos[j].off)
+		for k := j; k < i; k++ {
+			p.off.X -= s.pos[k].adv
+		}
+	}
+}
+
+// scale converts a distance in font units to pixels.
+func (s *shaper) scale(v int) fixed.Int26_6 {
+	upem := int64(s.font.Font.UnitsPerEm())
+	if upem == 0 {
+		return 0
+	}
+	x := fixed.Int26_6(int64(v) * int64(s.ppem) / upem)
+	if s.font.Hinting != font.HintingNone {
+		x = (x + 32) &^ 63
+	}
+	return x
+}
+
+// applyLookup applies a lookup to the glyphs selected by its mask.
+func (s *shaper) applyLookup(t *layoutTable, fl featureLookup) {
+	l := t.lookup(fl.index)
+	for i := 0; i < len(s.glyphs); {
+		if s.glyphs[i].mask&fl.mask == 0 || s.ignored(l, i) {
+			i++
+			continue
+		}
+		if next, ok := s.apply(t, l, i); ok && next > i {
+			i = next
+		} else {
+			i++
+		}
+	}
+}
+
+// apply applies the first subtable of a lookup that matches the
+// glyph at index i. It returns the index following the glyphs
+// affected.
+func (s *shaper) apply(t *layoutTable, l lookup, i int) (int, bool) {
+	for _, st := range l.subtables {
+		var next int
+		var ok bool
+		if t.gpos {
+			next, ok = s.applyPos(t, l, st, i)
+		} else {
+			next, ok = s.applySubst(t, l, st, i)
+		}
+		if ok {
+			return next, true
+		}
+	}
+	return 0, false
+}
+
+// ignored reports whether the lookup flags of l skip the glyph at
+// index i.
+func (s *shaper) ignored(l lookup, i int) bool {
+	g := s.glyphs[i]
+	switch g.class {
+	case classBase:
+		return l.flag&lookupIgnoreBaseGlyphs != 0
+	case classLigature:
+		return l.flag&lookupIgnoreLigatures != 0
+	case classMark:
+		if l.flag&lookupIgnoreMarks != 0 {
+			return true
+		}
+		gdef := s.font.Tables.gdef
+		if l.flag&lookupUseMarkFilteringSet != 0 {
+			return !gdef.inMarkSet(l.markSet, g.id)
+		}
+		if typ := (l.flag & lookupMarkAttachmentType) >> 8; typ != 0 {
+			return gdef.markAttach.class(g.id) != typ
+		}
+	}
+	return false
+}
+
+// next returns the index of the first glyph after i not skipped
+// by l, or -1.
+func (s *shaper) next(l lookup, i int) int {
+	for i++; i < len(s.glyphs); i++ {
+		if !s.ignored(l, i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// prev returns the index of the last glyph before i not skipped
+// by l, or -1.
+func (s *shaper) prev(l lookup, i int) int {
+	for i--; i >= 0; i-- {
+		if !s.ignored(l, i) {
+			return i
+		}
+	}
+	return -1
+}
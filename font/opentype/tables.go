@@ -0,0 +1,580 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// tables contains the OpenType layout and color tables of a font,
+// for the features not covered by package sfnt.
+type tables struct {
+	gdef gdefTable
+	gsub *layoutTable
+	gpos *layoutTable
+	// colr and cpal contain the layered color glyphs.
+	colr table
+	cpal table
+	// cblc, cbdt and sbix contain the bitmap glyphs.
+	cblc table
+	cbdt lazyTable
+	sbix *sbixTable
+	// fvar, avar, gvar and HVAR contain the variation data of
+	// variable fonts, and glyf and loca their outlines. Only the
+	// header and offsets of gvar are kept in memory; gvarData
+	// contains the glyph variations.
+	fvar     table
+	avar     table
+	gvar     table
+	gvarData lazyTable
+	hvar     table
+	glyf     lazyTable
+	loca     table
+	// longLoca reports whether the loca table has 32-bit offsets.
+	longLoca bool
+	// underline and strikeout contain the position and thickness of
+	// underlines and strikeout lines from the post and OS/2 tables,
+	// in font units with y pointing up.
+	underline, strikeout [2]int16
+
+	bitmaps bitmapCache
+}
+
+// table is a view of font data. Its accessors return zero for
+// out of range offsets, so that malformed fonts degrade to
+// unshaped text instead of failing.
+type table []byte
+
+// lazyTable is a font table that is read from the font source when
+// needed, for tables too large to keep in memory.
+type lazyTable struct {
+	src    io.ReaderAt
+	off    int64
+	length int
+}
+
+// sbixTable is an sbix table. The sizes and offsets of its strikes
+// are kept in memory.
+type sbixTable struct {
+	data    lazyTable
+	sizes   []int
+	offsets []int
+}
+
+// layoutTable is a GSUB or GPOS table.
+type layoutTable struct {
+	t           table
+	scriptList  table
+	featureList table
+	lookupList  table
+	// gpos reports whether the table is a GPOS table.
+	gpos bool
+}
+
+// lookup is a GSUB or GPOS lookup table.
+type lookup struct {
+	typ  uint16
+	flag uint16
+	// markSet is the index of the GDEF mark glyph set,
+	// for lookups with the lookupUseMarkFilteringSet flag.
+	markSet   uint16
+	subtables []table
+}
+
+// gdefTable is the glyph definition table.
+type gdefTable struct {
+	classes    table
+	markAttach table
+	markSets   table
+}
+
+type glyphClass uint8
+
+const (
+	classUnknown glyphClass = iota
+	classBase
+	classLigature
+	classMark
+	classComponent
+)
+
+// Lookup flags.
+const (
+	lookupIgnoreBaseGlyphs    = 0x0002
+	lookupIgnoreLigatures     = 0x0004
+	lookupIgnoreMarks         = 0x0008
+	lookupUseMarkFilteringSet = 0x0010
+	lookupMarkAttachmentType  = 0xff00
+)
+
+var errInvalidTables = errors.New("opentype: invalid table directory")
+
+// fontOffsets returns the offsets of the fonts in an SFNT
+// font or collection.
+func fontOffsets(src io.ReaderAt) ([]int64, error) {
+	var hdr [12]byte
+	if _, err := src.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	}
+	if string(hdr[:4]) != "ttcf" {
+		return []int64{0}, nil
+	}
+	n := binary.BigEndian.Uint32(hdr[8:])
+	if n > 0xffff {
+		return nil, errInvalidTables
+	}
+	offs := make([]byte, 4*n)
+	if _, err := src.ReadAt(offs, 12); err != nil {
+		return nil, err
+	}
+	offsets := make([]int64, n)
+	for i := range offsets {
+		offsets[i] = int64(binary.BigEndian.Uint32(offs[4*i:]))
+	}
+	return offsets, nil
+}
+
+// readTables reads the layout, color and variation tables of the
+// font at offset in src.
+func readTables(src io.ReaderAt, offset int64) (*tables, error) {
+	var hdr [12]byte
+	if _, err := src.ReadAt(hdr[:], offset); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint16(hdr[4:]))
+	dir := make([]byte, 16*n)
+	if _, err := src.ReadAt(dir, offset+12); err != nil {
+		return nil, err
+	}
+	// Variable fonts also need the raw outlines, which are not
+	// used for other fonts.
+	variable := false
+	for i := 0; i < n; i++ {
+		if string(dir[16*i:16*i+4]) == "fvar" {
+			variable = true
+		}
+	}
+	t := new(tables)
+	for i := 0; i < n; i++ {
+		rec := dir[16*i:]
+		tag := string(rec[:4])
+		switch tag {
+		case "GDEF", "GSUB", "GPOS", "COLR", "CPAL", "CBLC", "CBDT", "sbix", "post", "OS/2":
+		case "fvar", "avar", "gvar", "HVAR", "glyf", "loca", "head":
+			if !variable {
+				continue
+			}
+		default:
+			continue
+		}
+		off := binary.BigEndian.Uint32(rec[8:])
+		length := binary.BigEndian.Uint32(rec[12:])
+		// Tables larger than 64 MiB are not plausible.
+		if length > 1<<26 {
+			return nil, errInvalidTables
+		}
+		lazy := lazyTable{src: src, off: int64(off), length: int(length)}
+		switch tag {
+		case "CBDT":
+			t.cbdt = lazy
+			continue
+		case "glyf":
+			t.glyf = lazy
+			continue
+		case "sbix":
+			sbix, err := readSbix(lazy)
+			if err != nil {
+				return nil, err
+			}
+			t.sbix = sbix
+			continue
+		case "gvar":
+			gvar, data, err := readGvar(lazy)
+			if err != nil {
+				return nil, err
+			}
+			t.gvar, t.gvarData = gvar, data
+			continue
+		}
+		if (tag == "post" || tag == "OS/2") && length > 32 {
+			// Only the decoration metrics are needed.
+			length = 32
+		}
+		data := make(table, length)
+		if _, err := src.ReadAt(data, int64(off)); err != nil {
+			return nil, err
+		}
+		switch tag {
+		case "GDEF":
+			t.gdef = parseGDEF(data)
+		case "GSUB":
+			t.gsub = newLayoutTable(data, false)
+		case "GPOS":
+			t.gpos = newLayoutTable(data, true)
+		case "COLR":
+			t.colr = data
+		case "CPAL":
+			t.cpal = data
+		case "CBLC":
+			t.cblc = data
+		case "fvar":
+			t.fvar = data
+		case "avar":
+			t.avar = data
+		case "HVAR":
+			t.hvar = data
+		case "loca":
+			t.loca = data
+		case "head":
+			t.longLoca = data.i16(50) != 0
+		case "post":
+			t.underline = [2]int16{data.i16(8), data.i16(10)}
+		case "OS/2":
+			t.strikeout = [2]int16{data.i16(28), data.i16(26)}
+		}
+	}
+	return t, nil
+}
+
+// readSbix reads the strike sizes and offsets of an sbix table.
+func readSbix(t lazyTable) (*sbixTable, error) {
+	hdr, err := t.read(0, 8)
+	if err != nil {
+		return nil, err
+	}
+	n := int(hdr.u32(4))
+	if 8+4*n > t.length {
+		return nil, errInvalidTables
+	}
+	offs, err := t.read(8, 4*n)
+	if err != nil {
+		return nil, err
+	}
+	s := &sbixTable{data: t, sizes: make([]int, n), offsets: make([]int, n)}
+	for i := range s.offsets {
+		off := int(offs.u32(4 * i))
+		ppem, err := t.read(off, 2)
+		if err != nil {
+			return nil, err
+		}
+		s.offsets[i] = off
+		s.sizes[i] = int(ppem.u16(0))
+	}
+	return s, nil
+}
+
+// readGvar reads the header, glyph offsets and shared tuples of a
+// gvar table, and returns them along with the glyph variation data.
+func readGvar(t lazyTable) (table, lazyTable, error) {
+	hdr, err := t.read(0, 20)
+	if err != nil {
+		return nil, lazyTable{}, err
+	}
+	offSize := 2
+	if hdr.u16(14)&1 != 0 {
+		offSize = 4
+	}
+	// The header ends after the glyph offsets and the shared tuples.
+	end := 20 + offSize*(int(hdr.u16(12))+1)
+	if tuples := int(hdr.u32(8)) + 2*int(hdr.u16(4))*int(hdr.u16(6)); tuples > end {
+		end = tuples
+	}
+	dataOff := int(hdr.u32(16))
+	if end > t.length || dataOff > t.length {
+		return nil, lazyTable{}, errInvalidTables
+	}
+	gvar, err := t.read(0, end)
+	if err != nil {
+		return nil, lazyTable{}, err
+	}
+	return gvar, t.slice(dataOff, t.length-dataOff), nil
+}
+
+// read returns n bytes of the table at off.
+func (t lazyTable) read(off, n int) (table, error) {
+	if off < 0 || n < 0 || off+n > t.length {
+		return nil, errInvalidTables
+	}
+	data := make(table, n)
+	if _, err := t.src.ReadAt(data, t.off+int64(off)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// slice returns the n bytes of the table at off, or an empty table
+// if they are out of range.
+func (t lazyTable) slice(off, n int) lazyTable {
+	if off < 0 || n < 0 || off+n > t.length {
+		return lazyTable{}
+	}
+	return lazyTable{src: t.src, off: t.off + int64(off), length: n}
+}
+
+// bytes returns the contents of the table, or nil if they can't be
+// read.
+func (t lazyTable) bytes() table {
+	if t.src == nil {
+		return nil
+	}
+	data, err := t.read(0, t.length)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func newLayoutTable(t table, gpos bool) *layoutTable {
+	return &layoutTable{
+		t:           t,
+		scriptList:  t.offset16(4),
+		featureList: t.offset16(6),
+		lookupList:  t.offset16(8),
+		gpos:        gpos,
+	}
+}
+
+func parseGDEF(t table) gdefTable {
+	g := gdefTable{
+		classes:    t.offset16(4),
+		markAttach: t.offset16(10),
+	}
+	if t.u16(2) >= 2 {
+		g.markSets = t.offset16(12)
+	}
+	return g
+}
+
+func (t table) u8(off int) uint8 {
+	if off < 0 || off >= len(t) {
+		return 0
+	}
+	return t[off]
+}
+
+func (t table) u16(off int) uint16 {
+	if off < 0 || off+2 > len(t) {
+		return 0
+	}
+	return binary.BigEndian.Uint16(t[off:])
+}
+
+func (t table) i16(off int) int16 {
+	return int16(t.u16(off))
+}
+
+func (t table) u32(off int) uint32 {
+	if off < 0 || off+4 > len(t) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(t[off:])
+}
+
+// sub returns the table starting at off.
+func (t table) sub(off int) table {
+	if off <= 0 || off > len(t) {
+		return nil
+	}
+	return t[off:]
+}
+
+// offset16 returns the table at the 16-bit offset stored at off.
+func (t table) offset16(off int) table {
+	return t.sub(int(t.u16(off)))
+}
+
+// coverage returns the coverage index of g, or -1 if g is
+// not covered.
+func (t table) coverage(g sfnt.GlyphIndex) int {
+	switch t.u16(0) {
+	case 1:
+		n := int(t.u16(2))
+		lo, hi := 0, n
+		for lo < hi {
+			m := (lo + hi) / 2
+			switch id := sfnt.GlyphIndex(t.u16(4 + 2*m)); {
+			case g < id:
+				hi = m
+			case g > id:
+				lo = m + 1
+			default:
+				return m
+			}
+		}
+	case 2:
+		n := int(t.u16(2))
+		lo, hi := 0, n
+		for lo < hi {
+			m := (lo + hi) / 2
+			rec := 4 + 6*m
+			switch start, end := sfnt.GlyphIndex(t.u16(rec)), sfnt.GlyphIndex(t.u16(rec+2)); {
+			case g < start:
+				hi = m
+			case g > end:
+				lo = m + 1
+			default:
+				return int(t.u16(rec+4)) + int(g-start)
+			}
+		}
+	}
+	return -1
+}
+
+// class returns the class of g according to the class
+// definition table t.
+func (t table) class(g sfnt.GlyphIndex) uint16 {
+	switch t.u16(0) {
+	case 1:
+		start := sfnt.GlyphIndex(t.u16(2))
+		n := sfnt.GlyphIndex(t.u16(4))
+		if g >= start && g-start < n {
+			return t.u16(6 + 2*int(g-start))
+		}
+	case 2:
+		n := int(t.u16(2))
+		lo, hi := 0, n
+		for lo < hi {
+			m := (lo + hi) / 2
+			rec := 4 + 6*m
+			switch start, end := sfnt.GlyphIndex(t.u16(rec)), sfnt.GlyphIndex(t.u16(rec+2)); {
+			case g < start:
+				hi = m
+			case g > end:
+				lo = m + 1
+			default:
+				return t.u16(rec + 4)
+			}
+		}
+	}
+	return 0
+}
+
+// glyphClass returns the class of g, or classUnknown if the font
+// doesn't classify its glyphs.
+func (g gdefTable) glyphClass(id sfnt.GlyphIndex) glyphClass {
+	if g.classes == nil {
+		return classUnknown
+	}
+	c := g.classes.class(id)
+	if c > uint16(classComponent) {
+		return classUnknown
+	}
+	return glyphClass(c)
+}
+
+// inMarkSet reports whether the mark g is in the mark glyph set
+// with index set.
+func (g gdefTable) inMarkSet(set uint16, id sfnt.GlyphIndex) bool {
+	if g.markSets == nil || set >= g.markSets.u16(2) {
+		return false
+	}
+	cov := g.markSets.sub(int(g.markSets.u32(4 + 4*int(set))))
+	return cov.coverage(id) >= 0
+}
+
+// script returns the script table for the first of the script
+// tags supported by the table, falling back to the default
+// script.
+func (t *layoutTable) script(tags []uint32) table {
+	n := int(t.scriptList.u16(0))
+	find := func(tag uint32) table {
+		for i := 0; i < n; i++ {
+			if t.scriptList.u32(2+6*i) == tag {
+				return t.scriptList.sub(int(t.scriptList.u16(2 + 6*i + 4)))
+			}
+		}
+		return nil
+	}
+	for _, tag := range tags {
+		if s := find(tag); s != nil {
+			return s
+		}
+	}
+	for _, tag := range []uint32{tagDFLT, tagDflt, tagLatn} {
+		if s := find(tag); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// lookups returns the indices of the lookups of the features
+// for the script, in lookup list order, along with the masks of the
+// features they belong to. The lookups of the required feature are
+// included with the global mask.
+func (t *layoutTable) lookups(script table, features []feature, masks map[uint32]uint32) []featureLookup {
+	langSys := script.offset16(0)
+	if langSys == nil && script.u16(2) > 0 {
+		// No default language system; use the first.
+		langSys = script.offset16(4 + 4)
+	}
+	if langSys == nil {
+		return nil
+	}
+	lookupMasks := make(map[uint16]uint32)
+	addFeature := func(idx uint16, mask uint32) {
+		if idx >= t.featureList.u16(0) {
+			return
+		}
+		f := t.featureList.offset16(2 + 6*int(idx) + 4)
+		n := int(f.u16(2))
+		for i := 0; i < n; i++ {
+			lookupMasks[f.u16(4+2*i)] |= mask
+		}
+	}
+	if req := langSys.u16(2); req != 0xffff {
+		addFeature(req, globalMask)
+	}
+	n := int(langSys.u16(4))
+	for i := 0; i < n; i++ {
+		idx := langSys.u16(6 + 2*i)
+		tag := t.featureList.u32(2 + 6*int(idx))
+		for _, f := range features {
+			if f.tag == tag {
+				addFeature(idx, masks[tag])
+			}
+		}
+	}
+	var lookups []featureLookup
+	for idx, mask := range lookupMasks {
+		lookups = append(lookups, featureLookup{index: idx, mask: mask})
+	}
+	sortLookups(lookups)
+	return lookups
+}
+
+// lookup returns the lookup with index idx. Extension lookups are
+// resolved to the lookups they wrap.
+func (t *layoutTable) lookup(idx uint16) lookup {
+	if idx >= t.lookupList.u16(0) {
+		return lookup{}
+	}
+	lt := t.lookupList.offset16(2 + 2*int(idx))
+	l := lookup{
+		typ:  lt.u16(0),
+		flag: lt.u16(2),
+	}
+	n := int(lt.u16(4))
+	if l.flag&lookupUseMarkFilteringSet != 0 {
+		l.markSet = lt.u16(6 + 2*n)
+	}
+	extension := l.typ == 7 && !t.gpos || l.typ == 9 && t.gpos
+	for i := 0; i < n; i++ {
+		st := lt.offset16(6 + 2*i)
+		if extension {
+			l.typ = st.u16(2)
+			st = st.sub(int(st.u32(4)))
+		}
+		l.subtables = append(l.subtables, st)
+	}
+	return l
+}
+
+// anchor returns the coordinates of the anchor table t.
+func (t table) anchor() (x, y int16) {
+	return t.i16(2), t.i16(4)
+}
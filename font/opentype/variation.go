@@ -0,0 +1,688 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/text"
+)
+
+// variation is an instance of a variable font with TrueType
+// outlines. Glyph outlines are varied by the gvar table and advances
+// by the HVAR table, or the gvar phantom points if the font has no
+// HVAR table.
+type variation struct {
+	t *tables
+	// coords are the normalized coordinates of the instance, one
+	// for each fvar axis.
+	coords []float32
+	upem   float32
+}
+
+// glyfPoint is a point of a TrueType outline, in font units.
+type glyfPoint struct {
+	x, y float32
+	on   bool
+}
+
+// maxComponentDepth limits the nesting of composite glyphs.
+const maxComponentDepth = 8
+
+func fixed16Dot16(v uint32) float32 {
+	return float32(int32(v)) / 65536
+}
+
+func f2Dot14(v uint16) float32 {
+	return float32(int16(v)) / 16384
+}
+
+// axes returns the variation axes of the font, or nil if the font is
+// not a variable font.
+func (t *tables) axes() []text.Axis {
+	fvar := t.fvar
+	off, n, size := int(fvar.u16(4)), int(fvar.u16(8)), int(fvar.u16(10))
+	var axes []text.Axis
+	for i := 0; i < n; i++ {
+		rec := off + size*i
+		if rec+20 > len(fvar) {
+			break
+		}
+		axes = append(axes, text.Axis{
+			Tag:     string(fvar[rec : rec+4]),
+			Min:     fixed16Dot16(fvar.u32(rec + 4)),
+			Default: fixed16Dot16(fvar.u32(rec + 8)),
+			Max:     fixed16Dot16(fvar.u32(rec + 12)),
+		})
+	}
+	return axes
+}
+
+// normalize returns the normalized coordinates of the axis values,
+// in the range [-1, 1] with 0 for the default values.
+func (t *tables) normalize(axes []text.Axis, values []text.Variation) []float32 {
+	coords := make([]float32, len(axes))
+	for i, a := range axes {
+		v := a.Default
+		for _, val := range values {
+			if val.Tag == a.Tag {
+				v = val.Value
+			}
+		}
+		switch {
+		case v < a.Min:
+			v = a.Min
+		case v > a.Max:
+			v = a.Max
+		}
+		switch {
+		case v < a.Default:
+			coords[i] = (v - a.Default) / (a.Default - a.Min)
+		case v > a.Default:
+			coords[i] = (v - a.Default) / (a.Max - a.Default)
+		}
+	}
+	// Apply the avar segment maps.
+	avar := t.avar
+	if avar == nil || int(avar.u16(6)) != len(coords) {
+		return coords
+	}
+	off := 8
+	for i, c := range coords {
+		n := int(avar.u16(off))
+		maps := avar.sub(off + 2)
+		off += 2 + 4*n
+		for k := 0; k < n; k++ {
+			from, to := f2Dot14(maps.u16(4*k)), f2Dot14(maps.u16(4*k+2))
+			if c > from {
+				continue
+			}
+			if c == from || k == 0 {
+				coords[i] = to
+			} else {
+				prevFrom, prevTo := f2Dot14(maps.u16(4*k-4)), f2Dot14(maps.u16(4*k-2))
+				coords[i] = prevTo + (to-prevTo)*(c-prevFrom)/(from-prevFrom)
+			}
+			break
+		}
+	}
+	return coords
+}
+
+// regionScalar returns the scalar of a variation region at coords.
+func regionScalar(coords, start, peak, end []float32) float32 {
+	scalar := float32(1)
+	for i, p := range peak {
+		s, e := start[i], end[i]
+		// Ignore axes that don't participate and invalid ranges.
+		if p == 0 || s > p || p > e || s < 0 && e > 0 {
+			continue
+		}
+		v := coords[i]
+		switch {
+		case v == p:
+		case v <= s || v >= e:
+			return 0
+		case v < p:
+			scalar *= (v - s) / (p - s)
+		default:
+			scalar *= (e - v) / (e - p)
+		}
+	}
+	return scalar
+}
+
+// glyphData returns the glyf data of a glyph.
+func (t *tables) glyphData(id sfnt.GlyphIndex) table {
+	var start, end int
+	if t.longLoca {
+		start, end = int(t.loca.u32(4*int(id))), int(t.loca.u32(4*int(id)+4))
+	} else {
+		start, end = 2*int(t.loca.u16(2*int(id))), 2*int(t.loca.u16(2*int(id)+2))
+	}
+	if end <= start {
+		return nil
+	}
+	g, err := t.glyf.read(start, end-start)
+	if err != nil {
+		return nil
+	}
+	return g
+}
+
+// outline returns the points and contour end indices of a glyph with
+// the variation applied, and the variation of its advance.
+func (v *variation) outline(id sfnt.GlyphIndex, depth int) ([]glyfPoint, []int, float32) {
+	g := v.t.glyphData(id)
+	nc := int(g.i16(0))
+	if nc < 0 {
+		return v.compositeOutline(id, g, depth)
+	}
+	ends := make([]int, nc)
+	for i := range ends {
+		ends[i] = int(g.u16(10 + 2*i))
+	}
+	n := 0
+	if nc > 0 {
+		n = ends[nc-1] + 1
+	}
+	off := 10 + 2*nc
+	off += 2 + int(g.u16(off))
+	flags := make([]uint8, n)
+	for i := 0; i < n; {
+		f := g.u8(off)
+		off++
+		flags[i] = f
+		i++
+		// Repeated flags.
+		if f&0x08 != 0 {
+			r := int(g.u8(off))
+			off++
+			for ; r > 0 && i < n; r-- {
+				flags[i] = f
+				i++
+			}
+		}
+	}
+	points := make([]glyfPoint, n)
+	var x, y int
+	for i, f := range flags {
+		switch {
+		case f&0x02 != 0:
+			dx := int(g.u8(off))
+			off++
+			if f&0x10 == 0 {
+				dx = -dx
+			}
+			x += dx
+		case f&0x10 == 0:
+			x += int(g.i16(off))
+			off += 2
+		}
+		points[i] = glyfPoint{x: float32(x), on: f&0x01 != 0}
+	}
+	for i, f := range flags {
+		switch {
+		case f&0x04 != 0:
+			dy := int(g.u8(off))
+			off++
+			if f&0x20 == 0 {
+				dy = -dy
+			}
+			y += dy
+		case f&0x20 == 0:
+			y += int(g.i16(off))
+			off += 2
+		}
+		points[i].y = float32(y)
+	}
+	deltas := v.glyphDeltas(id, n+4, points, ends)
+	if deltas == nil {
+		return points, ends, 0
+	}
+	for i := range points {
+		points[i].x += deltas[i].X
+		points[i].y += deltas[i].Y
+	}
+	return points, ends, deltas[n+1].X - deltas[n].X
+}
+
+func (v *variation) compositeOutline(id sfnt.GlyphIndex, g table, depth int) ([]glyfPoint, []int, float32) {
+	type component struct {
+		id sfnt.GlyphIndex
+		// dx and dy are the offset of the component.
+		dx, dy float32
+		// xx, xy, yx and yy are the transformation of the
+		// component.
+		xx, xy, yx, yy float32
+	}
+	var comps []component
+	for off := 10; ; {
+		flags := g.u16(off)
+		c := component{id: sfnt.GlyphIndex(g.u16(off + 2)), xx: 1, yy: 1}
+		off += 4
+		var a1, a2 int
+		switch {
+		case flags&0x0001 != 0:
+			a1, a2 = int(g.i16(off)), int(g.i16(off+2))
+			off += 4
+		default:
+			a1, a2 = int(int8(g.u8(off))), int(int8(g.u8(off+1)))
+			off += 2
+		}
+		// Components positioned by matching points are not
+		// supported.
+		if flags&0x0002 != 0 {
+			c.dx, c.dy = float32(a1), float32(a2)
+		}
+		switch {
+		case flags&0x0008 != 0:
+			c.xx = f2Dot14(g.u16(off))
+			c.yy = c.xx
+			off += 2
+		case flags&0x0040 != 0:
+			c.xx, c.yy = f2Dot14(g.u16(off)), f2Dot14(g.u16(off+2))
+			off += 4
+		case flags&0x0080 != 0:
+			c.xx, c.xy = f2Dot14(g.u16(off)), f2Dot14(g.u16(off+2))
+			c.yx, c.yy = f2Dot14(g.u16(off+4)), f2Dot14(g.u16(off+6))
+			off += 8
+		}
+		comps = append(comps, c)
+		if flags&0x0020 == 0 || off >= len(g) {
+			break
+		}
+	}
+	n := len(comps)
+	deltas := v.glyphDeltas(id, n+4, nil, nil)
+	var advDelta float32
+	if deltas != nil {
+		advDelta = deltas[n+1].X - deltas[n].X
+	}
+	if depth >= maxComponentDepth {
+		return nil, nil, advDelta
+	}
+	var points []glyfPoint
+	var ends []int
+	for i, c := range comps {
+		if deltas != nil {
+			c.dx += deltas[i].X
+			c.dy += deltas[i].Y
+		}
+		cpoints, cends, _ := v.outline(c.id, depth+1)
+		for _, e := range cends {
+			ends = append(ends, len(points)+e)
+		}
+		for _, p := range cpoints {
+			points = append(points, glyfPoint{
+				x:  c.xx*p.x + c.yx*p.y + c.dx,
+				y:  c.xy*p.x + c.yy*p.y + c.dy,
+				on: p.on,
+			})
+		}
+	}
+	return points, ends, advDelta
+}
+
+// glyphDeltas returns the gvar deltas of the n points of a glyph,
+// including its 4 phantom points, or nil if the glyph has no
+// variations. The deltas of the points that are not referenced by a
+// variation are inferred from the outline of the glyph, if points is
+// not nil.
+func (v *variation) glyphDeltas(id sfnt.GlyphIndex, n int, points []glyfPoint, ends []int) []f32.Point {
+	gvar := v.t.gvar
+	axisCount := int(gvar.u16(4))
+	if axisCount != len(v.coords) || int(id) >= int(gvar.u16(12)) {
+		return nil
+	}
+	var start, end int
+	if gvar.u16(14)&1 != 0 {
+		start, end = int(gvar.u32(20+4*int(id))), int(gvar.u32(24+4*int(id)))
+	} else {
+		start, end = 2*int(gvar.u16(20+2*int(id))), 2*int(gvar.u16(22+2*int(id)))
+	}
+	if end <= start {
+		return nil
+	}
+	data, err := v.t.gvarData.read(start, end-start)
+	if err != nil {
+		return nil
+	}
+	sharedTuples := gvar.sub(int(gvar.u32(8)))
+	count := data.u16(0)
+	serialized := data.sub(int(data.u16(2)))
+	pos := 0
+	// A nil slice of point numbers refers to all points.
+	var shared []int
+	if count&0x8000 != 0 {
+		shared, pos = readPointNumbers(serialized, pos)
+	}
+	deltas := make([]f32.Point, n)
+	hdr := 4
+	peak := make([]float32, axisCount)
+	regionStart := make([]float32, axisCount)
+	regionEnd := make([]float32, axisCount)
+	for i := 0; i < int(count&0x0fff); i++ {
+		size, idx := int(data.u16(hdr)), data.u16(hdr+2)
+		hdr += 4
+		if idx&0x8000 != 0 {
+			for a := range peak {
+				peak[a] = f2Dot14(data.u16(hdr + 2*a))
+			}
+			hdr += 2 * axisCount
+		} else {
+			k := int(idx & 0x0fff)
+			for a := range peak {
+				peak[a] = f2Dot14(sharedTuples.u16(2 * (k*axisCount + a)))
+			}
+		}
+		if idx&0x4000 != 0 {
+			for a := range peak {
+				regionStart[a] = f2Dot14(data.u16(hdr + 2*a))
+				regionEnd[a] = f2Dot14(data.u16(hdr + 2*(axisCount+a)))
+			}
+			hdr += 4 * axisCount
+		} else {
+			for a, p := range peak {
+				regionStart[a], regionEnd[a] = 0, 0
+				if p < 0 {
+					regionStart[a] = p
+				} else {
+					regionEnd[a] = p
+				}
+			}
+		}
+		tuple := pos
+		pos += size
+		scalar := regionScalar(v.coords, regionStart, peak, regionEnd)
+		if scalar == 0 {
+			continue
+		}
+		pts := shared
+		if idx&0x2000 != 0 {
+			pts, tuple = readPointNumbers(serialized, tuple)
+		}
+		npts := len(pts)
+		if pts == nil {
+			npts = n
+		}
+		xs, tuple := readDeltas(serialized, tuple, npts)
+		ys, _ := readDeltas(serialized, tuple, npts)
+		if pts == nil {
+			for j := range deltas {
+				deltas[j].X += scalar * xs[j]
+				deltas[j].Y += scalar * ys[j]
+			}
+			continue
+		}
+		tupleDeltas := make([]f32.Point, n)
+		touched := make([]bool, n)
+		for j, p := range pts {
+			if p < n {
+				tupleDeltas[p] = f32.Point{X: xs[j], Y: ys[j]}
+				touched[p] = true
+			}
+		}
+		if points != nil {
+			interpolateDeltas(tupleDeltas, touched, points, ends)
+		}
+		for j, d := range tupleDeltas {
+			deltas[j] = deltas[j].Add(d.Mul(scalar))
+		}
+	}
+	return deltas
+}
+
+// readPointNumbers reads packed point numbers at pos, and returns nil
+// for all points.
+func readPointNumbers(t table, pos int) ([]int, int) {
+	count := int(t.u8(pos))
+	pos++
+	if count == 0 {
+		return nil, pos
+	}
+	if count&0x80 != 0 {
+		count = (count&0x7f)<<8 | int(t.u8(pos))
+		pos++
+	}
+	pts := make([]int, 0, count)
+	p := 0
+	for len(pts) < count {
+		ctrl := t.u8(pos)
+		pos++
+		for i := 0; i <= int(ctrl&0x7f) && len(pts) < count; i++ {
+			if ctrl&0x80 != 0 {
+				p += int(t.u16(pos))
+				pos += 2
+			} else {
+				p += int(t.u8(pos))
+				pos++
+			}
+			pts = append(pts, p)
+		}
+	}
+	return pts, pos
+}
+
+// readDeltas reads n packed deltas at pos.
+func readDeltas(t table, pos int, n int) ([]float32, int) {
+	deltas := make([]float32, 0, n)
+	for len(deltas) < n {
+		ctrl := t.u8(pos)
+		pos++
+		for i := 0; i <= int(ctrl&0x3f) && len(deltas) < n; i++ {
+			switch {
+			case ctrl&0x80 != 0:
+				deltas = append(deltas, 0)
+			case ctrl&0x40 != 0:
+				deltas = append(deltas, float32(t.i16(pos)))
+				pos += 2
+			default:
+				deltas = append(deltas, float32(int8(t.u8(pos))))
+				pos++
+			}
+		}
+	}
+	return deltas, pos
+}
+
+// interpolateDeltas infers the deltas of the untouched points of each
+// contour from the nearest touched points before and after them.
+func interpolateDeltas(deltas []f32.Point, touched []bool, points []glyfPoint, ends []int) {
+	start := 0
+	for _, end := range ends {
+		if end >= len(points) {
+			return
+		}
+		first := -1
+		for i := start; i <= end; i++ {
+			if touched[i] {
+				first = i
+				break
+			}
+		}
+		next := func(i int) int {
+			if i == end {
+				return start
+			}
+			return i + 1
+		}
+		for prev := first; prev != -1; {
+			ref := next(prev)
+			for !touched[ref] {
+				ref = next(ref)
+			}
+			for i := next(prev); i != ref; i = next(i) {
+				p1, p2 := points[prev], points[ref]
+				d1, d2 := deltas[prev], deltas[ref]
+				deltas[i] = f32.Point{
+					X: interpolateDelta(points[i].x, p1.x, p2.x, d1.X, d2.X),
+					Y: interpolateDelta(points[i].y, p1.y, p2.y, d1.Y, d2.Y),
+				}
+			}
+			if ref == first {
+				break
+			}
+			prev = ref
+		}
+		start = end + 1
+	}
+}
+
+// interpolateDelta infers the delta of a coordinate v from reference
+// coordinates c1, c2 with deltas d1, d2.
+func interpolateDelta(v, c1, c2, d1, d2 float32) float32 {
+	if c1 > c2 {
+		c1, c2, d1, d2 = c2, c1, d2, d1
+	}
+	switch {
+	case c1 == c2:
+		if d1 == d2 {
+			return d1
+		}
+		return 0
+	case v <= c1:
+		return d1
+	case v >= c2:
+		return d2
+	}
+	return d1 + (v-c1)*(d2-d1)/(c2-c1)
+}
+
+// advanceDelta returns the variation of the advance of a glyph, in
+// font units.
+func (v *variation) advanceDelta(id sfnt.GlyphIndex) float32 {
+	hvar := v.t.hvar
+	if hvar == nil {
+		_, _, d := v.outline(id, 0)
+		return d
+	}
+	outer, inner := 0, int(id)
+	if m := hvar.sub(int(hvar.u32(8))); m != nil {
+		outer, inner = deltaSetIndex(m, int(id))
+	}
+	return v.itemDelta(hvar.sub(int(hvar.u32(4))), outer, inner)
+}
+
+// deltaSetIndex returns the outer and inner item variation indices
+// of entry i of a delta set index map.
+func deltaSetIndex(m table, i int) (int, int) {
+	var count, off int
+	if m.u8(0) == 0 {
+		count, off = int(m.u16(2)), 4
+	} else {
+		count, off = int(m.u32(2)), 6
+	}
+	if count == 0 {
+		return 0, i
+	}
+	if i >= count {
+		i = count - 1
+	}
+	format := m.u8(1)
+	size := int(format&0x30>>4) + 1
+	bits := uint(format&0x0f) + 1
+	e := 0
+	for k := 0; k < size; k++ {
+		e = e<<8 | int(m.u8(off+size*i+k))
+	}
+	return e >> bits, e & (1<<bits - 1)
+}
+
+// itemDelta returns the delta of an item of an item variation store.
+func (v *variation) itemDelta(store table, outer, inner int) float32 {
+	if outer >= int(store.u16(6)) {
+		return 0
+	}
+	regions := store.sub(int(store.u32(2)))
+	axisCount, regionCount := int(regions.u16(0)), int(regions.u16(2))
+	if axisCount != len(v.coords) {
+		return 0
+	}
+	data := store.sub(int(store.u32(8 + 4*outer)))
+	itemCount, wordCount, n := int(data.u16(0)), int(data.u16(2)), int(data.u16(4))
+	if inner >= itemCount {
+		return 0
+	}
+	long := wordCount&0x8000 != 0
+	wordCount &= 0x7fff
+	wordSize := 2
+	if long {
+		wordSize = 4
+	}
+	row := 6 + 2*n + inner*(wordCount*wordSize+(n-wordCount)*wordSize/2)
+	start := make([]float32, axisCount)
+	peak := make([]float32, axisCount)
+	end := make([]float32, axisCount)
+	var delta float32
+	for k := 0; k < n; k++ {
+		var d int
+		switch {
+		case k < wordCount && long:
+			d = int(int32(data.u32(row)))
+			row += 4
+		case k < wordCount, long:
+			d = int(data.i16(row))
+			row += 2
+		default:
+			d = int(int8(data.u8(row)))
+			row++
+		}
+		r := int(data.u16(6 + 2*k))
+		if r >= regionCount {
+			continue
+		}
+		for a := 0; a < axisCount; a++ {
+			rec := 4 + 6*(r*axisCount+a)
+			start[a] = f2Dot14(regions.u16(rec))
+			peak[a] = f2Dot14(regions.u16(rec + 2))
+			end[a] = f2Dot14(regions.u16(rec + 4))
+		}
+		delta += float32(d) * regionScalar(v.coords, start, peak, end)
+	}
+	return delta
+}
+
+// loadGlyph returns the varied outline of a glyph, scaled to ppem.
+func (v *variation) loadGlyph(id sfnt.GlyphIndex, ppem fixed.Int26_6) []sfnt.Segment {
+	points, ends, _ := v.outline(id, 0)
+	scale := float32(ppem) / v.upem
+	pt := func(p glyfPoint) fixed.Point26_6 {
+		return fixed.Point26_6{
+			X: fixed.Int26_6(p.x * scale),
+			Y: fixed.Int26_6(-p.y * scale),
+		}
+	}
+	mid := func(p1, p2 glyfPoint) glyfPoint {
+		return glyfPoint{x: (p1.x + p2.x) / 2, y: (p1.y + p2.y) / 2, on: true}
+	}
+	var segs []sfnt.Segment
+	start := 0
+	for _, end := range ends {
+		if end >= len(points) || end < start {
+			break
+		}
+		contour := points[start : end+1]
+		start = end + 1
+		// Start at an on-curve point, or between the last and
+		// first points if there is none.
+		var first glyfPoint
+		var rest []glyfPoint
+		k := -1
+		for i, p := range contour {
+			if p.on {
+				k = i
+				break
+			}
+		}
+		if k >= 0 {
+			first = contour[k]
+			rest = append(rest, contour[k+1:]...)
+			rest = append(rest, contour[:k]...)
+		} else {
+			first = mid(contour[len(contour)-1], contour[0])
+			rest = append(rest, contour...)
+		}
+		rest = append(rest, first)
+		segs = append(segs, sfnt.Segment{Op: sfnt.SegmentOpMoveTo, Args: [3]fixed.Point26_6{pt(first)}})
+		var ctrl glyfPoint
+		hasCtrl := false
+		for _, p := range rest {
+			switch {
+			case p.on && hasCtrl:
+				segs = append(segs, sfnt.Segment{Op: sfnt.SegmentOpQuadTo, Args: [3]fixed.Point26_6{pt(ctrl), pt(p)}})
+				hasCtrl = false
+			case p.on:
+				segs = append(segs, sfnt.Segment{Op: sfnt.SegmentOpLineTo, Args: [3]fixed.Point26_6{pt(p)}})
+			default:
+				if hasCtrl {
+					segs = append(segs, sfnt.Segment{Op: sfnt.SegmentOpQuadTo, Args: [3]fixed.Point26_6{pt(ctrl), pt(mid(ctrl, p))}})
+				}
+				ctrl, hasCtrl = p, true
+			}
+		}
+	}
+	return segs
+}
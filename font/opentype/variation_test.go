@@ -0,0 +1,320 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/text"
+)
+
+// addTables returns a copy of an SFNT font with additional tables.
+func addTables(ttf []byte, extra map[string][]byte) []byte {
+	type record struct {
+		tag  string
+		data []byte
+	}
+	var records []record
+	n := int(binary.BigEndian.Uint16(ttf[4:]))
+	for i := 0; i < n; i++ {
+		rec := ttf[12+16*i:]
+		off, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		records = append(records, record{tag: string(rec[:4]), data: ttf[off : off+length]})
+	}
+	for tag, data := range extra {
+		records = append(records, record{tag: tag, data: data})
+	}
+	// The table directory is sorted by tag.
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].tag < records[j].tag
+	})
+	var buf bytes.Buffer
+	buf.Write(ttf[:4])
+	binary.Write(&buf, binary.BigEndian, uint16(len(records)))
+	buf.Write(ttf[6:12])
+	off := uint32(12 + 16*len(records))
+	for _, r := range records {
+		buf.WriteString(r.tag)
+		binary.Write(&buf, binary.BigEndian, uint32(0))
+		binary.Write(&buf, binary.BigEndian, off)
+		binary.Write(&buf, binary.BigEndian, uint32(len(r.data)))
+		off += (uint32(len(r.data)) + 3) &^ 3
+	}
+	for _, r := range records {
+		buf.Write(r.data)
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	return buf.Bytes()
+}
+
+// findTable returns a table of an SFNT font.
+func findTable(ttf []byte, tag string) table {
+	n := int(binary.BigEndian.Uint16(ttf[4:]))
+	for i := 0; i < n; i++ {
+		rec := ttf[12+16*i:]
+		if string(rec[:4]) == tag {
+			off, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+			return ttf[off : off+length]
+		}
+	}
+	return nil
+}
+
+// bytesTable returns a lazy table reading from data.
+func bytesTable(data []byte) lazyTable {
+	return lazyTable{src: bytes.NewReader(data), length: len(data)}
+}
+
+// variableFont returns a variable version of Go Regular with a weight
+// axis from 100 to 900 that moves the outline of glyph id 100 units
+// right and widens it 100 units at weight 900. If hvarDelta is not
+// zero, the font has an HVAR table that widens the glyph by
+// hvarDelta instead.
+func variableFont(t *testing.T, id sfnt.GlyphIndex, hvarDelta int8) []byte {
+	fnt, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numGlyphs := fnt.NumGlyphs()
+	head := findTable(goregular.TTF, "head")
+	orig := &tables{
+		glyf:     bytesTable(findTable(goregular.TTF, "glyf")),
+		loca:     findTable(goregular.TTF, "loca"),
+		longLoca: head.i16(50) != 0,
+	}
+	g := orig.glyphData(id)
+	nc := int(g.i16(0))
+	npoints := int(g.u16(10+2*(nc-1))) + 1
+	fvar := be(
+		uint16(1), uint16(0), uint16(16), uint16(2), uint16(1), uint16(20), uint16(0), uint16(8),
+		[]byte("wght"), int32(100<<16), int32(400<<16), int32(900<<16), uint16(0), uint16(256),
+	)
+	// The variation touches point 0 and the advance phantom point.
+	data := be(
+		// One tuple with its data at 10.
+		uint16(1), uint16(10),
+		// Tuple header with embedded peak and private points.
+		uint16(0), uint16(0xa000), uint16(0x4000),
+		// Point numbers 0 and npoints+1 as words.
+		uint8(2), uint8(0x81), uint16(0), uint16(npoints+1),
+		// X deltas as words, zero Y deltas.
+		uint8(0x41), int16(100), int16(100),
+		uint8(0x81),
+	)
+	binary.BigEndian.PutUint16(data[4:], uint16(len(data)-10))
+	if len(data)%2 != 0 {
+		data = append(data, 0)
+	}
+	gvar := be(uint16(1), uint16(0), uint16(1), uint16(0), uint32(0), uint16(numGlyphs), uint16(0), uint32(20+2*(numGlyphs+1)))
+	for i := 0; i <= numGlyphs; i++ {
+		off := 0
+		if i > int(id) {
+			off = len(data) / 2
+		}
+		gvar = append(gvar, be(uint16(off))...)
+	}
+	gvar = append(gvar, data...)
+	extra := map[string][]byte{"fvar": fvar, "gvar": gvar}
+	if hvarDelta != 0 {
+		hvar := be(
+			uint16(1), uint16(0), uint32(20), uint32(0), uint32(0), uint32(0),
+			// Item variation store with one region peaking at 1.
+			uint16(1), uint32(12), uint16(1), uint32(22),
+			uint16(1), uint16(1), uint16(0), uint16(0x4000), uint16(0x4000),
+			uint16(numGlyphs), uint16(0), uint16(1), uint16(0),
+		)
+		deltas := make([]byte, numGlyphs)
+		deltas[id] = byte(hvarDelta)
+		extra["HVAR"] = append(hvar, deltas...)
+	}
+	return addTables(goregular.TTF, extra)
+}
+
+// segmentBounds returns the horizontal extent of a glyph outline.
+func segmentBounds(segs []sfnt.Segment) (min, max fixed.Int26_6) {
+	min, max = 1<<30, -1<<30
+	for _, s := range segs {
+		for _, a := range s.Args {
+			if a == (fixed.Point26_6{}) {
+				continue
+			}
+			if a.X < min {
+				min = a.X
+			}
+			if a.X > max {
+				max = a.X
+			}
+		}
+	}
+	return
+}
+
+func TestVariableFont(t *testing.T) {
+	var buf sfnt.Buffer
+	def, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := def.font.GlyphIndex(&buf, 'l')
+	if err != nil {
+		t.Fatal(err)
+	}
+	upem := int(def.font.UnitsPerEm())
+	// Use one pixel per font unit.
+	ppem := fixed.I(upem)
+	baseAdv, err := def.opentype().glyphAdvance(&buf, id, ppem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseSegs, err := def.opentype().loadGlyph(&buf, id, ppem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseMin, baseMax := segmentBounds(baseSegs)
+
+	f, err := Parse(variableFont(t, id, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	axes := f.Axes()
+	if want := []text.Axis{{Tag: "wght", Min: 100, Default: 400, Max: 900}}; len(axes) != 1 || axes[0] != want[0] {
+		t.Fatalf("got axes %+v, expected %+v", axes, want)
+	}
+	if inst := f.Vary(nil).(*Font); inst.vary != nil {
+		t.Error("default instance is varied")
+	}
+	tests := []struct {
+		weight float32
+		delta  int
+	}{
+		{400, 0},
+		{650, 50},
+		{900, 100},
+		{2000, 100},
+	}
+	for _, test := range tests {
+		inst := f.Vary([]text.Variation{{Tag: "wght", Value: test.weight}}).(*Font).opentype()
+		adv, err := inst.glyphAdvance(&buf, id, ppem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := baseAdv + fixed.I(test.delta); adv != want {
+			t.Errorf("weight %v: got advance %v, expected %v", test.weight, adv, want)
+		}
+		segs, err := inst.loadGlyph(&buf, id, ppem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		min, max := segmentBounds(segs)
+		if d := fixed.I(test.delta); min != baseMin+d || max != baseMax+d {
+			t.Errorf("weight %v: got extent [%v,%v], expected [%v,%v]", test.weight, min, max, baseMin+d, baseMax+d)
+		}
+	}
+
+	// HVAR overrides the phantom point advances.
+	f, err = Parse(variableFont(t, id, 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst := f.Vary([]text.Variation{{Tag: "wght", Value: 900}}).(*Font).opentype()
+	if adv, _ := inst.glyphAdvance(&buf, id, ppem); adv != baseAdv+fixed.I(50) {
+		t.Errorf("got HVAR advance %v, expected %v", adv, baseAdv+fixed.I(50))
+	}
+}
+
+func TestCacheVariableFont(t *testing.T) {
+	var buf sfnt.Buffer
+	def, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := def.font.GlyphIndex(&buf, 'l')
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Parse(variableFont(t, id, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppem := fixed.I(int(def.font.UnitsPerEm()))
+	c := text.NewCache([]text.FontFace{{Face: f}})
+	width := func(font text.Font) fixed.Int26_6 {
+		return c.LayoutString(font, ppem, 1e6, "l")[0].Width
+	}
+	normal := width(text.Font{})
+	// Bold is weight 600, normalized to 0.4.
+	if got, want := width(text.Font{Weight: text.Bold}), normal+fixed.I(40); got != want {
+		t.Errorf("got bold width %v, expected %v", got, want)
+	}
+	if got, want := width(text.Font{Variations: "wght 900"}), normal+fixed.I(100); got != want {
+		t.Errorf("got wght 900 width %v, expected %v", got, want)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	axes := []text.Axis{{Tag: "wght", Min: 100, Default: 400, Max: 900}}
+	tab := new(tables)
+	tests := []struct {
+		v    float32
+		want float32
+	}{
+		{100, -1}, {250, -.5}, {400, 0}, {650, .5}, {900, 1}, {1000, 1},
+	}
+	for _, test := range tests {
+		if got := tab.normalize(axes, []text.Variation{{Tag: "wght", Value: test.v}}); got[0] != test.want {
+			t.Errorf("normalize(%v) = %v, expected %v", test.v, got[0], test.want)
+		}
+	}
+	// Map 0.5 to 0.25 with avar.
+	tab.avar = be(uint16(1), uint16(0), uint16(0), uint16(1), uint16(4),
+		int16(-16384), int16(-16384), int16(0), int16(0), int16(8192), int16(4096), int16(16384), int16(16384))
+	tests = []struct {
+		v    float32
+		want float32
+	}{
+		{400, 0}, {650, .25}, {525, .125}, {775, .625}, {900, 1},
+	}
+	for _, test := range tests {
+		if got := tab.normalize(axes, []text.Variation{{Tag: "wght", Value: test.v}}); got[0] != test.want {
+			t.Errorf("avar normalize(%v) = %v, expected %v", test.v, got[0], test.want)
+		}
+	}
+}
+
+func TestInterpolateDeltas(t *testing.T) {
+	// A square with the deltas of two corners.
+	points := []glyfPoint{{x: 0, y: 0}, {x: 100, y: 0}, {x: 100, y: 100}, {x: 50, y: 100}, {x: 0, y: 100}}
+	deltas := []f32.Point{{X: 10}, {}, {X: 20, Y: 10}, {}, {}}
+	touched := []bool{true, false, true, false, false}
+	interpolateDeltas(deltas, touched, points, []int{4})
+	want := []f32.Point{{X: 10}, {X: 20, Y: 0}, {X: 20, Y: 10}, {X: 15, Y: 10}, {X: 10, Y: 10}}
+	for i := range want {
+		if deltas[i] != want[i] {
+			t.Errorf("point %d: got delta %v, expected %v", i, deltas[i], want[i])
+		}
+	}
+}
+
+func TestVariationsValues(t *testing.T) {
+	v := text.Variations("wght 650, wdth 87.5,bad, opsz x")
+	got := v.Values()
+	want := []text.Variation{{Tag: "wght", Value: 650}, {Tag: "wdth", Value: 87.5}}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, expected %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %+v, expected %+v", got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+)
+
+// WOFF and WOFF2 file signatures.
+const (
+	woffSignature  = "wOFF"
+	woff2Signature = "wOF2"
+)
+
+// maxWOFFSize limits the size of decoded WOFF fonts.
+const maxWOFFSize = 1 << 28
+
+var errInvalidWOFF = errors.New("opentype: invalid WOFF data")
+
+// sfntTable is a table of an SFNT font.
+type sfntTable struct {
+	tag  string
+	data []byte
+}
+
+// isWOFF reports whether hdr starts with a WOFF or WOFF2 signature.
+func isWOFF(hdr []byte) bool {
+	if len(hdr) < 4 {
+		return false
+	}
+	sig := string(hdr[:4])
+	return sig == woffSignature || sig == woff2Signature
+}
+
+// readWOFF reads WOFF or WOFF2 data from src, and reports false if
+// src doesn't start with a WOFF or WOFF2 signature.
+func readWOFF(src io.ReaderAt) ([]byte, bool, error) {
+	var hdr [12]byte
+	if _, err := src.ReadAt(hdr[:], 0); err != nil {
+		return nil, false, err
+	}
+	if !isWOFF(hdr[:]) {
+		return nil, false, nil
+	}
+	// The header contains the length of the file.
+	length := binary.BigEndian.Uint32(hdr[8:])
+	if length > maxWOFFSize {
+		return nil, true, errInvalidWOFF
+	}
+	data := make([]byte, length)
+	if _, err := src.ReadAt(data, 0); err != nil {
+		return nil, true, err
+	}
+	return data, true, nil
+}
+
+// decodeWOFF converts WOFF or WOFF2 data to SFNT data.
+func decodeWOFF(src []byte) ([]byte, error) {
+	if len(src) < 4 {
+		return nil, errInvalidWOFF
+	}
+	switch string(src[:4]) {
+	case woffSignature:
+		return decodeWOFF1(src)
+	case woff2Signature:
+		return decodeWOFF2(src)
+	default:
+		return nil, errInvalidWOFF
+	}
+}
+
+// decodeWOFF1 converts WOFF data to SFNT data.
+func decodeWOFF1(src []byte) ([]byte, error) {
+	const hdrSize, entrySize = 44, 20
+	if len(src) < hdrSize {
+		return nil, errInvalidWOFF
+	}
+	flavor := src[4:8]
+	n := int(binary.BigEndian.Uint16(src[12:]))
+	if len(src) < hdrSize+n*entrySize {
+		return nil, errInvalidWOFF
+	}
+	tables := make([]sfntTable, n)
+	total := 0
+	for i := range tables {
+		e := src[hdrSize+i*entrySize:]
+		off := int64(binary.BigEndian.Uint32(e[4:]))
+		compLen := int64(binary.BigEndian.Uint32(e[8:]))
+		origLen := int64(binary.BigEndian.Uint32(e[12:]))
+		total += int(origLen)
+		if off+compLen > int64(len(src)) || compLen > origLen || total > maxWOFFSize {
+			return nil, errInvalidWOFF
+		}
+		data := src[off : off+compLen]
+		if compLen < origLen {
+			zr, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			data = make([]byte, origLen)
+			if _, err := io.ReadFull(zr, data); err != nil {
+				return nil, err
+			}
+		}
+		tables[i] = sfntTable{tag: string(e[:4]), data: data}
+	}
+	return buildSFNT(flavor, tables), nil
+}
+
+// buildSFNT returns the SFNT data of a font with the tables.
+func buildSFNT(flavor []byte, tables []sfntTable) []byte {
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].tag < tables[j].tag
+	})
+	n := len(tables)
+	sel := 0
+	for 2<<sel <= n {
+		sel++
+	}
+	searchRange := 16 << sel
+	size := 12 + 16*n
+	for _, t := range tables {
+		size += (len(t.data) + 3) &^ 3
+	}
+	out := make([]byte, 12+16*n, size)
+	copy(out, flavor)
+	be := binary.BigEndian
+	be.PutUint16(out[4:], uint16(n))
+	be.PutUint16(out[6:], uint16(searchRange))
+	be.PutUint16(out[8:], uint16(sel))
+	be.PutUint16(out[10:], uint16(16*n-searchRange))
+	for i, t := range tables {
+		rec := out[12+16*i:]
+		copy(rec, t.tag)
+		be.PutUint32(rec[4:], tableChecksum(t.data))
+		be.PutUint32(rec[8:], uint32(len(out)))
+		be.PutUint32(rec[12:], uint32(len(t.data)))
+		out = append(out, t.data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out
+}
+
+// tableChecksum returns the SFNT checksum of table data.
+func tableChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,549 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// woff2Tags are the tags of the known tables of WOFF2 files, indexed
+// by the table directory flags.
+var woff2Tags = [63]string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post",
+	"cvt ", "fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT",
+	"EBLC", "gasp", "hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea",
+	"vmtx", "BASE", "GDEF", "GPOS", "GSUB", "EBSC", "JSTF", "MATH",
+	"CBDT", "CBLC", "COLR", "CPAL", "SVG ", "sbix", "acnt", "avar",
+	"bdat", "bloc", "bsln", "cvar", "fdsc", "feat", "fmtx", "fvar",
+	"gvar", "hsty", "just", "lcar", "mort", "morx", "opbd", "prop",
+	"trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+// Glyph flags of composite glyphs.
+const (
+	compositeArgsAreWords   = 0x0001
+	compositeHaveScale      = 0x0008
+	compositeMoreComponents = 0x0020
+	compositeHaveXYScale    = 0x0040
+	compositeHave2x2        = 0x0080
+	compositeHaveInstrs     = 0x0100
+)
+
+var errWOFF2Collection = errors.New("opentype: WOFF2 collections are not supported")
+
+// woffReader reads the big-endian values of WOFF2 data. Reads past
+// the end of the data return zero and set err.
+type woffReader struct {
+	data []byte
+	err  bool
+}
+
+// woff2Entry is a table directory entry of a WOFF2 file.
+type woff2Entry struct {
+	tag       string
+	transform bool
+	// length is the length of the table in the decompressed
+	// data.
+	length int
+}
+
+// decodeWOFF2 converts WOFF2 data to SFNT data. Transformed glyf,
+// loca and hmtx tables are reconstructed.
+func decodeWOFF2(src []byte) ([]byte, error) {
+	r := &woffReader{data: src}
+	r.skip(4)
+	flavor := r.bytes(4)
+	r.skip(4) // length
+	n := int(r.u16())
+	r.skip(2 + 4) // reserved, totalSfntSize
+	compSize := int(r.u32())
+	r.skip(2 + 2 + 4 + 4 + 4 + 4 + 4) // version, metadata and private data
+	if r.err {
+		return nil, errInvalidWOFF
+	}
+	if string(flavor) == "ttcf" {
+		return nil, errWOFF2Collection
+	}
+	entries := make([]woff2Entry, n)
+	total := 0
+	for i := range entries {
+		e := &entries[i]
+		flags := r.u8()
+		if idx := flags & 0x3f; idx == 0x3f {
+			e.tag = string(r.bytes(4))
+		} else {
+			e.tag = woff2Tags[idx]
+		}
+		version := flags >> 6
+		if e.tag == "glyf" || e.tag == "loca" {
+			e.transform = version != 3
+		} else {
+			e.transform = version != 0
+		}
+		e.length = int(r.base128())
+		if e.transform {
+			e.length = int(r.base128())
+			if e.tag != "glyf" && e.tag != "loca" && e.tag != "hmtx" {
+				return nil, errInvalidWOFF
+			}
+		}
+		total += e.length
+		if total > maxWOFFSize {
+			return nil, errInvalidWOFF
+		}
+	}
+	comp := r.bytes(compSize)
+	if r.err {
+		return nil, errInvalidWOFF
+	}
+	data := make([]byte, total)
+	if _, err := io.ReadFull(brotli.NewReader(bytes.NewReader(comp)), data); err != nil {
+		return nil, err
+	}
+	tables := make([]sfntTable, 0, n)
+	var glyf, hmtx *woff2Entry
+	var glyfData, hmtxData, hhea []byte
+	for i := range entries {
+		e := &entries[i]
+		d := data[:e.length]
+		data = data[e.length:]
+		switch {
+		case e.tag == "hhea":
+			hhea = d
+		case e.tag == "glyf" && e.transform:
+			glyf, glyfData = e, d
+			continue
+		case e.tag == "loca" && e.transform:
+			// Reconstructed from glyf.
+			continue
+		case e.tag == "hmtx" && e.transform:
+			hmtx, hmtxData = e, d
+			continue
+		}
+		tables = append(tables, sfntTable{tag: e.tag, data: d})
+	}
+	var xMins []int16
+	if glyf != nil {
+		g, loca, mins, err := reconstructGlyf(glyfData)
+		if err != nil {
+			return nil, err
+		}
+		xMins = mins
+		tables = append(tables, sfntTable{tag: "glyf", data: g}, sfntTable{tag: "loca", data: loca})
+	}
+	if hmtx != nil {
+		if xMins == nil || len(hhea) < 36 {
+			return nil, errInvalidWOFF
+		}
+		numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+		h, err := reconstructHmtx(hmtxData, numHMetrics, xMins)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, sfntTable{tag: "hmtx", data: h})
+	}
+	return buildSFNT(flavor, tables), nil
+}
+
+// reconstructGlyf converts a transformed glyf table to glyf and loca
+// tables. It also returns the minimum x coordinate of every glyph.
+func reconstructGlyf(data []byte) (glyf, loca []byte, xMins []int16, err error) {
+	r := &woffReader{data: data}
+	r.skip(2) // reserved
+	options := r.u16()
+	numGlyphs := int(r.u16())
+	indexFormat := r.u16()
+	var streams [7]*woffReader
+	var sizes [len(streams)]int
+	for i := range sizes {
+		sizes[i] = int(r.u32())
+	}
+	for i, size := range sizes {
+		streams[i] = &woffReader{data: r.bytes(size)}
+	}
+	nContours, nPoints, flags, glyphs, composites, bboxes, instrs := streams[0], streams[1], streams[2], streams[3], streams[4], streams[5], streams[6]
+	bboxBitmap := bboxes.bytes(((numGlyphs + 31) >> 5) << 2)
+	var overlapBitmap []byte
+	if options&1 != 0 {
+		overlapBitmap = r.bytes((numGlyphs + 7) >> 3)
+	}
+	if r.err {
+		return nil, nil, nil, errInvalidWOFF
+	}
+	bit := func(bitmap []byte, i int) bool {
+		return bitmap != nil && bitmap[i>>3]&(0x80>>(i&7)) != 0
+	}
+	be := binary.BigEndian
+	put16 := func(v uint16) {
+		glyf = append(glyf, byte(v>>8), byte(v))
+	}
+	xMins = make([]int16, numGlyphs)
+	offsets := make([]int, numGlyphs+1)
+	var xs, ys []int
+	var onCurve []bool
+	for i := 0; i < numGlyphs; i++ {
+		offsets[i] = len(glyf)
+		n := int16(nContours.u16())
+		hasBBox := bit(bboxBitmap, i)
+		var bbox [4]int16
+		if hasBBox {
+			for j := range bbox {
+				bbox[j] = bboxes.i16()
+			}
+		}
+		switch {
+		case n == 0:
+			if hasBBox {
+				return nil, nil, nil, errInvalidWOFF
+			}
+		case n > 0:
+			endPts := make([]int, n)
+			total := 0
+			for j := range endPts {
+				total += int(nPoints.uint255())
+				endPts[j] = total - 1
+			}
+			if total > 0xffff {
+				return nil, nil, nil, errInvalidWOFF
+			}
+			xs, ys, onCurve = xs[:0], ys[:0], onCurve[:0]
+			x, y := 0, 0
+			for j := 0; j < total; j++ {
+				dx, dy, on := glyphs.triplet(flags.u8())
+				x, y = x+dx, y+dy
+				xs, ys, onCurve = append(xs, x), append(ys, y), append(onCurve, on)
+			}
+			ninstrs := int(glyphs.uint255())
+			if !hasBBox && total > 0 {
+				bbox = [4]int16{int16(xs[0]), int16(ys[0]), int16(xs[0]), int16(ys[0])}
+				for j := range xs {
+					x, y := int16(xs[j]), int16(ys[j])
+					if x < bbox[0] {
+						bbox[0] = x
+					}
+					if y < bbox[1] {
+						bbox[1] = y
+					}
+					if x > bbox[2] {
+						bbox[2] = x
+					}
+					if y > bbox[3] {
+						bbox[3] = y
+					}
+				}
+			}
+			put16(uint16(n))
+			for _, v := range bbox {
+				put16(uint16(v))
+			}
+			for _, e := range endPts {
+				put16(uint16(e))
+			}
+			put16(uint16(ninstrs))
+			glyf = append(glyf, instrs.bytes(ninstrs)...)
+			glyf = appendPoints(glyf, xs, ys, onCurve, bit(overlapBitmap, i))
+		case n == -1:
+			if !hasBBox {
+				return nil, nil, nil, errInvalidWOFF
+			}
+			put16(uint16(n))
+			for _, v := range bbox {
+				put16(uint16(v))
+			}
+			records := composites.data
+			haveInstrs := false
+			for {
+				f := composites.u16()
+				composites.skip(2) // glyph index
+				size := 2
+				if f&compositeArgsAreWords != 0 {
+					size = 4
+				}
+				switch {
+				case f&compositeHaveScale != 0:
+					size += 2
+				case f&compositeHaveXYScale != 0:
+					size += 4
+				case f&compositeHave2x2 != 0:
+					size += 8
+				}
+				composites.skip(size)
+				haveInstrs = haveInstrs || f&compositeHaveInstrs != 0
+				if f&compositeMoreComponents == 0 || composites.err {
+					break
+				}
+			}
+			if composites.err {
+				return nil, nil, nil, errInvalidWOFF
+			}
+			glyf = append(glyf, records[:len(records)-len(composites.data)]...)
+			if haveInstrs {
+				ninstrs := int(glyphs.uint255())
+				put16(uint16(ninstrs))
+				glyf = append(glyf, instrs.bytes(ninstrs)...)
+			}
+		default:
+			return nil, nil, nil, errInvalidWOFF
+		}
+		xMins[i] = bbox[0]
+		for len(glyf)%4 != 0 {
+			glyf = append(glyf, 0)
+		}
+		if len(glyf) > maxWOFFSize {
+			return nil, nil, nil, errInvalidWOFF
+		}
+	}
+	offsets[numGlyphs] = len(glyf)
+	for _, s := range streams {
+		if s.err {
+			return nil, nil, nil, errInvalidWOFF
+		}
+	}
+	if indexFormat == 0 {
+		if len(glyf) > 2*0xffff {
+			// The offsets don't fit the short loca format.
+			return nil, nil, nil, errInvalidWOFF
+		}
+		loca = make([]byte, 2*len(offsets))
+		for i, off := range offsets {
+			be.PutUint16(loca[2*i:], uint16(off/2))
+		}
+	} else {
+		loca = make([]byte, 4*len(offsets))
+		for i, off := range offsets {
+			be.PutUint32(loca[4*i:], uint32(off))
+		}
+	}
+	return glyf, loca, xMins, nil
+}
+
+// appendPoints appends the flags and coordinates of the points of a
+// simple glyph to glyf, using the smallest encoding of the coordinate
+// deltas.
+func appendPoints(glyf []byte, xs, ys []int, onCurve []bool, overlap bool) []byte {
+	const (
+		onCurvePoint = 0x01
+		xShort       = 0x02
+		yShort       = 0x04
+		repeat       = 0x08
+		xSame        = 0x10
+		ySame        = 0x20
+		overlapFlag  = 0x40
+	)
+	flags := make([]byte, len(xs))
+	// deltaFlags returns the flags of a coordinate delta. same
+	// doubles as the sign of short deltas.
+	deltaFlags := func(d int, short, same byte) byte {
+		switch {
+		case d == 0:
+			return same
+		case d > 0 && d < 256:
+			return short | same
+		case d < 0 && d > -256:
+			return short
+		default:
+			return 0
+		}
+	}
+	px, py := 0, 0
+	for j := range xs {
+		var f byte
+		if onCurve[j] {
+			f |= onCurvePoint
+		}
+		if j == 0 && overlap {
+			f |= overlapFlag
+		}
+		f |= deltaFlags(xs[j]-px, xShort, xSame)
+		f |= deltaFlags(ys[j]-py, yShort, ySame)
+		flags[j] = f
+		px, py = xs[j], ys[j]
+	}
+	for j := 0; j < len(flags); {
+		n := 1
+		for j+n < len(flags) && flags[j+n] == flags[j] && n <= 255 {
+			n++
+		}
+		if n > 1 {
+			glyf = append(glyf, flags[j]|repeat, byte(n-1))
+		} else {
+			glyf = append(glyf, flags[j])
+		}
+		j += n
+	}
+	for _, c := range [...]struct {
+		vals        []int
+		short, same byte
+	}{{xs, xShort, xSame}, {ys, yShort, ySame}} {
+		prev := 0
+		for j, v := range c.vals {
+			d := v - prev
+			prev = v
+			switch f := flags[j]; {
+			case f&c.short != 0:
+				if d < 0 {
+					d = -d
+				}
+				glyf = append(glyf, byte(d))
+			case f&c.same == 0:
+				glyf = append(glyf, byte(uint16(d)>>8), byte(d))
+			}
+		}
+	}
+	return glyf
+}
+
+// reconstructHmtx converts a transformed hmtx table to a hmtx table.
+// Missing left side bearings are the minimum x coordinates of the
+// glyphs.
+func reconstructHmtx(data []byte, numHMetrics int, xMins []int16) ([]byte, error) {
+	numGlyphs := len(xMins)
+	if numHMetrics < 1 || numHMetrics > numGlyphs {
+		return nil, errInvalidWOFF
+	}
+	r := &woffReader{data: data}
+	flags := r.u8()
+	advances := make([]uint16, numHMetrics)
+	for i := range advances {
+		advances[i] = r.u16()
+	}
+	lsbs := make([]int16, numGlyphs)
+	for i := range lsbs {
+		// Bit 0 marks missing bearings of proportional glyphs, bit 1
+		// of monospaced glyphs.
+		missing := flags&1 != 0
+		if i >= numHMetrics {
+			missing = flags&2 != 0
+		}
+		if missing {
+			lsbs[i] = xMins[i]
+		} else {
+			lsbs[i] = r.i16()
+		}
+	}
+	if r.err {
+		return nil, errInvalidWOFF
+	}
+	out := make([]byte, 0, 4*numHMetrics+2*(numGlyphs-numHMetrics))
+	for i, lsb := range lsbs {
+		if i < numHMetrics {
+			out = append(out, byte(advances[i]>>8), byte(advances[i]))
+		}
+		out = append(out, byte(uint16(lsb)>>8), byte(lsb))
+	}
+	return out, nil
+}
+
+// triplet decodes the coordinate deltas of a point from its flag and
+// the triplet data of r, and reports whether the point is on the
+// curve.
+func (r *woffReader) triplet(flag byte) (dx, dy int, onCurve bool) {
+	onCurve = flag&0x80 == 0
+	f := int(flag & 0x7f)
+	sign := func(f, v int) int {
+		if f&1 != 0 {
+			return v
+		}
+		return -v
+	}
+	switch {
+	case f < 10:
+		dy = sign(f, (f&14)<<7+int(r.u8()))
+	case f < 20:
+		dx = sign(f, ((f-10)&14)<<7+int(r.u8()))
+	case f < 84:
+		b0, b1 := f-20, int(r.u8())
+		dx = sign(f, 1+b0&0x30+b1>>4)
+		dy = sign(f>>1, 1+(b0&0x0c)<<2+b1&0x0f)
+	case f < 120:
+		b0 := f - 84
+		dx = sign(f, 1+(b0/12)<<8+int(r.u8()))
+		dy = sign(f>>1, 1+((b0%12)>>2)<<8+int(r.u8()))
+	case f < 124:
+		b0, b1, b2 := int(r.u8()), int(r.u8()), int(r.u8())
+		dx = sign(f, b0<<4+b1>>4)
+		dy = sign(f>>1, (b1&0x0f)<<8+b2)
+	default:
+		dx = sign(f, int(r.u16()))
+		dy = sign(f>>1, int(r.u16()))
+	}
+	return dx, dy, onCurve
+}
+
+func (r *woffReader) bytes(n int) []byte {
+	if n < 0 || n > len(r.data) {
+		r.err = true
+		r.data = nil
+		return nil
+	}
+	b := r.data[:n:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *woffReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *woffReader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *woffReader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *woffReader) i16() int16 {
+	return int16(r.u16())
+}
+
+func (r *woffReader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// base128 reads a UIntBase128 value.
+func (r *woffReader) base128() uint32 {
+	var v uint32
+	for i := 0; i < 5; i++ {
+		b := r.u8()
+		// Leading zeros and overflows are invalid.
+		if i == 0 && b == 0x80 || v&0xfe000000 != 0 {
+			r.err = true
+			return 0
+		}
+		v = v<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	r.err = true
+	return 0
+}
+
+// uint255 reads a 255UInt16 value.
+func (r *woffReader) uint255() uint16 {
+	switch code := r.u8(); code {
+	case 253:
+		return r.u16()
+	case 254:
+		return uint16(r.u8()) + 253*2
+	case 255:
+		return uint16(r.u8()) + 253
+	default:
+		return uint16(code)
+	}
+}
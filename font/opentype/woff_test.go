@@ -0,0 +1,469 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// countingReaderAt counts the bytes read from a ReaderAt.
+type countingReaderAt struct {
+	r *bytes.Reader
+	n int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func TestParseReaderAt(t *testing.T) {
+	src := &countingReaderAt{r: bytes.NewReader(goregular.TTF)}
+	f, err := ParseReaderAt(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The outlines are not read when parsing.
+	if n := atomic.LoadInt64(&src.n); n > int64(len(goregular.TTF))/2 {
+		t.Errorf("parsing read %d bytes of %d", n, len(goregular.TTF))
+	}
+	want, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareFonts(t, want, f)
+}
+
+func TestWOFF(t *testing.T) {
+	for _, ttf := range [][]byte{goregular.TTF, gobold.TTF} {
+		want, err := Parse(ttf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		woff := encodeWOFF(ttf)
+		f, err := Parse(woff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareFonts(t, want, f)
+		f, err = ParseReaderAt(bytes.NewReader(woff))
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareFonts(t, want, f)
+	}
+}
+
+func TestWOFF2(t *testing.T) {
+	for _, transform := range []bool{false, true} {
+		want, err := Parse(goregular.TTF)
+		if err != nil {
+			t.Fatal(err)
+		}
+		woff2 := encodeWOFF2(t, goregular.TTF, transform)
+		f, err := Parse(woff2)
+		if err != nil {
+			t.Fatalf("transform %v: %v", transform, err)
+		}
+		compareFonts(t, want, f)
+		c, err := ParseCollection(woff2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.NumFonts() != 1 {
+			t.Errorf("got %d fonts, expected 1", c.NumFonts())
+		}
+	}
+}
+
+func TestTruncatedWOFF(t *testing.T) {
+	for _, data := range [][]byte{encodeWOFF(goregular.TTF), encodeWOFF2(t, goregular.TTF, true)} {
+		for n := 0; n < len(data); n += 97 {
+			if _, err := decodeWOFF(data[:n]); err == nil {
+				t.Errorf("decoding %d of %d bytes succeeded", n, len(data))
+			}
+		}
+	}
+}
+
+// compareFonts compares the glyph outlines, advances and layout of
+// two fonts.
+func compareFonts(t *testing.T, want, got *Font) {
+	t.Helper()
+	var buf1, buf2 sfnt.Buffer
+	ppem := fixed.I(64)
+	n := want.font.NumGlyphs()
+	if got.font.NumGlyphs() != n {
+		t.Fatalf("got %d glyphs, expected %d", got.font.NumGlyphs(), n)
+	}
+	for i := 0; i < n; i++ {
+		gid := sfnt.GlyphIndex(i)
+		s1, err1 := want.font.LoadGlyph(&buf1, gid, ppem, nil)
+		s2, err2 := got.font.LoadGlyph(&buf2, gid, ppem, nil)
+		if (err1 == nil) != (err2 == nil) || !reflect.DeepEqual(s1, s2) {
+			t.Fatalf("glyph %d: got outline %v (%v), expected %v (%v)", i, s2, err2, s1, err1)
+		}
+		a1, _ := want.font.GlyphAdvance(&buf1, gid, ppem, font.HintingNone)
+		a2, _ := got.font.GlyphAdvance(&buf2, gid, ppem, font.HintingNone)
+		if a1 != a2 {
+			t.Fatalf("glyph %d: got advance %v, expected %v", i, a2, a1)
+		}
+	}
+	const str = "The quick brown fox"
+	l1, err := want.Layout(fixed.I(20), 1e6, strings.NewReader(str))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2, err := got.Layout(fixed.I(20), 1e6, strings.NewReader(str))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(l1, l2) {
+		t.Errorf("got layout %+v, expected %+v", l2, l1)
+	}
+}
+
+// sfntTables returns the tables of an SFNT font in directory order.
+func sfntTables(ttf []byte) []sfntTable {
+	var tables []sfntTable
+	n := int(binary.BigEndian.Uint16(ttf[4:]))
+	for i := 0; i < n; i++ {
+		rec := ttf[12+16*i:]
+		off, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		tables = append(tables, sfntTable{tag: string(rec[:4]), data: ttf[off : off+length]})
+	}
+	return tables
+}
+
+// encodeWOFF returns the WOFF encoding of an SFNT font.
+func encodeWOFF(ttf []byte) []byte {
+	tables := sfntTables(ttf)
+	var data bytes.Buffer
+	type entry struct {
+		off, compLen, origLen int
+	}
+	entries := make([]entry, len(tables))
+	dataOff := 44 + 20*len(tables)
+	for i, tbl := range tables {
+		var comp bytes.Buffer
+		zw := zlib.NewWriter(&comp)
+		zw.Write(tbl.data)
+		zw.Close()
+		d := comp.Bytes()
+		if len(d) >= len(tbl.data) {
+			d = tbl.data
+		}
+		entries[i] = entry{off: dataOff + data.Len(), compLen: len(d), origLen: len(tbl.data)}
+		data.Write(d)
+		for data.Len()%4 != 0 {
+			data.WriteByte(0)
+		}
+	}
+	var buf bytes.Buffer
+	w := func(v interface{}) {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	buf.WriteString(woffSignature)
+	buf.Write(ttf[:4])
+	w(uint32(dataOff + data.Len()))
+	w(uint16(len(tables)))
+	w(uint16(0))
+	w(uint32(len(ttf)))
+	w([6]uint32{})
+	for i, tbl := range tables {
+		e := entries[i]
+		buf.WriteString(tbl.tag)
+		w([4]uint32{uint32(e.off), uint32(e.compLen), uint32(e.origLen), tableChecksum(tbl.data)})
+	}
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+// encodeWOFF2 returns the WOFF2 encoding of an SFNT font with
+// TrueType outlines, optionally with transformed glyf, loca and hmtx
+// tables.
+func encodeWOFF2(t *testing.T, ttf []byte, transform bool) []byte {
+	tables := sfntTables(ttf)
+	var dir, data bytes.Buffer
+	var xMins []int16
+	for _, tbl := range tables {
+		idx := byte(0x3f)
+		for i, tag := range woff2Tags {
+			if tag == tbl.tag {
+				idx = byte(i)
+			}
+		}
+		d := tbl.data
+		version := byte(0)
+		switch tbl.tag {
+		case "glyf":
+			version = 3
+			if transform {
+				version = 0
+				head, maxp := findTable(ttf, "head"), findTable(ttf, "maxp")
+				d, xMins = transformGlyf(t, tbl.data, findTable(ttf, "loca"), int(maxp.u16(4)), head.u16(50))
+			}
+		case "loca":
+			version = 3
+			if transform {
+				version = 0
+				d = nil
+			}
+		case "hmtx":
+			if transform {
+				version = 1
+				// glyf precedes hmtx in the directory.
+				d = transformHmtx(tbl.data, int(findTable(ttf, "hhea").u16(34)), xMins)
+			}
+		}
+		dir.WriteByte(idx | version<<6)
+		if idx == 0x3f {
+			dir.WriteString(tbl.tag)
+		}
+		dir.Write(base128(len(tbl.data)))
+		if tbl.tag == "glyf" || tbl.tag == "loca" {
+			if version == 0 {
+				dir.Write(base128(len(d)))
+			}
+		} else if version != 0 {
+			dir.Write(base128(len(d)))
+		}
+		data.Write(d)
+	}
+	var comp bytes.Buffer
+	bw := brotli.NewWriter(&comp)
+	bw.Write(data.Bytes())
+	bw.Close()
+	var buf bytes.Buffer
+	w := func(v interface{}) {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	buf.WriteString(woff2Signature)
+	buf.Write(ttf[:4])
+	w(uint32(48 + dir.Len() + comp.Len()))
+	w(uint16(len(tables)))
+	w(uint16(0))
+	w(uint32(len(ttf)))
+	w(uint32(comp.Len()))
+	w([2]uint16{1, 0})
+	w([5]uint32{})
+	buf.Write(dir.Bytes())
+	buf.Write(comp.Bytes())
+	return buf.Bytes()
+}
+
+func base128(v int) []byte {
+	var b []byte
+	for {
+		b = append([]byte{byte(v & 0x7f)}, b...)
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := range b[:len(b)-1] {
+		b[i] |= 0x80
+	}
+	return b
+}
+
+func uint255(v int) []byte {
+	if v < 253 {
+		return []byte{byte(v)}
+	}
+	return []byte{253, byte(v >> 8), byte(v)}
+}
+
+// transformGlyf returns the WOFF2 transformed glyf table of a font,
+// and the minimum x coordinate of every glyph.
+func transformGlyf(t *testing.T, glyf, loca table, numGlyphs int, indexFormat uint16) ([]byte, []int16) {
+	var nContours, nPoints, flags, glyphs, composites, bboxes, instrs bytes.Buffer
+	bitmap := make([]byte, ((numGlyphs+31)>>5)<<2)
+	xMins := make([]int16, numGlyphs)
+	w := func(b *bytes.Buffer, v interface{}) {
+		binary.Write(b, binary.BigEndian, v)
+	}
+	for i := 0; i < numGlyphs; i++ {
+		var start, end int
+		if indexFormat == 0 {
+			start, end = 2*int(loca.u16(2*i)), 2*int(loca.u16(2*i+2))
+		} else {
+			start, end = int(loca.u32(4*i)), int(loca.u32(4*i+4))
+		}
+		if start == end {
+			w(&nContours, int16(0))
+			continue
+		}
+		g := glyf[start:end]
+		n := g.i16(0)
+		w(&nContours, n)
+		xMins[i] = g.i16(2)
+		p := 10
+		if n < 0 {
+			bitmap[i>>3] |= 0x80 >> (i & 7)
+			bboxes.Write(g[2:10])
+			haveInstrs := false
+			for {
+				f := g.u16(p)
+				size := 4 + 2
+				if f&compositeArgsAreWords != 0 {
+					size += 2
+				}
+				switch {
+				case f&compositeHaveScale != 0:
+					size += 2
+				case f&compositeHaveXYScale != 0:
+					size += 4
+				case f&compositeHave2x2 != 0:
+					size += 8
+				}
+				composites.Write(g[p : p+size])
+				p += size
+				haveInstrs = haveInstrs || f&compositeHaveInstrs != 0
+				if f&compositeMoreComponents == 0 {
+					break
+				}
+			}
+			if haveInstrs {
+				ni := int(g.u16(p))
+				glyphs.Write(uint255(ni))
+				instrs.Write(g[p+2 : p+2+ni])
+			}
+			continue
+		}
+		prev := -1
+		for c := 0; c < int(n); c++ {
+			e := int(g.u16(p))
+			nPoints.Write(uint255(e - prev))
+			prev = e
+			p += 2
+		}
+		total := prev + 1
+		ni := int(g.u16(p))
+		instr := g[p+2 : p+2+ni]
+		p += 2 + ni
+		var pf []byte
+		for len(pf) < total {
+			f := g[p]
+			p++
+			pf = append(pf, f)
+			if f&0x08 != 0 {
+				for r := g[p]; r > 0; r-- {
+					pf = append(pf, f)
+				}
+				p++
+			}
+		}
+		coords := func(short, same byte) []int {
+			var ds []int
+			for _, f := range pf {
+				switch {
+				case f&short != 0:
+					d := int(g[p])
+					p++
+					if f&same == 0 {
+						d = -d
+					}
+					ds = append(ds, d)
+				case f&same == 0:
+					ds = append(ds, int(g.i16(p)))
+					p += 2
+				default:
+					ds = append(ds, 0)
+				}
+			}
+			return ds
+		}
+		dxs := coords(0x02, 0x10)
+		dys := coords(0x04, 0x20)
+		for j, f := range pf {
+			dx, dy := dxs[j], dys[j]
+			flag := byte(124)
+			if dx >= 0 {
+				flag |= 1
+			} else {
+				dx = -dx
+			}
+			if dy >= 0 {
+				flag |= 2
+			} else {
+				dy = -dy
+			}
+			if f&0x01 == 0 {
+				flag |= 0x80
+			}
+			flags.WriteByte(flag)
+			w(&glyphs, [2]uint16{uint16(dx), uint16(dy)})
+		}
+		glyphs.Write(uint255(ni))
+		instrs.Write(instr)
+	}
+	var out bytes.Buffer
+	w(&out, [4]uint16{0, 0, uint16(numGlyphs), indexFormat})
+	streams := []*bytes.Buffer{&nContours, &nPoints, &flags, &glyphs, &composites, &bboxes, &instrs}
+	for _, s := range streams {
+		size := s.Len()
+		if s == &bboxes {
+			size += len(bitmap)
+		}
+		w(&out, uint32(size))
+	}
+	for _, s := range streams {
+		if s == &bboxes {
+			out.Write(bitmap)
+		}
+		out.Write(s.Bytes())
+	}
+	return out.Bytes(), xMins
+}
+
+// transformHmtx returns the WOFF2 transformed hmtx table of a font,
+// leaving out the left side bearings that equal the minimum x
+// coordinates of glyphs.
+func transformHmtx(hmtx table, numHMetrics int, xMins []int16) []byte {
+	lsb := func(i int) int16 {
+		if i < numHMetrics {
+			return hmtx.i16(4*i + 2)
+		}
+		return hmtx.i16(4*numHMetrics + 2*(i-numHMetrics))
+	}
+	flags := byte(3)
+	for i := range xMins {
+		if lsb(i) != xMins[i] {
+			if i < numHMetrics {
+				flags &^= 1
+			} else {
+				flags &^= 2
+			}
+		}
+	}
+	out := []byte{flags}
+	for i := 0; i < numHMetrics; i++ {
+		out = append(out, hmtx[4*i:4*i+2]...)
+	}
+	for i := range xMins {
+		missing := flags&1 != 0
+		if i >= numHMetrics {
+			missing = flags&2 != 0
+		}
+		if !missing {
+			l := lsb(i)
+			out = append(out, byte(uint16(l)>>8), byte(l))
+		}
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package sysfont
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// configElem is a <dir> or <include> element of a fontconfig
+// configuration.
+type configElem struct {
+	Prefix        string `xml:"prefix,attr"`
+	IgnoreMissing string `xml:"ignore_missing,attr"`
+	Path          string `xml:",chardata"`
+}
+
+// ParseConfig returns the font directories of a fontconfig
+// configuration file, including the directories of the files it
+// includes. Included files that are missing or malformed are ignored.
+func ParseConfig(path string) ([]string, error) {
+	p := &configParser{visited: make(map[string]bool)}
+	if err := p.parseFile(path); err != nil {
+		return nil, err
+	}
+	return p.dirs, nil
+}
+
+type configParser struct {
+	dirs    []string
+	visited map[string]bool
+}
+
+func (p *configParser) parseFile(path string) error {
+	path = filepath.Clean(path)
+	if p.visited[path] {
+		return nil
+	}
+	p.visited[path] = true
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d := xml.NewDecoder(f)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "dir", "include":
+		default:
+			continue
+		}
+		var e configElem
+		if err := d.DecodeElement(&e, &start); err != nil {
+			return err
+		}
+		if start.Name.Local == "dir" {
+			dir := expandPath(e, filepath.Dir(path), xdgDataHome)
+			if dir != "" {
+				p.dirs = append(p.dirs, dir)
+			}
+			continue
+		}
+		p.include(expandPath(e, filepath.Dir(path), xdgConfigHome))
+	}
+}
+
+// include parses an included file, or the *.conf files of an
+// included directory in sorted order.
+func (p *configParser) include(path string) {
+	if path == "" {
+		return
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if !fi.IsDir() {
+		p.parseFile(path)
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(path, "*.conf"))
+	if err != nil {
+		return
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		p.parseFile(f)
+	}
+}
+
+// expandPath returns the path of an element, relative to dir unless
+// it is absolute, or starts with ~ for the home directory. The xdg
+// prefix denotes a path relative to the directory returned by xdg.
+func expandPath(e configElem, dir string, xdg func() string) string {
+	path := strings.TrimSpace(e.Path)
+	if path == "" {
+		return ""
+	}
+	switch {
+	case e.Prefix == "xdg":
+		base := xdg()
+		if base == "" {
+			return ""
+		}
+		return filepath.Join(base, path)
+	case path == "~" || strings.HasPrefix(path, "~/"):
+		home := os.Getenv("HOME")
+		if home == "" {
+			return ""
+		}
+		return filepath.Join(home, path[1:])
+	case filepath.IsAbs(path):
+		return filepath.Clean(path)
+	default:
+		return filepath.Join(dir, path)
+	}
+}
+
+func xdgDataHome() string {
+	return xdgDir("XDG_DATA_HOME", ".local/share")
+}
+
+func xdgConfigHome() string {
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+// xdgDir returns the directory of an XDG environment variable, or the
+// default directory relative to the home directory.
+func xdgDir(env, def string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, def)
+	}
+	return ""
+}
@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build !linux && !freebsd && !openbsd
+// +build !linux,!freebsd,!openbsd
+
+package sysfont
+
+// Dirs returns nil; fontconfig is only supported on Linux and BSD.
+func Dirs() []string {
+	return nil
+}
@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux || freebsd || openbsd
+// +build linux freebsd openbsd
+
+package sysfont
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Dirs returns the font directories of the fontconfig configuration
+// named by $FONTCONFIG_FILE, or /etc/fonts/fonts.conf. If the
+// configuration can't be read, Dirs returns the usual font
+// directories.
+func Dirs() []string {
+	conf := os.Getenv("FONTCONFIG_FILE")
+	if conf == "" {
+		conf = "/etc/fonts/fonts.conf"
+	}
+	if dirs, err := ParseConfig(conf); err == nil {
+		return dirs
+	}
+	dirs := []string{"/usr/share/fonts", "/usr/local/share/fonts"}
+	if data := xdgDataHome(); data != "" {
+		dirs = append(dirs, filepath.Join(data, "fonts"))
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".fonts"))
+	}
+	return dirs
+}
@@ -0,0 +1,638 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package sysfont discovers the fonts installed on a system and
+// builds collections of them for text.NewCache.
+//
+// The fonts are found in the font directories of the fontconfig
+// configuration, read without the fontconfig library. The faces of a
+// collection fall back to the fonts of other typefaces for runes of
+// the scripts they don't support.
+package sysfont
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/font/opentype"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/text"
+)
+
+// Font describes a font of a font file.
+type Font struct {
+	// Path is the path of the font file.
+	Path string
+	// Index is the index of the font in a font collection file.
+	Index    int
+	Typeface text.Typeface
+	// Variant is the width of the font, such as "Condensed", or
+	// empty for normal width fonts.
+	Variant text.Variant
+	Style   text.Style
+	Weight  text.Weight
+	// Scripts lists the scripts supported by the font, by their
+	// names in unicode.Scripts.
+	Scripts []string
+}
+
+// Index is an index of fonts.
+type Index struct {
+	// Fonts is sorted by typeface, variant, style and weight.
+	Fonts []Font
+}
+
+// Fallbacks maps script names in unicode.Scripts to the typefaces
+// used for runes of the script, in order of preference.
+type Fallbacks map[string][]text.Typeface
+
+// maxFallbacks is the number of typefaces of the fallback chain of a
+// script used by the faces of a collection.
+const maxFallbacks = 2
+
+// scriptSamples contains a common letter of every script detected by
+// Scan.
+var scriptSamples = map[string]rune{
+	"Arabic":     'ب',
+	"Armenian":   'ա',
+	"Bengali":    'ক',
+	"Cyrillic":   'д',
+	"Devanagari": 'क',
+	"Ethiopic":   'አ',
+	"Georgian":   'ა',
+	"Greek":      'α',
+	"Gujarati":   'ક',
+	"Gurmukhi":   'ਕ',
+	"Han":        '中',
+	"Hangul":     '한',
+	"Hebrew":     'א',
+	"Hiragana":   'あ',
+	"Kannada":    'ಕ',
+	"Katakana":   'ア',
+	"Khmer":      'ក',
+	"Lao":        'ກ',
+	"Latin":      'a',
+	"Malayalam":  'ക',
+	"Myanmar":    'က',
+	"Sinhala":    'ක',
+	"Tamil":      'க',
+	"Telugu":     'క',
+	"Thai":       'ก',
+	"Tibetan":    'ཀ',
+}
+
+// widthVariants maps OS/2 width classes to variant names.
+var widthVariants = [...]text.Variant{
+	1: "UltraCondensed",
+	2: "ExtraCondensed",
+	3: "Condensed",
+	4: "SemiCondensed",
+	6: "SemiExpanded",
+	7: "Expanded",
+	8: "ExtraExpanded",
+	9: "UltraExpanded",
+}
+
+// Load returns the index of the fonts in the font directories of the
+// system.
+func Load() *Index {
+	return Scan(Dirs())
+}
+
+// Scan returns the index of the fonts in dirs and their
+// subdirectories. Files that are not TrueType or OpenType fonts or
+// collections are ignored, as are directories that can't be read.
+func Scan(dirs []string) *Index {
+	x := new(Index)
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				if seen[path] {
+					return filepath.SkipDir
+				}
+				seen[path] = true
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".ttf", ".otf", ".ttc", ".otc":
+				x.Fonts = append(x.Fonts, scanFile(path)...)
+			}
+			return nil
+		})
+	}
+	sort.SliceStable(x.Fonts, func(i, j int) bool {
+		f1, f2 := x.Fonts[i], x.Fonts[j]
+		switch {
+		case f1.Typeface != f2.Typeface:
+			return f1.Typeface < f2.Typeface
+		case f1.Variant != f2.Variant:
+			return f1.Variant < f2.Variant
+		case f1.Style != f2.Style:
+			return f1.Style < f2.Style
+		default:
+			return f1.Weight < f2.Weight
+		}
+	})
+	return x
+}
+
+// scanFile returns the fonts of a font file.
+func scanFile(path string) []Font {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	coll, err := sfnt.ParseCollectionReaderAt(f)
+	if err != nil {
+		return nil
+	}
+	offsets := fontOffsets(f, coll.NumFonts())
+	var fonts []Font
+	var buf sfnt.Buffer
+	for i := 0; i < coll.NumFonts(); i++ {
+		fnt, err := coll.Font(i)
+		if err != nil {
+			continue
+		}
+		family, err := fnt.Name(&buf, sfnt.NameIDTypographicFamily)
+		if err != nil || family == "" {
+			family, _ = fnt.Name(&buf, sfnt.NameIDFamily)
+		}
+		if family == "" {
+			family = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		desc := Font{Path: path, Index: i, Typeface: text.Typeface(family)}
+		if sub, err := fnt.Name(&buf, sfnt.NameIDSubfamily); err == nil {
+			if s := strings.ToLower(sub); strings.Contains(s, "italic") || strings.Contains(s, "oblique") {
+				desc.Style = text.Italic
+			}
+		}
+		if offsets != nil {
+			readOS2(f, offsets[i], &desc)
+		}
+		for _, name := range sortedScripts() {
+			if id, err := fnt.GlyphIndex(&buf, scriptSamples[name]); err == nil && id != 0 {
+				desc.Scripts = append(desc.Scripts, name)
+			}
+		}
+		fonts = append(fonts, desc)
+	}
+	return fonts
+}
+
+// fontOffsets returns the offsets of the fonts of a font file, or nil
+// if the file is malformed.
+func fontOffsets(r io.ReaderAt, n int) []int64 {
+	var hdr [12]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil
+	}
+	if string(hdr[:4]) != "ttcf" {
+		return []int64{0}
+	}
+	offs := make([]byte, 4*n)
+	if _, err := r.ReadAt(offs, 12); err != nil {
+		return nil
+	}
+	offsets := make([]int64, n)
+	for i := range offsets {
+		offsets[i] = int64(binary.BigEndian.Uint32(offs[4*i:]))
+	}
+	return offsets
+}
+
+// readOS2 reads the style, weight and width of a font from its OS/2
+// table, if any.
+func readOS2(r io.ReaderAt, offset int64, f *Font) {
+	var hdr [12]byte
+	if _, err := r.ReadAt(hdr[:], offset); err != nil {
+		return
+	}
+	dir := make([]byte, 16*int(binary.BigEndian.Uint16(hdr[4:])))
+	if _, err := r.ReadAt(dir, offset+12); err != nil {
+		return
+	}
+	for len(dir) >= 16 {
+		rec := dir[:16]
+		dir = dir[16:]
+		if string(rec[:4]) != "OS/2" {
+			continue
+		}
+		var os2 [64]byte
+		if _, err := r.ReadAt(os2[:], int64(binary.BigEndian.Uint32(rec[8:]))); err != nil {
+			return
+		}
+		weight := int(binary.BigEndian.Uint16(os2[4:]))
+		if weight > 0 && weight < 10 {
+			// Some fonts use the legacy weight classes 1-9.
+			weight *= 100
+		}
+		if weight > 0 {
+			f.Weight = text.Weight(weight - 400)
+		}
+		if width := int(binary.BigEndian.Uint16(os2[6:])); width < len(widthVariants) {
+			f.Variant = widthVariants[width]
+		}
+		// Bit 0 of fsSelection is italic, bit 9 oblique.
+		if sel := binary.BigEndian.Uint16(os2[62:]); sel&(1<<0|1<<9) != 0 {
+			f.Style = text.Italic
+		}
+		return
+	}
+}
+
+func sortedScripts() []string {
+	var names []string
+	for name := range scriptSamples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Supports reports whether the font supports a script.
+func (f Font) Supports(script string) bool {
+	for _, s := range f.Scripts {
+		if s == script {
+			return true
+		}
+	}
+	return false
+}
+
+// Typefaces returns the typefaces of the index in sorted order.
+func (x *Index) Typefaces() []text.Typeface {
+	var tfs []text.Typeface
+	for i, f := range x.Fonts {
+		if i == 0 || f.Typeface != x.Fonts[i-1].Typeface {
+			tfs = append(tfs, f.Typeface)
+		}
+	}
+	return tfs
+}
+
+// Fallbacks returns the fallback chains of the scripts supported by
+// the fonts of the index. The chain of a script lists the typefaces
+// with a font that supports the script, the typefaces of prefer first
+// and the others in sorted order.
+func (x *Index) Fallbacks(prefer ...text.Typeface) Fallbacks {
+	rank := func(tf text.Typeface) int {
+		for i, p := range prefer {
+			if p == tf {
+				return i
+			}
+		}
+		return len(prefer)
+	}
+	fbs := make(Fallbacks)
+	for _, f := range x.Fonts {
+		for _, s := range f.Scripts {
+			chain := fbs[s]
+			if n := len(chain); n == 0 || chain[n-1] != f.Typeface {
+				fbs[s] = append(chain, f.Typeface)
+			}
+		}
+	}
+	for _, chain := range fbs {
+		sort.SliceStable(chain, func(i, j int) bool {
+			return rank(chain[i]) < rank(chain[j])
+		})
+	}
+	return fbs
+}
+
+// Collection returns the faces of the fonts of the typefaces, or of
+// all typefaces if none are specified. The first typeface is the
+// default typeface of a text.Cache of the collection. Runes of the
+// scripts a font doesn't support are shaped with the fonts of the
+// first typefaces of the fallback chains of their scripts, matching
+// the font style and weight.
+//
+// The font files are read when a face is first used.
+func (x *Index) Collection(fallbacks Fallbacks, typefaces ...text.Typeface) []text.FontFace {
+	if len(typefaces) == 0 {
+		typefaces = x.Typefaces()
+	}
+	l := newLoader()
+	var scripts []string
+	for s := range fallbacks {
+		scripts = append(scripts, s)
+	}
+	sort.Strings(scripts)
+	var faces []text.FontFace
+	seen := make(map[text.Font]bool)
+	for _, tf := range typefaces {
+		for _, f := range x.Fonts {
+			font := text.Font{Typeface: f.Typeface, Variant: f.Variant, Style: f.Style, Weight: f.Weight}
+			if f.Typeface != tf || seen[font] {
+				continue
+			}
+			seen[font] = true
+			fc := &face{loader: l, chain: []chainFont{{font: f}}}
+			for _, s := range scripts {
+				if f.Supports(s) || unicode.Scripts[s] == nil {
+					continue
+				}
+				n := 0
+				for _, fbtf := range fallbacks[s] {
+					if n == maxFallbacks {
+						break
+					}
+					if fbtf == tf {
+						continue
+					}
+					if fb, ok := x.match(fbtf, s, f); ok {
+						fc.chain = append(fc.chain, chainFont{font: fb, script: unicode.Scripts[s]})
+						n++
+					}
+				}
+			}
+			faces = append(faces, text.FontFace{Font: font, Face: fc})
+		}
+	}
+	return faces
+}
+
+// match returns the font of a typeface that supports a script and
+// best matches the style and weight of f.
+func (x *Index) match(tf text.Typeface, script string, f Font) (Font, bool) {
+	var best Font
+	found := false
+	score := func(c Font) int {
+		d := int(c.Weight - f.Weight)
+		if d < 0 {
+			d = -d
+		}
+		if c.Style != f.Style {
+			d += 1000
+		}
+		if c.Variant != f.Variant {
+			d += 2000
+		}
+		return d
+	}
+	for _, c := range x.Fonts {
+		if c.Typeface != tf || !c.Supports(script) {
+			continue
+		}
+		if !found || score(c) < score(best) {
+			best, found = c, true
+		}
+	}
+	return best, found
+}
+
+// maxOpenFiles is the number of font files a loader keeps open.
+const maxOpenFiles = 16
+
+// loader parses and caches font files. Glyph outlines are read from
+// the files when needed, to avoid keeping large fonts in memory. The
+// most recently read files are kept open, and the other files are
+// opened again when read.
+type loader struct {
+	mu    sync.Mutex
+	files map[string]*opentype.Collection
+
+	// openMu guards open and the files of fontFiles.
+	openMu sync.Mutex
+	// open lists the open files, least recently used first.
+	open    []*fontFile
+	maxOpen int
+}
+
+// fontFile is an io.ReaderAt of a font file opened on demand.
+type fontFile struct {
+	loader *loader
+	path   string
+	file   *os.File
+	// refs is the number of reads in progress.
+	refs int
+}
+
+func newLoader() *loader {
+	return &loader{
+		files:   make(map[string]*opentype.Collection),
+		maxOpen: maxOpenFiles,
+	}
+}
+
+func (l *loader) load(f Font) (*opentype.Font, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	coll, ok := l.files[f.Path]
+	if !ok {
+		var err error
+		coll, err = opentype.ParseCollectionReaderAt(&fontFile{loader: l, path: f.Path})
+		if err != nil {
+			return nil, err
+		}
+		l.files[f.Path] = coll
+	}
+	return coll.Font(f.Index)
+}
+
+func (f *fontFile) ReadAt(p []byte, off int64) (int, error) {
+	file, err := f.loader.acquire(f)
+	if err != nil {
+		return 0, err
+	}
+	defer f.loader.release(f)
+	return file.ReadAt(p, off)
+}
+
+// acquire opens f if necessary and marks it as in use.
+func (l *loader) acquire(f *fontFile) (*os.File, error) {
+	l.openMu.Lock()
+	defer l.openMu.Unlock()
+	if f.file == nil {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return nil, err
+		}
+		f.file = file
+	} else {
+		for i, o := range l.open {
+			if o == f {
+				l.open = append(l.open[:i], l.open[i+1:]...)
+				break
+			}
+		}
+	}
+	l.open = append(l.open, f)
+	f.refs++
+	l.closeUnused()
+	return f.file, nil
+}
+
+// release marks the end of a read of f.
+func (l *loader) release(f *fontFile) {
+	l.openMu.Lock()
+	defer l.openMu.Unlock()
+	f.refs--
+	l.closeUnused()
+}
+
+// closeUnused closes the least recently used files not in use until at
+// most maxOpen files are open.
+func (l *loader) closeUnused() {
+	for i := 0; len(l.open) > l.maxOpen && i < len(l.open); {
+		f := l.open[i]
+		if f.refs > 0 {
+			i++
+			continue
+		}
+		f.file.Close()
+		f.file = nil
+		l.open = append(l.open[:i], l.open[i+1:]...)
+	}
+}
+
+// chainFont is a font of the fallback chain of a face.
+type chainFont struct {
+	font Font
+	// script restricts the font to a script, if not nil.
+	script *unicode.RangeTable
+}
+
+// face is a text.Face that reads its font files on first use.
+type face struct {
+	loader *loader
+	// chain contains the font of the face followed by its fallback
+	// fonts.
+	chain []chainFont
+
+	once sync.Once
+	// fonts are the loaded fonts of chain.
+	fonts []opentype.Fallback
+	coll  *opentype.Collection
+	err   error
+}
+
+func (f *face) collection() (*opentype.Collection, error) {
+	f.once.Do(func() {
+		var chain []opentype.Fallback
+		for i, c := range f.chain {
+			fnt, err := f.loader.load(c.font)
+			if err != nil {
+				if i == 0 {
+					f.err = err
+					return
+				}
+				// Skip unreadable fallback fonts.
+				continue
+			}
+			chain = append(chain, opentype.Fallback{Font: fnt, Script: c.script})
+		}
+		f.fonts = chain
+		f.coll = opentype.NewCollection(chain)
+	})
+	return f.coll, f.err
+}
+
+func (f *face) Layout(ppem fixed.Int26_6, maxWidth int, txt io.Reader) ([]text.Line, error) {
+	coll, err := f.collection()
+	if err != nil {
+		return nil, err
+	}
+	return coll.Layout(ppem, maxWidth, txt)
+}
+
+// LayoutWithOptions implements text.OptionsFace.
+func (f *face) LayoutWithOptions(ppem fixed.Int26_6, maxWidth int, opts text.LayoutOptions, txt io.Reader) ([]text.Line, error) {
+	coll, err := f.collection()
+	if err != nil {
+		return nil, err
+	}
+	return coll.LayoutWithOptions(ppem, maxWidth, opts, txt)
+}
+
+// Measure implements text.MeasureFace.
+func (f *face) Measure(ppem fixed.Int26_6, maxWidth int, txt io.Reader) (text.Measurement, error) {
+	coll, err := f.collection()
+	if err != nil {
+		return text.Measurement{}, err
+	}
+	return coll.Measure(ppem, maxWidth, txt)
+}
+
+func (f *face) Shape(ppem fixed.Int26_6, str text.Layout) op.CallOp {
+	coll, err := f.collection()
+	if err != nil {
+		return op.CallOp{}
+	}
+	return coll.Shape(ppem, str)
+}
+
+// ShapeColor implements text.ColorFace.
+func (f *face) ShapeColor(ppem fixed.Int26_6, str text.Layout) op.CallOp {
+	coll, err := f.collection()
+	if err != nil {
+		return op.CallOp{}
+	}
+	return coll.ShapeColor(ppem, str)
+}
+
+// Axes implements text.VariableFace. The axes are those of the font of
+// the face, not of its fallback fonts.
+func (f *face) Axes() []text.Axis {
+	if _, err := f.collection(); err != nil {
+		return nil
+	}
+	return f.fonts[0].Font.Axes()
+}
+
+// Vary implements text.VariableFace. Only the font of the face is
+// varied; the fallback fonts keep their default instances.
+func (f *face) Vary(values []text.Variation) text.Face {
+	if _, err := f.collection(); err != nil {
+		return f
+	}
+	fnt, ok := f.fonts[0].Font.Vary(values).(*opentype.Font)
+	if !ok || fnt == f.fonts[0].Font {
+		return f
+	}
+	chain := append([]opentype.Fallback(nil), f.fonts...)
+	chain[0].Font = fnt
+	return opentype.NewCollection(chain)
+}
+
+// Rasterize implements text.RasterFace.
+func (f *face) Rasterize(ppem fixed.Int26_6, id text.GlyphID, offset fixed.Point26_6) (*image.Alpha, image.Point) {
+	coll, err := f.collection()
+	if err != nil {
+		return nil, image.Point{}
+	}
+	return coll.Rasterize(ppem, id, offset)
+}
+
+// Decorations implements text.DecorationFace.
+func (f *face) Decorations(ppem fixed.Int26_6) text.DecorationMetrics {
+	coll, err := f.collection()
+	if err != nil {
+		return text.DecorationMetrics{}
+	}
+	return coll.Decorations(ppem)
+}
+
+// InkExtents implements text.DecorationFace.
+func (f *face) InkExtents(ppem fixed.Int26_6, str text.Layout, top, bottom fixed.Int26_6) []text.Extent {
+	coll, err := f.collection()
+	if err != nil {
+		return nil
+	}
+	return coll.InkExtents(ppem, str, top, bottom)
+}
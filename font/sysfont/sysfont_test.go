@@ -0,0 +1,264 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package sysfont
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/font/opentype"
+	"github.com/cybriq/giocore/text"
+)
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestParseConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysfont")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	setenv(t, "HOME", filepath.Join(dir, "home"))
+	setenv(t, "XDG_DATA_HOME", filepath.Join(dir, "data"))
+	setenv(t, "XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	writeFile(t, filepath.Join(dir, "fonts.conf"), []byte(`<?xml version="1.0"?>
+<!DOCTYPE fontconfig SYSTEM "urn:fontconfig:fonts.dtd">
+<fontconfig>
+	<dir>/usr/share/fonts</dir>
+	<dir prefix="xdg">fonts</dir>
+	<dir>~/.fonts</dir>
+	<include ignore_missing="yes">conf.d</include>
+	<include ignore_missing="yes">missing.conf</include>
+	<include prefix="xdg" ignore_missing="yes">fontconfig/fonts.conf</include>
+	<match target="pattern">
+		<test name="family"><string>mono</string></test>
+	</match>
+</fontconfig>
+`))
+	writeFile(t, filepath.Join(dir, "conf.d", "20-b.conf"), []byte(`<fontconfig><dir>b</dir></fontconfig>`))
+	writeFile(t, filepath.Join(dir, "conf.d", "10-a.conf"), []byte(`<fontconfig><dir>/opt/a</dir><include>../fonts.conf</include></fontconfig>`))
+	writeFile(t, filepath.Join(dir, "conf.d", "README"), []byte(`<fontconfig><dir>/ignored</dir></fontconfig>`))
+	writeFile(t, filepath.Join(dir, "config", "fontconfig", "fonts.conf"), []byte(`<fontconfig><dir>/user</dir></fontconfig>`))
+	got, err := ParseConfig(filepath.Join(dir, "fonts.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/usr/share/fonts",
+		filepath.Join(dir, "data", "fonts"),
+		filepath.Join(dir, "home", ".fonts"),
+		"/opt/a",
+		filepath.Join(dir, "conf.d", "b"),
+		"/user",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got dirs %q, expected %q", got, want)
+	}
+	if _, err := ParseConfig(filepath.Join(dir, "missing.conf")); err == nil {
+		t.Error("no error for missing configuration")
+	}
+}
+
+func shapingFont(t *testing.T) []byte {
+	t.Helper()
+	f, err := os.Open("../opentype/testdata/shaping.ttf.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func testIndex(t *testing.T) *Index {
+	dir, err := ioutil.TempDir("", "sysfont")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	writeFile(t, filepath.Join(dir, "go", "Go-Regular.ttf"), goregular.TTF)
+	writeFile(t, filepath.Join(dir, "go", "Go-Bold.TTF"), gobold.TTF)
+	writeFile(t, filepath.Join(dir, "go", "Go-Italic.ttf"), goitalic.TTF)
+	writeFile(t, filepath.Join(dir, "other", "shaping.otf"), shapingFont(t))
+	writeFile(t, filepath.Join(dir, "other", "notafont.ttf"), []byte("garbage"))
+	writeFile(t, filepath.Join(dir, "other", "readme.txt"), goregular.TTF)
+	// List a directory twice.
+	return Scan([]string{dir, filepath.Join(dir, "go"), filepath.Join(dir, "missing")})
+}
+
+func TestScan(t *testing.T) {
+	x := testIndex(t)
+	type font struct {
+		file     string
+		typeface text.Typeface
+		style    text.Style
+		weight   text.Weight
+	}
+	var got []font
+	for _, f := range x.Fonts {
+		got = append(got, font{filepath.Base(f.Path), f.Typeface, f.Style, f.Weight})
+	}
+	want := []font{
+		{"Go-Regular.ttf", "Go", text.Regular, text.Normal},
+		{"Go-Bold.TTF", "Go", text.Regular, text.Bold},
+		{"Go-Italic.ttf", "Go", text.Italic, text.Normal},
+		{"shaping.otf", "shaping", text.Regular, text.Normal},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got fonts %+v, expected %+v", got, want)
+	}
+	if s := strings.Join(x.Fonts[0].Scripts, " "); s != "Cyrillic Greek Latin" {
+		t.Errorf("got Go scripts %q", s)
+	}
+	if s := strings.Join(x.Fonts[3].Scripts, " "); s != "Arabic Devanagari" {
+		t.Errorf("got shaping scripts %q", s)
+	}
+	fbs := x.Fallbacks("shaping")
+	if got, want := fbs["Arabic"], []text.Typeface{"shaping"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got Arabic fallbacks %q, expected %q", got, want)
+	}
+	if got, want := fbs["Latin"], []text.Typeface{"Go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got Latin fallbacks %q, expected %q", got, want)
+	}
+}
+
+func TestCollectionFallback(t *testing.T) {
+	x := testIndex(t)
+	coll := x.Collection(x.Fallbacks(), "Go")
+	if len(coll) != 3 {
+		t.Fatalf("got %d faces, expected 3", len(coll))
+	}
+	if got, want := coll[1].Font, (text.Font{Typeface: "Go", Weight: text.Bold}); got != want {
+		t.Errorf("got font %+v, expected %+v", got, want)
+	}
+	c := text.NewCache(coll)
+	lines := c.LayoutString(text.Font{Weight: text.Bold}, fixed.I(20), 1000, "aب")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, expected 1", len(lines))
+	}
+	var fonts []int
+	for _, g := range lines[0].Layout.Glyphs {
+		fonts = append(fonts, g.ID.Font())
+	}
+	if want := []int{0, 1}; !reflect.DeepEqual(fonts, want) {
+		t.Errorf("got glyph fonts %v, expected %v", fonts, want)
+	}
+	if w := lines[0].Layout.Advances[1]; w == 0 {
+		t.Error("fallback glyph has no advance")
+	}
+}
+
+func TestFaceVary(t *testing.T) {
+	x := testIndex(t)
+	coll := x.Collection(x.Fallbacks(), "Go")
+	vf, ok := coll[0].Face.(text.VariableFace)
+	if !ok {
+		t.Fatal("face is not a text.VariableFace")
+	}
+	if axes := vf.Axes(); axes != nil {
+		t.Errorf("got axes %v for a static font", axes)
+	}
+	if f := vf.Vary([]text.Variation{{Tag: "wght", Value: 700}}); f != coll[0].Face {
+		t.Error("varying a static font returned a new face")
+	}
+}
+
+func TestFaceRasterize(t *testing.T) {
+	x := testIndex(t)
+	coll := x.Collection(x.Fallbacks(), "Go")
+	rf, ok := coll[0].Face.(text.RasterFace)
+	if !ok {
+		t.Fatal("face is not a text.RasterFace")
+	}
+	lines := text.NewCache(coll).LayoutString(text.Font{}, fixed.I(20), 1000, "a")
+	mask, _ := rf.Rasterize(fixed.I(20), lines[0].Layout.Glyphs[0].ID, fixed.Point26_6{})
+	if mask == nil || mask.Bounds().Empty() {
+		t.Error("no coverage mask for a glyph")
+	}
+}
+
+func TestFaceDecorations(t *testing.T) {
+	x := testIndex(t)
+	coll := x.Collection(x.Fallbacks(), "Go")
+	df, ok := coll[0].Face.(text.DecorationFace)
+	if !ok {
+		t.Fatal("face is not a text.DecorationFace")
+	}
+	m := df.Decorations(fixed.I(20))
+	if m.UnderlineThickness <= 0 || m.UnderlinePosition <= 0 {
+		t.Errorf("got underline metrics %+v, expected a line below the baseline", m)
+	}
+	lines := text.NewCache(coll).LayoutString(text.Font{}, fixed.I(20), 1000, "gap")
+	exts := df.InkExtents(fixed.I(20), lines[0].Layout, m.UnderlinePosition, m.UnderlinePosition+m.UnderlineThickness)
+	if len(exts) == 0 {
+		t.Error("no ink extents for descenders crossing the underline")
+	}
+}
+
+func TestLoaderOpenFiles(t *testing.T) {
+	x := testIndex(t)
+	l := newLoader()
+	l.maxOpen = 1
+	var fonts []*opentype.Font
+	for _, f := range x.Fonts {
+		fnt, err := l.load(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fonts = append(fonts, fnt)
+	}
+	if n := len(l.open); n > 1 {
+		t.Errorf("%d files open, expected at most 1", n)
+	}
+	// Closed files are opened again when read.
+	for i, fnt := range fonts {
+		lines, err := fnt.Layout(fixed.I(20), 1000, strings.NewReader("a"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != 1 || lines[0].Width == 0 {
+			t.Errorf("font %d: no layout after closing its file", i)
+		}
+	}
+	if n := len(l.open); n > 1 {
+		t.Errorf("%d files open, expected at most 1", n)
+	}
+}
@@ -4,8 +4,8 @@
 Package gesture implements common pointer gestures.
 
 Gestures accept low level pointer Events from an event
-Queue and detect higher level actions such as clicks
-and scrolling.
+Queue and detect higher level actions such as clicks,
+long presses, swipes and scrolling.
 */
 package gesture
 
@@ -24,8 +24,11 @@ import (
 	"github.com/cybriq/giocore/unit"
 )
 
-// The duration is somewhat arbitrary.
-const doubleClickDuration = 200 * time.Millisecond
+// The durations are somewhat arbitrary.
+const (
+	doubleClickDuration = 200 * time.Millisecond
+	longPressDuration   = 500 * time.Millisecond
+)
 
 // Click detects click gestures in the form
 // of ClickEvents.
@@ -61,6 +64,81 @@ type ClickEvent struct {
 
 type ClickType uint8
 
+// LongPress detects press-and-hold gestures, such as
+// the touch gesture for opening a context menu, in the
+// form of LongPressEvents.
+type LongPress struct {
+	// pressed tracks whether a pointer is held down.
+	pressed bool
+	// fired tracks whether the current press was
+	// reported.
+	fired bool
+	grab  bool
+	pid   pointer.ID
+	start f32.Point
+	// deadline is the time the current press becomes
+	// a long press.
+	deadline time.Time
+	// event is the event for the current press.
+	event LongPressEvent
+}
+
+// LongPressEvent is reported when a pointer is held down
+// without moving for a fixed duration.
+type LongPressEvent struct {
+	Position  f32.Point
+	Source    pointer.Source
+	Modifiers key.Modifiers
+}
+
+// DoubleTap detects two successive taps close to each
+// other in the form of DoubleTapEvents.
+type DoubleTap struct {
+	// pressed tracks whether a pointer is held down.
+	pressed bool
+	pid     pointer.ID
+	start   f32.Point
+	// tapped tracks whether the previous tap is the
+	// first of a double tap.
+	tapped bool
+	// tapTime and tapPos record the previous tap.
+	tapTime time.Duration
+	tapPos  f32.Point
+}
+
+// DoubleTapEvent is reported for the completion of the
+// second tap of a double tap.
+type DoubleTapEvent struct {
+	Position  f32.Point
+	Source    pointer.Source
+	Modifiers key.Modifiers
+}
+
+// Swipe detects swipe gestures, quick pointer motions in
+// a direction, in the form of SwipeEvents.
+type Swipe struct {
+	// pressed tracks whether a pointer is held down.
+	pressed bool
+	pid     pointer.ID
+	start   f32.Point
+	// Velocity estimators for each axis.
+	estX, estY fling.Extrapolation
+}
+
+// SwipeEvent is reported when a pointer is released after
+// a swipe.
+type SwipeEvent struct {
+	Direction SwipeDirection
+	// Velocity is the pointer velocity at release, in
+	// pixels per second.
+	Velocity  f32.Point
+	Position  f32.Point
+	Source    pointer.Source
+	Modifiers key.Modifiers
+}
+
+type SwipeDirection uint8
+
 // Drag detects drag gestures in the form of pointer.Drag events.
 type Drag struct {
 	dragging bool
@@ -71,7 +149,8 @@ type Drag struct {
 
 // Scroll detects scroll gestures and reduces them to
 // scroll distances. Scroll recognizes mouse wheel
-// movements as well as drag and fling touch gestures.
+// movements as well as drag and fling touch and touchpad
+// gestures.
 type Scroll struct {
 	dragging  bool
 	axis      Axis
@@ -86,6 +165,35 @@ type Scroll struct {
 
 type ScrollState uint8
 
+// Transform detects two-finger pinch and rotation gestures on touch
+// screens and pinch gestures on touchpads, in the form of
+// TransformEvents.
+type Transform struct {
+	// touches tracks the active touch pointers.
+	touches []touch
+	// pinching tracks whether a touchpad pinch is in progress.
+	pinching bool
+	grab     bool
+}
+
+// TransformEvent is an incremental change of a Transform gesture.
+type TransformEvent struct {
+	// Centroid is the center of the gesture.
+	Centroid f32.Point
+	// Scale is the scale factor since the previous event.
+	Scale float32
+	// Rotation is the clockwise rotation in radians since the
+	// previous event.
+	Rotation float32
+	// Pan is the motion of the centroid since the previous event.
+	Pan f32.Point
+}
+
+type touch struct {
+	id  pointer.ID
+	pos f32.Point
+}
+
 type Axis uint8
 
 const (
@@ -106,6 +214,13 @@ const (
 	TypeCancel
 )
 
+const (
+	SwipeLeft SwipeDirection = iota
+	SwipeRight
+	SwipeUp
+	SwipeDown
+)
+
 const (
 	// StateIdle is the default scroll state.
 	StateIdle ScrollState = iota
@@ -118,6 +233,15 @@ const (
 
 var touchSlop = unit.Dp(3)
 
+var (
+	// doubleTapSlop is the maximum distance between the taps
+	// of a double tap.
+	doubleTapSlop = unit.Dp(32)
+	// minSwipeVelocity is the minimum velocity of a swipe, in
+	// pixels per second.
+	minSwipeVelocity = unit.Dp(300)
+)
+
 // Add the handler to the operation list to receive click events.
 func (c *Click) Add(ops *op.Ops) {
 	op := pointer.InputOp{
@@ -267,12 +391,31 @@ func (s *Scroll) Scroll(cfg unit.Metric, q event.Queue, t time.Time, axis Axis)
 			s.dragging = false
 			s.grab = false
 		case pointer.Scroll:
+			var v float32
 			switch s.axis {
 			case Horizontal:
-				s.scroll += e.Scroll.X
+				v = e.Scroll.X
 			case Vertical:
-				s.scroll += e.Scroll.Y
+				v = e.Scroll.Y
 			}
+			if e.ScrollSource == pointer.ScrollSourceFinger {
+				// Touchpad scrolling flings when the fingers
+				// are lifted, like touch drags.
+				switch e.ScrollPhase {
+				case pointer.ScrollPhaseBegin:
+					s.Stop()
+					s.estimator = fling.Extrapolation{}
+					s.estimator.SampleDelta(e.Time, -v)
+				case pointer.ScrollPhaseUpdate:
+					s.estimator.SampleDelta(e.Time, -v)
+				case pointer.ScrollPhaseEnd:
+					est := s.estimator.Estimate()
+					if slop, d := float32(cfg.Px(touchSlop)), est.Distance; d < -slop || d > slop {
+						s.flinger.Start(cfg, t, est.Velocity)
+					}
+				}
+			}
+			s.scroll += v
 			iscroll := int(s.scroll)
 			s.scroll -= float32(iscroll)
 			total += iscroll
@@ -385,6 +528,369 @@ func (d *Drag) Events(cfg unit.Metric, q event.Queue, axis Axis) []pointer.Event
 // Dragging reports whether it's currently in use.
 func (d *Drag) Dragging() bool { return d.dragging }
 
+// Add the handler to the operation list to receive transform events.
+func (t *Transform) Add(ops *op.Ops) {
+	op := pointer.InputOp{
+		Tag:   t,
+		Grab:  t.grab,
+		Types: pointer.Press | pointer.Drag | pointer.Release | pointer.Pinch,
+	}
+	op.Add(ops)
+}
+
+// Active reports whether a transform gesture is in progress.
+func (t *Transform) Active() bool {
+	return len(t.touches) == 2 || t.pinching
+}
+
+// Events returns the next transform events, if any.
+func (t *Transform) Events(q event.Queue) []TransformEvent {
+	var events []TransformEvent
+	for _, e := range q.Events(t) {
+		e, ok := e.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch e.Type {
+		case pointer.Press:
+			if e.Source != pointer.Touch || len(t.touches) == 2 {
+				break
+			}
+			t.touches = append(t.touches, touch{id: e.PointerID, pos: e.Position})
+			t.grab = len(t.touches) == 2
+		case pointer.Drag:
+			idx := t.touchIndex(e.PointerID)
+			if idx == -1 {
+				break
+			}
+			if len(t.touches) < 2 {
+				t.touches[idx].pos = e.Position
+				break
+			}
+			p0, p1 := t.touches[0].pos, t.touches[1].pos
+			t.touches[idx].pos = e.Position
+			events = append(events, transformEvent(p0, p1, t.touches[0].pos, t.touches[1].pos))
+		case pointer.Release:
+			if idx := t.touchIndex(e.PointerID); idx != -1 {
+				t.touches = append(t.touches[:idx], t.touches[idx+1:]...)
+			}
+			t.grab = false
+		case pointer.Cancel:
+			t.touches = t.touches[:0]
+			t.pinching = false
+			t.grab = false
+		case pointer.Pinch:
+			switch e.ScrollPhase {
+			case pointer.ScrollPhaseBegin:
+				t.pinching = true
+			case pointer.ScrollPhaseEnd:
+				t.pinching = false
+			}
+			if e.PinchScale == 0 {
+				break
+			}
+			events = append(events, TransformEvent{
+				Centroid: e.Position,
+				Scale:    e.PinchScale,
+				Rotation: e.PinchRotation,
+				Pan:      e.Delta,
+			})
+		}
+	}
+	return events
+}
+
+func (t *Transform) touchIndex(id pointer.ID) int {
+	for i, p := range t.touches {
+		if p.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// transformEvent computes the transformation that moves the pair of
+// points (p0, p1) to (q0, q1).
+func transformEvent(p0, p1, q0, q1 f32.Point) TransformEvent {
+	dp, dq := p1.Sub(p0), q1.Sub(q0)
+	centroid := q0.Add(q1).Mul(.5)
+	e := TransformEvent{
+		Centroid: centroid,
+		Scale:    1,
+		Pan:      centroid.Sub(p0.Add(p1).Mul(.5)),
+	}
+	lp := math.Hypot(float64(dp.X), float64(dp.Y))
+	lq := math.Hypot(float64(dq.X), float64(dq.Y))
+	if lp == 0 || lq == 0 {
+		return e
+	}
+	e.Scale = float32(lq / lp)
+	rot := math.Atan2(float64(dq.Y), float64(dq.X)) - math.Atan2(float64(dp.Y), float64(dp.X))
+	// Normalize to (-π, π].
+	if rot > math.Pi {
+		rot -= 2 * math.Pi
+	} else if rot <= -math.Pi {
+		rot += 2 * math.Pi
+	}
+	e.Rotation = float32(rot)
+	return e
+}
+
+// Affine returns the transformation that applies the event
+// to content: a translation by Pan followed by scaling and
+// rotation around Centroid.
+func (e TransformEvent) Affine() f32.Affine2D {
+	return f32.Affine2D{}.
+		Offset(e.Pan).
+		Scale(e.Centroid, f32.Point{X: e.Scale, Y: e.Scale}).
+		Rotate(e.Centroid, e.Rotation)
+}
+
+func (TransformEvent) ImplementsEvent() {}
+
+// Add the handler to the operation list to receive long press
+// events.
+func (l *LongPress) Add(ops *op.Ops) {
+	oph := pointer.InputOp{
+		Tag:   l,
+		Grab:  l.grab,
+		Types: pointer.Press | pointer.Drag | pointer.Release,
+	}
+	oph.Add(ops)
+	if l.pressed && !l.fired {
+		op.InvalidateOp{At: l.deadline}.Add(ops)
+	}
+}
+
+// Pressed returns whether a pointer is held down.
+func (l *LongPress) Pressed() bool {
+	return l.pressed
+}
+
+// Events returns the next long press events, if any. The
+// time t is the current frame time, used to determine
+// whether a press has been held long enough.
+func (l *LongPress) Events(cfg unit.Metric, q event.Queue, t time.Time) []LongPressEvent {
+	var events []LongPressEvent
+	for _, e := range q.Events(l) {
+		e, ok := e.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch e.Type {
+		case pointer.Press:
+			if l.pressed || !(e.Buttons == pointer.ButtonPrimary || e.Source == pointer.Touch) {
+				break
+			}
+			l.pressed = true
+			l.fired = false
+			l.pid = e.PointerID
+			l.start = e.Position
+			l.deadline = t.Add(longPressDuration)
+			l.event = LongPressEvent{Position: e.Position, Source: e.Source, Modifiers: e.Modifiers}
+		case pointer.Drag:
+			if !l.pressed || l.fired || e.PointerID != l.pid {
+				break
+			}
+			if !withinSlop(cfg, e.Position.Sub(l.start), touchSlop) {
+				l.pressed = false
+			}
+		case pointer.Release:
+			if e.PointerID != l.pid {
+				break
+			}
+			fallthrough
+		case pointer.Cancel:
+			l.pressed = false
+			l.grab = false
+		}
+	}
+	if l.pressed && !l.fired && !t.Before(l.deadline) {
+		l.fired = true
+		// Grab the pointer to prevent other handlers
+		// from reporting a click on release.
+		l.grab = true
+		events = append(events, l.event)
+	}
+	return events
+}
+
+// Add the handler to the operation list to receive double tap
+// events.
+func (d *DoubleTap) Add(ops *op.Ops) {
+	op := pointer.InputOp{
+		Tag:   d,
+		Types: pointer.Press | pointer.Drag | pointer.Release,
+	}
+	op.Add(ops)
+}
+
+// Events returns the next double tap events, if any.
+func (d *DoubleTap) Events(cfg unit.Metric, q event.Queue) []DoubleTapEvent {
+	var events []DoubleTapEvent
+	for _, e := range q.Events(d) {
+		e, ok := e.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch e.Type {
+		case pointer.Press:
+			if d.pressed || !(e.Buttons == pointer.ButtonPrimary || e.Source == pointer.Touch) {
+				break
+			}
+			d.pressed = true
+			d.pid = e.PointerID
+			d.start = e.Position
+			if e.Time-d.tapTime >= doubleClickDuration || !withinSlop(cfg, e.Position.Sub(d.tapPos), doubleTapSlop) {
+				d.tapped = false
+			}
+		case pointer.Drag:
+			if !d.pressed || e.PointerID != d.pid {
+				break
+			}
+			if !withinSlop(cfg, e.Position.Sub(d.start), touchSlop) {
+				d.pressed = false
+				d.tapped = false
+			}
+		case pointer.Release:
+			if !d.pressed || e.PointerID != d.pid {
+				break
+			}
+			d.pressed = false
+			if d.tapped {
+				d.tapped = false
+				events = append(events, DoubleTapEvent{Position: e.Position, Source: e.Source, Modifiers: e.Modifiers})
+				break
+			}
+			d.tapped = true
+			d.tapTime = e.Time
+			d.tapPos = e.Position
+		case pointer.Cancel:
+			d.pressed = false
+			d.tapped = false
+		}
+	}
+	return events
+}
+
+// Add the handler to the operation list to receive swipe events.
+func (s *Swipe) Add(ops *op.Ops) {
+	op := pointer.InputOp{
+		Tag:   s,
+		Types: pointer.Press | pointer.Drag | pointer.Release,
+	}
+	op.Add(ops)
+}
+
+// Events returns the next swipe events, if any. Only swipes
+// along axis are reported.
+func (s *Swipe) Events(cfg unit.Metric, q event.Queue, axis Axis) []SwipeEvent {
+	var events []SwipeEvent
+	for _, e := range q.Events(s) {
+		e, ok := e.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch e.Type {
+		case pointer.Press:
+			if s.pressed || !(e.Buttons == pointer.ButtonPrimary || e.Source == pointer.Touch) {
+				break
+			}
+			s.pressed = true
+			s.pid = e.PointerID
+			s.start = e.Position
+			s.estX = fling.Extrapolation{}
+			s.estY = fling.Extrapolation{}
+			s.sample(e)
+		case pointer.Drag:
+			if !s.pressed || e.PointerID != s.pid {
+				break
+			}
+			s.sample(e)
+		case pointer.Release:
+			if !s.pressed || e.PointerID != s.pid {
+				break
+			}
+			s.pressed = false
+			s.sample(e)
+			if se, ok := s.swipe(cfg, e, axis); ok {
+				events = append(events, se)
+			}
+		case pointer.Cancel:
+			s.pressed = false
+		}
+	}
+	return events
+}
+
+func (s *Swipe) sample(e pointer.Event) {
+	s.estX.Sample(e.Time, e.Position.X)
+	s.estY.Sample(e.Time, e.Position.Y)
+}
+
+// swipe determines whether the pointer released by e completed
+// a swipe.
+func (s *Swipe) swipe(cfg unit.Metric, e pointer.Event, axis Axis) (SwipeEvent, bool) {
+	// The estimated velocity is opposite to the direction
+	// of motion.
+	v := f32.Point{
+		X: -s.estX.Estimate().Velocity,
+		Y: -s.estY.Estimate().Velocity,
+	}
+	d := e.Position.Sub(s.start)
+	horizontal := abs(v.X) >= abs(v.Y)
+	switch axis {
+	case Horizontal:
+		horizontal = true
+	case Vertical:
+		horizontal = false
+	}
+	var dir SwipeDirection
+	var vel, dist float32
+	if horizontal {
+		vel, dist = v.X, d.X
+		dir = SwipeRight
+		if vel < 0 {
+			dir = SwipeLeft
+		}
+	} else {
+		vel, dist = v.Y, d.Y
+		dir = SwipeDown
+		if vel < 0 {
+			dir = SwipeUp
+		}
+	}
+	if abs(vel) < float32(cfg.Px(minSwipeVelocity)) || abs(dist) <= float32(cfg.Px(touchSlop)) || (vel < 0) != (dist < 0) {
+		return SwipeEvent{}, false
+	}
+	return SwipeEvent{
+		Direction: dir,
+		Velocity:  v,
+		Position:  e.Position,
+		Source:    e.Source,
+		Modifiers: e.Modifiers,
+	}, true
+}
+
+// withinSlop reports whether d is within the distance slop.
+func withinSlop(cfg unit.Metric, d f32.Point, slop unit.Value) bool {
+	s := float32(cfg.Px(slop))
+	return d.X*d.X+d.Y*d.Y <= s*s
+}
+
+func abs(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func (LongPressEvent) ImplementsEvent() {}
+
+func (DoubleTapEvent) ImplementsEvent() {}
+
+func (SwipeEvent) ImplementsEvent() {}
+
 func (a Axis) String() string {
 	switch a {
 	case Horizontal:
@@ -409,6 +915,21 @@ func (ct ClickType) String() string {
 	}
 }
 
+func (d SwipeDirection) String() string {
+	switch d {
+	case SwipeLeft:
+		return "SwipeLeft"
+	case SwipeRight:
+		return "SwipeRight"
+	case SwipeUp:
+		return "SwipeUp"
+	case SwipeDown:
+		return "SwipeDown"
+	default:
+		panic("invalid SwipeDirection")
+	}
+}
+
 func (s ScrollState) String() string {
 	switch s {
 	case StateIdle:
@@ -1,13 +1,17 @@
 package gesture
 
 import (
+	"image"
+	"math"
 	"testing"
 	"time"
 
+	"github.com/cybriq/giocore/f32"
 	"github.com/cybriq/giocore/io/event"
 	"github.com/cybriq/giocore/io/pointer"
 	"github.com/cybriq/giocore/io/router"
 	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/unit"
 )
 
 func TestMouseClicks(t *testing.T) {
@@ -60,6 +64,315 @@ func TestMouseClicks(t *testing.T) {
 	}
 }
 
+func TestScrollFling(t *testing.T) {
+	for _, tc := range []struct {
+		label  string
+		source pointer.ScrollSource
+		fling  bool
+	}{
+		{label: "touchpad", source: pointer.ScrollSourceFinger, fling: true},
+		{label: "wheel", source: pointer.ScrollSourceWheel, fling: false},
+	} {
+		t.Run(tc.label, func(t *testing.T) {
+			var scroll Scroll
+			var ops op.Ops
+			scroll.Add(&ops, image.Rect(0, -1000, 0, 1000))
+
+			cfg := unit.Metric{PxPerDp: 1, PxPerSp: 1}
+			var r router.Router
+			// Select the vertical axis.
+			scroll.Scroll(cfg, &r, time.Now(), Vertical)
+			r.Frame(&ops)
+			r.Queue(scrollEvents(tc.source, 10, 10*time.Millisecond, 20)...)
+
+			if got, want := scroll.Scroll(cfg, &r, time.Now(), Vertical), 200; got != want {
+				t.Errorf("got scroll distance %d, expected %d", got, want)
+			}
+			if got := scroll.State() == StateFlinging; got != tc.fling {
+				t.Errorf("got flinging %v, expected %v", got, tc.fling)
+			}
+		})
+	}
+}
+
+func TestLongPress(t *testing.T) {
+	for _, tc := range []struct {
+		label  string
+		events []event.Event
+		fire   bool
+	}{
+		{
+			label:  "held",
+			events: []event.Event{touchEvent(pointer.Press, 0, f32.Pt(10, 10))},
+			fire:   true,
+		},
+		{
+			label: "released",
+			events: []event.Event{
+				touchEvent(pointer.Press, 0, f32.Pt(10, 10)),
+				touchEvent(pointer.Release, 0, f32.Pt(10, 10)),
+			},
+		},
+		{
+			label: "moved",
+			events: []event.Event{
+				touchEvent(pointer.Press, 0, f32.Pt(10, 10)),
+				touchEvent(pointer.Drag, 0, f32.Pt(30, 10)),
+			},
+		},
+	} {
+		t.Run(tc.label, func(t *testing.T) {
+			var lp LongPress
+			var ops op.Ops
+			lp.Add(&ops)
+
+			cfg := unit.Metric{PxPerDp: 1, PxPerSp: 1}
+			var r router.Router
+			r.Frame(&ops)
+			r.Queue(tc.events...)
+
+			now := time.Unix(0, 0)
+			if events := lp.Events(cfg, &r, now); len(events) != 0 {
+				t.Fatalf("got %d long press events before timeout", len(events))
+			}
+			ops.Reset()
+			lp.Add(&ops)
+			// Use a separate router to observe the
+			// InvalidateOp without pending events.
+			var inv router.Router
+			inv.Frame(&ops)
+			wakeup, ok := inv.WakeupTime()
+			if ok != tc.fire {
+				t.Fatalf("got wakeup %v, expected %v", ok, tc.fire)
+			}
+			if ok && !wakeup.Equal(now.Add(longPressDuration)) {
+				t.Errorf("got wakeup at %v, expected %v", wakeup, now.Add(longPressDuration))
+			}
+			events := lp.Events(cfg, &r, now.Add(longPressDuration))
+			if got := len(events) == 1; got != tc.fire {
+				t.Fatalf("got %d long press events, expected fire %v", len(events), tc.fire)
+			}
+			if tc.fire && events[0].Position != f32.Pt(10, 10) {
+				t.Errorf("got long press at %v, expected %v", events[0].Position, f32.Pt(10, 10))
+			}
+			// The long press is reported once.
+			if events := lp.Events(cfg, &r, now.Add(2*longPressDuration)); len(events) != 0 {
+				t.Errorf("got %d long press events after the first", len(events))
+			}
+		})
+	}
+}
+
+func TestDoubleTap(t *testing.T) {
+	for _, tc := range []struct {
+		label string
+		// gap is the time between the taps.
+		gap time.Duration
+		// pos is the position of the second tap.
+		pos  f32.Point
+		taps int
+	}{
+		{label: "double tap", gap: doubleClickDuration - 1, pos: f32.Pt(15, 10), taps: 1},
+		{label: "slow taps", gap: doubleClickDuration + 1, pos: f32.Pt(10, 10), taps: 0},
+		{label: "distant taps", gap: doubleClickDuration - 1, pos: f32.Pt(100, 10), taps: 0},
+	} {
+		t.Run(tc.label, func(t *testing.T) {
+			var dt DoubleTap
+			var ops op.Ops
+			dt.Add(&ops)
+
+			cfg := unit.Metric{PxPerDp: 1, PxPerSp: 1}
+			var r router.Router
+			r.Frame(&ops)
+			const tap = 10 * time.Millisecond
+			press := touchEvent(pointer.Press, 0, f32.Pt(10, 10))
+			release := touchEvent(pointer.Release, 0, f32.Pt(10, 10))
+			release.Time = tap
+			press2 := touchEvent(pointer.Press, 1, tc.pos)
+			press2.Time = tap + tc.gap
+			release2 := touchEvent(pointer.Release, 1, tc.pos)
+			release2.Time = press2.Time + tap
+			r.Queue(press, release, press2, release2)
+			if got := len(dt.Events(cfg, &r)); got != tc.taps {
+				t.Errorf("got %d double taps, expected %d", got, tc.taps)
+			}
+		})
+	}
+}
+
+func TestSwipe(t *testing.T) {
+	for _, tc := range []struct {
+		label string
+		axis  Axis
+		// Motion per 10ms step.
+		step  f32.Point
+		swipe bool
+		dir   SwipeDirection
+	}{
+		{label: "right", axis: Both, step: f32.Pt(10, 1), swipe: true, dir: SwipeRight},
+		{label: "up", axis: Both, step: f32.Pt(-1, -10), swipe: true, dir: SwipeUp},
+		{label: "slow", axis: Both, step: f32.Pt(1, 0), swipe: false},
+		{label: "off axis", axis: Vertical, step: f32.Pt(10, 0), swipe: false},
+	} {
+		t.Run(tc.label, func(t *testing.T) {
+			var sw Swipe
+			var ops op.Ops
+			sw.Add(&ops)
+
+			cfg := unit.Metric{PxPerDp: 1, PxPerSp: 1}
+			var r router.Router
+			r.Frame(&ops)
+			const n = 10
+			pos := f32.Pt(100, 100)
+			events := []event.Event{touchEvent(pointer.Press, 0, pos)}
+			for i := 1; i <= n; i++ {
+				typ := pointer.Drag
+				if i == n {
+					typ = pointer.Release
+				}
+				pos = pos.Add(tc.step)
+				e := touchEvent(typ, 0, pos)
+				e.Time = time.Duration(i) * 10 * time.Millisecond
+				events = append(events, e)
+			}
+			r.Queue(events...)
+			swipes := sw.Events(cfg, &r, tc.axis)
+			if got := len(swipes) == 1; got != tc.swipe {
+				t.Fatalf("got %d swipes, expected swipe %v", len(swipes), tc.swipe)
+			}
+			if !tc.swipe {
+				return
+			}
+			if got := swipes[0].Direction; got != tc.dir {
+				t.Errorf("got swipe direction %v, expected %v", got, tc.dir)
+			}
+			// The motion is linear at 100 steps per second.
+			want := tc.step.Mul(100)
+			if v := swipes[0].Velocity; abs(v.X-want.X) > 1 || abs(v.Y-want.Y) > 1 {
+				t.Errorf("got velocity %v, expected %v", v, want)
+			}
+		})
+	}
+}
+
+func TestTransformTouch(t *testing.T) {
+	var tr Transform
+	var ops op.Ops
+	tr.Add(&ops)
+
+	var r router.Router
+	r.Frame(&ops)
+	r.Queue(
+		touchEvent(pointer.Press, 0, f32.Pt(0, 0)),
+		touchEvent(pointer.Press, 1, f32.Pt(10, 0)),
+		// Stretch.
+		touchEvent(pointer.Drag, 1, f32.Pt(20, 0)),
+		// Rotate a quarter turn clockwise.
+		touchEvent(pointer.Drag, 1, f32.Pt(0, 20)),
+	)
+	events := tr.Events(&r)
+	if got, want := len(events), 2; got != want {
+		t.Fatalf("got %d transform events, expected %d", got, want)
+	}
+	want := []TransformEvent{
+		{Centroid: f32.Pt(10, 0), Scale: 2, Pan: f32.Pt(5, 0)},
+		{Centroid: f32.Pt(0, 10), Scale: 1, Rotation: math.Pi / 2, Pan: f32.Pt(-10, 10)},
+	}
+	for i, e := range events {
+		if e != want[i] {
+			t.Errorf("event %d: got %+v, expected %+v", i, e, want[i])
+		}
+	}
+	if !tr.Active() {
+		t.Error("transform not active with two touches")
+	}
+	r.Queue(touchEvent(pointer.Release, 1, f32.Pt(0, 20)))
+	tr.Events(&r)
+	if tr.Active() {
+		t.Error("transform active after release")
+	}
+}
+
+func TestTransformPinch(t *testing.T) {
+	var tr Transform
+	var ops op.Ops
+	tr.Add(&ops)
+
+	var r router.Router
+	r.Frame(&ops)
+	pinch := pointer.Event{
+		Type:        pointer.Pinch,
+		Source:      pointer.Mouse,
+		Position:    f32.Pt(5, 5),
+		ScrollPhase: pointer.ScrollPhaseBegin,
+		PinchScale:  1,
+	}
+	update := pinch
+	update.ScrollPhase = pointer.ScrollPhaseUpdate
+	update.PinchScale = 1.5
+	update.Delta = f32.Pt(1, 2)
+	end := pinch
+	end.ScrollPhase = pointer.ScrollPhaseEnd
+	end.PinchScale = 0
+	r.Queue(pinch, update)
+	events := tr.Events(&r)
+	if !tr.Active() {
+		t.Error("transform not active during pinch")
+	}
+	want := TransformEvent{Centroid: f32.Pt(5, 5), Scale: 1.5, Pan: f32.Pt(1, 2)}
+	if len(events) != 2 || events[1] != want {
+		t.Errorf("got events %+v, expected %+v", events, want)
+	}
+	r.Queue(end)
+	if events := tr.Events(&r); len(events) != 0 {
+		t.Errorf("got events %+v after pinch end", events)
+	}
+	if tr.Active() {
+		t.Error("transform active after pinch end")
+	}
+}
+
+func touchEvent(typ pointer.Type, id pointer.ID, pos f32.Point) pointer.Event {
+	return pointer.Event{
+		Type:      typ,
+		Source:    pointer.Touch,
+		PointerID: id,
+		Position:  pos,
+	}
+}
+
+// scrollEvents returns a scroll sequence of n steps of dist pixels,
+// every interval, followed by the end of the sequence.
+func scrollEvents(src pointer.ScrollSource, dist float32, interval time.Duration, n int) []event.Event {
+	var events []event.Event
+	for i := 0; i <= n; i++ {
+		e := pointer.Event{
+			Type:         pointer.Scroll,
+			Source:       pointer.Mouse,
+			ScrollSource: src,
+			Time:         time.Duration(i) * interval,
+		}
+		switch {
+		case src == pointer.ScrollSourceWheel:
+			if i == n {
+				continue
+			}
+			e.ScrollSteps.Y = 1
+		case i == 0:
+			e.ScrollPhase = pointer.ScrollPhaseBegin
+		case i == n:
+			e.ScrollPhase = pointer.ScrollPhaseEnd
+		default:
+			e.ScrollPhase = pointer.ScrollPhaseUpdate
+		}
+		if e.ScrollPhase != pointer.ScrollPhaseEnd {
+			e.Scroll.Y = dist
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
 func mouseClickEvents(times ...time.Duration) []event.Event {
 	press := pointer.Event{
 		Type:    pointer.Press,
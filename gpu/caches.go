@@ -28,6 +28,8 @@ type opCacheValue struct {
 	data pathData
 
 	bounds f32.Rectangle
+	// hash identifies the path contents across frames.
+	hash uint64
 	// the fields below are handled by opCache
 	key  opKey
 	keep bool
@@ -56,6 +58,20 @@ func (r *resourceCache) put(key interface{}, val resource) {
 	r.newRes[key] = val
 }
 
+// evict removes and releases the resource with key key, unless it is
+// used by the current frame. It reports whether the resource was
+// removed.
+func (r *resourceCache) evict(key interface{}) bool {
+	if _, exists := r.newRes[key]; exists {
+		return false
+	}
+	if v, exists := r.res[key]; exists {
+		delete(r.res, key)
+		v.release()
+	}
+	return true
+}
+
 func (r *resourceCache) frame() {
 	for k, v := range r.res {
 		if _, exists := r.newRes[k]; !exists {
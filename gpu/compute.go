@@ -19,7 +19,6 @@ import (
 	"time"
 	"unsafe"
 
-	"gioui.org/cpu"
 	"gioui.org/f32"
 	"gioui.org/gpu/internal/driver"
 	"gioui.org/internal/byteslice"
@@ -73,7 +72,7 @@ type compute struct {
 		layerAtlases  []*layerAtlas
 		packer        packer
 
-		descriptors *piet.Kernel4DescriptorSetLayout
+		descriptors *kernel4DescriptorSetLayout
 	}
 	// images contains ImageOp images packed into a texture atlas.
 	images struct {
@@ -110,7 +109,7 @@ type compute struct {
 		}
 
 		// CPU fields
-		cpuTex cpu.ImageDescriptor
+		cpuTex cpuImageDescriptor
 		// regions track new materials in tex, so they can be transferred to cpuTex.
 		regions []image.Rectangle
 		scratch []byte
@@ -150,7 +149,7 @@ type layerAtlas struct {
 	// but contains data in sRGB. See blitLayers for more detail.
 	image    driver.Texture
 	fbo      driver.Framebuffer
-	cpuImage cpu.ImageDescriptor
+	cpuImage cpuImageDescriptor
 	size     image.Point
 	layers   int
 }
@@ -183,6 +182,10 @@ type collector struct {
 	order      []hashIndex
 	prevFrame  opsCollector
 	frame      opsCollector
+	// tints and prevTints cache the tinted images of the masks
+	// painted in the current and previous frames.
+	tints     map[ops.MaskKey]*image.RGBA
+	prevTints map[ops.MaskKey]*image.RGBA
 }
 
 type hashIndex struct {
@@ -309,7 +312,7 @@ type sizedBuffer struct {
 	size   int
 	buffer driver.Buffer
 	// cpuBuf is initialized when useCPU is true.
-	cpuBuf cpu.BufferDescriptor
+	cpuBuf cpuBufferDescriptor
 }
 
 // computeProgram holds a compute program, or its equivalent CPU implementation.
@@ -317,9 +320,9 @@ type computeProgram struct {
 	prog driver.Program
 
 	// CPU fields.
-	progInfo    *cpu.ProgramInfo
+	progInfo    *cpuProgramInfo
 	descriptors unsafe.Pointer
-	buffers     []*cpu.BufferDescriptor
+	buffers     []*cpuBufferDescriptor
 }
 
 // config matches Config in setup.h
@@ -392,18 +395,17 @@ func newCompute(ctx driver.Device) (*compute, error) {
 	shaders := []struct {
 		prog *computeProgram
 		src  shader.Sources
-		info *cpu.ProgramInfo
 	}{
-		{&g.programs.elements, piet.Shader_elements_comp, piet.ElementsProgramInfo},
-		{&g.programs.tileAlloc, piet.Shader_tile_alloc_comp, piet.Tile_allocProgramInfo},
-		{&g.programs.pathCoarse, piet.Shader_path_coarse_comp, piet.Path_coarseProgramInfo},
-		{&g.programs.backdrop, piet.Shader_backdrop_comp, piet.BackdropProgramInfo},
-		{&g.programs.binning, piet.Shader_binning_comp, piet.BinningProgramInfo},
-		{&g.programs.coarse, piet.Shader_coarse_comp, piet.CoarseProgramInfo},
-		{&g.programs.kernel4, piet.Shader_kernel4_comp, piet.Kernel4ProgramInfo},
+		{&g.programs.elements, piet.Shader_elements_comp},
+		{&g.programs.tileAlloc, piet.Shader_tile_alloc_comp},
+		{&g.programs.pathCoarse, piet.Shader_path_coarse_comp},
+		{&g.programs.backdrop, piet.Shader_backdrop_comp},
+		{&g.programs.binning, piet.Shader_binning_comp},
+		{&g.programs.coarse, piet.Shader_coarse_comp},
+		{&g.programs.kernel4, piet.Shader_kernel4_comp},
 	}
 	if !caps.Features.Has(driver.FeatureCompute) {
-		if !cpu.Supported {
+		if !cpuSupported {
 			return nil, errors.New("gpu: missing support for compute programs")
 		}
 		g.useCPU = true
@@ -476,54 +478,16 @@ func newCompute(ctx driver.Device) (*compute, error) {
 	g.materials.frag.buf = buf
 	g.materials.prog.SetFragmentUniforms(buf)
 
-	for _, shader := range shaders {
-		if !g.useCPU {
+	if g.useCPU {
+		g.initCPUPrograms()
+	} else {
+		for _, shader := range shaders {
 			p, err := ctx.NewComputeProgram(shader.src)
 			if err != nil {
 				g.Release()
 				return nil, err
 			}
 			shader.prog.prog = p
-		} else {
-			shader.prog.progInfo = shader.info
-		}
-	}
-	if g.useCPU {
-		{
-			desc := new(piet.ElementsDescriptorSetLayout)
-			g.programs.elements.descriptors = unsafe.Pointer(desc)
-			g.programs.elements.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1(), desc.Binding2(), desc.Binding3()}
-		}
-		{
-			desc := new(piet.Tile_allocDescriptorSetLayout)
-			g.programs.tileAlloc.descriptors = unsafe.Pointer(desc)
-			g.programs.tileAlloc.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
-		}
-		{
-			desc := new(piet.Path_coarseDescriptorSetLayout)
-			g.programs.pathCoarse.descriptors = unsafe.Pointer(desc)
-			g.programs.pathCoarse.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
-		}
-		{
-			desc := new(piet.BackdropDescriptorSetLayout)
-			g.programs.backdrop.descriptors = unsafe.Pointer(desc)
-			g.programs.backdrop.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
-		}
-		{
-			desc := new(piet.BinningDescriptorSetLayout)
-			g.programs.binning.descriptors = unsafe.Pointer(desc)
-			g.programs.binning.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
-		}
-		{
-			desc := new(piet.CoarseDescriptorSetLayout)
-			g.programs.coarse.descriptors = unsafe.Pointer(desc)
-			g.programs.coarse.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
-		}
-		{
-			desc := new(piet.Kernel4DescriptorSetLayout)
-			g.programs.kernel4.descriptors = unsafe.Pointer(desc)
-			g.programs.kernel4.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
-			g.output.descriptors = desc
 		}
 	}
 	return g, nil
@@ -547,7 +511,7 @@ func (g *compute) Clear(col color.NRGBA) {
 
 func (g *compute) Frame(target RenderTarget) error {
 	viewport := g.viewport
-	defFBO := g.ctx.BeginFrame(target, g.collector.clear, viewport)
+	defFBO := g.ctx.BeginFrame(target, g.collector.clear, false, viewport)
 	defer g.ctx.EndFrame()
 
 	t := &g.timers
@@ -612,6 +576,13 @@ func (g *compute) dumpAtlases() {
 	}
 }
 
+// SetBufferAge is a no-op; compute frames are always drawn in full.
+func (g *compute) SetBufferAge(age int) {}
+
+func (g *compute) Damage() image.Rectangle {
+	return image.Rectangle{Max: g.viewport}
+}
+
 func (g *compute) Profile() string {
 	return g.timers.profile
 }
@@ -890,7 +861,7 @@ restart:
 		m.tex = handle
 		m.fbo = fbo
 		if g.useCPU {
-			m.cpuTex = cpu.NewImageRGBA(texSize, texSize)
+			m.cpuTex = newCPUImage(texSize, texSize)
 		}
 	}
 	// Transform to clip space: [-1, -1] - [1, 1].
@@ -1077,7 +1048,7 @@ func (enc *encoder) encodePath(verts []byte) {
 	}
 }
 
-func (g *compute) render(dst driver.Texture, cpuDst cpu.ImageDescriptor, tileDims image.Point, stride int) error {
+func (g *compute) render(dst driver.Texture, cpuDst cpuImageDescriptor, tileDims image.Point, stride int) error {
 	const (
 		// wgSize is the largest and most common workgroup size.
 		wgSize = 128
@@ -1305,7 +1276,7 @@ func (a *layerAtlas) ensureSize(useCPU bool, ctx driver.Device, size image.Point
 	a.fbo = fbo
 	a.image = img
 	if useCPU {
-		a.cpuImage = cpu.NewImageRGBA(size.X, size.Y)
+		a.cpuImage = newCPUImage(size.X, size.Y)
 	}
 	a.size = size
 	return nil
@@ -1402,7 +1373,7 @@ func (b *sizedBuffer) ensureCapacity(useCPU bool, ctx driver.Device, binding dri
 		}
 		b.buffer = buf
 	} else {
-		b.cpuBuf = cpu.NewBuffer(size)
+		b.cpuBuf = newCPUBuffer(size)
 	}
 	b.size = size
 	return nil
@@ -1523,6 +1494,28 @@ func (c *collector) reset() {
 	c.profile = false
 	c.clipStates = c.clipStates[:0]
 	c.frame.reset()
+	c.prevTints, c.tints = c.tints, c.prevTints
+	for k := range c.tints {
+		delete(c.tints, k)
+	}
+}
+
+// tint returns the tinted image of a mask, reusing the image from the
+// previous frame if the mask is unchanged.
+func (c *collector) tint(m ops.Mask) imageOpData {
+	k := m.Key()
+	img, ok := c.tints[k]
+	if !ok {
+		img, ok = c.prevTints[k]
+		if !ok {
+			img = m.Tint()
+		}
+		if c.tints == nil {
+			c.tints = make(map[ops.MaskKey]*image.RGBA)
+		}
+		c.tints[k] = img
+	}
+	return imageOpData{src: img, handle: img}
 }
 
 func (c *opsCollector) reset() {
@@ -1596,7 +1589,7 @@ func (c *collector) collect(root *op.Ops, viewport image.Point) {
 			state.t = state.t.Mul(dop)
 			state.relTrans = state.relTrans.Mul(dop)
 		case opconst.TypeStroke:
-			str = decodeStrokeOp(encOp.Data)
+			str = clip.StrokeStyle{Width: ops.DecodeStroke(encOp.Data)}
 		case opconst.TypePath:
 			hash := bo.Uint64(encOp.Data[1:])
 			encOp, ok = r.Decode()
@@ -1607,67 +1600,36 @@ func (c *collector) collect(root *op.Ops, viewport image.Point) {
 			pathData.key = encOp.Key
 			pathData.hash = hash
 		case opconst.TypeClip:
-			var op clipOp
-			op.decode(encOp.Data)
-			c.addClip(&state, fview, op.bounds, pathData.data, pathData.key, pathData.hash, str)
+			bounds, _ := ops.DecodeClip(encOp.Data)
+			c.addClip(&state, fview, bounds, pathData.data, pathData.key, pathData.hash, str)
 			pathData.data = nil
 			str = clip.StrokeStyle{}
 		case opconst.TypeColor:
 			state.matType = materialColor
-			state.color = decodeColorOp(encOp.Data)
+			state.color = ops.DecodeColor(encOp.Data)
 		case opconst.TypeLinearGradient:
 			state.matType = materialLinearGradient
-			op := decodeLinearGradientOp(encOp.Data)
-			state.stop1 = op.stop1
-			state.stop2 = op.stop2
-			state.color1 = op.color1
-			state.color2 = op.color2
+			g := ops.DecodeLinearGradient(encOp.Data)
+			state.stop1, state.stop2 = g.Stop1, g.Stop2
+			state.color1, state.color2 = g.Color1, g.Color2
 		case opconst.TypeImage:
 			state.matType = materialTexture
 			state.image = decodeImageOp(encOp.Data, encOp.Refs)
 		case opconst.TypePaint:
-			paintState := state
-			if paintState.matType == materialTexture {
-				// Clip to the bounds of the image, to hide other images in the atlas.
-				bounds := paintState.image.src.Bounds()
-				c.addClip(&paintState, fview, layout.FRect(bounds), nil, ops.Key{}, 0, clip.StrokeStyle{})
-			}
-			if paintState.intersect.Empty() {
-				break
+			c.paint(state, fview)
+		case opconst.TypeMask:
+			m := ops.DecodeMask(encOp.Data, encOp.Refs)
+			maskState := state
+			if isPureOffset(state.t) {
+				// Place the mask on whole pixels.
+				_, _, ox, _, _, oy := state.t.Elems()
+				d := f32.Pt(float32(math.Round(float64(ox)))-ox, float32(math.Round(float64(oy)))-oy)
+				maskState.t = maskState.t.Offset(d)
+				maskState.relTrans = maskState.relTrans.Offset(d)
 			}
-
-			// If the paint is a uniform opaque color that takes up the whole
-			// screen, it covers all previous paints and we can discard all
-			// rendering commands recorded so far.
-			if paintState.clip == nil && paintState.matType == materialColor && paintState.color.A == 255 {
-				c.clearColor = f32color.LinearFromSRGB(paintState.color).Opaque()
-				c.clear = true
-				c.frame.reset()
-				break
-			}
-
-			// Flatten clip stack.
-			p := paintState.clip
-			startIdx := len(c.frame.clipCmds)
-			for p != nil {
-				idx := len(c.frame.paths)
-				c.frame.paths = append(c.frame.paths, make([]byte, len(p.path))...)
-				path := c.frame.paths[idx:]
-				copy(path, p.path)
-				c.frame.clipCmds = append(c.frame.clipCmds, clipCmd{
-					state:     p.clipKey,
-					path:      path,
-					pathKey:   p.pathKey,
-					absBounds: p.absBounds,
-				})
-				p = p.parent
-			}
-			clipStack := c.frame.clipCmds[startIdx:]
-			c.frame.ops = append(c.frame.ops, paintOp{
-				clipStack: clipStack,
-				state:     paintState.paintKey,
-				intersect: paintState.intersect,
-			})
+			maskState.matType = materialTexture
+			maskState.image = c.tint(m)
+			c.paint(maskState, fview)
 		case opconst.TypeSave:
 			id := ops.DecodeSave(encOp.Data)
 			c.save(id, state)
@@ -1716,6 +1678,51 @@ func (c *collector) collect(root *op.Ops, viewport image.Point) {
 	}
 }
 
+// paint records a paint of the clip area of state with its material.
+func (c *collector) paint(state encoderState, fview f32.Rectangle) {
+	if state.matType == materialTexture {
+		// Clip to the bounds of the image, to hide other images in the atlas.
+		bounds := state.image.src.Bounds()
+		c.addClip(&state, fview, layout.FRect(bounds), nil, ops.Key{}, 0, clip.StrokeStyle{})
+	}
+	if state.intersect.Empty() {
+		return
+	}
+
+	// If the paint is a uniform opaque color that takes up the whole
+	// screen, it covers all previous paints and we can discard all
+	// rendering commands recorded so far.
+	if state.clip == nil && state.matType == materialColor && state.color.A == 255 {
+		c.clearColor = f32color.LinearFromSRGB(state.color).Opaque()
+		c.clear = true
+		c.frame.reset()
+		return
+	}
+
+	// Flatten clip stack.
+	p := state.clip
+	startIdx := len(c.frame.clipCmds)
+	for p != nil {
+		idx := len(c.frame.paths)
+		c.frame.paths = append(c.frame.paths, make([]byte, len(p.path))...)
+		path := c.frame.paths[idx:]
+		copy(path, p.path)
+		c.frame.clipCmds = append(c.frame.clipCmds, clipCmd{
+			state:     p.clipKey,
+			path:      path,
+			pathKey:   p.pathKey,
+			absBounds: p.absBounds,
+		})
+		p = p.parent
+	}
+	clipStack := c.frame.clipCmds[startIdx:]
+	c.frame.ops = append(c.frame.ops, paintOp{
+		clipStack: clipStack,
+		state:     state.paintKey,
+		intersect: state.intersect,
+	})
+}
+
 func (c *collector) hashOp(op paintOp) uint64 {
 	c.hasher.Reset()
 	for _, cl := range op.clipStack {
@@ -1,15 +1,85 @@
 // SPDX-License-Identifier: Unlicense OR MIT
 
+//go:build !(linux && (arm64 || arm || amd64)) || cgo
+// +build !linux !arm64,!arm,!amd64 cgo
+
 package gpu
 
 import (
 	"unsafe"
 
 	"gioui.org/cpu"
+	"gioui.org/shader/piet"
 )
 
 // This file contains code specific to running compute shaders on the CPU.
 
+// cpuSupported reports whether compute programs can run on the CPU.
+const cpuSupported = cpu.Supported
+
+type (
+	cpuImageDescriptor         = cpu.ImageDescriptor
+	cpuBufferDescriptor        = cpu.BufferDescriptor
+	cpuProgramInfo             = cpu.ProgramInfo
+	kernel4DescriptorSetLayout = piet.Kernel4DescriptorSetLayout
+)
+
+func newCPUImage(width, height int) cpuImageDescriptor {
+	return cpu.NewImageRGBA(width, height)
+}
+
+func newCPUBuffer(size int) cpuBufferDescriptor {
+	return cpu.NewBuffer(size)
+}
+
+// initCPUPrograms sets up the CPU implementations of the compute
+// programs.
+func (g *compute) initCPUPrograms() {
+	{
+		desc := new(piet.ElementsDescriptorSetLayout)
+		g.programs.elements.progInfo = piet.ElementsProgramInfo
+		g.programs.elements.descriptors = unsafe.Pointer(desc)
+		g.programs.elements.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1(), desc.Binding2(), desc.Binding3()}
+	}
+	{
+		desc := new(piet.Tile_allocDescriptorSetLayout)
+		g.programs.tileAlloc.progInfo = piet.Tile_allocProgramInfo
+		g.programs.tileAlloc.descriptors = unsafe.Pointer(desc)
+		g.programs.tileAlloc.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
+	}
+	{
+		desc := new(piet.Path_coarseDescriptorSetLayout)
+		g.programs.pathCoarse.progInfo = piet.Path_coarseProgramInfo
+		g.programs.pathCoarse.descriptors = unsafe.Pointer(desc)
+		g.programs.pathCoarse.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
+	}
+	{
+		desc := new(piet.BackdropDescriptorSetLayout)
+		g.programs.backdrop.progInfo = piet.BackdropProgramInfo
+		g.programs.backdrop.descriptors = unsafe.Pointer(desc)
+		g.programs.backdrop.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
+	}
+	{
+		desc := new(piet.BinningDescriptorSetLayout)
+		g.programs.binning.progInfo = piet.BinningProgramInfo
+		g.programs.binning.descriptors = unsafe.Pointer(desc)
+		g.programs.binning.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
+	}
+	{
+		desc := new(piet.CoarseDescriptorSetLayout)
+		g.programs.coarse.progInfo = piet.CoarseProgramInfo
+		g.programs.coarse.descriptors = unsafe.Pointer(desc)
+		g.programs.coarse.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
+	}
+	{
+		desc := new(piet.Kernel4DescriptorSetLayout)
+		g.programs.kernel4.progInfo = piet.Kernel4ProgramInfo
+		g.programs.kernel4.descriptors = unsafe.Pointer(desc)
+		g.programs.kernel4.buffers = []*cpu.BufferDescriptor{desc.Binding0(), desc.Binding1()}
+		g.output.descriptors = desc
+	}
+}
+
 // dispatcher dispatches CPU compute programs across multiple goroutines.
 type dispatcher struct {
 	// done is notified when a worker completes its work slice.
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && (arm64 || arm || amd64) && !cgo
+// +build linux
+// +build arm64 arm amd64
+// +build !cgo
+
+package gpu
+
+import "unsafe"
+
+// This file replaces cpu.go where running compute shaders on the CPU
+// requires cgo.
+
+const cpuSupported = false
+
+type (
+	cpuImageDescriptor  struct{}
+	cpuBufferDescriptor struct{}
+	cpuProgramInfo      struct{}
+
+	kernel4DescriptorSetLayout struct{}
+
+	dispatcher struct{}
+)
+
+func newCPUImage(width, height int) cpuImageDescriptor {
+	panic("unsupported")
+}
+
+func newCPUBuffer(size int) cpuBufferDescriptor {
+	panic("unsupported")
+}
+
+func (d *cpuImageDescriptor) Data() []byte {
+	panic("unsupported")
+}
+
+func (d *cpuImageDescriptor) Free() {
+}
+
+func (d *cpuBufferDescriptor) Data() []byte {
+	panic("unsupported")
+}
+
+func (d *cpuBufferDescriptor) Free() {
+}
+
+func (l *kernel4DescriptorSetLayout) Binding2() *cpuImageDescriptor {
+	panic("unsupported")
+}
+
+func (l *kernel4DescriptorSetLayout) Binding3() *cpuImageDescriptor {
+	panic("unsupported")
+}
+
+func (g *compute) initCPUPrograms() {
+	panic("unsupported")
+}
+
+func newDispatcher(workers int) *dispatcher {
+	panic("unsupported")
+}
+
+func (d *dispatcher) Barrier() {
+	panic("unsupported")
+}
+
+func (d *dispatcher) Sync() {
+	panic("unsupported")
+}
+
+func (d *dispatcher) Dispatch(program *cpuProgramInfo, descSet unsafe.Pointer, x, y, z int) {
+	panic("unsupported")
+}
+
+func (d *dispatcher) Stop() {
+}
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package gpu
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"image"
+	"math"
+	"unsafe"
+
+	"gioui.org/f32"
+	"gioui.org/internal/f32color"
+	"gioui.org/internal/ops"
+	"gioui.org/op/clip"
+)
+
+// maxBufferAge is the number of frames of damage tracked for
+// render targets that preserve their contents.
+const maxBufferAge = 4
+
+// damageTracker computes the area changed between frames by
+// comparing the draw keys of their operations.
+type damageTracker struct {
+	viewport   image.Point
+	clear      bool
+	clearColor f32color.RGBA
+	// keys are the draw keys of the previous frame.
+	keys    []drawKey
+	newKeys []drawKey
+	// index maps a key to the index+1 of its first unmatched
+	// occurrence in keys, and next links equal keys.
+	index   map[drawKey]int
+	next    []int
+	matched []bool
+	// history contains the damage of the most recent frames,
+	// newest first.
+	history [maxBufferAge]image.Rectangle
+	nframes int
+}
+
+// drawKey identifies the pixels drawn by an imageOp. Operations
+// with equal keys draw identical pixels.
+type drawKey struct {
+	clip image.Rectangle
+	// path is the hash of the clip paths.
+	path     uint64
+	material material
+}
+
+// frame records the damage of a frame compared to the previous
+// frame.
+func (t *damageTracker) frame(viewport image.Point, clear bool, clearColor f32color.RGBA, ops []imageOp) {
+	t.newKeys = t.newKeys[:0]
+	for _, img := range ops {
+		t.newKeys = append(t.newKeys, drawKey{
+			clip:     img.clip,
+			path:     pathHash(img.path),
+			material: img.material,
+		})
+	}
+	full := image.Rectangle{Max: viewport}
+	var damage image.Rectangle
+	if t.nframes == 0 || !clear || viewport != t.viewport || !t.clear || clearColor != t.clearColor {
+		// Without a clear, the frame draws on top of the previous
+		// frame.
+		damage = full
+		t.nframes = 0
+	} else {
+		damage = t.diff(t.newKeys).Intersect(full)
+	}
+	t.viewport = viewport
+	t.clear = clear
+	t.clearColor = clearColor
+	t.keys, t.newKeys = t.newKeys, t.keys
+	copy(t.history[1:], t.history[:])
+	t.history[0] = damage
+	if t.nframes < len(t.history) {
+		t.nframes++
+	}
+}
+
+// diff returns the union of the areas drawn by operations in only
+// one of the previous and the current frame, and by operations whose
+// order changed.
+func (t *damageTracker) diff(keys []drawKey) image.Rectangle {
+	if t.index == nil {
+		t.index = make(map[drawKey]int)
+	}
+	for k := range t.index {
+		delete(t.index, k)
+	}
+	t.next = t.next[:0]
+	t.matched = t.matched[:0]
+	for range t.keys {
+		t.next = append(t.next, 0)
+		t.matched = append(t.matched, false)
+	}
+	for i := len(t.keys) - 1; i >= 0; i-- {
+		k := t.keys[i]
+		t.next[i] = t.index[k]
+		t.index[k] = i + 1
+	}
+	var damage image.Rectangle
+	last := -1
+	for _, k := range keys {
+		j := t.index[k]
+		if j == 0 {
+			damage = damage.Union(k.clip)
+			continue
+		}
+		i := j - 1
+		t.index[k] = t.next[i]
+		t.matched[i] = true
+		if i < last {
+			// The operation moved above others; redraw its area.
+			damage = damage.Union(k.clip)
+		} else {
+			last = i
+		}
+	}
+	for i, k := range t.keys {
+		if !t.matched[i] {
+			damage = damage.Union(k.clip)
+		}
+	}
+	return damage
+}
+
+// area returns the area to draw for a target whose contents are
+// age frames old.
+func (t *damageTracker) area(age int) image.Rectangle {
+	if age <= 0 || age > t.nframes {
+		return image.Rectangle{Max: t.viewport}
+	}
+	var area image.Rectangle
+	for _, d := range t.history[:age] {
+		area = area.Union(d)
+	}
+	return area
+}
+
+// pathHash combines the hashes and offsets of the clip paths of an
+// imageOp in the manner of FNV-1a.
+func pathHash(p *pathOp) uint64 {
+	h := uint64(14695981039346656037)
+	for ; p != nil; p = p.parent {
+		if !p.path && p.mask == nil {
+			continue
+		}
+		for _, v := range [...]uint64{p.hash, uint64(math.Float32bits(p.off.X)), uint64(math.Float32bits(p.off.Y))} {
+			h = (h ^ v) * 1099511628211
+		}
+	}
+	return h
+}
+
+// maskHash returns a hash that identifies the contents of the area
+// of the mask m.
+func maskHash(m ops.Mask) uint64 {
+	h := uint64(14695981039346656037)
+	for _, v := range [...]uint64{
+		uint64(uintptr(unsafe.Pointer(m.Version))), uint64(*m.Version),
+		uint64(m.Rect.Min.X), uint64(m.Rect.Min.Y),
+	} {
+		h = (h ^ v) * 1099511628211
+	}
+	return h
+}
+
+// hashPath returns a hash of path data and the parameters that
+// determine its vertices.
+func hashPath(data []byte, t f32.Affine2D, outline bool, str clip.StrokeStyle) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	var outlineBit uint32
+	if outline {
+		outlineBit = 1
+	}
+	sx, hx, ox, hy, sy, oy := t.Elems()
+	params := [...]uint32{
+		math.Float32bits(sx), math.Float32bits(hx), math.Float32bits(ox),
+		math.Float32bits(hy), math.Float32bits(sy), math.Float32bits(oy),
+		math.Float32bits(str.Width), math.Float32bits(str.Miter),
+		uint32(str.Cap), uint32(str.Join), outlineBit,
+	}
+	var buf [4 * len(params)]byte
+	for i, v := range params {
+		binary.LittleEndian.PutUint32(buf[i*4:], v)
+	}
+	h.Write(buf[:])
+	return h.Sum64()
+}
@@ -0,0 +1,348 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+/*
+Package export converts operation lists to vector formats.
+
+SVG and PDF translate transformations, clip paths and strokes, colors,
+linear gradients and images to the native constructs of their formats,
+so the result can be scaled and printed without loss of quality.
+
+One unit of the operation coordinates is one pixel in SVG and one point
+(1/72 inch) in PDF. Scale the operations, for example through the
+unit.Metric used to lay them out, to export at a particular physical
+size.
+*/
+package export
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/internal/f32color"
+	"github.com/cybriq/giocore/internal/opconst"
+	"github.com/cybriq/giocore/internal/ops"
+	"github.com/cybriq/giocore/internal/scene"
+	"github.com/cybriq/giocore/internal/stroke"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/op/clip"
+)
+
+// drawing is the result of walking an operation list.
+type drawing struct {
+	size  image.Point
+	clips []*clipArea
+	fills []fill
+}
+
+// clipArea is the intersection of a clip path and its parent area.
+type clipArea struct {
+	// id is the index of the area in drawing.clips.
+	id     int
+	parent *clipArea
+	// path is in viewport coordinates.
+	path []segment
+	// empty is set if the area or one of its ancestors is empty.
+	empty bool
+}
+
+type segmentOp uint8
+
+const (
+	segmentMove segmentOp = iota
+	segmentLine
+	segmentQuad
+	segmentCubic
+)
+
+// segment is a path segment from the end of the previous segment.
+type segment struct {
+	op segmentOp
+	// args are the control points followed by the end point.
+	args [3]f32.Point
+}
+
+type materialType uint8
+
+const (
+	materialColor materialType = iota
+	materialLinearGradient
+	materialTexture
+)
+
+// fill is a paint operation.
+type fill struct {
+	clip     *clipArea
+	material materialType
+	// For materialColor.
+	color color.NRGBA
+	// For materialLinearGradient.
+	stop1  f32.Point
+	stop2  f32.Point
+	color1 color.NRGBA
+	color2 color.NRGBA
+	// For materialTexture.
+	image *image.RGBA
+	// t transforms gradients and images to viewport coordinates.
+	t f32.Affine2D
+}
+
+type drawState struct {
+	t    f32.Affine2D
+	clip *clipArea
+	fill fill
+}
+
+var bo = binary.LittleEndian
+
+// collect walks the operation list and records its paint operations.
+func collect(root *op.Ops, size image.Point) *drawing {
+	d := &drawing{size: size}
+	var (
+		r      ops.Reader
+		states []drawState
+		path   []byte
+		str    clip.StrokeStyle
+		state  = drawState{fill: fill{color: color.NRGBA{A: 0xff}}}
+	)
+	save := func(id int) {
+		if extra := id - len(states) + 1; extra > 0 {
+			states = append(states, make([]drawState, extra)...)
+		}
+		states[id] = state
+	}
+	save(opconst.InitialStateID)
+	r.Reset(root)
+loop:
+	for encOp, ok := r.Decode(); ok; encOp, ok = r.Decode() {
+		switch opconst.OpType(encOp.Data[0]) {
+		case opconst.TypeTransform:
+			dop := ops.DecodeTransform(encOp.Data)
+			state.t = state.t.Mul(dop)
+		case opconst.TypeStroke:
+			str = clip.StrokeStyle{Width: ops.DecodeStroke(encOp.Data)}
+		case opconst.TypePath:
+			encOp, ok = r.Decode()
+			if !ok {
+				break loop
+			}
+			path = encOp.Data[opconst.TypeAuxLen:]
+		case opconst.TypeClip:
+			bounds, outline := ops.DecodeClip(encOp.Data)
+			state.clip = d.addClip(state.clip, clipPath(bounds, path, outline, str, state.t))
+			path = nil
+			str = clip.StrokeStyle{}
+		case opconst.TypeColor:
+			state.fill.material = materialColor
+			state.fill.color = ops.DecodeColor(encOp.Data)
+		case opconst.TypeLinearGradient:
+			state.fill.material = materialLinearGradient
+			g := ops.DecodeLinearGradient(encOp.Data)
+			state.fill.stop1, state.fill.stop2 = g.Stop1, g.Stop2
+			state.fill.color1, state.fill.color2 = g.Color1, g.Color2
+		case opconst.TypeImage:
+			state.fill.material = materialTexture
+			state.fill.image, _ = ops.DecodeImage(encOp.Data, encOp.Refs)
+		case opconst.TypePaint:
+			if state.clip != nil && state.clip.empty {
+				continue
+			}
+			if state.fill.material == materialTexture && state.fill.image == nil {
+				continue
+			}
+			f := state.fill
+			f.clip = state.clip
+			f.t = state.t
+			d.fills = append(d.fills, f)
+		case opconst.TypeMask:
+			if state.clip != nil && state.clip.empty {
+				continue
+			}
+			m := ops.DecodeMask(encOp.Data, encOp.Refs)
+			d.fills = append(d.fills, fill{
+				clip:     state.clip,
+				material: materialTexture,
+				image:    m.Tint(),
+				t:        snapOffset(state.t),
+			})
+		case opconst.TypeSave:
+			id := ops.DecodeSave(encOp.Data)
+			save(id)
+		case opconst.TypeLoad:
+			id, mask := ops.DecodeLoad(encOp.Data)
+			s := states[id]
+			if mask&opconst.TransformState != 0 {
+				state.t = s.t
+			}
+			if mask&^opconst.TransformState != 0 {
+				state = s
+			}
+		}
+	}
+	return d
+}
+
+// snapOffset rounds the offset of t to whole pixels if t is a pure
+// offset, the way masks are placed by the renderers.
+func snapOffset(t f32.Affine2D) f32.Affine2D {
+	sx, hx, ox, hy, sy, oy := t.Elems()
+	if sx != 1 || hx != 0 || hy != 0 || sy != 1 {
+		return t
+	}
+	return f32.NewAffine2D(1, 0, float32(math.Round(float64(ox))), 0, 1, float32(math.Round(float64(oy))))
+}
+
+func (d *drawing) addClip(parent *clipArea, path []segment) *clipArea {
+	c := &clipArea{
+		id:     len(d.clips),
+		parent: parent,
+		path:   path,
+		empty:  len(path) == 0 || parent != nil && parent.empty,
+	}
+	d.clips = append(d.clips, c)
+	return c
+}
+
+// clipPath returns the outline of a clip operation in viewport
+// coordinates.
+func clipPath(bounds f32.Rectangle, path []byte, outline bool, str clip.StrokeStyle, t f32.Affine2D) []segment {
+	var segs []segment
+	switch {
+	case bounds.Empty():
+		// Nothing is visible through an empty clip.
+	case len(path) == 0:
+		corners := [...]f32.Point{
+			t.Transform(bounds.Min), t.Transform(f32.Pt(bounds.Max.X, bounds.Min.Y)),
+			t.Transform(bounds.Max), t.Transform(f32.Pt(bounds.Min.X, bounds.Max.Y)),
+		}
+		segs = append(segs, segment{op: segmentMove, args: [3]f32.Point{corners[0]}})
+		for _, c := range corners[1:] {
+			segs = append(segs, segment{op: segmentLine, args: [3]f32.Point{c}})
+		}
+	case str.Width > 0:
+		ss := stroke.StrokeStyle{
+			Width: str.Width,
+			Miter: str.Miter,
+			Cap:   stroke.StrokeCap(str.Cap),
+			Join:  stroke.StrokeJoin(str.Join),
+		}
+		var pen f32.Point
+		for i, q := range stroke.StrokePathCommands(ss, stroke.DashOp{}, path) {
+			q.Quad = q.Quad.Transform(t)
+			if i == 0 || q.Quad.From != pen {
+				segs = append(segs, segment{op: segmentMove, args: [3]f32.Point{q.Quad.From}})
+			}
+			segs = append(segs, segment{op: segmentQuad, args: [3]f32.Point{q.Quad.Ctrl, q.Quad.To}})
+			pen = q.Quad.To
+		}
+	case outline:
+		segs = outlineSegments(path, t)
+	}
+	return segs
+}
+
+// outlineSegments decodes path data and transforms it by t.
+func outlineSegments(path []byte, t f32.Affine2D) []segment {
+	var (
+		segs    []segment
+		contour uint32
+		pen     f32.Point
+	)
+	for i := 0; len(path) >= scene.CommandSize+4; i++ {
+		c := bo.Uint32(path)
+		cmd := ops.DecodeCommand(path[4:])
+		var from f32.Point
+		var seg segment
+		switch cmd.Op() {
+		case scene.OpLine:
+			var to f32.Point
+			from, to = scene.DecodeLine(cmd)
+			seg = segment{op: segmentLine, args: [3]f32.Point{t.Transform(to)}}
+		case scene.OpQuad:
+			var ctrl, to f32.Point
+			from, ctrl, to = scene.DecodeQuad(cmd)
+			seg = segment{op: segmentQuad, args: [3]f32.Point{t.Transform(ctrl), t.Transform(to)}}
+		case scene.OpCubic:
+			var ctrl0, ctrl1, to f32.Point
+			from, ctrl0, ctrl1, to = scene.DecodeCubic(cmd)
+			seg = segment{op: segmentCubic, args: [3]f32.Point{t.Transform(ctrl0), t.Transform(ctrl1), t.Transform(to)}}
+		default:
+			panic("unsupported scene command")
+		}
+		if from = t.Transform(from); i == 0 || c != contour || from != pen {
+			segs = append(segs, segment{op: segmentMove, args: [3]f32.Point{from}})
+		}
+		segs = append(segs, seg)
+		contour = c
+		pts := seg.points()
+		pen = pts[len(pts)-1]
+		path = path[scene.CommandSize+4:]
+	}
+	return segs
+}
+
+// points returns the control points of the segment followed by its
+// end point.
+func (s *segment) points() []f32.Point {
+	switch s.op {
+	case segmentQuad:
+		return s.args[:2]
+	case segmentCubic:
+		return s.args[:3]
+	default:
+		return s.args[:1]
+	}
+}
+
+// chain returns the clip areas from the outermost to c.
+func (c *clipArea) chain() []*clipArea {
+	var areas []*clipArea
+	for ; c != nil; c = c.parent {
+		areas = append(areas, c)
+	}
+	for i, j := 0, len(areas)-1; i < j; i, j = i+1, j-1 {
+		areas[i], areas[j] = areas[j], areas[i]
+	}
+	return areas
+}
+
+// gradientColors returns n colors evenly spaced between the stop
+// colors of a linear gradient, interpolated in linear color space
+// like the GPU renderer.
+func gradientColors(f fill, n int) []color.NRGBA {
+	c1 := f32color.LinearFromSRGB(f.color1)
+	c2 := f32color.LinearFromSRGB(f.color2)
+	cols := make([]color.NRGBA, n)
+	for i := range cols {
+		t := float32(i) / float32(n-1)
+		c := f32color.RGBA{
+			R: c1.R + (c2.R-c1.R)*t,
+			G: c1.G + (c2.G-c1.G)*t,
+			B: c1.B + (c2.B-c1.B)*t,
+			A: c1.A + (c2.A-c1.A)*t,
+		}
+		cols[i] = c.SRGB()
+	}
+	return cols
+}
+
+// toNRGBA converts an image to non-premultiplied colors.
+func toNRGBA(img *image.RGBA) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rectangle{Max: b.Size()})
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := f32color.RGBAToNRGBA(img.RGBAAt(x, y))
+			dst.SetNRGBA(x-b.Min.X, y-b.Min.Y, c)
+		}
+	}
+	return dst
+}
+
+// formatFloat formats v without exponent, as required by PDF.
+func formatFloat(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
@@ -0,0 +1,486 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/gpu/internal/soft"
+	"github.com/cybriq/giocore/internal/f32color"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/op/clip"
+	"github.com/cybriq/giocore/op/paint"
+)
+
+var exportSize = image.Pt(64, 64)
+
+// drawScene draws shapes exercising every supported operation.
+func drawScene(ops *op.Ops, gradient paint.LinearGradientOp) {
+	paint.FillShape(ops, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, clip.Rect(image.Rect(0, 0, 64, 64)).Op())
+
+	st := op.Save(ops)
+	clip.RRect{Rect: f32.Rect(4, 4, 40, 30), SE: 10, NW: 6}.Add(ops)
+	gradient.Add(ops)
+	paint.PaintOp{}.Add(ops)
+	st.Load()
+
+	var p clip.Path
+	p.Begin(ops)
+	p.MoveTo(f32.Pt(8, 50))
+	p.QuadTo(f32.Pt(30, 30), f32.Pt(56, 56))
+	paint.FillShape(ops, color.NRGBA{B: 0xff, A: 0xc0}, clip.Stroke{
+		Path:  p.End(),
+		Style: clip.StrokeStyle{Width: 4},
+	}.Op())
+
+	st = op.Save(ops)
+	op.Affine(f32.Affine2D{}.Rotate(f32.Pt(48, 16), math.Pi/6)).Add(ops)
+	clip.Rect(image.Rect(38, 6, 58, 26)).Add(ops)
+	clip.Circle{Center: f32.Pt(48, 16), Radius: 12}.Add(ops)
+	paint.ColorOp{Color: color.NRGBA{G: 0x80, A: 0xff}}.Add(ops)
+	paint.PaintOp{}.Add(ops)
+	st.Load()
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.SetRGBA(0, 0, color.RGBA{R: 0xff, A: 0xff})
+	img.SetRGBA(1, 0, color.RGBA{G: 0xff, A: 0xff})
+	img.SetRGBA(0, 1, color.RGBA{B: 0xff, A: 0xff})
+	img.SetRGBA(1, 1, color.RGBA{A: 0xff})
+	st = op.Save(ops)
+	op.Affine(f32.Affine2D{}.Scale(f32.Point{}, f32.Pt(5, 5)).Offset(f32.Pt(4, 36))).Add(ops)
+	paint.NewImageOp(img).Add(ops)
+	paint.PaintOp{}.Add(ops)
+	st.Load()
+}
+
+func TestSVGRoundTrip(t *testing.T) {
+	var ops op.Ops
+	drawScene(&ops, paint.LinearGradientOp{
+		Stop1:  f32.Pt(4, 4),
+		Color1: color.NRGBA{R: 0xff, A: 0xff},
+		Stop2:  f32.Pt(40, 30),
+		Color2: color.NRGBA{G: 0xff, B: 0xff, A: 0x40},
+	})
+	var b bytes.Buffer
+	if err := SVG(&b, &ops, exportSize); err != nil {
+		t.Fatal(err)
+	}
+	var ops2 op.Ops
+	svgOps(t, b.Bytes(), &ops2)
+	compareRaster(t, &ops, &ops2)
+}
+
+func TestPDFRoundTrip(t *testing.T) {
+	var ops op.Ops
+	drawScene(&ops, paint.LinearGradientOp{
+		Stop1:  f32.Pt(4, 4),
+		Color1: color.NRGBA{R: 0xff, A: 0x80},
+		Stop2:  f32.Pt(40, 30),
+		Color2: color.NRGBA{G: 0xff, B: 0xff, A: 0x80},
+	})
+	var b bytes.Buffer
+	if err := PDF(&b, &ops, exportSize); err != nil {
+		t.Fatal(err)
+	}
+	var ops2 op.Ops
+	pdfOps(t, b.Bytes(), &ops2)
+	compareRaster(t, &ops, &ops2)
+}
+
+func TestPDFSoftMask(t *testing.T) {
+	var ops op.Ops
+	paint.LinearGradientOp{
+		Stop1:  f32.Pt(0, 0),
+		Color1: color.NRGBA{R: 0xff, A: 0xff},
+		Stop2:  f32.Pt(10, 0),
+		Color2: color.NRGBA{R: 0xff},
+	}.Add(&ops)
+	paint.PaintOp{}.Add(&ops)
+	var b bytes.Buffer
+	if err := PDF(&b, &ops, exportSize); err != nil {
+		t.Fatal(err)
+	}
+	objs := pdfObjects(t, b.Bytes())
+	if !strings.Contains(objs[pdfPage], "/SMask") {
+		t.Errorf("gradient with varying alpha has no soft mask")
+	}
+}
+
+func TestEmptyClip(t *testing.T) {
+	var ops op.Ops
+	var p clip.Path
+	p.Begin(&ops)
+	paint.FillShape(&ops, color.NRGBA{A: 0xff}, clip.Outline{Path: p.End()}.Op())
+	var b bytes.Buffer
+	if err := SVG(&b, &ops, exportSize); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "<rect") {
+		t.Errorf("painted outside empty clip:\n%s", b.String())
+	}
+}
+
+// compareRaster compares the rasterization of ops with the
+// rasterization of their exported and parsed form.
+func compareRaster(t *testing.T, ops, exported *op.Ops) {
+	t.Helper()
+	var r soft.Renderer
+	want := image.NewRGBA(image.Rectangle{Max: exportSize})
+	r.Render(want, ops)
+	got := image.NewRGBA(image.Rectangle{Max: exportSize})
+	r.Render(got, exported)
+	for i := range want.Pix {
+		if d := int(want.Pix[i]) - int(got.Pix[i]); d < -2 || d > 2 {
+			x, y := i/4%exportSize.X, i/4/exportSize.X
+			t.Fatalf("(%d,%d): got %v, expected %v", x, y, got.RGBAAt(x, y), want.RGBAAt(x, y))
+		}
+	}
+}
+
+type svgDocument struct {
+	Defs struct {
+		ClipPaths []struct {
+			ID       string `xml:"id,attr"`
+			ClipPath string `xml:"clip-path,attr"`
+			Path     struct {
+				D string `xml:"d,attr"`
+			} `xml:"path"`
+		} `xml:"clipPath"`
+		Gradients []struct {
+			ID        string  `xml:"id,attr"`
+			X1        float32 `xml:"x1,attr"`
+			Y1        float32 `xml:"y1,attr"`
+			X2        float32 `xml:"x2,attr"`
+			Y2        float32 `xml:"y2,attr"`
+			Transform string  `xml:"gradientTransform,attr"`
+			Stops     []struct {
+				Color   string  `xml:"stop-color,attr"`
+				Opacity float32 `xml:"stop-opacity,attr"`
+			} `xml:"stop"`
+		} `xml:"linearGradient"`
+		Images []struct {
+			ID   string `xml:"id,attr"`
+			X    int    `xml:"x,attr"`
+			Y    int    `xml:"y,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"image"`
+	} `xml:"defs"`
+	Groups []struct {
+		ClipPath string `xml:"clip-path,attr"`
+		Rect     *struct {
+			Fill    string `xml:"fill,attr"`
+			Opacity string `xml:"fill-opacity,attr"`
+		} `xml:"rect"`
+		Use *struct {
+			Href      string `xml:"href,attr"`
+			Transform string `xml:"transform,attr"`
+		} `xml:"use"`
+	} `xml:"g"`
+}
+
+// svgOps converts SVG written by SVG to operations.
+func svgOps(t *testing.T, data []byte, ops *op.Ops) {
+	var doc svgDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	ref := func(url string) string {
+		return strings.TrimSuffix(strings.TrimPrefix(url, "url(#"), ")")
+	}
+	clips := make(map[string]int)
+	for i, c := range doc.Defs.ClipPaths {
+		clips[c.ID] = i
+	}
+	for _, g := range doc.Groups {
+		st := op.Save(ops)
+		var chain []string
+		for id := ref(g.ClipPath); id != ""; id = ref(doc.Defs.ClipPaths[clips[id]].ClipPath) {
+			chain = append([]string{id}, chain...)
+		}
+		for _, id := range chain {
+			addPath(ops, parseSVGPath(t, doc.Defs.ClipPaths[clips[id]].Path.D))
+		}
+		switch {
+		case g.Rect != nil && strings.HasPrefix(g.Rect.Fill, "url("):
+			for _, gr := range doc.Defs.Gradients {
+				if gr.ID != ref(g.Rect.Fill) {
+					continue
+				}
+				first, last := gr.Stops[0], gr.Stops[len(gr.Stops)-1]
+				op.Affine(parseSVGMatrix(t, gr.Transform)).Add(ops)
+				paint.LinearGradientOp{
+					Stop1:  f32.Pt(gr.X1, gr.Y1),
+					Color1: parseSVGColor(t, first.Color, first.Opacity),
+					Stop2:  f32.Pt(gr.X2, gr.Y2),
+					Color2: parseSVGColor(t, last.Color, last.Opacity),
+				}.Add(ops)
+			}
+		case g.Rect != nil:
+			opacity := float32(1)
+			if g.Rect.Opacity != "" {
+				opacity = parseFloat(t, g.Rect.Opacity)
+			}
+			paint.ColorOp{Color: parseSVGColor(t, g.Rect.Fill, opacity)}.Add(ops)
+		case g.Use != nil:
+			for _, img := range doc.Defs.Images {
+				if "#"+img.ID != g.Use.Href {
+					continue
+				}
+				b64 := strings.TrimPrefix(img.Href, "data:image/png;base64,")
+				src, err := png.Decode(base64.NewDecoder(base64.StdEncoding, strings.NewReader(b64)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				op.Affine(parseSVGMatrix(t, g.Use.Transform)).Add(ops)
+				op.Offset(f32.Pt(float32(img.X), float32(img.Y))).Add(ops)
+				paint.NewImageOp(toRGBA(src)).Add(ops)
+			}
+		default:
+			t.Fatal("unknown SVG group")
+		}
+		paint.PaintOp{}.Add(ops)
+		st.Load()
+	}
+}
+
+// pathCmd is a path command with its points.
+type pathCmd struct {
+	op  byte
+	pts []f32.Point
+}
+
+func parseSVGPath(t *testing.T, d string) []pathCmd {
+	var cmds []pathCmd
+	for _, f := range strings.Fields(d) {
+		if c := f[0]; c >= 'A' && c <= 'Z' {
+			cmds = append(cmds, pathCmd{op: c})
+			continue
+		}
+		cmd := &cmds[len(cmds)-1]
+		if n := len(cmd.pts); n > 0 && math.IsNaN(float64(cmd.pts[n-1].Y)) {
+			cmd.pts[n-1].Y = parseFloat(t, f)
+		} else {
+			cmd.pts = append(cmd.pts, f32.Pt(parseFloat(t, f), float32(math.NaN())))
+		}
+	}
+	return cmds
+}
+
+func addPath(ops *op.Ops, cmds []pathCmd) {
+	var p clip.Path
+	p.Begin(ops)
+	for _, c := range cmds {
+		switch c.op {
+		case 'M':
+			p.Close()
+			p.MoveTo(c.pts[0])
+		case 'L':
+			p.LineTo(c.pts[0])
+		case 'Q':
+			p.QuadTo(c.pts[0], c.pts[1])
+		case 'C':
+			p.CubeTo(c.pts[0], c.pts[1], c.pts[2])
+		}
+	}
+	p.Close()
+	clip.Outline{Path: p.End()}.Op().Add(ops)
+}
+
+func parseSVGMatrix(t *testing.T, m string) f32.Affine2D {
+	v := strings.Fields(strings.TrimSuffix(strings.TrimPrefix(m, "matrix("), ")"))
+	if len(v) != 6 {
+		t.Fatalf("invalid matrix %q", m)
+	}
+	return f32.NewAffine2D(parseFloat(t, v[0]), parseFloat(t, v[2]), parseFloat(t, v[4]),
+		parseFloat(t, v[1]), parseFloat(t, v[3]), parseFloat(t, v[5]))
+}
+
+func parseSVGColor(t *testing.T, c string, opacity float32) color.NRGBA {
+	v, err := strconv.ParseUint(strings.TrimPrefix(c, "#"), 16, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: uint8(opacity*0xff + .5)}
+}
+
+// pdfOps converts a PDF document written by PDF to operations.
+func pdfOps(t *testing.T, data []byte, ops *op.Ops) {
+	objs := pdfObjects(t, data)
+	page := objs[pdfPage]
+	resource := func(name string) int {
+		m := regexp.MustCompile(`/` + name + ` (\d+) 0 R`).FindStringSubmatch(page)
+		if m == nil {
+			t.Fatalf("missing resource %s", name)
+		}
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	var (
+		args  []string
+		ctm   f32.Affine2D
+		stack []f32.Affine2D
+		saves []op.StateOp
+		path  []pathCmd
+		pen   f32.Point
+		col   = color.NRGBA{A: 0xff}
+		alpha = uint8(0xff)
+	)
+	// Map page coordinates to operation coordinates.
+	ctm = f32.NewAffine2D(1, 0, 0, 0, -1, float32(exportSize.Y))
+	num := func(i int) float32 {
+		return parseFloat(t, args[len(args)-i])
+	}
+	pt := func(i int) f32.Point {
+		return ctm.Transform(f32.Pt(num(i), num(i-1)))
+	}
+	for _, tok := range strings.Fields(pdfStream(t, objs[pdfContent])) {
+		switch tok {
+		case "q":
+			stack = append(stack, ctm)
+			saves = append(saves, op.Save(ops))
+		case "Q":
+			ctm = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			saves[len(saves)-1].Load()
+			saves = saves[:len(saves)-1]
+			alpha = 0xff
+		case "cm":
+			ctm = ctm.Mul(f32.NewAffine2D(num(6), num(4), num(2), num(5), num(3), num(1)))
+		case "m":
+			pen = pt(2)
+			path = append(path, pathCmd{op: 'M', pts: []f32.Point{pen}})
+		case "l":
+			pen = pt(2)
+			path = append(path, pathCmd{op: 'L', pts: []f32.Point{pen}})
+		case "c":
+			path = append(path, pathCmd{op: 'C', pts: []f32.Point{pt(6), pt(4), pt(2)}})
+		case "W":
+			addPath(ops, path)
+			path = nil
+		case "gs":
+			name := strings.TrimPrefix(args[len(args)-1], "/")
+			m := regexp.MustCompile(`/` + name + ` << /ca ([\d.]+) >>`).FindStringSubmatch(page)
+			if m == nil {
+				t.Fatalf("unsupported graphics state %s", name)
+			}
+			alpha = uint8(parseFloat(t, m[1])*0xff + .5)
+		case "rg":
+			col = color.NRGBA{R: uint8(num(3)*0xff + .5), G: uint8(num(2)*0xff + .5), B: uint8(num(1)*0xff + .5)}
+		case "f":
+			col.A = alpha
+			paint.ColorOp{Color: col}.Add(ops)
+			paint.PaintOp{}.Add(ops)
+		case "sh":
+			sh := objs[resource(strings.TrimPrefix(args[len(args)-1], "/"))]
+			coords := strings.Fields(regexp.MustCompile(`/Coords \[([^\]]*)\]`).FindStringSubmatch(sh)[1])
+			fn, _ := strconv.Atoi(regexp.MustCompile(`/Function (\d+) 0 R`).FindStringSubmatch(sh)[1])
+			samples := pdfStream(t, objs[fn])
+			first, last := samples[:3], samples[len(samples)-3:]
+			op.Affine(ctm).Add(ops)
+			paint.LinearGradientOp{
+				Stop1:  f32.Pt(parseFloat(t, coords[0]), parseFloat(t, coords[1])),
+				Color1: color.NRGBA{R: first[0], G: first[1], B: first[2], A: alpha},
+				Stop2:  f32.Pt(parseFloat(t, coords[2]), parseFloat(t, coords[3])),
+				Color2: color.NRGBA{R: last[0], G: last[1], B: last[2], A: alpha},
+			}.Add(ops)
+			paint.PaintOp{}.Add(ops)
+		case "Do":
+			im := objs[resource(strings.TrimPrefix(args[len(args)-1], "/"))]
+			w, _ := strconv.Atoi(regexp.MustCompile(`/Width (\d+)`).FindStringSubmatch(im)[1])
+			h, _ := strconv.Atoi(regexp.MustCompile(`/Height (\d+)`).FindStringSubmatch(im)[1])
+			rgb := pdfStream(t, im)
+			img := image.NewNRGBA(image.Rect(0, 0, w, h))
+			for i := 0; i < w*h; i++ {
+				copy(img.Pix[i*4:], rgb[i*3:i*3+3])
+				img.Pix[i*4+3] = 0xff
+			}
+			// Map image pixels to the unit square.
+			unit := f32.NewAffine2D(1/float32(w), 0, 0, 0, -1/float32(h), 1)
+			op.Affine(ctm.Mul(unit)).Add(ops)
+			paint.NewImageOp(toRGBA(img)).Add(ops)
+			paint.PaintOp{}.Add(ops)
+		default:
+			args = append(args, tok)
+			continue
+		}
+		args = args[:0]
+	}
+}
+
+// pdfObjects returns the objects of a PDF document by number, after
+// verifying the cross-reference table.
+func pdfObjects(t *testing.T, data []byte) map[int]string {
+	doc := string(data)
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindStringSubmatch(doc)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(m[1])
+	lines := strings.Split(doc[xref:], "\n")
+	if lines[0] != "xref" {
+		t.Fatalf("startxref points to %q", lines[0])
+	}
+	objs := make(map[int]string)
+	for i, l := range lines[3:] {
+		if strings.HasPrefix(l, "trailer") {
+			break
+		}
+		off, _ := strconv.Atoi(l[:10])
+		hdr := strconv.Itoa(i+1) + " 0 obj\n"
+		if !strings.HasPrefix(doc[off:], hdr) {
+			t.Fatalf("object %d not at offset %d", i+1, off)
+		}
+		body := doc[off+len(hdr):]
+		objs[i+1] = body[:strings.Index(body, "\nendobj\n")]
+	}
+	return objs
+}
+
+// pdfStream returns the decompressed data of a stream object.
+func pdfStream(t *testing.T, obj string) string {
+	start := strings.Index(obj, "stream\n") + len("stream\n")
+	end := strings.LastIndex(obj, "\nendstream")
+	zr, err := zlib.NewReader(strings.NewReader(obj[start:end]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// toRGBA converts an image to the sRGB encoded premultiplied linear
+// format of image operations.
+func toRGBA(src image.Image) *image.RGBA {
+	dst := image.NewRGBA(src.Bounds())
+	for y := 0; y < src.Bounds().Dy(); y++ {
+		for x := 0; x < src.Bounds().Dx(); x++ {
+			c := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+			dst.SetRGBA(x, y, f32color.NRGBAToRGBA(c))
+		}
+	}
+	return dst
+}
+
+func parseFloat(t *testing.T, s string) float32 {
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return float32(v)
+}
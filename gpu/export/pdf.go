@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/op"
+)
+
+// pdfGradientSamples is the number of samples of the functions
+// approximating the linear color space interpolation of gradients.
+const pdfGradientSamples = 256
+
+// pdfWriter builds a single page PDF document.
+type pdfWriter struct {
+	size image.Point
+	// objects are the indirect objects, numbered from 1.
+	objects [][]byte
+	content bytes.Buffer
+	// Page resources, as dictionary entries.
+	gstates  bytes.Buffer
+	shadings bytes.Buffer
+	xobjects bytes.Buffer
+	alphas   map[uint8]int
+	images   map[*image.RGBA]int
+	nshading int
+	ngstate  int
+}
+
+// Numbers of the fixed objects.
+const (
+	pdfCatalog = 1 + iota
+	pdfPages
+	pdfPage
+	pdfContent
+)
+
+// PDF writes the operations in ops as a single page PDF document of
+// size points.
+func PDF(w io.Writer, ops *op.Ops, size image.Point) error {
+	d := collect(ops, size)
+	p := &pdfWriter{
+		size:    size,
+		objects: make([][]byte, pdfContent),
+		alphas:  make(map[uint8]int),
+		images:  make(map[*image.RGBA]int),
+	}
+	// Flip the y axis to match the operation coordinates.
+	fmt.Fprintf(&p.content, "1 0 0 -1 0 %d cm\n", size.Y)
+	for _, f := range d.fills {
+		p.fill(f)
+	}
+	p.objects[pdfCatalog-1] = []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPages))
+	p.objects[pdfPages-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%d 0 R] /Count 1 >>", pdfPage))
+	p.objects[pdfPage-1] = []byte(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R "+
+		"/Resources << /ExtGState << %s>> /Shading << %s>> /XObject << %s>> >> "+
+		"/Group << /S /Transparency /CS /DeviceRGB >> >>",
+		pdfPages, size.X, size.Y, pdfContent, p.gstates.Bytes(), p.shadings.Bytes(), p.xobjects.Bytes()))
+	p.objects[pdfContent-1] = streamObject("", p.content.Bytes())
+	return p.write(w)
+}
+
+// fill draws a paint operation.
+func (p *pdfWriter) fill(f fill) {
+	c := &p.content
+	c.WriteString("q\n")
+	if f.clip != nil {
+		for _, a := range f.clip.chain() {
+			writePDFPath(c, a.path)
+			c.WriteString("W n\n")
+		}
+	}
+	switch f.material {
+	case materialColor:
+		if f.color.A != 0xff {
+			fmt.Fprintf(c, "/GS%d gs\n", p.alpha(f.color.A))
+		}
+		fmt.Fprintf(c, "%s %s %s rg\n", pdfComponent(f.color.R), pdfComponent(f.color.G), pdfComponent(f.color.B))
+		fmt.Fprintf(c, "0 0 %d %d re f\n", p.size.X, p.size.Y)
+	case materialLinearGradient:
+		cols := gradientColors(f, pdfGradientSamples)
+		rgb := make([]byte, 0, 3*len(cols))
+		alpha := make([]byte, 0, len(cols))
+		opaque := true
+		for _, col := range cols {
+			rgb = append(rgb, col.R, col.G, col.B)
+			alpha = append(alpha, col.A)
+			opaque = opaque && col.A == 0xff
+		}
+		sh := p.nshading
+		p.nshading++
+		fmt.Fprintf(&p.shadings, "/Sh%d %d 0 R ", sh, p.shading(f, "/DeviceRGB", rgb))
+		switch {
+		case f.color1.A == f.color2.A && !opaque:
+			fmt.Fprintf(c, "/GS%d gs\n", p.alpha(f.color1.A))
+		case !opaque:
+			// Varying alpha is applied through a soft mask
+			// painted by a gray shading.
+			ash := p.shading(f, "/DeviceGray", alpha)
+			form := p.stream(fmt.Sprintf("/Type /XObject /Subtype /Form /BBox [0 0 %d %d] "+
+				"/Group << /S /Transparency /CS /DeviceGray >> /Resources << /Shading << /Sh %d 0 R >> >>",
+				p.size.X, p.size.Y, ash), []byte(fmt.Sprintf("%s cm /Sh sh", pdfMatrix(f.t))))
+			fmt.Fprintf(&p.gstates, "/GS%d << /SMask << /Type /Mask /S /Luminosity /G %d 0 R >> >> ", p.ngstate, form)
+			fmt.Fprintf(c, "/GS%d gs\n", p.ngstate)
+			p.ngstate++
+		}
+		fmt.Fprintf(c, "%s cm /Sh%d sh\n", pdfMatrix(f.t), sh)
+	case materialTexture:
+		id, exists := p.images[f.image]
+		if !exists {
+			id = len(p.images)
+			p.images[f.image] = id
+			obj := p.image(f.image)
+			fmt.Fprintf(&p.xobjects, "/Im%d %d 0 R ", id, obj)
+		}
+		// Images are drawn in the unit square with their first
+		// row at the top.
+		r := f.image.Rect
+		fmt.Fprintf(c, "%s cm %d 0 0 %d %d %d cm /Im%d Do\n", pdfMatrix(f.t), r.Dx(), -r.Dy(), r.Min.X, r.Max.Y, id)
+	}
+	c.WriteString("Q\n")
+}
+
+// alpha returns the number of the graphics state for a constant
+// alpha.
+func (p *pdfWriter) alpha(a uint8) int {
+	if n, exists := p.alphas[a]; exists {
+		return n
+	}
+	n := p.ngstate
+	p.ngstate++
+	p.alphas[a] = n
+	fmt.Fprintf(&p.gstates, "/GS%d << /ca %s >> ", n, pdfComponent(a))
+	return n
+}
+
+// shading adds an axial shading of a linear gradient with samples in
+// the color space cs, and returns its object number.
+func (p *pdfWriter) shading(f fill, cs string, samples []byte) int {
+	ncomp := len(samples) / pdfGradientSamples
+	var rng bytes.Buffer
+	for i := 0; i < ncomp; i++ {
+		rng.WriteString(" 0 1")
+	}
+	fn := p.stream(fmt.Sprintf("/FunctionType 0 /Domain [0 1] /Range [%s ] /Size [%d] /BitsPerSample 8",
+		rng.Bytes(), pdfGradientSamples), samples)
+	return p.add([]byte(fmt.Sprintf("<< /ShadingType 2 /ColorSpace %s /Coords [%s %s %s %s] /Function %d 0 R /Extend [true true] >>",
+		cs, formatFloat(f.stop1.X), formatFloat(f.stop1.Y), formatFloat(f.stop2.X), formatFloat(f.stop2.Y), fn)))
+}
+
+// image adds an image XObject and returns its object number.
+func (p *pdfWriter) image(img *image.RGBA) int {
+	src := toNRGBA(img)
+	sz := src.Bounds().Size()
+	rgb := make([]byte, 0, 3*sz.X*sz.Y)
+	alpha := make([]byte, 0, sz.X*sz.Y)
+	opaque := true
+	for i := 0; i < len(src.Pix); i += 4 {
+		rgb = append(rgb, src.Pix[i:i+3]...)
+		alpha = append(alpha, src.Pix[i+3])
+		opaque = opaque && src.Pix[i+3] == 0xff
+	}
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /BitsPerComponent 8 /Interpolate true", sz.X, sz.Y)
+	smask := ""
+	if !opaque {
+		smask = fmt.Sprintf(" /SMask %d 0 R", p.stream(dict+" /ColorSpace /DeviceGray", alpha))
+	}
+	return p.stream(dict+" /ColorSpace /DeviceRGB"+smask, rgb)
+}
+
+// add adds an indirect object and returns its number.
+func (p *pdfWriter) add(obj []byte) int {
+	p.objects = append(p.objects, obj)
+	return len(p.objects)
+}
+
+// stream adds a compressed stream object with the dictionary entries
+// in dict and returns its number.
+func (p *pdfWriter) stream(dict string, data []byte) int {
+	return p.add(streamObject(dict, data))
+}
+
+// streamObject returns a compressed stream object.
+func streamObject(dict string, data []byte) []byte {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(data)
+	zw.Close()
+	var obj bytes.Buffer
+	fmt.Fprintf(&obj, "<< %s /Filter /FlateDecode /Length %d >>\nstream\n", dict, z.Len())
+	obj.Write(z.Bytes())
+	obj.WriteString("\nendstream")
+	return obj.Bytes()
+}
+
+// write writes the document with its cross-reference table.
+func (p *pdfWriter) write(w io.Writer) error {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(p.objects))
+	for i, obj := range p.objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n", i+1)
+		b.Write(obj)
+		b.WriteString("\nendobj\n")
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(p.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.objects)+1, pdfCatalog, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func writePDFPath(b *bytes.Buffer, path []segment) {
+	var pen f32.Point
+	for i := range path {
+		s := &path[i]
+		switch s.op {
+		case segmentMove:
+			fmt.Fprintf(b, "%s m\n", pdfPoint(s.args[0]))
+		case segmentLine:
+			fmt.Fprintf(b, "%s l\n", pdfPoint(s.args[0]))
+		case segmentQuad:
+			// Convert to the equivalent cubic Bézier.
+			ctrl, to := s.args[0], s.args[1]
+			c0 := pen.Add(ctrl.Sub(pen).Mul(2.0 / 3))
+			c1 := to.Add(ctrl.Sub(to).Mul(2.0 / 3))
+			fmt.Fprintf(b, "%s %s %s c\n", pdfPoint(c0), pdfPoint(c1), pdfPoint(to))
+		case segmentCubic:
+			fmt.Fprintf(b, "%s %s %s c\n", pdfPoint(s.args[0]), pdfPoint(s.args[1]), pdfPoint(s.args[2]))
+		}
+		pts := s.points()
+		pen = pts[len(pts)-1]
+	}
+}
+
+func pdfPoint(p f32.Point) string {
+	return formatFloat(p.X) + " " + formatFloat(p.Y)
+}
+
+func pdfMatrix(t f32.Affine2D) string {
+	sx, hx, ox, hy, sy, oy := t.Elems()
+	return fmt.Sprintf("%s %s %s %s %s %s",
+		formatFloat(sx), formatFloat(hy), formatFloat(hx), formatFloat(sy), formatFloat(ox), formatFloat(oy))
+}
+
+// pdfComponent formats a color component in the range [0, 1].
+func pdfComponent(c uint8) string {
+	return formatFloat(float32(c) / 0xff)
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/op"
+)
+
+// svgGradientStops is the number of stops used for approximating
+// the linear color space interpolation of gradients.
+const svgGradientStops = 17
+
+// SVG writes the operations in ops as an SVG image of size pixels.
+func SVG(w io.Writer, ops *op.Ops, size image.Point) error {
+	d := collect(ops, size)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d">
+<defs>
+`, size.X, size.Y, size.X, size.Y)
+	for _, c := range d.clips {
+		if c.empty {
+			continue
+		}
+		fmt.Fprintf(&b, `<clipPath id="c%d" clipPathUnits="userSpaceOnUse"`, c.id)
+		if c.parent != nil {
+			fmt.Fprintf(&b, ` clip-path="url(#c%d)"`, c.parent.id)
+		}
+		b.WriteString(`><path d="`)
+		writeSVGPath(&b, c.path)
+		b.WriteString("\"/></clipPath>\n")
+	}
+	images := make(map[*image.RGBA]int)
+	for i, f := range d.fills {
+		switch f.material {
+		case materialLinearGradient:
+			fmt.Fprintf(&b, `<linearGradient id="g%d" gradientUnits="userSpaceOnUse" x1="%s" y1="%s" x2="%s" y2="%s" gradientTransform="%s">`,
+				i, formatFloat(f.stop1.X), formatFloat(f.stop1.Y), formatFloat(f.stop2.X), formatFloat(f.stop2.Y), svgMatrix(f.t))
+			for j, c := range gradientColors(f, svgGradientStops) {
+				fmt.Fprintf(&b, `<stop offset="%s" stop-color="%s" stop-opacity="%s"/>`,
+					formatFloat(float32(j)/(svgGradientStops-1)), svgColor(c), svgOpacity(c))
+			}
+			b.WriteString("</linearGradient>\n")
+		case materialTexture:
+			if _, exists := images[f.image]; exists {
+				continue
+			}
+			id := len(images)
+			images[f.image] = id
+			r := f.image.Rect
+			fmt.Fprintf(&b, `<image id="i%d" x="%d" y="%d" width="%d" height="%d" xlink:href="data:image/png;base64,`,
+				id, r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+			enc := base64.NewEncoder(base64.StdEncoding, &b)
+			if err := png.Encode(enc, toNRGBA(f.image)); err != nil {
+				return err
+			}
+			enc.Close()
+			b.WriteString("\"/>\n")
+		}
+	}
+	b.WriteString("</defs>\n")
+	for i, f := range d.fills {
+		b.WriteString("<g")
+		if f.clip != nil {
+			fmt.Fprintf(&b, ` clip-path="url(#c%d)"`, f.clip.id)
+		}
+		b.WriteString(">")
+		switch f.material {
+		case materialColor:
+			fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"`, size.X, size.Y, svgColor(f.color))
+			if f.color.A != 0xff {
+				fmt.Fprintf(&b, ` fill-opacity="%s"`, svgOpacity(f.color))
+			}
+			b.WriteString("/>")
+		case materialLinearGradient:
+			fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="url(#g%d)"/>`, size.X, size.Y, i)
+		case materialTexture:
+			fmt.Fprintf(&b, `<use xlink:href="#i%d" transform="%s"/>`, images[f.image], svgMatrix(f.t))
+		}
+		b.WriteString("</g>\n")
+	}
+	b.WriteString("</svg>\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func writeSVGPath(b *bytes.Buffer, path []segment) {
+	cmds := [...]string{
+		segmentMove:  "M",
+		segmentLine:  "L",
+		segmentQuad:  "Q",
+		segmentCubic: "C",
+	}
+	for i := range path {
+		s := &path[i]
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(cmds[s.op])
+		for _, p := range s.points() {
+			fmt.Fprintf(b, " %s %s", formatFloat(p.X), formatFloat(p.Y))
+		}
+	}
+}
+
+func svgMatrix(t f32.Affine2D) string {
+	sx, hx, ox, hy, sy, oy := t.Elems()
+	return fmt.Sprintf("matrix(%s %s %s %s %s %s)",
+		formatFloat(sx), formatFloat(hy), formatFloat(hx), formatFloat(sy), formatFloat(ox), formatFloat(oy))
+}
+
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func svgOpacity(c color.NRGBA) string {
+	return formatFloat(float32(c.A) / 0xff)
+}
@@ -48,6 +48,16 @@ type GPU interface {
 	Collect(viewport image.Point, frame *op.Ops)
 	// Frame draws the collected operations to target.
 	Frame(target RenderTarget) error
+	// SetBufferAge sets the age in frames of the contents of the target
+	// for the next Frame. An age of 1 means the target contains the
+	// previous frame, 2 the frame before that and so on. Frame draws
+	// only the areas that changed since then. An age of 0, the
+	// default, means the contents are undefined and the whole target
+	// is drawn.
+	SetBufferAge(age int)
+	// Damage returns the area of the last Frame that differs from
+	// the frame before it.
+	Damage() image.Rectangle
 	// Profile returns the last available profiling information. Profiling
 	// information is requested when Collect sees an io/profile.Op, and the result
 	// is available through Profile at some later time.
@@ -64,6 +74,11 @@ type gpu struct {
 	drawOps                                drawOps
 	ctx                                    driver.Device
 	renderer                               *renderer
+	damage                                 damageTracker
+	bufferAge                              int
+	// preserved tracks whether the contents of the target were kept
+	// after the previous frame.
+	preserved bool
 }
 
 type renderer struct {
@@ -76,6 +91,7 @@ type renderer struct {
 
 type drawOps struct {
 	profile    bool
+	ctx        driver.Device
 	reader     ops.Reader
 	states     []drawState
 	cache      *resourceCache
@@ -91,6 +107,10 @@ type drawOps struct {
 	pathOpCache []pathOp
 	qs          quadSplitter
 	pathCache   *opCache
+	// cacheOps are the operations of the cached calls in
+	// cacheCalls, to be rendered to their textures.
+	cacheOps   []imageOp
+	cacheCalls []cacheCall
 }
 
 type drawState struct {
@@ -110,6 +130,9 @@ type drawState struct {
 	stop2  f32.Point
 	color1 color.NRGBA
 	color2 color.NRGBA
+
+	// cached is set for the operations of a cached call.
+	cached bool
 }
 
 type pathOp struct {
@@ -123,6 +146,12 @@ type pathOp struct {
 	pathVerts []byte
 	parent    *pathOp
 	place     placement
+	// mask is the texture of a paint.MaskOp that covers the clip
+	// instead of the path. The clip area is at place.Pos in the
+	// texture.
+	mask *maskTexture
+	// hash identifies the path contents across frames.
+	hash uint64
 }
 
 type imageOp struct {
@@ -137,15 +166,41 @@ type imageOp struct {
 // this package. Shader programs are not backwards or forwards compatible.
 const shaderModuleVersion = "v0.0.0-20210816161847-c12352edbd45"
 
-func decodeStrokeOp(data []byte) clip.StrokeStyle {
-	_ = data[4]
-	if opconst.OpType(data[0]) != opconst.TypeStroke {
-		panic("invalid op")
-	}
-	bo := binary.LittleEndian
-	return clip.StrokeStyle{
-		Width: math.Float32frombits(bo.Uint32(data[1:])),
-	}
+// cacheKey identifies the rendering of a call marked by op.CacheOp.
+type cacheKey struct {
+	ops.Key
+	// t is the transform of the call without its whole pixel
+	// offset, so renderings are reused for calls that differ only by
+	// whole pixel translations.
+	t        f32.Affine2D
+	viewport image.Point
+}
+
+// cacheFracs is the number of sub-pixel offsets that tell apart the
+// renderings of a call.
+const cacheFracs = 64
+
+// cacheCall is a cached call to render to a texture.
+type cacheCall struct {
+	key cacheKey
+	// bounds is the area covered by the operations.
+	bounds image.Rectangle
+	// origin is the whole pixel offset of the rendering.
+	origin image.Point
+	// start and end delimit the operations in drawOps.cacheOps.
+	start, end int
+	// tex and fbo are the texture to render to, or nil if
+	// bounds is empty.
+	tex driver.Texture
+	fbo driver.Framebuffer
+}
+
+// cachedCall is the rendering of a call marked by op.CacheOp.
+type cachedCall struct {
+	// tex is nil if the call draws nothing.
+	tex    driver.Texture
+	bounds image.Rectangle
+	origin image.Point
 }
 
 type quadsOp struct {
@@ -171,100 +226,15 @@ type material struct {
 	uvTrans f32.Affine2D
 }
 
-// clipOp is the shadow of clip.Op.
-type clipOp struct {
-	// TODO: Use image.Rectangle?
-	bounds  f32.Rectangle
-	outline bool
-}
-
 // imageOpData is the shadow of paint.ImageOp.
 type imageOpData struct {
 	src    *image.RGBA
 	handle interface{}
 }
 
-type linearGradientOpData struct {
-	stop1  f32.Point
-	color1 color.NRGBA
-	stop2  f32.Point
-	color2 color.NRGBA
-}
-
-func (op *clipOp) decode(data []byte) {
-	if opconst.OpType(data[0]) != opconst.TypeClip {
-		panic("invalid op")
-	}
-	bo := binary.LittleEndian
-	r := image.Rectangle{
-		Min: image.Point{
-			X: int(int32(bo.Uint32(data[1:]))),
-			Y: int(int32(bo.Uint32(data[5:]))),
-		},
-		Max: image.Point{
-			X: int(int32(bo.Uint32(data[9:]))),
-			Y: int(int32(bo.Uint32(data[13:]))),
-		},
-	}
-	*op = clipOp{
-		bounds:  layout.FRect(r),
-		outline: data[17] == 1,
-	}
-}
-
 func decodeImageOp(data []byte, refs []interface{}) imageOpData {
-	if opconst.OpType(data[0]) != opconst.TypeImage {
-		panic("invalid op")
-	}
-	handle := refs[1]
-	if handle == nil {
-		return imageOpData{}
-	}
-	return imageOpData{
-		src:    refs[0].(*image.RGBA),
-		handle: handle,
-	}
-}
-
-func decodeColorOp(data []byte) color.NRGBA {
-	if opconst.OpType(data[0]) != opconst.TypeColor {
-		panic("invalid op")
-	}
-	return color.NRGBA{
-		R: data[1],
-		G: data[2],
-		B: data[3],
-		A: data[4],
-	}
-}
-
-func decodeLinearGradientOp(data []byte) linearGradientOpData {
-	if opconst.OpType(data[0]) != opconst.TypeLinearGradient {
-		panic("invalid op")
-	}
-	bo := binary.LittleEndian
-	return linearGradientOpData{
-		stop1: f32.Point{
-			X: math.Float32frombits(bo.Uint32(data[1:])),
-			Y: math.Float32frombits(bo.Uint32(data[5:])),
-		},
-		stop2: f32.Point{
-			X: math.Float32frombits(bo.Uint32(data[9:])),
-			Y: math.Float32frombits(bo.Uint32(data[13:])),
-		},
-		color1: color.NRGBA{
-			R: data[17+0],
-			G: data[17+1],
-			B: data[17+2],
-			A: data[17+3],
-		},
-		color2: color.NRGBA{
-			R: data[21+0],
-			G: data[21+1],
-			B: data[21+2],
-			A: data[21+3],
-		},
-	}
+	src, handle := ops.DecodeImage(data, refs)
+	return imageOpData{src: src, handle: handle}
 }
 
 type clipType uint8
@@ -349,6 +319,7 @@ const (
 	clipTypeNone clipType = iota
 	clipTypePath
 	clipTypeIntersection
+	clipTypeMask
 )
 
 const (
@@ -365,7 +336,7 @@ func New(api API) (GPU, error) {
 	if err != nil {
 		return nil, err
 	}
-	d.BeginFrame(nil, false, image.Point{})
+	d.BeginFrame(nil, false, false, image.Point{})
 	defer d.EndFrame()
 	forceCompute := os.Getenv("GIORENDERER") == "forcecompute"
 	feats := d.Caps().Features
@@ -441,29 +412,57 @@ func (g *gpu) Collect(viewport image.Point, frameOps *op.Ops) {
 
 func (g *gpu) Frame(target RenderTarget) error {
 	viewport := g.renderer.blitter.viewport
-	defFBO := g.ctx.BeginFrame(target, g.drawOps.clear, viewport)
+	g.damage.frame(viewport, g.drawOps.clear, g.drawOps.clearColor, g.drawOps.imageOps)
+	age := g.bufferAge
+	if !g.preserved {
+		age = 0
+	}
+	area := g.damage.area(age)
+	// Keep the contents of the target while the window reports buffer
+	// ages, so the next frame may be partial.
+	g.preserved = g.bufferAge > 0
+	g.bufferAge = 0
+	partial := area != image.Rectangle{Max: viewport}
+	if partial {
+		g.drawOps.cull(area)
+	}
+	defFBO := g.ctx.BeginFrame(target, g.drawOps.clear, g.preserved, viewport)
 	defer g.ctx.EndFrame()
-	for _, img := range g.drawOps.imageOps {
+	// Process the operations of cached calls along with the frame
+	// operations.
+	ncache := len(g.drawOps.cacheOps)
+	g.drawOps.cacheOps = append(g.drawOps.cacheOps, g.drawOps.imageOps...)
+	imageOps := g.drawOps.cacheOps
+	for _, img := range imageOps {
 		expandPathOp(img.path, img.clip)
 	}
 	g.ctx.BindFramebuffer(defFBO)
 	if g.drawOps.clear {
 		g.drawOps.clear = false
-		g.ctx.Clear(g.drawOps.clearColor.Float32())
+		if !partial {
+			g.ctx.Clear(g.drawOps.clearColor.Float32())
+		}
 	}
 	g.ctx.Viewport(0, 0, viewport.X, viewport.Y)
 	g.stencilTimer.begin()
 	g.ctx.SetBlend(true)
 	g.renderer.packStencils(&g.drawOps.pathOps)
 	g.renderer.stencilClips(g.drawOps.pathCache, g.drawOps.pathOps)
-	g.renderer.packIntersections(g.drawOps.imageOps)
-	g.renderer.intersect(g.drawOps.imageOps)
+	g.renderer.packIntersections(imageOps)
+	g.renderer.intersect(imageOps)
 	g.stencilTimer.end()
 	g.coverTimer.begin()
+	g.renderer.drawCached(g.cache, g.drawOps.cacheCalls, imageOps)
 	g.ctx.BindFramebuffer(defFBO)
 	g.ctx.Viewport(0, 0, viewport.X, viewport.Y)
-	g.renderer.drawOps(g.cache, g.drawOps.imageOps)
+	if partial {
+		// The target contents outside area are up to date.
+		g.renderer.scissor(area)
+		g.renderer.clearRect(area, g.drawOps.clearColor)
+	}
+	g.renderer.drawOps(g.cache, imageOps[ncache:])
 	g.ctx.SetBlend(false)
+	g.ctx.SetScissor(false)
 	g.renderer.pather.stenciler.invalidateFBO()
 	g.coverTimer.end()
 	g.ctx.BindFramebuffer(defFBO)
@@ -483,6 +482,14 @@ func (g *gpu) Frame(target RenderTarget) error {
 	return nil
 }
 
+func (g *gpu) SetBufferAge(age int) {
+	g.bufferAge = age
+}
+
+func (g *gpu) Damage() image.Rectangle {
+	return g.damage.history[0]
+}
+
 func (g *gpu) Profile() string {
 	return g.profile
 }
@@ -490,6 +497,9 @@ func (g *gpu) Profile() string {
 func (r *renderer) texHandle(cache *resourceCache, data imageOpData) driver.Texture {
 	var tex *texture
 	t, exists := cache.get(data.handle)
+	if c, ok := t.(*cachedCall); ok {
+		return c.tex
+	}
 	if !exists {
 		t = &texture{
 			src: data.src,
@@ -515,6 +525,12 @@ func (t *texture) release() {
 	}
 }
 
+func (c *cachedCall) release() {
+	if c.tex != nil {
+		c.tex.Release()
+	}
+}
+
 func newRenderer(ctx driver.Device) *renderer {
 	r := &renderer{
 		ctx:     ctx,
@@ -662,6 +678,63 @@ func (r *renderer) stencilClips(pathCache *opCache, ops []*pathOp) {
 	}
 }
 
+// drawCached renders cached calls to their textures and adds the
+// textures to cache.
+func (r *renderer) drawCached(cache *resourceCache, calls []cacheCall, ops []imageOp) {
+	if len(calls) == 0 {
+		return
+	}
+	viewport := r.blitter.viewport
+	for i := range calls {
+		c := &calls[i]
+		cache.put(c.key, &cachedCall{tex: c.tex, bounds: c.bounds, origin: c.origin})
+		fbo := c.fbo
+		// The cache owns the texture.
+		c.tex, c.fbo = nil, nil
+		if fbo == nil {
+			continue
+		}
+		sz := c.bounds.Size()
+		r.ctx.BindFramebuffer(fbo)
+		r.ctx.Viewport(0, 0, sz.X, sz.Y)
+		r.ctx.Clear(0, 0, 0, 0)
+		// Draw relative to the texture.
+		cops := ops[c.start:c.end]
+		for i := range cops {
+			cops[i].clip = cops[i].clip.Sub(c.bounds.Min)
+		}
+		r.blitter.viewport = sz
+		r.drawOps(cache, cops)
+		fbo.Release()
+	}
+	r.blitter.viewport = viewport
+}
+
+// scissor restricts drawing to the area rect of the viewport.
+func (r *renderer) scissor(rect image.Rectangle) {
+	y := rect.Min.Y
+	if r.ctx.Caps().BottomLeftOrigin {
+		y = r.blitter.viewport.Y - rect.Max.Y
+	}
+	r.ctx.Scissor(rect.Min.X, y, rect.Dx(), rect.Dy())
+	r.ctx.SetScissor(true)
+}
+
+// clearRect replaces the contents of rect with col. Unlike
+// Device.Clear, it is restricted by the scissor rectangle on every
+// backend.
+func (r *renderer) clearRect(rect image.Rectangle, col f32color.RGBA) {
+	if rect.Empty() {
+		return
+	}
+	r.ctx.SetBlend(false)
+	r.ctx.BindVertexBuffer(r.blitter.quadVerts, 4*4, 0)
+	r.ctx.BindInputLayout(r.blitter.layout)
+	scale, off := clipSpaceTransform(rect, r.blitter.viewport)
+	r.blitter.blit(materialColor, col, f32color.RGBA{}, f32color.RGBA{}, scale, off, f32.Affine2D{})
+	r.ctx.SetBlend(true)
+}
+
 func (r *renderer) intersect(ops []imageOp) {
 	if len(r.intersections.sizes) == 0 {
 		return
@@ -689,7 +762,7 @@ func (r *renderer) intersectPath(p *pathOp, clip image.Rectangle) {
 	if p.parent != nil {
 		r.intersectPath(p.parent, clip)
 	}
-	if !p.path {
+	if !p.path && p.mask == nil {
 		return
 	}
 	uv := image.Rectangle{
@@ -701,10 +774,21 @@ func (r *renderer) intersectPath(p *pathOp, clip image.Rectangle) {
 		Min: o,
 		Max: o.Add(clip.Size()),
 	}
-	fbo := r.pather.stenciler.cover(p.place.Idx)
-	r.ctx.BindTexture(0, fbo.tex)
-	coverScale, coverOff := texSpaceTransform(layout.FRect(uv), fbo.size)
+	var tex driver.Texture
+	var size image.Point
+	if p.mask != nil {
+		tex, size = p.mask.tex, p.mask.size
+	} else {
+		fbo := r.pather.stenciler.cover(p.place.Idx)
+		tex, size = fbo.tex, fbo.size
+	}
+	r.ctx.BindTexture(0, tex)
+	coverScale, coverOff := texSpaceTransform(layout.FRect(uv), size)
 	subScale, subOff := texSpaceTransform(layout.FRect(sub), p.clip.Size())
+	if p.mask != nil && !r.ctx.Caps().BottomLeftOrigin {
+		// Undo the flip of framebuffer textures in the shader.
+		subOff.Y = 1 - subScale.Y - subOff.Y
+	}
 	r.pather.stenciler.iprog.uniforms.vert.uvTransform = [4]float32{coverScale.X, coverScale.Y, coverOff.X, coverOff.Y}
 	r.pather.stenciler.iprog.uniforms.vert.subUVTransform = [4]float32{subScale.X, subScale.Y, subOff.X, subOff.Y}
 	r.pather.stenciler.iprog.prog.UploadUniforms()
@@ -717,7 +801,7 @@ func (r *renderer) packIntersections(ops []imageOp) {
 		var npaths int
 		var onePath *pathOp
 		for p := img.path; p != nil; p = p.parent {
-			if p.path {
+			if p.path || p.mask != nil {
 				onePath = p
 				npaths++
 			}
@@ -729,6 +813,9 @@ func (r *renderer) packIntersections(ops []imageOp) {
 			place.Pos = place.Pos.Sub(onePath.clip.Min).Add(img.clip.Min)
 			ops[i].place = place
 			ops[i].clipType = clipTypePath
+			if onePath.mask != nil {
+				ops[i].clipType = clipTypeMask
+			}
 		default:
 			sz := image.Point{X: img.clip.Dx(), Y: img.clip.Dy()}
 			place, ok := r.intersections.add(sz)
@@ -796,9 +883,19 @@ func (d *drawOps) reset(cache *resourceCache, viewport image.Point) {
 	d.pathOps = d.pathOps[:0]
 	d.pathOpCache = d.pathOpCache[:0]
 	d.vertCache = d.vertCache[:0]
+	d.cacheOps = d.cacheOps[:0]
+	// Release the textures of cached calls not drawn by a Frame.
+	for _, c := range d.cacheCalls {
+		if c.fbo != nil {
+			c.fbo.Release()
+			c.tex.Release()
+		}
+	}
+	d.cacheCalls = d.cacheCalls[:0]
 }
 
 func (d *drawOps) collect(ctx driver.Device, cache *resourceCache, root *op.Ops, viewport image.Point) {
+	d.ctx = ctx
 	clip := f32.Rectangle{
 		Max: f32.Point{X: float32(viewport.X), Y: float32(viewport.Y)},
 	}
@@ -815,23 +912,41 @@ func (d *drawOps) collect(ctx driver.Device, cache *resourceCache, root *op.Ops,
 			d.pathCache.put(p.pathKey, opCacheValue{
 				data:   data,
 				bounds: p.bounds,
+				hash:   p.hash,
 			})
 		}
 		p.pathVerts = nil
 	}
 }
 
+// cull removes the operations that don't overlap area.
+func (d *drawOps) cull(area image.Rectangle) {
+	ops := d.imageOps[:0]
+	for _, img := range d.imageOps {
+		if img.clip.Overlaps(area) {
+			ops = append(ops, img)
+			continue
+		}
+		if m := img.material; m.material == materialTexture {
+			// Keep the texture for later frames.
+			d.cache.get(m.data.handle)
+		}
+	}
+	d.imageOps = ops
+}
+
 func (d *drawOps) newPathOp() *pathOp {
 	d.pathOpCache = append(d.pathOpCache, pathOp{})
 	return &d.pathOpCache[len(d.pathOpCache)-1]
 }
 
-func (d *drawOps) addClipPath(state *drawState, aux []byte, auxKey opKey, bounds f32.Rectangle, off f32.Point) {
+func (d *drawOps) addClipPath(state *drawState, aux []byte, auxKey opKey, hash uint64, bounds f32.Rectangle, off f32.Point) {
 	npath := d.newPathOp()
 	*npath = pathOp{
 		parent: state.cpath,
 		bounds: bounds,
 		off:    off,
+		hash:   hash,
 	}
 	state.cpath = npath
 	if len(aux) > 0 {
@@ -884,7 +999,7 @@ loop:
 			state.t = state.t.Mul(dop)
 
 		case opconst.TypeStroke:
-			str = decodeStrokeOp(encOp.Data)
+			str = clip.StrokeStyle{Width: ops.DecodeStroke(encOp.Data)}
 
 		case opconst.TypePath:
 			encOp, ok = r.Decode()
@@ -895,10 +1010,11 @@ loop:
 			quads.key = opKey{Key: encOp.Key}
 
 		case opconst.TypeClip:
-			var op clipOp
-			op.decode(encOp.Data)
-			bounds := op.bounds
+			bounds, outline := ops.DecodeClip(encOp.Data)
+			// cbounds are the bounds of the clip area.
+			cbounds := bounds
 			trans, off := splitTransform(state.t)
+			var hash uint64
 			if len(quads.aux) > 0 {
 				// There is a clipping path, build the gpu data and update the
 				// cache key such that it will be equal only if the transform is the
@@ -907,90 +1023,58 @@ loop:
 				if v, ok := d.pathCache.get(quads.key); ok {
 					// Since the GPU data exists in the cache aux will not be used.
 					// Why is this not used for the offset shapes?
-					op.bounds = v.bounds
+					cbounds = v.bounds
+					hash = v.hash
 				} else {
+					hash = hashPath(quads.aux, trans, outline, str)
 					pathData, bounds := d.buildVerts(
-						quads.aux, trans, op.outline, str,
+						quads.aux, trans, outline, str,
 					)
-					op.bounds = bounds
+					cbounds = bounds
 					quads.aux = pathData
 					// add it to the cache, without GPU data, so the transform can be
 					// reused.
-					d.pathCache.put(quads.key, opCacheValue{bounds: op.bounds})
+					d.pathCache.put(quads.key, opCacheValue{bounds: cbounds, hash: hash})
 				}
 			} else {
-				quads.aux, op.bounds, _ = d.boundsForTransformedRect(bounds, trans)
+				quads.aux, cbounds, _ = d.boundsForTransformedRect(bounds, trans)
+				if quads.aux != nil {
+					hash = hashPath(quads.aux, f32.Affine2D{}, false, clip.StrokeStyle{})
+				}
 				quads.key = opKey{Key: encOp.Key}
 				quads.key.SetTransform(trans) // TODO: This call has no effect.
 			}
-			state.clip = state.clip.Intersect(op.bounds.Add(off))
-			d.addClipPath(&state, quads.aux, quads.key, op.bounds, off)
+			state.clip = state.clip.Intersect(cbounds.Add(off))
+			d.addClipPath(&state, quads.aux, quads.key, hash, cbounds, off)
 			quads = quadsOp{}
 			str = clip.StrokeStyle{}
 
 		case opconst.TypeColor:
 			state.matType = materialColor
-			state.color = decodeColorOp(encOp.Data)
+			state.color = ops.DecodeColor(encOp.Data)
 		case opconst.TypeLinearGradient:
 			state.matType = materialLinearGradient
-			op := decodeLinearGradientOp(encOp.Data)
-			state.stop1 = op.stop1
-			state.stop2 = op.stop2
-			state.color1 = op.color1
-			state.color2 = op.color2
+			g := ops.DecodeLinearGradient(encOp.Data)
+			state.stop1, state.stop2 = g.Stop1, g.Stop2
+			state.color1, state.color2 = g.Color1, g.Color2
 		case opconst.TypeImage:
 			state.matType = materialTexture
 			state.image = decodeImageOp(encOp.Data, encOp.Refs)
 		case opconst.TypePaint:
-			// Transform (if needed) the painting rectangle and if so generate a clip path,
-			// for those cases also compute a partialTrans that maps texture coordinates between
-			// the new bounding rectangle and the transformed original paint rectangle.
-			trans, off := splitTransform(state.t)
-			// Fill the clip area, unless the material is a (bounded) image.
-			// TODO: Find a tighter bound.
-			inf := float32(1e6)
-			dst := f32.Rect(-inf, -inf, inf, inf)
-			if state.matType == materialTexture {
-				dst = layout.FRect(state.image.src.Rect)
-			}
-			clipData, bnd, partialTrans := d.boundsForTransformedRect(dst, trans)
-			cl := state.clip.Intersect(bnd.Add(off))
-			if cl.Empty() {
-				continue
-			}
-
-			wasrect := state.rect
-			if clipData != nil {
-				// The paint operation is sheared or rotated, add a clip path representing
-				// this transformed rectangle.
-				k := opKey{Key: encOp.Key}
-				k.SetTransform(trans) // TODO: This call has no effect.
-				d.addClipPath(&state, clipData, k, bnd, off)
-			}
-
-			bounds := boundRectF(cl)
-			mat := state.materialFor(bnd, off, partialTrans, bounds)
-
-			if bounds.Min == (image.Point{}) && bounds.Max == d.viewport && state.rect && mat.opaque && (mat.material == materialColor) {
-				// The image is a uniform opaque color and takes up the whole screen.
-				// Scrap images up to and including this image and set clear color.
-				d.imageOps = d.imageOps[:0]
-				d.clearColor = mat.color.Opaque()
-				d.clear = true
-				continue
-			}
-			img := imageOp{
-				path:     state.cpath,
-				clip:     bounds,
-				material: mat,
-			}
-
-			d.imageOps = append(d.imageOps, img)
-			if clipData != nil {
-				// we added a clip path that should not remain
-				state.cpath = state.cpath.parent
-				state.rect = wasrect
+			d.collectPaint(encOp, state)
+		case opconst.TypeMask:
+			m := ops.DecodeMask(encOp.Data, encOp.Refs)
+			if isPureOffset(state.t) {
+				d.collectMask(m, state)
+				break
 			}
+			// Paint the mask as an image.
+			state.matType = materialTexture
+			state.image = d.maskImage(m)
+			d.collectPaint(encOp, state)
+		case opconst.TypeCache:
+			r.SkipCall()
+			d.collectCache(encOp, state)
 		case opconst.TypeSave:
 			id := ops.DecodeSave(encOp.Data)
 			d.save(id, state)
@@ -1007,6 +1091,246 @@ loop:
 	}
 }
 
+// collectPaint adds an operation for filling the clip area of state
+// with its material.
+func (d *drawOps) collectPaint(encOp ops.EncodedOp, state drawState) {
+	// Transform (if needed) the painting rectangle and if so generate a clip path,
+	// for those cases also compute a partialTrans that maps texture coordinates between
+	// the new bounding rectangle and the transformed original paint rectangle.
+	trans, off := splitTransform(state.t)
+	// Fill the clip area, unless the material is a (bounded) image.
+	// TODO: Find a tighter bound.
+	inf := float32(1e6)
+	dst := f32.Rect(-inf, -inf, inf, inf)
+	if state.matType == materialTexture {
+		dst = layout.FRect(state.image.src.Rect)
+	}
+	clipData, bnd, partialTrans := d.boundsForTransformedRect(dst, trans)
+	cl := state.clip.Intersect(bnd.Add(off))
+	if cl.Empty() {
+		return
+	}
+
+	if clipData != nil {
+		// The paint operation is sheared or rotated, add a clip path representing
+		// this transformed rectangle.
+		k := opKey{Key: encOp.Key}
+		k.SetTransform(trans) // TODO: This call has no effect.
+		d.addClipPath(&state, clipData, k, hashPath(clipData, f32.Affine2D{}, false, clip.StrokeStyle{}), bnd, off)
+	}
+
+	bounds := boundRectF(cl)
+	mat := state.materialFor(bnd, off, partialTrans, bounds)
+
+	if bounds.Min == (image.Point{}) && bounds.Max == d.viewport && state.rect && mat.opaque && (mat.material == materialColor) && !state.cached {
+		// The image is a uniform opaque color and takes up the whole screen.
+		// Scrap images up to and including this image and set clear color.
+		d.imageOps = d.imageOps[:0]
+		d.clearColor = mat.color.Opaque()
+		d.clear = true
+		return
+	}
+	img := imageOp{
+		path:     state.cpath,
+		clip:     bounds,
+		material: mat,
+	}
+
+	d.imageOps = append(d.imageOps, img)
+}
+
+// collectCache adds an operation for drawing the rendering of the
+// cached call in encOp. The operations of the call are collected for
+// rendering if no rendering exists. If no texture can be allocated
+// for the rendering, the operations are collected for drawing
+// directly.
+func (d *drawOps) collectCache(encOp ops.EncodedOp, state drawState) {
+	k, origin := newCacheKey(encOp.Key, state.t, d.viewport)
+	visible := boundRectF(state.clip).Intersect(image.Rectangle{Max: d.viewport})
+	c, exists := d.cachedCall(k)
+	if exists && !d.covers(c, visible.Sub(origin.Sub(c.origin))) {
+		if !d.evictCached(k) {
+			// The rendering is in use by this frame.
+			d.collectUncached(encOp, state)
+			return
+		}
+		exists = false
+	}
+	if exists {
+		// Keep the rendering for later frames.
+		d.cache.get(k)
+	} else {
+		start, pstart := len(d.imageOps), len(d.pathOps)
+		d.collectCall(encOp, cachedState(state, d.viewport))
+		var bounds image.Rectangle
+		for _, img := range d.imageOps[start:] {
+			bounds = bounds.Union(img.clip)
+		}
+		tex, fbo, err := d.newCacheTexture(bounds.Size())
+		if err != nil {
+			d.imageOps = d.imageOps[:start]
+			d.pathOps = d.pathOps[:pstart]
+			d.collectUncached(encOp, state)
+			return
+		}
+		cstart := len(d.cacheOps)
+		d.cacheOps = append(d.cacheOps, d.imageOps[start:]...)
+		d.imageOps = d.imageOps[:start]
+		d.cacheCalls = append(d.cacheCalls, cacheCall{
+			key:    k,
+			bounds: bounds,
+			origin: origin,
+			start:  cstart,
+			end:    len(d.cacheOps),
+			tex:    tex,
+			fbo:    fbo,
+		})
+		c = cachedCall{bounds: bounds, origin: origin}
+	}
+	// Move the rendering to the current offset.
+	bounds := c.bounds.Add(origin.Sub(c.origin))
+	cl := state.clip.Intersect(layout.FRect(bounds))
+	if cl.Empty() {
+		return
+	}
+	clip := boundRectF(cl)
+	// Map the clip area to its part of the texture. The texture is
+	// rendered upside down.
+	uvScale, uvOffset := texSpaceTransform(layout.FRect(clip.Sub(bounds.Min)), bounds.Size())
+	uvTrans := f32.Affine2D{}.
+		Scale(f32.Point{}, uvScale).
+		Offset(uvOffset).
+		Scale(f32.Point{}, f32.Pt(1, -1)).
+		Offset(f32.Pt(0, 1))
+	d.imageOps = append(d.imageOps, imageOp{
+		path: state.cpath,
+		clip: clip,
+		material: material{
+			material: materialTexture,
+			data:     imageOpData{handle: k},
+			uvTrans:  uvTrans,
+		},
+	})
+}
+
+// cachedState returns the state for collecting the operations of a
+// cached call drawn in state to its rendering.
+func cachedState(state drawState, viewport image.Point) drawState {
+	return drawState{
+		clip:   f32.Rectangle{Max: layout.FPt(viewport)},
+		t:      state.t,
+		rect:   true,
+		color:  color.NRGBA{A: 0xff},
+		cached: true,
+	}
+}
+
+// collectUncached collects the operations of the cached call in encOp
+// for drawing directly, clipped like its rendering would be.
+func (d *drawOps) collectUncached(encOp ops.EncodedOp, state drawState) {
+	cstate := cachedState(state, d.viewport)
+	cstate.clip = state.clip
+	cstate.cpath = state.cpath
+	cstate.rect = state.rect
+	cstate.cached = false
+	d.collectCall(encOp, cstate)
+}
+
+// newCacheKey returns the key of the rendering of the cached call
+// with key k and transform t, along with the whole pixel offset of
+// the rendering. The sub-pixel offset is rounded to a multiple of
+// 1/cacheFracs pixels.
+func newCacheKey(k ops.Key, t f32.Affine2D, viewport image.Point) (cacheKey, image.Point) {
+	sx, hx, ox, hy, sy, oy := t.Elems()
+	split := func(v float32) (float32, int) {
+		i := floor(v)
+		f := float32(math.Round(float64((v-float32(i))*cacheFracs))) / cacheFracs
+		if f == 1 {
+			i, f = i+1, 0
+		}
+		return f, i
+	}
+	fx, ix := split(ox)
+	fy, iy := split(oy)
+	key := cacheKey{
+		Key:      k,
+		t:        f32.NewAffine2D(sx, hx, fx, hy, sy, fy),
+		viewport: viewport,
+	}
+	return key, image.Pt(ix, iy)
+}
+
+// covers reports whether the rendering c covers the area visible of
+// the window, relative to the origin of the rendering. Renderings that
+// don't reach the window edges were not clipped by the window and
+// cover every area.
+func (d *drawOps) covers(c cachedCall, visible image.Rectangle) bool {
+	win := image.Rectangle{Max: d.viewport}
+	b := c.bounds
+	clipped := b.Min.X <= win.Min.X || b.Min.Y <= win.Min.Y || b.Max.X >= win.Max.X || b.Max.Y >= win.Max.Y
+	return !clipped || visible.In(win)
+}
+
+// collectCall collects the operations of the call or cache operation
+// in encOp.
+func (d *drawOps) collectCall(encOp ops.EncodedOp, state drawState) {
+	// The call has its own saved states.
+	states := d.states
+	d.states = nil
+	var r ops.Reader
+	r.ResetCall(encOp.Data, encOp.Refs)
+	d.collectOps(&r, state)
+	d.states = states
+}
+
+// newCacheTexture allocates a texture and framebuffer for rendering a
+// cached call of size sz. Both are nil if sz is empty.
+func (d *drawOps) newCacheTexture(sz image.Point) (driver.Texture, driver.Framebuffer, error) {
+	if sz.X <= 0 || sz.Y <= 0 {
+		return nil, nil, nil
+	}
+	if max := d.ctx.Caps().MaxTextureSize; sz.X > max || sz.Y > max {
+		return nil, nil, fmt.Errorf("gpu: cached call size %v is larger than maximum texture size %dx%d", sz, max, max)
+	}
+	tex, err := d.ctx.NewTexture(driver.TextureFormatSRGBA, sz.X, sz.Y, driver.FilterNearest, driver.FilterNearest,
+		driver.BufferBindingTexture|driver.BufferBindingFramebuffer)
+	if err != nil {
+		return nil, nil, err
+	}
+	fbo, err := d.ctx.NewFramebuffer(tex)
+	if err != nil {
+		tex.Release()
+		return nil, nil, err
+	}
+	return tex, fbo, nil
+}
+
+// cachedCall returns the rendering of the cached call with key k,
+// if it exists or is to be rendered this frame.
+func (d *drawOps) cachedCall(k cacheKey) (cachedCall, bool) {
+	for _, c := range d.cacheCalls {
+		if c.key == k {
+			return cachedCall{bounds: c.bounds, origin: c.origin}, true
+		}
+	}
+	if c, ok := d.cache.res[k]; ok {
+		return *c.(*cachedCall), true
+	}
+	return cachedCall{}, false
+}
+
+// evictCached removes the rendering with key k from the cache, unless
+// it is used by the current frame. It reports whether the rendering
+// was removed.
+func (d *drawOps) evictCached(k cacheKey) bool {
+	for _, c := range d.cacheCalls {
+		if c.key == k {
+			return false
+		}
+	}
+	return d.cache.evict(k)
+}
+
 func expandPathOp(p *pathOp, clip image.Rectangle) {
 	for p != nil {
 		pclip := p.clip
@@ -1081,6 +1405,8 @@ func (r *renderer) drawOps(cache *resourceCache, ops []imageOp) {
 			fbo = r.pather.stenciler.cover(img.place.Idx)
 		case clipTypeIntersection:
 			fbo = r.pather.stenciler.intersections.fbos[img.place.Idx]
+		case clipTypeMask:
+			fbo.tex, fbo.size = img.path.mask.tex, img.path.mask.size
 		}
 		if coverTex != fbo.tex {
 			coverTex = fbo.tex
@@ -1091,6 +1417,11 @@ func (r *renderer) drawOps(cache *resourceCache, ops []imageOp) {
 			Max: img.place.Pos.Add(drc.Size()),
 		}
 		coverScale, coverOff := texSpaceTransform(layout.FRect(uv), fbo.size)
+		if img.clipType == clipTypeMask && !r.ctx.Caps().BottomLeftOrigin {
+			// Undo the flip of framebuffer textures in the shader.
+			coverOff.Y += coverScale.Y
+			coverScale.Y = -coverScale.Y
+		}
 		r.pather.cover(m.material, m.color, m.color1, m.color2, scale, off, m.uvTrans, coverScale, coverOff)
 	}
 }
@@ -2,15 +2,21 @@
 
 // Package headless implements headless windows for rendering
 // an operation list to an image.
+//
+// Windows are rendered by a pure Go software renderer when no GPU
+// context is available, such as in programs built without cgo on
+// platforms where GPU contexts require it.
 package headless
 
 import (
+	"errors"
 	"image"
 	"image/color"
 	"runtime"
 
 	"github.com/cybriq/giocore/gpu"
 	"github.com/cybriq/giocore/gpu/internal/driver"
+	"github.com/cybriq/giocore/gpu/internal/soft"
 	"github.com/cybriq/giocore/op"
 )
 
@@ -22,8 +28,16 @@ type Window struct {
 	gpu    gpu.GPU
 	fboTex driver.Texture
 	fbo    driver.Framebuffer
+	// soft and img replace the GPU context and framebuffer
+	// for software rendering.
+	soft *soft.Renderer
+	img  *image.RGBA
 }
 
+// errNoGPU is wrapped by the errors of newContext when no GPU context
+// is available.
+var errNoGPU = errors.New("headless: no GPU context available")
+
 type context interface {
 	API() gpu.API
 	MakeCurrent() error
@@ -31,9 +45,15 @@ type context interface {
 	Release()
 }
 
-// NewWindow creates a new headless window.
+// NewWindow creates a new headless window. The window falls back to
+// software rendering if no GPU context is available, but not if
+// creating an available context fails. Use Software to detect the
+// fallback.
 func NewWindow(width, height int) (*Window, error) {
 	ctx, err := newContext()
+	if errors.Is(err, errNoGPU) {
+		return newSoftWindow(width, height), nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +75,7 @@ func NewWindow(width, height int) (*Window, error) {
 			driver.BufferBindingFramebuffer,
 		)
 		if err != nil {
-			return nil
+			return err
 		}
 		fbo, err := dev.NewFramebuffer(fboTex)
 		if err != nil {
@@ -81,8 +101,27 @@ func NewWindow(width, height int) (*Window, error) {
 	return w, nil
 }
 
+func newSoftWindow(width, height int) *Window {
+	return &Window{
+		size: image.Point{X: width, Y: height},
+		soft: new(soft.Renderer),
+		img:  image.NewRGBA(image.Rectangle{Max: image.Point{X: width, Y: height}}),
+	}
+}
+
+// Software reports whether the window is rendered by the software
+// renderer, because no GPU context was available.
+func (w *Window) Software() bool {
+	return w.soft != nil
+}
+
 // Release resources associated with the window.
 func (w *Window) Release() {
+	if w.soft != nil {
+		w.soft = nil
+		w.img = nil
+		return
+	}
 	contextDo(w.ctx, func() error {
 		if w.fbo != nil {
 			w.fbo.Release()
@@ -107,15 +146,29 @@ func (w *Window) Release() {
 // Frame replace the window content and state with the
 // operation list.
 func (w *Window) Frame(frame *op.Ops) error {
+	if w.soft != nil {
+		for i := range w.img.Pix {
+			w.img.Pix[i] = 0
+		}
+		w.soft.Render(w.img, frame)
+		return nil
+	}
 	return contextDo(w.ctx, func() error {
 		w.gpu.Clear(color.NRGBA{})
 		w.gpu.Collect(w.size, frame)
+		// The framebuffer contains the previous frame.
+		w.gpu.SetBufferAge(1)
 		return w.gpu.Frame(driver.RenderTarget(w.fbo))
 	})
 }
 
 // Screenshot returns an image with the content of the window.
 func (w *Window) Screenshot() (*image.RGBA, error) {
+	if w.soft != nil {
+		img := image.NewRGBA(w.img.Rect)
+		copy(img.Pix, w.img.Pix)
+		return img, nil
+	}
 	var img *image.RGBA
 	err := contextDo(w.ctx, func() error {
 		var err error
@@ -1,14 +1,25 @@
 // SPDX-License-Identifier: Unlicense OR MIT
 
-//go:build linux || freebsd || openbsd
+//go:build (linux || freebsd || openbsd) && cgo
 // +build linux freebsd openbsd
+// +build cgo
 
 package headless
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/cybriq/giocore/internal/egl"
 )
 
 func newContext() (context, error) {
-	return egl.NewContext(egl.EGL_DEFAULT_DISPLAY)
+	ctx, err := egl.NewContext(egl.EGL_DEFAULT_DISPLAY)
+	if errors.Is(err, egl.ErrNoDisplay) {
+		return nil, fmt.Errorf("%w: %v", errNoGPU, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ctx, nil
 }
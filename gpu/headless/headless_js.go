@@ -3,7 +3,7 @@
 package headless
 
 import (
-	"errors"
+	"fmt"
 	"syscall/js"
 
 	"github.com/cybriq/giocore/gpu"
@@ -22,7 +22,7 @@ func newContext() (context, error) {
 		ctx = cnv.Call("getContext", "webgl")
 	}
 	if ctx.IsNull() {
-		return nil, errors.New("headless: webgl is not supported")
+		return nil, fmt.Errorf("%w: webgl is not supported", errNoGPU)
 	}
 	c := &jsContext{
 		ctx: ctx,
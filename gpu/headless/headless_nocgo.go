@@ -0,0 +1,13 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build (darwin || linux || freebsd || openbsd) && !cgo
+// +build darwin linux freebsd openbsd
+// +build !cgo
+
+package headless
+
+import "fmt"
+
+func newContext() (context, error) {
+	return nil, fmt.Errorf("%w: GPU contexts require cgo", errNoGPU)
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package headless
+
+import (
+	"image"
+	"sync"
+
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/unit"
+)
+
+// Renderer renders operation lists to images without the need to
+// manage windows. It keeps a pool of windows for reuse and is safe
+// for concurrent use. The zero value is ready to use.
+type Renderer struct {
+	// Metric converts the sizes given to RenderFunc to pixels, and
+	// is passed to its drawing function. The zero Metric maps dp
+	// and sp to pixels 1-to-1.
+	Metric unit.Metric
+
+	mu sync.Mutex
+	// idle windows, the least recently used first.
+	idle []*Window
+	ops  sync.Pool
+}
+
+// maxIdleWindows is the number of unused windows kept by a
+// Renderer.
+const maxIdleWindows = 4
+
+var defaultRenderer Renderer
+
+// Render renders ops to an image of size pixels, using a shared
+// Renderer.
+func Render(ops *op.Ops, size image.Point) (*image.RGBA, error) {
+	return defaultRenderer.Render(ops, size)
+}
+
+// Render renders ops to an image of size pixels.
+func (r *Renderer) Render(ops *op.Ops, size image.Point) (*image.RGBA, error) {
+	w, err := r.get(size)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Frame(ops); err != nil {
+		w.Release()
+		return nil, err
+	}
+	img, err := w.Screenshot()
+	if err != nil {
+		w.Release()
+		return nil, err
+	}
+	r.put(w)
+	return img, nil
+}
+
+// RenderFunc renders the operations recorded by draw to an image of
+// the given width and height, converted to pixels by the Metric of
+// r. The Metric is passed to draw for converting its own sizes.
+func (r *Renderer) RenderFunc(width, height unit.Value, draw func(ops *op.Ops, m unit.Metric)) (*image.RGBA, error) {
+	ops, ok := r.ops.Get().(*op.Ops)
+	if !ok {
+		ops = new(op.Ops)
+	}
+	defer r.ops.Put(ops)
+	ops.Reset()
+	draw(ops, r.Metric)
+	size := image.Point{X: r.Metric.Px(width), Y: r.Metric.Px(height)}
+	return r.Render(ops, size)
+}
+
+// Release the pooled windows of r. Release may be called while r is
+// in use, in which case windows in use are returned to the pool when
+// their rendering completes.
+func (r *Renderer) Release() {
+	r.mu.Lock()
+	idle := r.idle
+	r.idle = nil
+	r.mu.Unlock()
+	for _, w := range idle {
+		w.Release()
+	}
+}
+
+// get removes and returns a pooled window of the size, or creates a
+// new window.
+func (r *Renderer) get(size image.Point) (*Window, error) {
+	r.mu.Lock()
+	for i := len(r.idle) - 1; i >= 0; i-- {
+		if w := r.idle[i]; w.size == size {
+			r.idle = append(r.idle[:i], r.idle[i+1:]...)
+			r.mu.Unlock()
+			return w, nil
+		}
+	}
+	r.mu.Unlock()
+	return NewWindow(size.X, size.Y)
+}
+
+// put returns a window to the pool, releasing the least recently
+// used window if the pool is full.
+func (r *Renderer) put(w *Window) {
+	r.mu.Lock()
+	r.idle = append(r.idle, w)
+	var evicted *Window
+	if len(r.idle) > maxIdleWindows {
+		evicted = r.idle[0]
+		r.idle = append(r.idle[:0], r.idle[1:]...)
+	}
+	r.mu.Unlock()
+	if evicted != nil {
+		evicted.Release()
+	}
+}
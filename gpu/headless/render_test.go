@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package headless
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+
+	"github.com/cybriq/giocore/internal/f32color"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/op/clip"
+	"github.com/cybriq/giocore/op/paint"
+	"github.com/cybriq/giocore/unit"
+)
+
+func TestRender(t *testing.T) {
+	col := color.NRGBA{A: 0xff, R: 0xca, G: 0xfe}
+	var ops op.Ops
+	paint.FillShape(&ops, col, clip.Rect(image.Rect(0, 0, 10, 10)).Op())
+	img, err := Render(&ops, image.Pt(20, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(20, 20) {
+		t.Errorf("got %v image, expected 20x20", got)
+	}
+	if got, exp := img.RGBAAt(5, 5), f32color.NRGBAToRGBA(col); got != exp {
+		t.Errorf("got color %v, expected %v", got, exp)
+	}
+	if got := img.RGBAAt(15, 15); got != (color.RGBA{}) {
+		t.Errorf("got color %v outside the shape, expected transparent", got)
+	}
+}
+
+func TestRenderConcurrent(t *testing.T) {
+	var r Renderer
+	defer r.Release()
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var ops op.Ops
+			col := color.NRGBA{R: uint8(i), A: 0xff}
+			paint.Fill(&ops, col)
+			sz := image.Pt(10+i%3, 10)
+			img, err := r.Render(&ops, sz)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := img.Bounds().Size(); got != sz {
+				t.Errorf("got %v image, expected %v", got, sz)
+			}
+			if got, exp := img.RGBAAt(1, 1), f32color.NRGBAToRGBA(col); got != exp {
+				t.Errorf("got color %v, expected %v", got, exp)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(r.idle); n > maxIdleWindows {
+		t.Errorf("got %d idle windows, expected at most %d", n, maxIdleWindows)
+	}
+}
+
+func TestRenderFunc(t *testing.T) {
+	r := Renderer{Metric: unit.Metric{PxPerDp: 2}}
+	defer r.Release()
+	col := color.NRGBA{A: 0xff, B: 0xff}
+	img, err := r.RenderFunc(unit.Dp(20), unit.Dp(10), func(ops *op.Ops, m unit.Metric) {
+		sz := m.Px(unit.Dp(5))
+		paint.FillShape(ops, col, clip.Rect(image.Rect(0, 0, sz, sz)).Op())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(40, 20) {
+		t.Errorf("got %v image, expected 40x20", got)
+	}
+	if got, exp := img.RGBAAt(9, 9), f32color.NRGBAToRGBA(col); got != exp {
+		t.Errorf("got color %v, expected %v", got, exp)
+	}
+	if got := img.RGBAAt(10, 10); got != (color.RGBA{}) {
+		t.Errorf("got color %v outside the shape, expected transparent", got)
+	}
+}
@@ -38,6 +38,9 @@ type Backend struct {
 
 	// cached state objects.
 	blendStates map[blendState]*d3d11.BlendState
+	// rasterStates are the rasterizer states without
+	// and with the scissor test.
+	rasterStates [2]*d3d11.RasterizerState
 }
 
 type blendState struct {
@@ -140,20 +143,32 @@ func newDirect3D11Device(api driver.Direct3D11) (driver.Device, error) {
 		b.floatFormat = fmt
 		b.caps.Features |= driver.FeatureFloatRenderTargets
 	}
-	// Disable backface culling to match OpenGL.
-	state, err := dev.CreateRasterizerState(&d3d11.RASTERIZER_DESC{
-		CullMode: d3d11.CULL_NONE,
-		FillMode: d3d11.FILL_SOLID,
-	})
-	if err != nil {
-		return nil, err
+	for i := range b.rasterStates {
+		// Disable backface culling to match OpenGL.
+		state, err := dev.CreateRasterizerState(&d3d11.RASTERIZER_DESC{
+			CullMode:      d3d11.CULL_NONE,
+			FillMode:      d3d11.FILL_SOLID,
+			ScissorEnable: uint32(i),
+		})
+		if err != nil {
+			b.releaseRasterStates()
+			return nil, err
+		}
+		b.rasterStates[i] = state
 	}
-	defer d3d11.IUnknownRelease(unsafe.Pointer(state), state.Vtbl.Release)
-	b.ctx.RSSetState(state)
+	b.ctx.RSSetState(b.rasterStates[0])
 	return b, nil
 }
 
-func (b *Backend) BeginFrame(target driver.RenderTarget, clear bool, viewport image.Point) driver.Framebuffer {
+func (b *Backend) releaseRasterStates() {
+	for _, state := range b.rasterStates {
+		if state != nil {
+			d3d11.IUnknownRelease(unsafe.Pointer(state), state.Vtbl.Release)
+		}
+	}
+}
+
+func (b *Backend) BeginFrame(target driver.RenderTarget, clear, preserve bool, viewport image.Point) driver.Framebuffer {
 	var (
 		renderTarget *d3d11.RenderTargetView
 	)
@@ -194,6 +209,7 @@ func (b *Backend) Release() {
 	for _, state := range b.blendStates {
 		d3d11.IUnknownRelease(unsafe.Pointer(state), state.Vtbl.Release)
 	}
+	b.releaseRasterStates()
 	d3d11.IUnknownRelease(unsafe.Pointer(b.ctx), b.ctx.Vtbl.Release)
 	*b = Backend{}
 }
@@ -417,6 +433,15 @@ func (b *Backend) Viewport(x, y, width, height int) {
 	b.ctx.RSSetViewports(&b.viewport)
 }
 
+func (b *Backend) Scissor(x, y, width, height int) {
+	b.ctx.RSSetScissorRects(&d3d11.RECT{
+		Left:   int32(x),
+		Top:    int32(y),
+		Right:  int32(x + width),
+		Bottom: int32(y + height),
+	})
+}
+
 func (b *Backend) DrawArrays(mode driver.DrawMode, off, count int) {
 	b.prepareDraw(mode)
 	b.ctx.Draw(uint32(count), uint32(off))
@@ -479,6 +504,14 @@ func (b *Backend) SetBlend(enable bool) {
 	b.blendState.enable = enable
 }
 
+func (b *Backend) SetScissor(enable bool) {
+	if enable {
+		b.ctx.RSSetState(b.rasterStates[1])
+	} else {
+		b.ctx.RSSetState(b.rasterStates[0])
+	}
+}
+
 func (b *Backend) BlendFunc(sfactor, dfactor driver.BlendFactor) {
 	b.blendState.sfactor = sfactor
 	b.blendState.dfactor = dfactor
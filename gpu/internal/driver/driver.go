@@ -14,7 +14,10 @@ import (
 // APIs such as OpenGL, Direct3D useful for rendering Gio
 // operations.
 type Device interface {
-	BeginFrame(target RenderTarget, clear bool, viewport image.Point) Framebuffer
+	// BeginFrame starts a frame drawn to target. Unless preserve is
+	// set, the contents of target may be discarded after the frame,
+	// so the next frame must draw all of it.
+	BeginFrame(target RenderTarget, clear, preserve bool, viewport image.Point) Framebuffer
 	EndFrame()
 	Caps() Caps
 	NewTimer() Timer
@@ -31,9 +34,14 @@ type Device interface {
 
 	Clear(r, g, b, a float32)
 	Viewport(x, y, width, height int)
+	// Scissor sets the rectangle, in the coordinates of Viewport,
+	// outside of which draw calls have no effect while the scissor
+	// test is enabled by SetScissor.
+	Scissor(x, y, width, height int)
 	DrawArrays(mode DrawMode, off, count int)
 	DrawElements(mode DrawMode, off, count int)
 	SetBlend(enable bool)
+	SetScissor(enable bool)
 	BlendFunc(sfactor, dfactor BlendFactor)
 
 	BindInputLayout(i InputLayout)
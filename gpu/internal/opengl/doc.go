@@ -0,0 +1,6 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// This file exists so this package builds without cgo on platforms
+// where OpenGL requires cgo.
+
+package opengl
@@ -1,5 +1,8 @@
 // SPDX-License-Identifier: Unlicense OR MIT
 
+//go:build !(darwin || linux || freebsd || openbsd) || cgo
+// +build !darwin,!linux,!freebsd,!openbsd cgo
+
 package opengl
 
 import (
@@ -20,6 +23,7 @@ type Backend struct {
 	funcs *gl.Functions
 
 	clear      bool
+	preserve   bool
 	glstate    glState
 	state      state
 	savedState glState
@@ -74,6 +78,10 @@ type glState struct {
 		srcRGB, dstRGB gl.Enum
 		srcA, dstA     gl.Enum
 	}
+	scissor struct {
+		enable bool
+		box    [4]int
+	}
 	clearColor        [4]float32
 	viewport          [4]int
 	unpack_row_length int
@@ -207,8 +215,9 @@ func newOpenGLDevice(api driver.OpenGL) (driver.Device, error) {
 	return b, nil
 }
 
-func (b *Backend) BeginFrame(target driver.RenderTarget, clear bool, viewport image.Point) driver.Framebuffer {
+func (b *Backend) BeginFrame(target driver.RenderTarget, clear, preserve bool, viewport image.Point) driver.Framebuffer {
 	b.clear = clear
+	b.preserve = preserve
 	b.glstate = b.queryState()
 	b.savedState = b.glstate
 	b.state = state{}
@@ -268,7 +277,8 @@ func (b *Backend) EndFrame() {
 			b.BlendFunc(driver.BlendFactorOne, driver.BlendFactorOneMinusSrcAlpha)
 			b.SetBlend(true)
 		}
-		b.sRGBFBO.Blit()
+		b.SetScissor(false)
+		b.sRGBFBO.Blit(!b.preserve)
 	}
 	b.restoreState(b.savedState)
 	// For single-buffered framebuffers such as on macOS.
@@ -286,6 +296,8 @@ func (b *Backend) queryState() glState {
 		unpack_row_length: b.funcs.GetInteger(gl.UNPACK_ROW_LENGTH),
 	}
 	s.blend.enable = b.funcs.IsEnabled(gl.BLEND)
+	s.scissor.enable = b.funcs.IsEnabled(gl.SCISSOR_TEST)
+	s.scissor.box = b.funcs.GetInteger4(gl.SCISSOR_BOX)
 	s.blend.srcRGB = gl.Enum(b.funcs.GetInteger(gl.BLEND_SRC_RGB))
 	s.blend.dstRGB = gl.Enum(b.funcs.GetInteger(gl.BLEND_DST_RGB))
 	s.blend.srcA = gl.Enum(b.funcs.GetInteger(gl.BLEND_SRC_ALPHA))
@@ -358,6 +370,9 @@ func (b *Backend) restoreState(dst glState) {
 	src.bindBuffer(f, gl.ARRAY_BUFFER, dst.arrayBuf)
 	v := dst.viewport
 	src.setViewport(f, v[0], v[1], v[2], v[3])
+	src.set(f, gl.SCISSOR_TEST, dst.scissor.enable)
+	sb := dst.scissor.box
+	src.setScissor(f, sb[0], sb[1], sb[2], sb[3])
 	src.pixelStorei(f, gl.UNPACK_ROW_LENGTH, dst.unpack_row_length)
 }
 
@@ -586,6 +601,14 @@ func (s *glState) setViewport(f *gl.Functions, x, y, width, height int) {
 	}
 }
 
+func (s *glState) setScissor(f *gl.Functions, x, y, width, height int) {
+	box := [4]int{x, y, width, height}
+	if box != s.scissor.box {
+		f.Scissor(int32(x), int32(y), int32(width), int32(height))
+		s.scissor.box = box
+	}
+}
+
 func (s *glState) setBlendFuncSeparate(f *gl.Functions, srcRGB, dstRGB, srcA, dstA gl.Enum) {
 	if srcRGB != s.blend.srcRGB || dstRGB != s.blend.dstRGB || srcA != s.blend.srcA || dstA != s.blend.dstA {
 		s.blend.srcRGB = srcRGB
@@ -608,6 +631,11 @@ func (s *glState) set(f *gl.Functions, target gl.Enum, enable bool) {
 			return
 		}
 		s.blend.enable = enable
+	case gl.SCISSOR_TEST:
+		if enable == s.scissor.enable {
+			return
+		}
+		s.scissor.enable = enable
 	default:
 		panic("unknown enable")
 	}
@@ -806,6 +834,10 @@ func (b *Backend) SetBlend(enable bool) {
 	b.glstate.set(b.funcs, gl.BLEND, enable)
 }
 
+func (b *Backend) SetScissor(enable bool) {
+	b.glstate.set(b.funcs, gl.SCISSOR_TEST, enable)
+}
+
 func (b *Backend) DrawElements(mode driver.DrawMode, off, count int) {
 	b.prepareDraw()
 	// off is in 16-bit indices, but DrawElements take a byte offset.
@@ -842,6 +874,10 @@ func (b *Backend) Viewport(x, y, width, height int) {
 	b.glstate.setViewport(b.funcs, x, y, width, height)
 }
 
+func (b *Backend) Scissor(x, y, width, height int) {
+	b.glstate.setScissor(b.funcs, x, y, width, height)
+}
+
 func (b *Backend) Clear(colR, colG, colB, colA float32) {
 	b.glstate.setClearColor(b.funcs, colR, colG, colB, colA)
 	b.funcs.Clear(gl.COLOR_BUFFER_BIT)
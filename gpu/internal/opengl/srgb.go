@@ -1,5 +1,8 @@
 // SPDX-License-Identifier: Unlicense OR MIT
 
+//go:build !(darwin || linux || freebsd || openbsd) || cgo
+// +build !darwin,!linux,!freebsd,!openbsd cgo
+
 package opengl
 
 import (
@@ -55,7 +58,9 @@ func NewSRGBFBO(f *gl.Functions, state *glState) (*SRGBFBO, error) {
 	return s, nil
 }
 
-func (s *SRGBFBO) Blit() {
+// Blit draws the FBO contents to the current framebuffer. If
+// invalidate is set, the FBO contents are discarded afterwards.
+func (s *SRGBFBO) Blit(invalidate bool) {
 	if !s.blitted {
 		prog, err := gl.CreateProgram(s.c, blitVSrc, blitFSrc, []string{"pos", "uv"})
 		if err != nil {
@@ -83,8 +88,10 @@ func (s *SRGBFBO) Blit() {
 	s.state.setVertexAttribArray(s.c, 0, true)
 	s.state.setVertexAttribArray(s.c, 1, true)
 	s.c.DrawArrays(gl.TRIANGLE_STRIP, 0, 4)
-	s.state.bindFramebuffer(s.c, gl.FRAMEBUFFER, s.fbo)
-	s.c.InvalidateFramebuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0)
+	if invalidate {
+		s.state.bindFramebuffer(s.c, gl.FRAMEBUFFER, s.fbo)
+		s.c.InvalidateFramebuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0)
+	}
 }
 
 func (s *SRGBFBO) Framebuffer() gl.Framebuffer {
@@ -210,6 +210,112 @@ func TestBuildOffscreen(t *testing.T) {
 			}))
 }
 
+func TestPartialRedraw(t *testing.T) {
+	// Check that moving one shape redraws its old and new areas
+	// while preserving the rest of the frame.
+	requireGPU(t)
+
+	draw := func(off float32, o *op.Ops) {
+		paint.FillShape(o, red, clip.Rect(image.Rect(0, 0, 50, 50)).Op())
+		s := op.Save(o)
+		op.Offset(f32.Pt(off, 60)).Add(o)
+		paint.FillShape(o, blue, clip.Rect(image.Rect(0, 0, 30, 30)).Op())
+		s.Load()
+	}
+
+	multiRun(t,
+		frame(
+			func(ops *op.Ops) {
+				draw(0, ops)
+			}, func(r result) {
+				r.expect(25, 25, colornames.Red)
+				r.expect(15, 75, colornames.Blue)
+				r.expect(75, 75, transparent)
+			}),
+		frame(
+			func(ops *op.Ops) {
+				draw(60, ops)
+			}, func(r result) {
+				r.expect(25, 25, colornames.Red)
+				r.expect(15, 75, transparent)
+				r.expect(75, 75, colornames.Blue)
+			}))
+}
+
+func TestCachedCall(t *testing.T) {
+	// Check that a cached call is drawn correctly when its rendering
+	// is created, reused with a different clip or a whole pixel
+	// offset and recreated when the offset uncovers parts clipped by
+	// the window.
+	requireGPU(t)
+
+	cached := new(op.Ops)
+	m := op.Record(cached)
+	paint.FillShape(cached, red, clip.Rect(image.Rect(0, 0, 40, 40)).Op())
+	rr := clip.RRect{Rect: f32.Rect(40, 0, 80, 40), SE: 10, SW: 10, NW: 10, NE: 10}
+	paint.FillShape(cached, blue, rr.Op(cached))
+	call := m.Stop()
+
+	draw := func(off float32, o *op.Ops) {
+		s := op.Save(o)
+		op.Offset(f32.Pt(off, off)).Add(o)
+		op.CacheOp{Call: call}.Add(o)
+		s.Load()
+	}
+
+	multiRun(t,
+		frame(
+			func(ops *op.Ops) {
+				draw(10, ops)
+			}, func(r result) {
+				r.expect(20, 20, colornames.Red)
+				r.expect(70, 30, colornames.Blue)
+				r.expect(5, 5, transparent)
+				r.expect(51, 11, transparent)
+			}),
+		frame(
+			func(ops *op.Ops) {
+				s := op.Save(ops)
+				clip.Rect(image.Rect(0, 0, 30, 128)).Add(ops)
+				draw(10, ops)
+				s.Load()
+			}, func(r result) {
+				r.expect(20, 20, colornames.Red)
+				r.expect(35, 20, transparent)
+				r.expect(70, 30, transparent)
+			}),
+		frame(
+			func(ops *op.Ops) {
+				draw(40, ops)
+			}, func(r result) {
+				r.expect(20, 20, transparent)
+				r.expect(50, 50, colornames.Red)
+				r.expect(100, 60, colornames.Blue)
+			}),
+		frame(
+			func(ops *op.Ops) {
+				draw(41, ops)
+			}, func(r result) {
+				r.expect(41, 41, colornames.Red)
+				r.expect(101, 61, colornames.Blue)
+			}),
+		// Evict the rendering.
+		frame(func(ops *op.Ops) {}, nil),
+		frame(
+			func(ops *op.Ops) {
+				draw(100, ops)
+			}, func(r result) {
+				r.expect(110, 110, colornames.Red)
+			}),
+		frame(
+			func(ops *op.Ops) {
+				draw(10, ops)
+			}, func(r result) {
+				r.expect(20, 20, colornames.Red)
+				r.expect(70, 30, colornames.Blue)
+			}))
+}
+
 func TestNegativeOverlaps(t *testing.T) {
 	run(t, func(ops *op.Ops) {
 		clip.RRect{Rect: f32.Rect(50, 50, 100, 100)}.Add(ops)
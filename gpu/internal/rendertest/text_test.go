@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package rendertest
+
+import (
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/font/gofont"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/text"
+)
+
+const benchText = "The quick brown fox jumps over the lazy dog, 0123456789 times."
+
+// drawText draws lines of small text filling a window.
+func drawText(ops *op.Ops, cache *text.Cache, lines int) {
+	font := text.Font{}
+	size := fixed.I(12)
+	l := cache.LayoutString(font, size, 1000, benchText)[0]
+	col := color.NRGBA{A: 0xff}
+	for i := 0; i < lines; i++ {
+		stack := op.Save(ops)
+		op.Offset(f32.Pt(0, float32(i*16+12))).Add(ops)
+		cache.PaintText(font, size, l.Layout, col).Add(ops)
+		stack.Load()
+	}
+}
+
+func benchmarkText(b *testing.B, atlas bool) {
+	w := newWindow(b, 512, 1024)
+	cache := text.NewCache(gofont.Collection())
+	if atlas {
+		cache.SetGlyphAtlas(fixed.I(16))
+	}
+	ops := new(op.Ops)
+	drawText(ops, cache, 60)
+	w.Frame(ops)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ops.Reset()
+		drawText(ops, cache, 60)
+		w.Frame(ops)
+	}
+	b.StopTimer()
+	if *dumpImages {
+		img, err := w.Screenshot()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := saveImage(b.Name()+".png", img); err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func BenchmarkTextPaths(b *testing.B) {
+	benchmarkText(b, false)
+}
+
+func BenchmarkTextAtlas(b *testing.B) {
+	benchmarkText(b, true)
+}
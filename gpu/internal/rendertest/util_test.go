@@ -59,9 +59,7 @@ func buildSquares(size int) paint.ImageOp {
 	return paint.NewImageOp(im)
 }
 
-func drawImage(t *testing.T, size int, ops *op.Ops, draw func(o *op.Ops)) (im *image.RGBA, err error) {
-	sz := image.Point{X: size, Y: size}
-	w := newWindow(t, sz.X, sz.Y)
+func drawImage(w *headless.Window, ops *op.Ops, draw func(o *op.Ops)) (im *image.RGBA, err error) {
 	draw(ops)
 	if err := w.Frame(ops); err != nil {
 		return nil, err
@@ -77,13 +75,14 @@ func run(t *testing.T, f func(o *op.Ops), c func(r result)) {
 	ops := new(op.Ops)
 	for i := 0; i < 3; i++ {
 		ops.Reset()
-		img, err = drawImage(t, 128, ops, f)
+		w := newWindow(t, 128, 128)
+		img, err = drawImage(w, ops, f)
 		if err != nil {
 			t.Error("error rendering:", err)
 			return
 		}
 		// Check for a reference image and make sure it is identical.
-		if !verifyRef(t, img, 0) {
+		if !verifyRef(t, w, img, 0) {
 			name := fmt.Sprintf("%s-%d-bad.png", t.Name(), i)
 			if err := saveImage(name, img); err != nil {
 				t.Error(err)
@@ -131,7 +130,7 @@ func multiRun(t *testing.T, frames ...frameT) {
 			continue
 		}
 		// Check for a reference image and make sure they are identical.
-		ok := verifyRef(t, img, i)
+		ok := verifyRef(t, w, img, i)
 		if frames[i].c != nil {
 			frames[i].c(result{t: t, img: img})
 		}
@@ -148,53 +147,135 @@ func multiRun(t *testing.T, frames ...frameT) {
 
 }
 
-func verifyRef(t *testing.T, img *image.RGBA, frame int) (ok bool) {
+func verifyRef(t *testing.T, w *headless.Window, img *image.RGBA, frame int) (ok bool) {
 	// ensure identical to ref data
-	path := filepath.Join("refs", t.Name()+".png")
+	dir := "refs"
+	if w.Software() {
+		// The software renderer antialiases differently than GPUs.
+		dir = filepath.Join("refs", "soft")
+	}
+	ref, ok := loadRef(t, refPath(dir, t.Name(), frame), img.Bounds())
+	if !ok {
+		return false
+	}
+	bnd := img.Bounds()
+	for x := bnd.Min.X; x < bnd.Max.X; x++ {
+		for y := bnd.Min.Y; y < bnd.Max.Y; y++ {
+			exp := ref.RGBAAt(x, y)
+			got := img.RGBAAt(x, y)
+			if !colorsClose(exp, got) {
+				t.Error("not equal to ref at", x, y, " ", got, exp)
+				return false
+			}
+		}
+	}
+	if w.Software() {
+		// The software references are rendered by the software
+		// renderer itself; check them against the GPU references.
+		return verifyGPURef(t, img, frame)
+	}
+	return true
+}
+
+// verifyGPURef compares the image of a software window with the GPU
+// reference image. The images may differ slightly along antialiased
+// edges.
+func verifyGPURef(t *testing.T, img *image.RGBA, frame int) bool {
+	const (
+		// edgeDelta is the largest channel difference of
+		// antialiased edges.
+		edgeDelta = 24
+		// maxEdges is the number of pixels allowed to differ
+		// by more than edgeDelta.
+		maxEdges = 16
+		// maxDelta is the largest channel difference of any pixel.
+		maxDelta = 64
+	)
+	ref, ok := loadRef(t, refPath("refs", t.Name(), frame), img.Bounds())
+	if !ok {
+		return false
+	}
+	edges := 0
+	bnd := img.Bounds()
+	for x := bnd.Min.X; x < bnd.Max.X; x++ {
+		for y := bnd.Min.Y; y < bnd.Max.Y; y++ {
+			exp := ref.RGBAAt(x, y)
+			got := img.RGBAAt(x, y)
+			d := channelDelta(exp, got)
+			if d > maxDelta {
+				t.Error("software rendering not close to GPU ref at", x, y, " ", got, exp)
+				return false
+			}
+			if d > edgeDelta {
+				edges++
+			}
+		}
+	}
+	if edges > maxEdges {
+		t.Errorf("software rendering differs from GPU ref in %d pixels, expected at most %d", edges, maxEdges)
+		return false
+	}
+	return true
+}
+
+// channelDelta returns the largest difference between the channels of
+// two colors, in linear color space.
+func channelDelta(c1, c2 color.RGBA) int {
+	l1 := f32color.LinearFromSRGB(f32color.RGBAToNRGBA(c1)).Array()
+	l2 := f32color.LinearFromSRGB(f32color.RGBAToNRGBA(c2)).Array()
+	var d float32
+	for i := range l1 {
+		v := l1[i] - l2[i]
+		if v < 0 {
+			v = -v
+		}
+		if v > d {
+			d = v
+		}
+	}
+	return int(d*255 + .5)
+}
+
+// refPath returns the path of the reference image in dir of a frame of
+// a test.
+func refPath(dir, name string, frame int) string {
 	if frame != 0 {
-		path = filepath.Join("refs", t.Name()+"_"+strconv.Itoa(frame)+".png")
+		return filepath.Join(dir, name+"_"+strconv.Itoa(frame)+".png")
 	}
+	return filepath.Join(dir, name+".png")
+}
+
+// loadRef loads a reference image with bounds bnd.
+func loadRef(t *testing.T, path string, bnd image.Rectangle) (*image.RGBA, bool) {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		t.Error("could not open ref:", err)
-		return
+		return nil, false
 	}
 	r, err := png.Decode(bytes.NewReader(b))
 	if err != nil {
 		t.Error("could not decode ref:", err)
-		return
+		return nil, false
 	}
-	if img.Bounds() != r.Bounds() {
-		t.Errorf("reference image is %v, expected %v", r.Bounds(), img.Bounds())
-		return false
+	if bnd != r.Bounds() {
+		t.Errorf("reference image is %v, expected %v", r.Bounds(), bnd)
+		return nil, false
 	}
-	var ref *image.RGBA
 	switch r := r.(type) {
 	case *image.RGBA:
-		ref = r
+		return r, true
 	case *image.NRGBA:
-		ref = image.NewRGBA(r.Bounds())
-		bnd := r.Bounds()
+		ref := image.NewRGBA(r.Bounds())
 		for x := bnd.Min.X; x < bnd.Max.X; x++ {
 			for y := bnd.Min.Y; y < bnd.Max.Y; y++ {
 				ref.SetRGBA(x, y, f32color.NRGBAToRGBA(r.NRGBAAt(x, y)))
 			}
 		}
+		return ref, true
 	default:
 		t.Fatalf("reference image is a %T, expected *image.NRGBA or *image.RGBA", r)
+		return nil, false
 	}
-	bnd := img.Bounds()
-	for x := bnd.Min.X; x < bnd.Max.X; x++ {
-		for y := bnd.Min.Y; y < bnd.Max.Y; y++ {
-			exp := ref.RGBAAt(x, y)
-			got := img.RGBAAt(x, y)
-			if !colorsClose(exp, got) {
-				t.Error("not equal to ref at", x, y, " ", got, exp)
-				return false
-			}
-		}
-	}
-	return true
 }
 
 func colorsClose(c1, c2 color.RGBA) bool {
@@ -282,6 +363,14 @@ func newWindow(t testing.TB, width, height int) *headless.Window {
 	return w
 }
 
+// requireGPU skips tests of GPU renderer features, such as its
+// caches and damage tracking, that the software renderer lacks.
+func requireGPU(t *testing.T) {
+	if newWindow(t, 1, 1).Software() {
+		t.Skip("no GPU available, skipping")
+	}
+}
+
 func scale(sx, sy float32) op.TransformOp {
 	return op.Affine(f32.Affine2D{}.Scale(f32.Point{}, f32.Pt(sx, sy)))
 }
@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package soft
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/internal/f32color"
+)
+
+type materialType uint8
+
+const (
+	materialColor materialType = iota
+	materialLinearGradient
+	materialTexture
+)
+
+// material is a paint source in linear premultiplied color space.
+type material struct {
+	material materialType
+	// For materialColor.
+	color f32color.RGBA
+	// For materialLinearGradient.
+	color1 f32color.RGBA
+	color2 f32color.RGBA
+	stop1  f32.Point
+	// dir is the gradient vector divided by its squared length.
+	dir f32.Point
+	// For materialTexture.
+	src *image.RGBA
+	// inv maps pixel coordinates to the coordinates of the paint
+	// operation.
+	inv f32.Affine2D
+}
+
+// linearLevels is the number of linear values in toSRGB.
+const linearLevels = 1 << 16
+
+var (
+	tablesOnce sync.Once
+	// fromSRGB maps sRGB values to linear values.
+	fromSRGB [256]float32
+	// toSRGB maps linear values scaled to 16 bits to sRGB values.
+	toSRGB [linearLevels]uint8
+)
+
+func initTables() {
+	tablesOnce.Do(func() {
+		for i := range fromSRGB {
+			fromSRGB[i] = f32color.LinearFromSRGB(color.NRGBA{R: uint8(i), A: 0xff}).R
+		}
+		for i := range toSRGB {
+			c := f32color.RGBA{R: float32(i) / (linearLevels - 1), A: 1}
+			toSRGB[i] = c.SRGB().R
+		}
+	})
+}
+
+func newMaterial(state drawState) material {
+	m := material{
+		material: state.matType,
+		inv:      state.t.Invert(),
+	}
+	switch state.matType {
+	case materialColor:
+		m.color = f32color.LinearFromSRGB(state.color)
+	case materialLinearGradient:
+		m.color1 = f32color.LinearFromSRGB(state.color1)
+		m.color2 = f32color.LinearFromSRGB(state.color2)
+		m.stop1 = state.stop1
+		d := state.stop2.Sub(state.stop1)
+		if l2 := d.X*d.X + d.Y*d.Y; l2 > 0 {
+			m.dir = d.Mul(1 / l2)
+		}
+	case materialTexture:
+		m.src = state.image
+	}
+	return m
+}
+
+// shade returns the color of the material at the pixel coordinates
+// p.
+func (m *material) shade(p f32.Point) f32color.RGBA {
+	switch m.material {
+	case materialLinearGradient:
+		d := m.inv.Transform(p).Sub(m.stop1)
+		t := d.X*m.dir.X + d.Y*m.dir.Y
+		switch {
+		case t < 0:
+			t = 0
+		case t > 1:
+			t = 1
+		}
+		return lerp(m.color1, m.color2, t)
+	case materialTexture:
+		return m.sample(m.inv.Transform(p))
+	default:
+		return m.color
+	}
+}
+
+// sample returns the bilinearly filtered color of the texture at p,
+// clamped to its edges.
+func (m *material) sample(p f32.Point) f32color.RGBA {
+	r := m.src.Rect
+	// Texel centers are at half-integer coordinates.
+	u := p.X - float32(r.Min.X) - .5
+	v := p.Y - float32(r.Min.Y) - .5
+	x0, y0 := math.Floor(float64(u)), math.Floor(float64(v))
+	fx, fy := u-float32(x0), v-float32(y0)
+	x, y := int(x0)+r.Min.X, int(y0)+r.Min.Y
+	c00, c10 := m.texel(x, y), m.texel(x+1, y)
+	c01, c11 := m.texel(x, y+1), m.texel(x+1, y+1)
+	return lerp(lerp(c00, c10, fx), lerp(c01, c11, fx), fy)
+}
+
+// texel returns the linear color of the texture pixel closest to
+// (x, y).
+func (m *material) texel(x, y int) f32color.RGBA {
+	r := m.src.Rect
+	switch {
+	case x < r.Min.X:
+		x = r.Min.X
+	case x >= r.Max.X:
+		x = r.Max.X - 1
+	}
+	switch {
+	case y < r.Min.Y:
+		y = r.Min.Y
+	case y >= r.Max.Y:
+		y = r.Max.Y - 1
+	}
+	i := m.src.PixOffset(x, y)
+	p := m.src.Pix[i : i+4]
+	return f32color.RGBA{
+		R: fromSRGB[p[0]],
+		G: fromSRGB[p[1]],
+		B: fromSRGB[p[2]],
+		A: float32(p[3]) / 0xff,
+	}
+}
+
+func lerp(c1, c2 f32color.RGBA, t float32) f32color.RGBA {
+	return f32color.RGBA{
+		R: c1.R + (c2.R-c1.R)*t,
+		G: c1.G + (c2.G-c1.G)*t,
+		B: c1.B + (c2.B-c1.B)*t,
+		A: c1.A + (c2.A-c1.A)*t,
+	}
+}
+
+// blend draws the color c with coverage cov over the sRGB pixel p.
+func blend(p []uint8, c f32color.RGBA, cov float32) {
+	inv := 1 - c.A*cov
+	p[0] = encodeSRGB(c.R*cov + fromSRGB[p[0]]*inv)
+	p[1] = encodeSRGB(c.G*cov + fromSRGB[p[1]]*inv)
+	p[2] = encodeSRGB(c.B*cov + fromSRGB[p[2]]*inv)
+	a := c.A*cov + float32(p[3])/0xff*inv
+	p[3] = uint8(a*0xff + .5)
+}
+
+func encodeSRGB(c float32) uint8 {
+	i := int(c*(linearLevels-1) + .5)
+	switch {
+	case i < 0:
+		i = 0
+	case i >= linearLevels:
+		i = linearLevels - 1
+	}
+	return toSRGB[i]
+}
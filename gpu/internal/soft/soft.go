@@ -0,0 +1,416 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+/*
+Package soft implements a software renderer for Gio drawing
+operations. It is used by package gpu/headless when no GPU is
+available.
+
+Operations are rasterized in the same color space as the GPU
+renderer: blending is done in linear space and the result is
+stored as sRGB with premultiplied alpha.
+*/
+package soft
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/vector"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/internal/opconst"
+	"github.com/cybriq/giocore/internal/ops"
+	"github.com/cybriq/giocore/internal/scene"
+	"github.com/cybriq/giocore/internal/stroke"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/op/clip"
+)
+
+// Renderer rasterizes operation lists to images. The zero value is
+// ready to use. A Renderer must not be used concurrently.
+type Renderer struct {
+	reader ops.Reader
+	states []drawState
+	rast   vector.Rasterizer
+	quads  []stroke.QuadSegment
+	dst    *image.RGBA
+}
+
+type drawState struct {
+	t f32.Affine2D
+	// clip bounds the area painted by paint operations.
+	clip f32.Rectangle
+	// mask is the coverage of the clip area, or nil if the clip
+	// area is a rectangle.
+	mask *image.Alpha
+
+	matType materialType
+	// Current paint.ColorOp, if any.
+	color color.NRGBA
+	// Current paint.LinearGradientOp.
+	stop1  f32.Point
+	stop2  f32.Point
+	color1 color.NRGBA
+	color2 color.NRGBA
+	// Current paint.ImageOp, if any.
+	image *image.RGBA
+}
+
+// flattenTolerance is the largest distance in pixels between curves
+// and the line segments they're rasterized as.
+const flattenTolerance = 1.0 / 64
+
+// Render draws the operations in frame onto dst. The operation
+// coordinates are the pixel coordinates of dst.
+func (r *Renderer) Render(dst *image.RGBA, frame *op.Ops) {
+	initTables()
+	r.dst = dst
+	r.reader.Reset(frame)
+	state := drawState{
+		clip:  frect(dst.Bounds()),
+		color: color.NRGBA{A: 0xff},
+	}
+	r.collect(state)
+	r.dst = nil
+	r.states = r.states[:0]
+}
+
+func (r *Renderer) save(id int, state drawState) {
+	if extra := id - len(r.states) + 1; extra > 0 {
+		r.states = append(r.states, make([]drawState, extra)...)
+	}
+	r.states[id] = state
+}
+
+func (r *Renderer) collect(state drawState) {
+	var (
+		path []byte
+		str  clip.StrokeStyle
+	)
+	r.save(opconst.InitialStateID, state)
+loop:
+	for encOp, ok := r.reader.Decode(); ok; encOp, ok = r.reader.Decode() {
+		switch opconst.OpType(encOp.Data[0]) {
+		case opconst.TypeTransform:
+			dop := ops.DecodeTransform(encOp.Data)
+			state.t = state.t.Mul(dop)
+		case opconst.TypeStroke:
+			str = clip.StrokeStyle{Width: ops.DecodeStroke(encOp.Data)}
+		case opconst.TypePath:
+			encOp, ok = r.reader.Decode()
+			if !ok {
+				break loop
+			}
+			path = encOp.Data[opconst.TypeAuxLen:]
+		case opconst.TypeClip:
+			bounds, outline := ops.DecodeClip(encOp.Data)
+			r.clip(&state, bounds, path, outline, str)
+			path = nil
+			str = clip.StrokeStyle{}
+		case opconst.TypeColor:
+			state.matType = materialColor
+			state.color = ops.DecodeColor(encOp.Data)
+		case opconst.TypeLinearGradient:
+			state.matType = materialLinearGradient
+			g := ops.DecodeLinearGradient(encOp.Data)
+			state.stop1, state.stop2 = g.Stop1, g.Stop2
+			state.color1, state.color2 = g.Color1, g.Color2
+		case opconst.TypeImage:
+			state.matType = materialTexture
+			state.image, _ = ops.DecodeImage(encOp.Data, encOp.Refs)
+		case opconst.TypePaint:
+			r.paint(state)
+		case opconst.TypeMask:
+			r.paintMask(state, ops.DecodeMask(encOp.Data, encOp.Refs))
+		case opconst.TypeSave:
+			id := ops.DecodeSave(encOp.Data)
+			r.save(id, state)
+		case opconst.TypeLoad:
+			id, mask := ops.DecodeLoad(encOp.Data)
+			s := r.states[id]
+			if mask&opconst.TransformState != 0 {
+				state.t = s.t
+			}
+			if mask&^opconst.TransformState != 0 {
+				state = s
+			}
+		}
+	}
+}
+
+// clip intersects the clip area of state with the clip rectangle
+// bounds, or with the path if present.
+func (r *Renderer) clip(state *drawState, bounds f32.Rectangle, path []byte, outline bool, str clip.StrokeStyle) {
+	r.quads = r.quads[:0]
+	switch {
+	case len(path) == 0:
+		if isPureOffset(state.t) {
+			_, _, ox, _, _, oy := state.t.Elems()
+			state.clip = state.clip.Intersect(bounds.Add(f32.Pt(ox, oy)))
+			return
+		}
+		r.appendRect(bounds, state.t)
+	case str.Width > 0:
+		ss := stroke.StrokeStyle{
+			Width: str.Width,
+			Miter: str.Miter,
+			Cap:   stroke.StrokeCap(str.Cap),
+			Join:  stroke.StrokeJoin(str.Join),
+		}
+		for _, q := range stroke.StrokePathCommands(ss, stroke.DashOp{}, path) {
+			r.quads = append(r.quads, q.Quad.Transform(state.t))
+		}
+	case outline:
+		r.appendOutline(path, state.t)
+	}
+	state.clip = state.clip.Intersect(quadBounds(r.quads))
+	state.mask = r.coverage(state.mask, state.clip)
+}
+
+// appendOutline appends the outline of the path data, transformed by
+// t, to the quads of r.
+func (r *Renderer) appendOutline(path []byte, t f32.Affine2D) {
+	for len(path) >= scene.CommandSize+4 {
+		cmd := ops.DecodeCommand(path[4:])
+		switch cmd.Op() {
+		case scene.OpLine:
+			var q stroke.QuadSegment
+			q.From, q.To = scene.DecodeLine(cmd)
+			q.Ctrl = q.From.Add(q.To).Mul(.5)
+			r.quads = append(r.quads, q.Transform(t))
+		case scene.OpQuad:
+			var q stroke.QuadSegment
+			q.From, q.Ctrl, q.To = scene.DecodeQuad(cmd)
+			r.quads = append(r.quads, q.Transform(t))
+		case scene.OpCubic:
+			for _, q := range stroke.SplitCubic(scene.DecodeCubic(cmd)) {
+				r.quads = append(r.quads, q.Transform(t))
+			}
+		default:
+			panic("unsupported scene command")
+		}
+		path = path[scene.CommandSize+4:]
+	}
+}
+
+// appendRect appends the outline of rect, transformed by t, to the
+// quads of r.
+func (r *Renderer) appendRect(rect f32.Rectangle, t f32.Affine2D) {
+	corners := [4]f32.Point{
+		t.Transform(rect.Min), t.Transform(f32.Pt(rect.Max.X, rect.Min.Y)),
+		t.Transform(rect.Max), t.Transform(f32.Pt(rect.Min.X, rect.Max.Y)),
+	}
+	for i, from := range corners {
+		to := corners[(i+1)%len(corners)]
+		r.quads = append(r.quads, stroke.QuadSegment{
+			From: from,
+			Ctrl: from.Add(to).Mul(.5),
+			To:   to,
+		})
+	}
+}
+
+// coverage rasterizes the quads of r inside the clip rectangle and
+// multiplies the result with the parent coverage, if any.
+func (r *Renderer) coverage(parent *image.Alpha, clip f32.Rectangle) *image.Alpha {
+	b := boundRectF(clip).Intersect(r.dst.Bounds())
+	if b.Empty() {
+		return nil
+	}
+	mask := image.NewAlpha(b)
+	r.rast.Reset(b.Dx(), b.Dy())
+	r.rast.DrawOp = draw.Src
+	o := fpt(b.Min)
+	pen := f32.Pt(float32(math.NaN()), 0)
+	for _, q := range r.quads {
+		from, ctrl, to := q.From.Sub(o), q.Ctrl.Sub(o), q.To.Sub(o)
+		// Unconnected segments are allowed; paths are implicitly
+		// closed by the accumulation of coverage.
+		if from != pen {
+			r.rast.MoveTo(from.X, from.Y)
+		}
+		r.flatten(from, ctrl, to)
+		pen = to
+	}
+	r.rast.Draw(mask, b, image.Opaque, image.Point{})
+	if parent != nil {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := mask.Pix[mask.PixOffset(b.Min.X, y):mask.PixOffset(b.Max.X, y)]
+			prow := parent.Pix[parent.PixOffset(b.Min.X, y):]
+			for i, a := range row {
+				row[i] = uint8((uint32(a)*uint32(prow[i]) + 0x7f) / 0xff)
+			}
+		}
+	}
+	return mask
+}
+
+// flatten adds line segments approximating a quadratic curve to the
+// rasterizer. The flattening of vector.Rasterizer.QuadTo is too coarse
+// to match the coverage of the GPU renderers.
+func (r *Renderer) flatten(from, ctrl, to f32.Point) {
+	d := from.Sub(ctrl.Mul(2)).Add(to)
+	dev := math.Hypot(float64(d.X), float64(d.Y))
+	// The distance between a curve and a chord of n segments is at
+	// most dev/(4n²).
+	n := int(math.Ceil(math.Sqrt(dev / (4 * flattenTolerance))))
+	if n < 1 {
+		n = 1
+	}
+	for i := 1; i < n; i++ {
+		t := float32(i) / float32(n)
+		u := 1 - t
+		p := from.Mul(u * u).Add(ctrl.Mul(2 * u * t)).Add(to.Mul(t * t))
+		r.rast.LineTo(p.X, p.Y)
+	}
+	r.rast.LineTo(to.X, to.Y)
+}
+
+// paint fills the clip area of state with its material.
+func (r *Renderer) paint(state drawState) {
+	mat := newMaterial(state)
+	cl := state.clip
+	mask := state.mask
+	if state.matType == materialTexture {
+		if state.image == nil {
+			return
+		}
+		// Images are bounded by their rectangle.
+		dst := frect(state.image.Rect)
+		if isPureOffset(state.t) {
+			_, _, ox, _, _, oy := state.t.Elems()
+			cl = cl.Intersect(dst.Add(f32.Pt(ox, oy)))
+		} else {
+			r.quads = r.quads[:0]
+			r.appendRect(dst, state.t)
+			cl = cl.Intersect(quadBounds(r.quads))
+			mask = r.coverage(mask, cl)
+		}
+	}
+	b := boundRectF(cl).Intersect(r.dst.Bounds())
+	if b.Empty() {
+		return
+	}
+	r.fill(b, mask, &mat)
+}
+
+// paintMask fills the area of a mask with its color, weighted by the
+// mask values.
+func (r *Renderer) paintMask(state drawState, m ops.Mask) {
+	if !isPureOffset(state.t) {
+		// Paint the mask as an image.
+		state.matType = materialTexture
+		state.image = m.Tint()
+		r.paint(state)
+		return
+	}
+	// Place the mask on whole pixels.
+	_, _, ox, _, _, oy := state.t.Elems()
+	off := image.Pt(int(math.Round(float64(ox))), int(math.Round(float64(oy))))
+	dst := image.Rectangle{Max: m.Rect.Size()}.Add(off)
+	b := boundRectF(state.clip).Intersect(dst).Intersect(r.dst.Bounds())
+	if b.Empty() {
+		return
+	}
+	mask := image.NewAlpha(b)
+	src := m.Rect.Min.Sub(off)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := mask.Pix[mask.PixOffset(b.Min.X, y):mask.PixOffset(b.Max.X, y)]
+		copy(row, m.Image.Pix[m.Image.PixOffset(b.Min.X+src.X, y+src.Y):])
+		if state.mask != nil {
+			prow := state.mask.Pix[state.mask.PixOffset(b.Min.X, y):]
+			for i, a := range row {
+				row[i] = uint8((uint32(a)*uint32(prow[i]) + 0x7f) / 0xff)
+			}
+		}
+	}
+	state.matType = materialColor
+	state.color = m.Color
+	mat := newMaterial(state)
+	r.fill(b, mask, &mat)
+}
+
+// fill blends the material over dst inside b, scaled by the coverage
+// in mask.
+func (r *Renderer) fill(b image.Rectangle, mask *image.Alpha, mat *material) {
+	dst := r.dst
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := dst.Pix[dst.PixOffset(b.Min.X, y):dst.PixOffset(b.Max.X, y)]
+		var mrow []uint8
+		if mask != nil {
+			mrow = mask.Pix[mask.PixOffset(b.Min.X, y):]
+		}
+		for i := 0; i < b.Dx(); i++ {
+			cov := float32(1)
+			if mrow != nil {
+				a := mrow[i]
+				if a == 0 {
+					continue
+				}
+				cov = float32(a) / 0xff
+			}
+			// Sample at the pixel center.
+			c := mat.shade(f32.Pt(float32(b.Min.X+i)+.5, float32(y)+.5))
+			blend(row[i*4:i*4+4], c, cov)
+		}
+	}
+}
+
+func isPureOffset(t f32.Affine2D) bool {
+	a, b, _, d, e, _ := t.Elems()
+	return a == 1 && b == 0 && d == 0 && e == 1
+}
+
+// quadBounds returns the bounds of the control points of quads.
+func quadBounds(quads []stroke.QuadSegment) f32.Rectangle {
+	if len(quads) == 0 {
+		return f32.Rectangle{}
+	}
+	b := f32.Rectangle{Min: quads[0].From, Max: quads[0].From}
+	for _, q := range quads {
+		for _, p := range [...]f32.Point{q.From, q.Ctrl, q.To} {
+			b.Min.X = min(b.Min.X, p.X)
+			b.Min.Y = min(b.Min.Y, p.Y)
+			b.Max.X = max(b.Max.X, p.X)
+			b.Max.Y = max(b.Max.Y, p.Y)
+		}
+	}
+	return b
+}
+
+func min(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func fpt(p image.Point) f32.Point {
+	return f32.Point{X: float32(p.X), Y: float32(p.Y)}
+}
+
+func frect(r image.Rectangle) f32.Rectangle {
+	return f32.Rectangle{Min: fpt(r.Min), Max: fpt(r.Max)}
+}
+
+func boundRectF(r f32.Rectangle) image.Rectangle {
+	return image.Rectangle{
+		Min: image.Point{
+			X: int(math.Floor(float64(r.Min.X))),
+			Y: int(math.Floor(float64(r.Min.Y))),
+		},
+		Max: image.Point{
+			X: int(math.Ceil(float64(r.Max.X))),
+			Y: int(math.Ceil(float64(r.Max.Y))),
+		},
+	}
+}
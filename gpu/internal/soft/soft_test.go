@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package soft
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/internal/f32color"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/op/clip"
+	"github.com/cybriq/giocore/op/paint"
+)
+
+var (
+	red  = color.NRGBA{R: 0xff, A: 0xff}
+	blue = color.NRGBA{B: 0xff, A: 0xff}
+)
+
+func render(ops *op.Ops, w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	new(Renderer).Render(img, ops)
+	return img
+}
+
+func expect(t *testing.T, img *image.RGBA, x, y int, col color.NRGBA) {
+	t.Helper()
+	if got, exp := img.RGBAAt(x, y), f32color.NRGBAToRGBA(col); got != exp {
+		t.Errorf("(%d,%d): got color %v, expected %v", x, y, got, exp)
+	}
+}
+
+func TestFill(t *testing.T) {
+	var ops op.Ops
+	paint.FillShape(&ops, blue, clip.Rect(image.Rect(0, 0, 50, 100)).Op())
+	paint.FillShape(&ops, red, clip.Rect(image.Rect(0, 0, 100, 50)).Op())
+	img := render(&ops, 128, 128)
+	expect(t, img, 25, 25, red)
+	expect(t, img, 75, 25, red)
+	expect(t, img, 25, 75, blue)
+	expect(t, img, 75, 75, color.NRGBA{})
+}
+
+func TestBlend(t *testing.T) {
+	var ops op.Ops
+	paint.Fill(&ops, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+	paint.FillShape(&ops, color.NRGBA{A: 0x80}, clip.Rect(image.Rect(0, 0, 10, 10)).Op())
+	paint.FillShape(&ops, color.NRGBA{R: 0xff, A: 0x80}, clip.Rect(image.Rect(10, 0, 20, 10)).Op())
+	img := render(&ops, 20, 10)
+	// Blending is done in linear color space.
+	const v = 1 - float32(0x80)/0xff
+	gray := f32color.RGBA{R: v, G: v, B: v, A: 1}.SRGB()
+	expect(t, img, 5, 5, gray)
+	expect(t, img, 15, 5, color.NRGBA{R: 0xff, G: gray.G, B: gray.B, A: 0xff})
+
+	ops.Reset()
+	paint.Fill(&ops, color.NRGBA{R: 0xff, A: 0x80})
+	img = render(&ops, 1, 1)
+	expect(t, img, 0, 0, color.NRGBA{R: 0xff, A: 0x80})
+}
+
+func TestClipPath(t *testing.T) {
+	col := color.NRGBA{A: 0xff, R: 0xca, G: 0xfe}
+	col2 := color.NRGBA{A: 0xff, G: 0xfe}
+	var ops op.Ops
+	paint.ColorOp{Color: col}.Add(&ops)
+	clip.RRect{
+		Rect: f32.Rect(50, 50, 250, 250),
+		SE:   75,
+	}.Add(&ops)
+	paint.PaintOp{}.Add(&ops)
+	paint.ColorOp{Color: col2}.Add(&ops)
+	clip.RRect{
+		Rect: f32.Rect(100, 100, 350, 350),
+		NW:   75,
+	}.Add(&ops)
+	paint.PaintOp{}.Add(&ops)
+	img := render(&ops, 400, 400)
+	expect(t, img, 120, 120, col)
+	expect(t, img, 130, 130, col2)
+	expect(t, img, 210, 210, col2)
+	expect(t, img, 230, 230, color.NRGBA{})
+	expect(t, img, 40, 40, color.NRGBA{})
+	// Anti-aliased corner.
+	if a := img.RGBAAt(245, 200).A; a == 0 || a == 0xff {
+		t.Errorf("got alpha %d at the edge, expected partial coverage", a)
+	}
+}
+
+func TestNestedClip(t *testing.T) {
+	var ops op.Ops
+	st := op.Save(&ops)
+	clip.Rect(image.Rect(0, 0, 60, 60)).Add(&ops)
+	clip.Circle{Center: f32.Pt(50, 50), Radius: 50}.Add(&ops)
+	paint.ColorOp{Color: red}.Add(&ops)
+	paint.PaintOp{}.Add(&ops)
+	st.Load()
+	paint.FillShape(&ops, blue, clip.Rect(image.Rect(90, 90, 100, 100)).Op())
+	img := render(&ops, 100, 100)
+	expect(t, img, 50, 50, red)
+	expect(t, img, 70, 50, color.NRGBA{})
+	expect(t, img, 2, 2, color.NRGBA{})
+	expect(t, img, 95, 95, blue)
+}
+
+func TestStroke(t *testing.T) {
+	var ops op.Ops
+	var p clip.Path
+	p.Begin(&ops)
+	p.MoveTo(f32.Pt(10, 50))
+	p.LineTo(f32.Pt(90, 50))
+	paint.FillShape(&ops, red, clip.Stroke{
+		Path:  p.End(),
+		Style: clip.StrokeStyle{Width: 10},
+	}.Op())
+	img := render(&ops, 100, 100)
+	expect(t, img, 50, 46, red)
+	expect(t, img, 50, 53, red)
+	expect(t, img, 50, 40, color.NRGBA{})
+	// Strokes have round caps by default.
+	expect(t, img, 7, 50, red)
+	expect(t, img, 3, 50, color.NRGBA{})
+}
+
+func TestTransformedClip(t *testing.T) {
+	var ops op.Ops
+	op.Affine(f32.Affine2D{}.Rotate(f32.Pt(50, 50), math.Pi/4)).Add(&ops)
+	paint.FillShape(&ops, red, clip.Rect(image.Rect(30, 30, 70, 70)).Op())
+	img := render(&ops, 100, 100)
+	expect(t, img, 50, 50, red)
+	// The corners of the rectangle are rotated away.
+	expect(t, img, 32, 32, color.NRGBA{})
+	expect(t, img, 50, 25, red)
+}
+
+func TestLinearGradient(t *testing.T) {
+	var ops op.Ops
+	paint.LinearGradientOp{
+		Stop1:  f32.Pt(10, 0),
+		Color1: red,
+		Stop2:  f32.Pt(90, 0),
+		Color2: blue,
+	}.Add(&ops)
+	paint.PaintOp{}.Add(&ops)
+	img := render(&ops, 100, 10)
+	expect(t, img, 5, 5, red)
+	expect(t, img, 95, 5, blue)
+	// The gradient is interpolated in linear color space at
+	// pixel centers.
+	const x = 49.5
+	mid := f32color.RGBA{R: (90 - x) / 80, B: (x - 10) / 80, A: 1}
+	expect(t, img, 49, 5, mid.SRGB())
+}
+
+func TestImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.SetRGBA(0, 0, f32color.NRGBAToRGBA(red))
+	src.SetRGBA(1, 1, f32color.NRGBAToRGBA(blue))
+	var ops op.Ops
+	op.Offset(f32.Pt(10, 10)).Add(&ops)
+	paint.NewImageOp(src).Add(&ops)
+	paint.PaintOp{}.Add(&ops)
+	img := render(&ops, 20, 20)
+	expect(t, img, 10, 10, red)
+	expect(t, img, 11, 11, blue)
+	expect(t, img, 11, 10, color.NRGBA{})
+	expect(t, img, 12, 12, color.NRGBA{})
+
+	ops.Reset()
+	op.Affine(f32.Affine2D{}.Scale(f32.Point{}, f32.Pt(10, 10))).Add(&ops)
+	paint.NewImageOp(src).Add(&ops)
+	paint.PaintOp{}.Add(&ops)
+	img = render(&ops, 20, 20)
+	expect(t, img, 2, 2, red)
+	expect(t, img, 17, 17, blue)
+	// Scaled images are filtered.
+	if c := img.RGBAAt(10, 10); c.R == 0 || c.B == 0 {
+		t.Errorf("got %v, expected filtered color", c)
+	}
+}
+
+func TestMask(t *testing.T) {
+	m := paint.NewMask(image.NewAlpha(image.Rect(0, 0, 4, 4)))
+	m.Image().SetAlpha(1, 1, color.Alpha{A: 0xff})
+	m.Image().SetAlpha(2, 1, color.Alpha{A: 0x80})
+	m.Image().SetAlpha(3, 3, color.Alpha{A: 0xff})
+	var ops op.Ops
+	// Masks are placed on whole pixels.
+	op.Offset(f32.Pt(10.4, 5.6)).Add(&ops)
+	paint.MaskOp{Mask: m, Rect: image.Rect(1, 1, 3, 3), Color: red}.Add(&ops)
+	img := render(&ops, 20, 20)
+	expect(t, img, 10, 6, red)
+	expect(t, img, 11, 6, color.NRGBA{R: 0xff, A: 0x80})
+	expect(t, img, 10, 7, color.NRGBA{})
+	// Pixels outside Rect are not painted.
+	expect(t, img, 12, 8, color.NRGBA{})
+
+	// Clipped masks.
+	ops.Reset()
+	clip.Rect(image.Rect(0, 0, 11, 20)).Add(&ops)
+	op.Offset(f32.Pt(10, 6)).Add(&ops)
+	paint.MaskOp{Mask: m, Rect: image.Rect(1, 1, 3, 3), Color: red}.Add(&ops)
+	img = render(&ops, 20, 20)
+	expect(t, img, 10, 6, red)
+	expect(t, img, 11, 6, color.NRGBA{})
+
+	// Transformed masks are painted as images.
+	ops.Reset()
+	op.Affine(f32.Affine2D{}.Scale(f32.Point{}, f32.Pt(10, 10))).Add(&ops)
+	paint.MaskOp{Mask: m, Rect: image.Rect(1, 1, 3, 3), Color: blue}.Add(&ops)
+	img = render(&ops, 20, 20)
+	expect(t, img, 2, 2, blue)
+	expect(t, img, 17, 17, color.NRGBA{})
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package gpu
+
+import (
+	"image"
+	"math"
+
+	"gioui.org/gpu/internal/driver"
+	"gioui.org/internal/f32color"
+	"gioui.org/internal/ops"
+	"gioui.org/layout"
+)
+
+// maskTexture is the texture of a paint.Mask. The red channel of a
+// texel is the sRGB encoding of the mask value, so that it decodes to
+// the mask value when sampled.
+type maskTexture struct {
+	tex     driver.Texture
+	size    image.Point
+	version int
+	// pix is the converted mask for uploading.
+	pix []byte
+}
+
+// coverageSRGB maps mask values to their sRGB encoding.
+var coverageSRGB [256]byte
+
+func init() {
+	for i := range coverageSRGB {
+		c := f32color.RGBA{R: float32(i) / 0xff, A: 1}
+		coverageSRGB[i] = c.SRGB().R
+	}
+}
+
+// collectMask adds an operation for filling the area of the mask m
+// with its color, weighted by the mask values. The transform of state
+// must be a pure offset, which is rounded to whole pixels.
+func (d *drawOps) collectMask(m ops.Mask, state drawState) {
+	_, _, ox, _, _, oy := state.t.Elems()
+	pos := image.Pt(int(math.Round(float64(ox))), int(math.Round(float64(oy))))
+	dst := image.Rectangle{Min: pos, Max: pos.Add(m.Rect.Size())}
+	cl := state.clip.Intersect(layout.FRect(dst))
+	if cl.Empty() {
+		return
+	}
+	tex := d.maskTexture(m)
+	if tex == nil {
+		return
+	}
+	bounds := boundRectF(cl)
+	p := d.newPathOp()
+	*p = pathOp{
+		parent: state.cpath,
+		clip:   bounds,
+		off:    layout.FPt(dst.Min),
+		mask:   tex,
+		place:  placement{Pos: bounds.Min.Sub(dst.Min).Add(m.Rect.Min.Sub(m.Image.Rect.Min))},
+		hash:   maskHash(m),
+	}
+	d.imageOps = append(d.imageOps, imageOp{
+		path: p,
+		clip: bounds,
+		material: material{
+			material: materialColor,
+			color:    f32color.LinearFromSRGB(m.Color),
+		},
+	})
+}
+
+// maskTexture returns the texture of the mask m, uploading the mask
+// if it changed since its last upload. It returns nil if the texture
+// can't be created.
+func (d *drawOps) maskTexture(m ops.Mask) *maskTexture {
+	var t *maskTexture
+	if v, exists := d.cache.get(m.Version); exists {
+		t = v.(*maskTexture)
+	} else {
+		sz := m.Image.Rect.Size()
+		tex, err := d.ctx.NewTexture(driver.TextureFormatSRGBA, sz.X, sz.Y, driver.FilterNearest, driver.FilterNearest, driver.BufferBindingTexture)
+		if err != nil {
+			return nil
+		}
+		t = &maskTexture{tex: tex, size: sz, version: *m.Version - 1}
+		d.cache.put(m.Version, t)
+	}
+	if t.version != *m.Version {
+		t.upload(m.Image)
+		t.version = *m.Version
+	}
+	return t
+}
+
+// upload replaces the contents of the texture with the mask img.
+func (t *maskTexture) upload(img *image.Alpha) {
+	n := t.size.X * t.size.Y * 4
+	if cap(t.pix) < n {
+		t.pix = make([]byte, n)
+	}
+	t.pix = t.pix[:n]
+	for y := 0; y < t.size.Y; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+t.size.X]
+		dst := t.pix[y*t.size.X*4:]
+		for x, a := range row {
+			dst[x*4] = coverageSRGB[a]
+			dst[x*4+3] = 0xff
+		}
+	}
+	t.tex.Upload(image.Point{}, t.size, t.pix, t.size.X*4)
+}
+
+func (t *maskTexture) release() {
+	t.tex.Release()
+}
+
+// maskImage returns the image of the mask m filled with its color,
+// for painting masks that are not placed at an offset.
+func (d *drawOps) maskImage(m ops.Mask) imageOpData {
+	k := m.Key()
+	if v, exists := d.cache.res[k]; exists {
+		return imageOpData{src: v.(*texture).src, handle: k}
+	}
+	src := m.Tint()
+	d.cache.put(k, &texture{src: src})
+	return imageOpData{src: src, handle: k}
+}
@@ -0,0 +1,641 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package bidi implements the Unicode Bidirectional Algorithm, UAX #9.
+//
+// Levels resolves the embedding levels of a paragraph of text in logical
+// order and Runs reorders the levels of a line of the paragraph into
+// directional runs in visual order.
+package bidi
+
+// Level is an embedding level. Odd levels are right-to-left, even
+// levels left-to-right.
+type Level uint8
+
+// Auto requests the paragraph level be determined from the text.
+const Auto Level = 0xff
+
+// maxDepth is the maximum explicit embedding level.
+const maxDepth = 125
+
+// Run is a sequence of runes of a line at the same level.
+type Run struct {
+	// Start and End are the indices of the run in the line.
+	Start, End int
+	Level      Level
+}
+
+// RTL reports whether the level is right-to-left.
+func (l Level) RTL() bool {
+	return l&1 == 1
+}
+
+// direction returns the strong class of the level direction.
+func (l Level) direction() Class {
+	if l.RTL() {
+		return R
+	}
+	return L
+}
+
+// paragraph is the state of the resolution of a paragraph.
+type paragraph struct {
+	runes   []rune
+	initial []Class
+	classes []Class
+	levels  []Level
+	base    Level
+	// matches maps isolate initiators to the index of their
+	// matching PDI, or -1 if there is none.
+	matches map[int]int
+}
+
+// Levels resolves the embedding levels of a paragraph. If base is
+// Auto, the paragraph level is determined by the first strong rune
+// of the text. Levels returns the level of every rune along with
+// the paragraph level.
+func Levels(runes []rune, base Level) ([]Level, Level) {
+	p := &paragraph{
+		runes:   runes,
+		initial: make([]Class, len(runes)),
+		classes: make([]Class, len(runes)),
+		levels:  make([]Level, len(runes)),
+		matches: make(map[int]int),
+	}
+	for i, r := range runes {
+		c := ClassOf(r)
+		p.initial[i] = c
+		p.classes[i] = c
+	}
+	p.matchIsolates()
+	if base == Auto {
+		base = 0
+		if p.firstStrong(0, len(runes)) == R {
+			base = 1
+		}
+	}
+	p.base = base
+	p.explicit()
+	for _, seq := range p.sequences() {
+		p.resolveWeak(seq)
+		p.resolveBrackets(seq)
+		p.resolveNeutral(seq)
+	}
+	p.resolveImplicit()
+	return p.levels, p.base
+}
+
+// matchIsolates finds the matching PDI of every isolate
+// initiator (BD9).
+func (p *paragraph) matchIsolates() {
+	var open []int
+	for i, c := range p.initial {
+		switch c {
+		case LRI, RLI, FSI:
+			open = append(open, i)
+			p.matches[i] = -1
+		case PDI:
+			if n := len(open); n > 0 {
+				p.matches[open[n-1]] = i
+				open = open[:n-1]
+			}
+		case B:
+			open = open[:0]
+		}
+	}
+}
+
+// firstStrong returns the class of the first strong rune between
+// start and end, skipping isolates. It returns ON if there is no
+// strong rune (P2).
+func (p *paragraph) firstStrong(start, end int) Class {
+	for i := start; i < end; i++ {
+		switch c := p.initial[i]; c {
+		case L:
+			return L
+		case R, AL:
+			return R
+		case LRI, RLI, FSI:
+			m := p.matches[i]
+			if m == -1 {
+				return ON
+			}
+			i = m
+		case B:
+			return ON
+		}
+	}
+	return ON
+}
+
+// isRemoved reports whether a class is removed by rule X9.
+func isRemoved(c Class) bool {
+	switch c {
+	case RLE, LRE, RLO, LRO, PDF, BN:
+		return true
+	}
+	return false
+}
+
+func isIsolateInitiator(c Class) bool {
+	return c == LRI || c == RLI || c == FSI
+}
+
+// explicit resolves the explicit levels and directions (X1-X8).
+func (p *paragraph) explicit() {
+	type status struct {
+		level    Level
+		override Class
+		isolate  bool
+	}
+	stack := []status{{level: p.base, override: ON}}
+	overflowIsolates, overflowEmbeddings, validIsolates := 0, 0, 0
+	for i, c := range p.initial {
+		top := stack[len(stack)-1]
+		switch c {
+		case RLE, LRE, RLO, LRO:
+			p.levels[i] = top.level
+			var level Level
+			if c == RLE || c == RLO {
+				level = (top.level + 1) | 1
+			} else {
+				level = (top.level + 2) &^ 1
+			}
+			override := ON
+			switch c {
+			case RLO:
+				override = R
+			case LRO:
+				override = L
+			}
+			if level <= maxDepth && overflowIsolates == 0 && overflowEmbeddings == 0 {
+				stack = append(stack, status{level: level, override: override})
+			} else if overflowIsolates == 0 {
+				overflowEmbeddings++
+			}
+		case RLI, LRI, FSI:
+			p.levels[i] = top.level
+			if top.override != ON {
+				p.classes[i] = top.override
+			}
+			rtl := c == RLI
+			if c == FSI {
+				end := p.matches[i]
+				if end == -1 {
+					end = len(p.runes)
+				}
+				rtl = p.firstStrong(i+1, end) == R
+			}
+			var level Level
+			if rtl {
+				level = (top.level + 1) | 1
+			} else {
+				level = (top.level + 2) &^ 1
+			}
+			if level <= maxDepth && overflowIsolates == 0 && overflowEmbeddings == 0 {
+				validIsolates++
+				stack = append(stack, status{level: level, override: ON, isolate: true})
+			} else {
+				overflowIsolates++
+			}
+		case PDI:
+			switch {
+			case overflowIsolates > 0:
+				overflowIsolates--
+			case validIsolates == 0:
+			default:
+				overflowEmbeddings = 0
+				for !stack[len(stack)-1].isolate {
+					stack = stack[:len(stack)-1]
+				}
+				stack = stack[:len(stack)-1]
+				validIsolates--
+			}
+			top = stack[len(stack)-1]
+			p.levels[i] = top.level
+			if top.override != ON {
+				p.classes[i] = top.override
+			}
+		case PDF:
+			p.levels[i] = top.level
+			switch {
+			case overflowIsolates > 0:
+			case overflowEmbeddings > 0:
+				overflowEmbeddings--
+			case !top.isolate && len(stack) >= 2:
+				stack = stack[:len(stack)-1]
+			}
+		case B:
+			p.levels[i] = p.base
+		case BN:
+			p.levels[i] = top.level
+		default:
+			p.levels[i] = top.level
+			if top.override != ON {
+				p.classes[i] = top.override
+			}
+		}
+	}
+}
+
+// sequence is an isolating run sequence: the indices of its runes
+// along with the classes of its boundaries.
+type sequence struct {
+	indices  []int
+	level    Level
+	sos, eos Class
+}
+
+// sequences computes the isolating run sequences of the paragraph
+// (BD13, X10).
+func (p *paragraph) sequences() []*sequence {
+	// Compute the level runs, ignoring removed runes.
+	var runs [][]int
+	var run []int
+	for i, c := range p.initial {
+		if isRemoved(c) {
+			continue
+		}
+		if len(run) > 0 && p.levels[run[0]] != p.levels[i] {
+			runs = append(runs, run)
+			run = nil
+		}
+		run = append(run, i)
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	// runOf maps the first index of runs starting with a
+	// matched PDI to their run.
+	runOf := make(map[int]int)
+	matched := make(map[int]bool)
+	for _, m := range p.matches {
+		if m != -1 {
+			matched[m] = true
+		}
+	}
+	for i, r := range runs {
+		if matched[r[0]] {
+			runOf[r[0]] = i
+		}
+	}
+	var seqs []*sequence
+	for _, r := range runs {
+		if matched[r[0]] {
+			// Part of the sequence of its isolate initiator.
+			continue
+		}
+		seq := &sequence{level: p.levels[r[0]]}
+		for {
+			seq.indices = append(seq.indices, r...)
+			last := r[len(r)-1]
+			if !isIsolateInitiator(p.initial[last]) {
+				break
+			}
+			m := p.matches[last]
+			ri, ok := runOf[m]
+			if m == -1 || !ok {
+				break
+			}
+			r = runs[ri]
+		}
+		seqs = append(seqs, seq)
+	}
+	for _, seq := range seqs {
+		first, last := seq.indices[0], seq.indices[len(seq.indices)-1]
+		prev := p.base
+		for i := first - 1; i >= 0; i-- {
+			if !isRemoved(p.initial[i]) {
+				prev = p.levels[i]
+				break
+			}
+		}
+		next := p.base
+		if !isIsolateInitiator(p.initial[last]) || p.matches[last] != -1 {
+			for i := last + 1; i < len(p.initial); i++ {
+				if !isRemoved(p.initial[i]) {
+					next = p.levels[i]
+					break
+				}
+			}
+		}
+		seq.sos = maxLevel(prev, seq.level).direction()
+		seq.eos = maxLevel(next, seq.level).direction()
+	}
+	return seqs
+}
+
+func maxLevel(a, b Level) Level {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// resolveWeak resolves the weak types of a sequence (W1-W7).
+func (p *paragraph) resolveWeak(seq *sequence) {
+	cls := p.classes
+	idx := seq.indices
+	// W1.
+	prev := seq.sos
+	for _, i := range idx {
+		if cls[i] == NSM {
+			if isIsolateInitiator(prev) || prev == PDI {
+				cls[i] = ON
+			} else {
+				cls[i] = prev
+			}
+		}
+		prev = cls[i]
+	}
+	// W2 and W3.
+	strong := seq.sos
+	for _, i := range idx {
+		switch cls[i] {
+		case L, R:
+			strong = cls[i]
+		case AL:
+			strong = AL
+			cls[i] = R
+		case EN:
+			if strong == AL {
+				cls[i] = AN
+			}
+		}
+	}
+	// W4.
+	for k := 1; k+1 < len(idx); k++ {
+		c, before, after := cls[idx[k]], cls[idx[k-1]], cls[idx[k+1]]
+		switch {
+		case c == ES && before == EN && after == EN:
+			cls[idx[k]] = EN
+		case c == CS && before == EN && after == EN:
+			cls[idx[k]] = EN
+		case c == CS && before == AN && after == AN:
+			cls[idx[k]] = AN
+		}
+	}
+	// W5.
+	for k := 0; k < len(idx); k++ {
+		if cls[idx[k]] != ET {
+			continue
+		}
+		end := k
+		for end < len(idx) && cls[idx[end]] == ET {
+			end++
+		}
+		if (k > 0 && cls[idx[k-1]] == EN) || (end < len(idx) && cls[idx[end]] == EN) {
+			for m := k; m < end; m++ {
+				cls[idx[m]] = EN
+			}
+		}
+		k = end - 1
+	}
+	// W6.
+	for _, i := range idx {
+		switch cls[i] {
+		case ES, ET, CS:
+			cls[i] = ON
+		}
+	}
+	// W7.
+	strong = seq.sos
+	for _, i := range idx {
+		switch cls[i] {
+		case L, R:
+			strong = cls[i]
+		case EN:
+			if strong == L {
+				cls[i] = L
+			}
+		}
+	}
+}
+
+// strongOf returns the direction of a class for rules N0-N1, or ON
+// for neutrals.
+func strongOf(c Class) Class {
+	switch c {
+	case L:
+		return L
+	case R, AL, EN, AN:
+		return R
+	}
+	return ON
+}
+
+// maxBrackets is the size of the bracket stack of rule BD16.
+const maxBrackets = 63
+
+// resolveBrackets resolves paired brackets (N0).
+func (p *paragraph) resolveBrackets(seq *sequence) {
+	cls := p.classes
+	idx := seq.indices
+	type pair struct{ open, close int }
+	type entry struct {
+		bracket rune
+		pos     int
+	}
+	var pairs []pair
+	var stack []entry
+loop:
+	for k, i := range idx {
+		if cls[i] != ON {
+			continue
+		}
+		b, opening := bracketOf(p.runes[i])
+		if b == 0 {
+			continue
+		}
+		if opening {
+			if len(stack) == maxBrackets {
+				break
+			}
+			stack = append(stack, entry{bracket: b, pos: k})
+			continue
+		}
+		for s := len(stack) - 1; s >= 0; s-- {
+			if stack[s].bracket == b {
+				pairs = append(pairs, pair{open: stack[s].pos, close: k})
+				stack = stack[:s]
+				continue loop
+			}
+		}
+	}
+	// Process the pairs in order of their opening brackets.
+	for i := 1; i < len(pairs); i++ {
+		for j := i; j > 0 && pairs[j].open < pairs[j-1].open; j-- {
+			pairs[j], pairs[j-1] = pairs[j-1], pairs[j]
+		}
+	}
+	e := seq.level.direction()
+	for _, pr := range pairs {
+		found := ON
+		for k := pr.open + 1; k < pr.close; k++ {
+			d := strongOf(cls[idx[k]])
+			if d == ON {
+				continue
+			}
+			found = d
+			if d == e {
+				break
+			}
+		}
+		if found == ON {
+			continue
+		}
+		dir := e
+		if found != e {
+			ctx := seq.sos
+			for k := pr.open - 1; k >= 0; k-- {
+				if d := strongOf(cls[idx[k]]); d != ON {
+					ctx = d
+					break
+				}
+			}
+			if ctx == found {
+				dir = found
+			}
+		}
+		for _, k := range []int{pr.open, pr.close} {
+			cls[idx[k]] = dir
+			// Marks following a bracket take its direction.
+			for m := k + 1; m < len(idx) && p.initial[idx[m]] == NSM; m++ {
+				cls[idx[m]] = dir
+			}
+		}
+	}
+}
+
+// isNeutral reports whether c is a neutral or isolate formatting
+// class.
+func isNeutral(c Class) bool {
+	switch c {
+	case B, S, WS, ON, LRI, RLI, FSI, PDI:
+		return true
+	}
+	return false
+}
+
+// resolveNeutral resolves neutral types (N1-N2).
+func (p *paragraph) resolveNeutral(seq *sequence) {
+	cls := p.classes
+	idx := seq.indices
+	e := seq.level.direction()
+	for k := 0; k < len(idx); k++ {
+		if !isNeutral(cls[idx[k]]) {
+			continue
+		}
+		end := k
+		for end < len(idx) && isNeutral(cls[idx[end]]) {
+			end++
+		}
+		before := seq.sos
+		if k > 0 {
+			before = strongOf(cls[idx[k-1]])
+		}
+		after := seq.eos
+		if end < len(idx) {
+			after = strongOf(cls[idx[end]])
+		}
+		dir := e
+		if before == after && before != ON {
+			dir = before
+		}
+		for m := k; m < end; m++ {
+			cls[idx[m]] = dir
+		}
+		k = end - 1
+	}
+}
+
+// resolveImplicit resolves the implicit levels (I1-I2) and assigns
+// removed runes the level of the preceding rune.
+func (p *paragraph) resolveImplicit() {
+	for i, c := range p.classes {
+		if isRemoved(p.initial[i]) {
+			if i > 0 {
+				p.levels[i] = p.levels[i-1]
+			} else {
+				p.levels[i] = p.base
+			}
+			continue
+		}
+		l := p.levels[i]
+		if !l.RTL() {
+			switch c {
+			case R:
+				l++
+			case AN, EN:
+				l += 2
+			}
+		} else {
+			switch c {
+			case L, EN, AN:
+				l++
+			}
+		}
+		p.levels[i] = l
+	}
+}
+
+// Runs returns the runs of a line of a paragraph in visual order.
+// The levels are the levels of the runes of the line as resolved by
+// Levels, and base is the paragraph level. Trailing whitespace and
+// separators are reset to the paragraph level (L1) before reordering
+// (L2).
+func Runs(runes []rune, levels []Level, base Level) []Run {
+	levels = append([]Level(nil), levels...)
+	// trailing is set while the runes following the current one are
+	// whitespace up to a separator or the end of the line.
+	trailing := true
+	for i := len(runes) - 1; i >= 0; i-- {
+		switch c := ClassOf(runes[i]); {
+		case c == S || c == B:
+			levels[i] = base
+			trailing = true
+		case c == WS || isIsolateInitiator(c) || c == PDI || isRemoved(c):
+			if trailing {
+				levels[i] = base
+			}
+		default:
+			trailing = false
+		}
+	}
+	var runs []Run
+	for i := 0; i < len(levels); {
+		end := i + 1
+		for end < len(levels) && levels[end] == levels[i] {
+			end++
+		}
+		runs = append(runs, Run{Start: i, End: end, Level: levels[i]})
+		i = end
+	}
+	var highest, lowest Level = 0, maxDepth + 2
+	for _, r := range runs {
+		if r.Level > highest {
+			highest = r.Level
+		}
+		if r.Level < lowest {
+			lowest = r.Level
+		}
+	}
+	// Reverse any sequence of runs at or above each level, from the
+	// highest level to the lowest odd level.
+	for l := highest; l >= lowest|1; l-- {
+		for i := 0; i < len(runs); {
+			if runs[i].Level < l {
+				i++
+				continue
+			}
+			end := i + 1
+			for end < len(runs) && runs[end].Level >= l {
+				end++
+			}
+			for a, b := i, end-1; a < b; a, b = a+1, b-1 {
+				runs[a], runs[b] = runs[b], runs[a]
+			}
+			i = end
+		}
+	}
+	return runs
+}
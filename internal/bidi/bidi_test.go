@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package bidi
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		txt    string
+		base   Level
+		levels []Level
+		para   Level
+	}{
+		{"abc", Auto, []Level{0, 0, 0}, 0},
+		{"אבג", Auto, []Level{1, 1, 1}, 1},
+		{"ab אב", Auto, []Level{0, 0, 0, 1, 1}, 0},
+		{"אב ab", Auto, []Level{1, 1, 1, 2, 2}, 1},
+		// Numbers in right-to-left text.
+		{"אב 12", Auto, []Level{1, 1, 1, 2, 2}, 1},
+		{"ب ١٢", Auto, []Level{1, 1, 2, 2}, 1},
+		// European numbers after Arabic letters are Arabic numbers.
+		{"ب 12", Auto, []Level{1, 1, 2, 2}, 1},
+		// Neutrals between runs of the same direction.
+		{"א - ב", Auto, []Level{1, 1, 1, 1, 1}, 1},
+		{"a - ב", Auto, []Level{0, 0, 0, 0, 1}, 0},
+		// Explicit paragraph level.
+		{"abc", 1, []Level{2, 2, 2}, 1},
+		// Right-to-left override.
+		{"a‮bc‬", Auto, []Level{0, 0, 1, 1, 1}, 0},
+		// Isolates don't affect the paragraph level.
+		{"⁧אב⁩ ab", Auto, []Level{0, 1, 1, 0, 0, 0, 0}, 0},
+		// Paired brackets take the embedding direction.
+		{"א (b) ג", Auto, []Level{1, 1, 1, 2, 1, 1, 1}, 1},
+		{"a (ב) c", Auto, []Level{0, 0, 0, 1, 0, 0, 0}, 0},
+		// Non-spacing marks take the class of their base.
+		{"אְ", Auto, []Level{1, 1}, 1},
+	}
+	for _, test := range tests {
+		levels, para := Levels([]rune(test.txt), test.base)
+		if para != test.para {
+			t.Errorf("%q: got paragraph level %d, expected %d", test.txt, para, test.para)
+		}
+		if fmt.Sprint(levels) != fmt.Sprint(test.levels) {
+			t.Errorf("%q: got levels %v, expected %v", test.txt, levels, test.levels)
+		}
+	}
+}
+
+func TestRuns(t *testing.T) {
+	tests := []struct {
+		txt  string
+		runs []Run
+	}{
+		{"abc", []Run{{0, 3, 0}}},
+		{"אבג", []Run{{0, 3, 1}}},
+		{"ab אב cd", []Run{{0, 3, 0}, {3, 5, 1}, {5, 8, 0}}},
+		{"אב ab גד", []Run{{5, 8, 1}, {3, 5, 2}, {0, 3, 1}}},
+		// Trailing whitespace is at the paragraph level.
+		{"ab אב ", []Run{{0, 3, 0}, {3, 5, 1}, {5, 6, 0}}},
+		{"אב ab ", []Run{{5, 6, 1}, {3, 5, 2}, {0, 3, 1}}},
+	}
+	for _, test := range tests {
+		runes := []rune(test.txt)
+		levels, base := Levels(runes, Auto)
+		runs := Runs(runes, levels, base)
+		if fmt.Sprint(runs) != fmt.Sprint(test.runs) {
+			t.Errorf("%q: got runs %v, expected %v", test.txt, runs, test.runs)
+		}
+	}
+}
+
+func TestMirror(t *testing.T) {
+	for _, p := range [][2]rune{{'(', ')'}, {')', '('}, {'<', '>'}, {'«', '»'}, {'a', 'a'}} {
+		if got := Mirror(p[0]); got != p[1] {
+			t.Errorf("Mirror(%q) = %q, expected %q", p[0], got, p[1])
+		}
+	}
+}
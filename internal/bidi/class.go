@@ -0,0 +1,266 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package bidi
+
+import (
+	"unicode"
+)
+
+// Class is the bidirectional character type of a rune.
+type Class uint8
+
+const (
+	L   Class = iota // Left-to-right.
+	R                // Right-to-left.
+	AL               // Arabic letter.
+	EN               // European number.
+	ES               // European separator.
+	ET               // European terminator.
+	AN               // Arabic number.
+	CS               // Common separator.
+	NSM              // Non-spacing mark.
+	BN               // Boundary neutral.
+	B                // Paragraph separator.
+	S                // Segment separator.
+	WS               // Whitespace.
+	ON               // Other neutral.
+	LRE              // Left-to-right embedding.
+	LRO              // Left-to-right override.
+	RLE              // Right-to-left embedding.
+	RLO              // Right-to-left override.
+	PDF              // Pop directional format.
+	LRI              // Left-to-right isolate.
+	RLI              // Right-to-left isolate.
+	FSI              // First strong isolate.
+	PDI              // Pop directional isolate.
+)
+
+// rtlScripts contain the letters of class R.
+var rtlScripts = []*unicode.RangeTable{
+	unicode.Hebrew,
+	unicode.Samaritan,
+	unicode.Mandaic,
+	unicode.Nko,
+	unicode.Adlam,
+	unicode.Hatran,
+	unicode.Nabataean,
+	unicode.Palmyrene,
+	unicode.Phoenician,
+	unicode.Imperial_Aramaic,
+	unicode.Old_South_Arabian,
+	unicode.Old_North_Arabian,
+	unicode.Avestan,
+	unicode.Kharoshthi,
+	unicode.Lydian,
+	unicode.Manichaean,
+	unicode.Mende_Kikakui,
+	unicode.Old_Hungarian,
+	unicode.Old_Turkic,
+	unicode.Psalter_Pahlavi,
+	unicode.Inscriptional_Pahlavi,
+	unicode.Inscriptional_Parthian,
+	unicode.Cypriot,
+}
+
+// alScripts contain the letters of class AL.
+var alScripts = []*unicode.RangeTable{
+	unicode.Arabic,
+	unicode.Syriac,
+	unicode.Thaana,
+	unicode.Hanifi_Rohingya,
+	unicode.Sogdian,
+}
+
+// arabicNumbers lists the runes of class AN.
+var arabicNumbers = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x0600, Hi: 0x0605, Stride: 1},
+		{Lo: 0x0660, Hi: 0x0669, Stride: 1},
+		{Lo: 0x066b, Hi: 0x066c, Stride: 1},
+		{Lo: 0x06dd, Hi: 0x06dd, Stride: 1},
+		{Lo: 0x08e2, Hi: 0x08e2, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x10d30, Hi: 0x10d39, Stride: 1},
+		{Lo: 0x10e60, Hi: 0x10e7e, Stride: 1},
+	},
+}
+
+// ClassOf returns the bidirectional class of r. The classes are
+// derived from the general categories and scripts of package
+// unicode, which approximates the Bidi_Class property closely for
+// the runes in common use.
+func ClassOf(r rune) Class {
+	switch r {
+	case 0x000a, 0x000d, 0x001c, 0x001d, 0x001e, 0x0085, 0x2029:
+		return B
+	case 0x0009, 0x000b, 0x001f:
+		return S
+	case 0x000c, 0x0020, 0x1680, 0x2028, 0x205f, 0x3000:
+		return WS
+	case '+', '-', 0x207a, 0x207b, 0x208a, 0x208b, 0x2212, 0xfb29, 0xfe62, 0xfe63, 0xff0b, 0xff0d:
+		return ES
+	case '#', '$', '%', 0x00a2, 0x00a3, 0x00a4, 0x00a5, 0x00b0, 0x00b1, 0x0609, 0x060a, 0x066a, 0x2030, 0x2031, 0x2032, 0x2033, 0x2034, 0x212e, 0x2213, 0xfe5f, 0xfe69, 0xfe6a, 0xff03, 0xff04, 0xff05, 0xffe0, 0xffe1, 0xffe5, 0xffe6:
+		return ET
+	case ',', '.', '/', ':', 0x00a0, 0x060c, 0x202f, 0x2044, 0xfe50, 0xfe52, 0xfe55, 0xff0c, 0xff0e, 0xff0f, 0xff1a:
+		return CS
+	case 0x200e: // LRM.
+		return L
+	case 0x200f: // RLM.
+		return R
+	case 0x061c: // ALM.
+		return AL
+	case 0x202a:
+		return LRE
+	case 0x202b:
+		return RLE
+	case 0x202c:
+		return PDF
+	case 0x202d:
+		return LRO
+	case 0x202e:
+		return RLO
+	case 0x2066:
+		return LRI
+	case 0x2067:
+		return RLI
+	case 0x2068:
+		return FSI
+	case 0x2069:
+		return PDI
+	}
+	switch {
+	case r >= '0' && r <= '9', r >= 0x06f0 && r <= 0x06f9,
+		r >= 0x2070 && r <= 0x2079 && r != 0x2071 && r != 0x2072 && r != 0x2073,
+		r >= 0x2080 && r <= 0x2089, r == 0x00b2, r == 0x00b3, r == 0x00b9,
+		r >= 0xff10 && r <= 0xff19, r >= 0x1d7ce && r <= 0x1d7ff:
+		return EN
+	case unicode.Is(arabicNumbers, r):
+		return AN
+	case r < 0x20, r >= 0x7f && r <= 0x9f:
+		return BN
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return NSM
+	case unicode.Is(unicode.Cf, r):
+		return BN
+	case unicode.Is(unicode.Sc, r):
+		return ET
+	case unicode.In(r, unicode.Zs):
+		return WS
+	}
+	if unicode.In(r, unicode.L, unicode.Mc, unicode.Nd, unicode.Nl, unicode.Cs, unicode.Co) {
+		for _, s := range alScripts {
+			if unicode.Is(s, r) {
+				return AL
+			}
+		}
+		for _, s := range rtlScripts {
+			if unicode.Is(s, r) {
+				return R
+			}
+		}
+		return L
+	}
+	if unicode.In(r, unicode.P, unicode.S, unicode.No) {
+		return ON
+	}
+	// Unassigned runes in right-to-left blocks default to R
+	// or AL.
+	switch {
+	case r >= 0x0590 && r <= 0x05ff, r >= 0x07c0 && r <= 0x085f,
+		r >= 0xfb1d && r <= 0xfb4f, r >= 0x10800 && r <= 0x10fff, r >= 0x1e800 && r <= 0x1efff:
+		return R
+	case r >= 0x0600 && r <= 0x07bf, r >= 0x0860 && r <= 0x08ff,
+		r >= 0xfb50 && r <= 0xfdcf, r >= 0xfdf0 && r <= 0xfdff, r >= 0xfe70 && r <= 0xfeff:
+		return AL
+	}
+	return L
+}
+
+// brackets lists the paired brackets, opening bracket first.
+var brackets = [][2]rune{
+	{'(', ')'},
+	{'[', ']'},
+	{'{', '}'},
+	{0x0f3a, 0x0f3b},
+	{0x0f3c, 0x0f3d},
+	{0x169b, 0x169c},
+	{0x2045, 0x2046},
+	{0x207d, 0x207e},
+	{0x208d, 0x208e},
+	{0x2308, 0x2309},
+	{0x230a, 0x230b},
+	{0x2329, 0x232a},
+	{0x2768, 0x2769},
+	{0x276a, 0x276b},
+	{0x276c, 0x276d},
+	{0x276e, 0x276f},
+	{0x2770, 0x2771},
+	{0x2772, 0x2773},
+	{0x2774, 0x2775},
+	{0x27c5, 0x27c6},
+	{0x27e6, 0x27e7},
+	{0x27e8, 0x27e9},
+	{0x27ea, 0x27eb},
+	{0x2983, 0x2984},
+	{0x2985, 0x2986},
+	{0x3008, 0x3009},
+	{0x300a, 0x300b},
+	{0x300c, 0x300d},
+	{0x300e, 0x300f},
+	{0x3010, 0x3011},
+	{0x3014, 0x3015},
+	{0x3016, 0x3017},
+	{0x3018, 0x3019},
+	{0x301a, 0x301b},
+	{0xfe59, 0xfe5a},
+	{0xfe5b, 0xfe5c},
+	{0xfe5d, 0xfe5e},
+	{0xff08, 0xff09},
+	{0xff3b, 0xff3d},
+	{0xff5b, 0xff5d},
+	{0xff5f, 0xff60},
+	{0xff62, 0xff63},
+}
+
+// mirrors lists mirrored runes that are not brackets.
+var mirrors = [][2]rune{
+	{'<', '>'},
+	{0x00ab, 0x00bb},
+	{0x2039, 0x203a},
+	{0x2264, 0x2265},
+	{0x226a, 0x226b},
+	{0x2282, 0x2283},
+	{0x2286, 0x2287},
+}
+
+// bracketOf returns the opening bracket of the pair r belongs to,
+// and whether r is an opening bracket. It returns zero if r is not
+// a paired bracket.
+func bracketOf(r rune) (rune, bool) {
+	for _, p := range brackets {
+		switch r {
+		case p[0]:
+			return p[0], true
+		case p[1]:
+			return p[0], false
+		}
+	}
+	return 0, false
+}
+
+// Mirror returns the mirrored form of r, for display in right-to-left
+// text. It returns r if r has no mirrored form.
+func Mirror(r rune) rune {
+	for _, tab := range [][][2]rune{brackets, mirrors} {
+		for _, p := range tab {
+			switch r {
+			case p[0]:
+				return p[1]
+			case p[1]:
+				return p[0]
+			}
+		}
+	}
+	return r
+}
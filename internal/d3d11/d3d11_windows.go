@@ -525,6 +525,13 @@ type VIEWPORT struct {
 	MaxDepth float32
 }
 
+type RECT struct {
+	Left   int32
+	Top    int32
+	Right  int32
+	Bottom int32
+}
+
 type SUBRESOURCE_DATA struct {
 	pSysMem *byte
 }
@@ -1172,6 +1179,16 @@ func (c *DeviceContext) RSSetViewports(viewport *VIEWPORT) {
 	)
 }
 
+func (c *DeviceContext) RSSetScissorRects(rect *RECT) {
+	syscall.Syscall(
+		c.Vtbl.RSSetScissorRects,
+		3,
+		uintptr(unsafe.Pointer(c)),
+		1, // NumRects
+		uintptr(unsafe.Pointer(rect)),
+	)
+}
+
 func (c *DeviceContext) VSSetShader(s *VertexShader) {
 	syscall.Syscall6(
 		c.Vtbl.VSSetShader,
@@ -1,19 +1,24 @@
 // SPDX-License-Identifier: Unlicense OR MIT
 
-//go:build linux || windows || freebsd || openbsd
-// +build linux windows freebsd openbsd
+//go:build (linux && cgo) || (freebsd && cgo) || (openbsd && cgo) || windows
+// +build linux,cgo freebsd,cgo openbsd,cgo windows
 
 package egl
 
 import (
 	"errors"
 	"fmt"
+	"image"
 	"runtime"
 	"strings"
 
 	"github.com/cybriq/giocore/gpu"
 )
 
+// ErrNoDisplay is wrapped by the errors of NewContext when no EGL
+// display is available.
+var ErrNoDisplay = errors.New("egl: no display available")
+
 type Context struct {
 	disp          _EGLDisplay
 	eglCtx        *eglContext
@@ -28,6 +33,10 @@ type eglContext struct {
 	visualID    int
 	srgb        bool
 	surfaceless bool
+	bufferAge   bool
+	// swapWithDamage is the eglSwapBuffersWithDamage function, or
+	// nil if not supported.
+	swapWithDamage swapBuffersWithDamageFunc
 }
 
 var (
@@ -41,6 +50,7 @@ var (
 const (
 	_EGL_ALPHA_SIZE             = 0x3021
 	_EGL_BLUE_SIZE              = 0x3022
+	_EGL_BUFFER_AGE_EXT         = 0x313d
 	_EGL_CONFIG_CAVEAT          = 0x3027
 	_EGL_CONTEXT_CLIENT_VERSION = 0x3098
 	_EGL_DEPTH_SIZE             = 0x3025
@@ -73,6 +83,37 @@ func (c *Context) Present() error {
 	return nil
 }
 
+// BufferAge returns the number of frames since the contents of the
+// back buffer were presented, or 0 if they are undefined.
+func (c *Context) BufferAge() int {
+	if !c.eglCtx.bufferAge {
+		return 0
+	}
+	age, ok := eglQuerySurface(c.disp, c.eglSurf, _EGL_BUFFER_AGE_EXT)
+	if !ok {
+		return 0
+	}
+	return int(age)
+}
+
+// PresentDamage is like Present but informs the compositor that only
+// damage changed since the previous frame.
+func (c *Context) PresentDamage(damage image.Rectangle) error {
+	f := c.eglCtx.swapWithDamage
+	if f == nil {
+		return c.Present()
+	}
+	// EGL rectangles have their origin in the lower left corner.
+	rect := []_EGLint{
+		_EGLint(damage.Min.X), _EGLint(c.height - damage.Max.Y),
+		_EGLint(damage.Dx()), _EGLint(damage.Dy()),
+	}
+	if !eglSwapBuffersWithDamage(f, c.disp, c.eglSurf, rect) {
+		return fmt.Errorf("eglSwapBuffersWithDamage failed (%x)", eglGetError())
+	}
+	return nil
+}
+
 func NewContext(disp NativeDisplayType) (*Context, error) {
 	if err := loadEGL(); err != nil {
 		return nil, err
@@ -85,7 +126,7 @@ func NewContext(disp NativeDisplayType) (*Context, error) {
 		eglDisp = eglGetDisplay(EGL_DEFAULT_DISPLAY)
 	}
 	if eglDisp == nilEGLDisplay {
-		return nil, fmt.Errorf("eglGetDisplay failed: 0x%x", eglGetError())
+		return nil, fmt.Errorf("%w: eglGetDisplay failed: 0x%x", ErrNoDisplay, eglGetError())
 	}
 	eglCtx, err := createContext(eglDisp)
 	if err != nil {
@@ -166,7 +207,7 @@ func hasExtension(exts []string, ext string) bool {
 func createContext(disp _EGLDisplay) (*eglContext, error) {
 	major, minor, ret := eglInitialize(disp)
 	if !ret {
-		return nil, fmt.Errorf("eglInitialize failed: 0x%x", eglGetError())
+		return nil, fmt.Errorf("%w: eglInitialize failed: 0x%x", ErrNoDisplay, eglGetError())
 	}
 	// sRGB framebuffer support on EGL 1.5 or if EGL_KHR_gl_colorspace is supported.
 	exts := strings.Split(eglQueryString(disp, _EGL_EXTENSIONS), " ")
@@ -223,12 +264,21 @@ func createContext(disp _EGLDisplay) (*eglContext, error) {
 			return nil, fmt.Errorf("eglCreateContext failed: 0x%x", eglGetError())
 		}
 	}
+	var swapWithDamage swapBuffersWithDamageFunc
+	switch {
+	case hasExtension(exts, "EGL_KHR_swap_buffers_with_damage"):
+		swapWithDamage = loadSwapBuffersWithDamage("eglSwapBuffersWithDamageKHR")
+	case hasExtension(exts, "EGL_EXT_swap_buffers_with_damage"):
+		swapWithDamage = loadSwapBuffersWithDamage("eglSwapBuffersWithDamageEXT")
+	}
 	return &eglContext{
-		config:      _EGLConfig(eglCfg),
-		ctx:         _EGLContext(eglCtx),
-		visualID:    int(visID),
-		srgb:        srgb,
-		surfaceless: hasExtension(exts, "EGL_KHR_surfaceless_context"),
+		config:         _EGLConfig(eglCfg),
+		ctx:            _EGLContext(eglCtx),
+		visualID:       int(visID),
+		srgb:           srgb,
+		surfaceless:    hasExtension(exts, "EGL_KHR_surfaceless_context"),
+		bufferAge:      hasExtension(exts, "EGL_EXT_buffer_age"),
+		swapWithDamage: swapWithDamage,
 	}, nil
 }
 
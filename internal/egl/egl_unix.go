@@ -14,11 +14,24 @@ package egl
 #cgo openbsd LDFLAGS: -L/usr/X11R6/lib
 #cgo CFLAGS: -DEGL_NO_X11
 
+#include <stdlib.h>
 #include <EGL/egl.h>
 #include <EGL/eglext.h>
+
+typedef EGLBoolean (*swapBuffersWithDamageFunc)(EGLDisplay dpy, EGLSurface surface, EGLint *rects, EGLint n_rects);
+
+static EGLBoolean gio_eglSwapBuffersWithDamage(swapBuffersWithDamageFunc f, EGLDisplay dpy, EGLSurface surface, EGLint *rects, EGLint n_rects) {
+	return f(dpy, surface, rects, n_rects);
+}
+
+static swapBuffersWithDamageFunc gio_loadSwapBuffersWithDamage(const char *name) {
+	return (swapBuffersWithDamageFunc)eglGetProcAddress(name);
+}
 */
 import "C"
 
+import "unsafe"
+
 type (
 	_EGLint           = C.EGLint
 	_EGLDisplay       = C.EGLDisplay
@@ -27,6 +40,8 @@ type (
 	_EGLSurface       = C.EGLSurface
 	NativeDisplayType = C.EGLNativeDisplayType
 	NativeWindowType  = C.EGLNativeWindowType
+
+	swapBuffersWithDamageFunc = C.swapBuffersWithDamageFunc
 )
 
 func loadEGL() error {
@@ -107,3 +122,19 @@ func eglCreateWindowSurface(disp _EGLDisplay, conf _EGLConfig, win NativeWindowT
 func eglWaitClient() bool {
 	return C.eglWaitClient() == C.EGL_TRUE
 }
+
+func eglQuerySurface(disp _EGLDisplay, surf _EGLSurface, attr _EGLint) (_EGLint, bool) {
+	var val _EGLint
+	ret := C.eglQuerySurface(disp, surf, attr, &val)
+	return val, ret == C.EGL_TRUE
+}
+
+func loadSwapBuffersWithDamage(name string) swapBuffersWithDamageFunc {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	return C.gio_loadSwapBuffersWithDamage(cname)
+}
+
+func eglSwapBuffersWithDamage(f swapBuffersWithDamageFunc, disp _EGLDisplay, surf _EGLSurface, rects []_EGLint) bool {
+	return C.gio_eglSwapBuffersWithDamage(f, disp, surf, &rects[0], _EGLint(len(rects)/4)) == C.EGL_TRUE
+}
@@ -14,6 +14,10 @@ import (
 )
 
 type (
+	// swapBuffersWithDamageFunc is an eglSwapBuffersWithDamage
+	// function exported by libEGL.dll.
+	swapBuffersWithDamageFunc *syscall.LazyProc
+
 	_EGLint           int32
 	_EGLDisplay       uintptr
 	_EGLConfig        uintptr
@@ -40,6 +44,7 @@ var (
 	_eglSwapBuffers         = libEGL.NewProc("eglSwapBuffers")
 	_eglTerminate           = libEGL.NewProc("eglTerminate")
 	_eglQueryString         = libEGL.NewProc("eglQueryString")
+	_eglQuerySurface        = libEGL.NewProc("eglQuerySurface")
 	_eglWaitClient          = libEGL.NewProc("eglWaitClient")
 )
 
@@ -157,6 +162,26 @@ func eglQueryString(disp _EGLDisplay, name _EGLint) string {
 	return syscall.BytePtrToString((*byte)(unsafe.Pointer(r)))
 }
 
+func eglQuerySurface(disp _EGLDisplay, surf _EGLSurface, attr _EGLint) (_EGLint, bool) {
+	var val uintptr
+	r, _, _ := _eglQuerySurface.Call(uintptr(disp), uintptr(surf), uintptr(attr), uintptr(unsafe.Pointer(&val)))
+	return _EGLint(val), r != 0
+}
+
+func loadSwapBuffersWithDamage(name string) swapBuffersWithDamageFunc {
+	p := libEGL.NewProc(name)
+	if p.Find() != nil {
+		return nil
+	}
+	return p
+}
+
+func eglSwapBuffersWithDamage(f swapBuffersWithDamageFunc, disp _EGLDisplay, surf _EGLSurface, rects []_EGLint) bool {
+	r, _, _ := (*syscall.LazyProc)(f).Call(uintptr(disp), uintptr(surf), uintptr(unsafe.Pointer(&rects[0])), uintptr(len(rects)/4))
+	issue34474KeepAlive(rects)
+	return r != 0
+}
+
 func eglWaitClient() bool {
 	r, _, _ := _eglWaitClient.Call()
 	return r != 0
@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build (darwin || linux || freebsd || openbsd) && !cgo
+// +build darwin linux freebsd openbsd
+// +build !cgo
+
+package gl
+
+// Context is a platform specific GL context. OpenGL functions are not
+// available without cgo on this platform.
+type Context interface{}
@@ -1,5 +1,8 @@
 // SPDX-License-Identifier: Unlicense OR MIT
 
+//go:build !(darwin || linux || freebsd || openbsd) || cgo
+// +build !darwin,!linux,!freebsd,!openbsd cgo
+
 package gl
 
 import (
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package grapheme segments text into extended grapheme clusters
+// according to the Unicode Text Segmentation algorithm, UAX #29.
+package grapheme
+
+import (
+	"unicode"
+)
+
+// property is the Grapheme_Cluster_Break property of a rune.
+type property uint8
+
+const (
+	propOther property = iota
+	propCR
+	propLF
+	propControl
+	propExtend
+	propZWJ
+	propRI
+	propPrepend
+	propSpacingMark
+	propL
+	propV
+	propT
+	propLV
+	propLVT
+	propPictographic
+)
+
+// prepend lists the runes with the Prepend property.
+var prepend = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x0600, Hi: 0x0605, Stride: 1},
+		{Lo: 0x06dd, Hi: 0x06dd, Stride: 1},
+		{Lo: 0x070f, Hi: 0x070f, Stride: 1},
+		{Lo: 0x08e2, Hi: 0x08e2, Stride: 1},
+		{Lo: 0x0d4e, Hi: 0x0d4e, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x110bd, Hi: 0x110bd, Stride: 1},
+		{Lo: 0x110cd, Hi: 0x110cd, Stride: 1},
+		{Lo: 0x111c2, Hi: 0x111c3, Stride: 1},
+	},
+}
+
+// pictographic approximates the Extended_Pictographic property.
+var pictographic = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00a9, Hi: 0x00ae, Stride: 5},
+		{Lo: 0x203c, Hi: 0x203c, Stride: 1},
+		{Lo: 0x2049, Hi: 0x2049, Stride: 1},
+		{Lo: 0x2122, Hi: 0x2122, Stride: 1},
+		{Lo: 0x2139, Hi: 0x2139, Stride: 1},
+		{Lo: 0x2194, Hi: 0x2199, Stride: 1},
+		{Lo: 0x21a9, Hi: 0x21aa, Stride: 1},
+		{Lo: 0x231a, Hi: 0x231b, Stride: 1},
+		{Lo: 0x2328, Hi: 0x2328, Stride: 1},
+		{Lo: 0x23cf, Hi: 0x23cf, Stride: 1},
+		{Lo: 0x23e9, Hi: 0x23f3, Stride: 1},
+		{Lo: 0x23f8, Hi: 0x23fa, Stride: 1},
+		{Lo: 0x24c2, Hi: 0x24c2, Stride: 1},
+		{Lo: 0x25aa, Hi: 0x25ab, Stride: 1},
+		{Lo: 0x25b6, Hi: 0x25b6, Stride: 1},
+		{Lo: 0x25c0, Hi: 0x25c0, Stride: 1},
+		{Lo: 0x25fb, Hi: 0x25fe, Stride: 1},
+		{Lo: 0x2600, Hi: 0x27bf, Stride: 1},
+		{Lo: 0x2934, Hi: 0x2935, Stride: 1},
+		{Lo: 0x2b05, Hi: 0x2b07, Stride: 1},
+		{Lo: 0x2b1b, Hi: 0x2b1c, Stride: 1},
+		{Lo: 0x2b50, Hi: 0x2b50, Stride: 1},
+		{Lo: 0x2b55, Hi: 0x2b55, Stride: 1},
+		{Lo: 0x3030, Hi: 0x3030, Stride: 1},
+		{Lo: 0x303d, Hi: 0x303d, Stride: 1},
+		{Lo: 0x3297, Hi: 0x3299, Stride: 2},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1f1e5, Stride: 1},
+		{Lo: 0x1f200, Hi: 0x1f3fa, Stride: 1},
+		{Lo: 0x1f400, Hi: 0x1faff, Stride: 1},
+		{Lo: 0x1fc00, Hi: 0x1fffd, Stride: 1},
+	},
+}
+
+func propertyOf(r rune) property {
+	switch {
+	case r == '\r':
+		return propCR
+	case r == '\n':
+		return propLF
+	case r == 0x200d:
+		return propZWJ
+	case r == 0x200c, r >= 0x1f3fb && r <= 0x1f3ff, r >= 0xe0020 && r <= 0xe007f,
+		r == 0xff9e, r == 0xff9f:
+		return propExtend
+	case r >= 0x1f1e6 && r <= 0x1f1ff:
+		return propRI
+	case unicode.Is(prepend, r):
+		return propPrepend
+	case r >= 0x1100 && r <= 0x115f, r >= 0xa960 && r <= 0xa97c:
+		return propL
+	case r >= 0x1160 && r <= 0x11a7, r >= 0xd7b0 && r <= 0xd7c6:
+		return propV
+	case r >= 0x11a8 && r <= 0x11ff, r >= 0xd7cb && r <= 0xd7fb:
+		return propT
+	case r >= 0xac00 && r <= 0xd7a3:
+		if (r-0xac00)%28 == 0 {
+			return propLV
+		}
+		return propLVT
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return propExtend
+	case unicode.Is(unicode.Mc, r):
+		return propSpacingMark
+	case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Zl, unicode.Zp):
+		return propControl
+	case unicode.Is(pictographic, r):
+		return propPictographic
+	}
+	return propOther
+}
+
+// Breaks reports for every rune of a text whether a grapheme
+// cluster ends after it. The last rune always ends a cluster.
+func Breaks(runes []rune) []bool {
+	breaks := make([]bool, len(runes))
+	if len(runes) == 0 {
+		return breaks
+	}
+	prev := propertyOf(runes[0])
+	// pict is set if the current cluster is an extended
+	// pictographic followed by extending runes.
+	pict := prev == propPictographic
+	// riCount is the number of consecutive regional indicators.
+	riCount := 0
+	if prev == propRI {
+		riCount = 1
+	}
+	for i := 1; i < len(runes); i++ {
+		cur := propertyOf(runes[i])
+		breaks[i-1] = isBreak(prev, cur, pict, riCount)
+		switch {
+		case cur == propPictographic:
+			pict = true
+		case cur == propExtend && pict:
+		case cur == propZWJ && pict && prev != propZWJ:
+		default:
+			pict = false
+		}
+		if cur == propRI {
+			riCount++
+		} else {
+			riCount = 0
+		}
+		prev = cur
+	}
+	breaks[len(runes)-1] = true
+	return breaks
+}
+
+// isBreak reports whether there is a cluster boundary between runes
+// with properties a and b. pict is set if a ends an emoji sequence
+// and riCount is the number of regional indicators ending with a.
+func isBreak(a, b property, pict bool, riCount int) bool {
+	switch {
+	// GB3: don't break within CRLF.
+	case a == propCR && b == propLF:
+		return false
+	// GB4 and GB5: break around controls.
+	case a == propCR || a == propLF || a == propControl,
+		b == propCR || b == propLF || b == propControl:
+		return true
+	// GB6, GB7 and GB8: don't break Hangul syllables.
+	case a == propL && (b == propL || b == propV || b == propLV || b == propLVT),
+		(a == propLV || a == propV) && (b == propV || b == propT),
+		(a == propLVT || a == propT) && b == propT:
+		return false
+	// GB9, GB9a and GB9b: don't break before extending runes and
+	// spacing marks, or after prepended runes.
+	case b == propExtend || b == propZWJ || b == propSpacingMark || a == propPrepend:
+		return false
+	// GB11: don't break within emoji ZWJ sequences.
+	case a == propZWJ && b == propPictographic && pict:
+		return false
+	// GB12 and GB13: don't break within regional indicator pairs.
+	case a == propRI && b == propRI:
+		return riCount%2 == 0
+	}
+	// GB999: break everywhere else.
+	return true
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package grapheme
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBreaks(t *testing.T) {
+	tests := []struct {
+		txt      string
+		clusters []string
+	}{
+		{"abc", []string{"a", "b", "c"}},
+		{"e\u0301x", []string{"e\u0301", "x"}},
+		{"a\r\nb", []string{"a", "\r\n", "b"}},
+		// Flags are pairs of regional indicators.
+		{"🇩🇪🇫🇷🇩", []string{"🇩🇪", "🇫🇷", "🇩"}},
+		// Skin tone modifiers.
+		{"👍🏽x", []string{"👍🏽", "x"}},
+		// Emoji ZWJ sequences.
+		{"👩‍💻!", []string{"👩‍💻", "!"}},
+		{"a\u200d💻", []string{"a\u200d", "💻"}},
+		// Hangul syllables from jamo.
+		{"\u1100\u1161\u11a8x", []string{"\u1100\u1161\u11a8", "x"}},
+		// Spacing marks.
+		{"कि", []string{"कि"}},
+		{"", nil},
+	}
+	for _, test := range tests {
+		runes := []rune(test.txt)
+		var clusters []string
+		start := 0
+		for i, b := range Breaks(runes) {
+			if b {
+				clusters = append(clusters, string(runes[start:i+1]))
+				start = i + 1
+			}
+		}
+		if fmt.Sprintf("%q", clusters) != fmt.Sprintf("%q", test.clusters) {
+			t.Errorf("%q: got clusters %q, expected %q", test.txt, clusters, test.clusters)
+		}
+	}
+}
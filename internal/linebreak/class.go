@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package linebreak
+
+import (
+	"unicode"
+)
+
+// class is the line breaking class of a rune, after the
+// resolution of rule LB1.
+type class uint8
+
+const (
+	clsAL  class = iota // Alphabetic.
+	clsBK               // Mandatory break.
+	clsCR               // Carriage return.
+	clsLF               // Line feed.
+	clsNL               // Next line.
+	clsSP               // Space.
+	clsZW               // Zero width space.
+	clsZWJ              // Zero width joiner.
+	clsCM               // Combining mark.
+	clsWJ               // Word joiner.
+	clsGL               // Non-breaking glue.
+	clsBA               // Break after.
+	clsBB               // Break before.
+	clsB2               // Break opportunity before and after.
+	clsHY               // Hyphen.
+	clsCL               // Close punctuation.
+	clsCP               // Close parenthesis.
+	clsEX               // Exclamation and interrogation.
+	clsIN               // Inseparable.
+	clsNS               // Nonstarter.
+	clsOP               // Open punctuation.
+	clsQU               // Quotation.
+	clsIS               // Infix numeric separator.
+	clsNU               // Numeric.
+	clsPO               // Postfix numeric.
+	clsPR               // Prefix numeric.
+	clsSY               // Symbols allowing break after.
+	clsHL               // Hebrew letter.
+	clsID               // Ideographic.
+	clsEB               // Emoji base.
+	clsEM               // Emoji modifier.
+	clsH2               // Hangul LV syllable.
+	clsH3               // Hangul LVT syllable.
+	clsJL               // Hangul L jamo.
+	clsJV               // Hangul V jamo.
+	clsJT               // Hangul T jamo.
+	clsRI               // Regional indicator.
+)
+
+// smallKana lists the conditional Japanese starters, resolved
+// to NS.
+var smallKana = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x3041, Hi: 0x3049, Stride: 2},
+		{Lo: 0x3063, Hi: 0x3063, Stride: 1},
+		{Lo: 0x3083, Hi: 0x3087, Stride: 2},
+		{Lo: 0x308e, Hi: 0x308e, Stride: 1},
+		{Lo: 0x3095, Hi: 0x3096, Stride: 1},
+		{Lo: 0x30a1, Hi: 0x30a9, Stride: 2},
+		{Lo: 0x30c3, Hi: 0x30c3, Stride: 1},
+		{Lo: 0x30e3, Hi: 0x30e7, Stride: 2},
+		{Lo: 0x30ee, Hi: 0x30ee, Stride: 1},
+		{Lo: 0x30f5, Hi: 0x30f6, Stride: 1},
+		{Lo: 0x30fc, Hi: 0x30fc, Stride: 1},
+		{Lo: 0x31f0, Hi: 0x31ff, Stride: 1},
+		{Lo: 0xff67, Hi: 0xff70, Stride: 1},
+	},
+}
+
+// ideographic lists the scripts of class ID.
+var ideographic = []*unicode.RangeTable{
+	unicode.Han,
+	unicode.Hiragana,
+	unicode.Katakana,
+	unicode.Bopomofo,
+	unicode.Yi,
+}
+
+// classOf returns the line breaking class of r. The classes are
+// derived from the general categories and scripts of package
+// unicode, which approximates the Line_Break property closely for
+// the runes in common use.
+func classOf(r rune) class {
+	switch r {
+	case 0x000b, 0x000c, 0x2028, 0x2029:
+		return clsBK
+	case 0x000d:
+		return clsCR
+	case 0x000a:
+		return clsLF
+	case 0x0085:
+		return clsNL
+	case 0x0020:
+		return clsSP
+	case 0x200b:
+		return clsZW
+	case 0x200d:
+		return clsZWJ
+	case 0x2060, 0xfeff:
+		return clsWJ
+	case 0x00a0, 0x034f, 0x035c, 0x035d, 0x035e, 0x035f, 0x0360, 0x0361, 0x0362, 0x0f08, 0x0f0c, 0x0f12, 0x180e, 0x2007, 0x2011, 0x202f:
+		return clsGL
+	case 0x0009, 0x007c, 0x00ad, 0x058a, 0x05be, 0x0964, 0x0965, 0x0e5a, 0x0e5b, 0x1680, 0x2010, 0x2012, 0x2013, 0x2027, 0x205f:
+		return clsBA
+	case 0x00b4, 0x02c8, 0x02cc, 0x02df, 0x0f01, 0x0f02, 0x0f03, 0x0f04, 0x1806, 0x1ffd, 0xa874, 0xa875:
+		return clsBB
+	case 0x2014, 0x2e3a, 0x2e3b:
+		return clsB2
+	case '-':
+		return clsHY
+	case ')', ']':
+		return clsCP
+	case 0x3001, 0x3002, 0xfe11, 0xfe12, 0xfe50, 0xfe52, 0xff0c, 0xff0e, 0xff61, 0xff64:
+		return clsCL
+	case '!', '?', 0x05c6, 0x061b, 0x061e, 0x061f, 0x06d4, 0x07f9, 0x0f0d, 0xfe15, 0xfe16, 0xfe56, 0xfe57, 0xff01, 0xff1f:
+		return clsEX
+	case 0x2024, 0x2025, 0x2026, 0x22ef, 0xfe19:
+		return clsIN
+	case 0x17d6, 0x203c, 0x203d, 0x2047, 0x2048, 0x2049, 0x3005, 0x301c, 0x303b, 0x303c, 0x309b, 0x309c, 0x309d, 0x309e, 0x30a0, 0x30fb, 0x30fd, 0x30fe, 0xa015, 0xfe54, 0xfe55, 0xff1a, 0xff1b, 0xff65, 0xff9e, 0xff9f:
+		return clsNS
+	case 0x00a1, 0x00bf, 0x2e18:
+		return clsOP
+	case '"', '\'', 0x275b, 0x275c, 0x275d, 0x275e, 0x2e00, 0x2e01, 0x2e06, 0x2e07, 0x2e08, 0x2e0b:
+		return clsQU
+	case ',', '.', ':', ';', 0x037e, 0x0589, 0x060c, 0x060d, 0x07f8, 0x2044, 0xfe10, 0xfe13, 0xfe14:
+		return clsIS
+	case '%', 0x00a2, 0x00b0, 0x060b, 0x066a, 0x2030, 0x2031, 0x2032, 0x2033, 0x2034, 0x2035, 0x2036, 0x2037, 0x20a7, 0x2103, 0x2109, 0xfdfc, 0xfe6a, 0xff05, 0xffe0:
+		return clsPO
+	case '$', '+', '\\', 0x00b1, 0x2116, 0x2212, 0x2213, 0xfe69, 0xff04, 0xffe1, 0xffe5, 0xffe6:
+		return clsPR
+	case '/':
+		return clsSY
+	case 0x066b, 0x066c:
+		return clsNU
+	}
+	switch {
+	case r >= 0x1f1e6 && r <= 0x1f1ff:
+		return clsRI
+	case r >= 0x1f3fb && r <= 0x1f3ff:
+		return clsEM
+	case r >= 0xac00 && r <= 0xd7a3:
+		if (r-0xac00)%28 == 0 {
+			return clsH2
+		}
+		return clsH3
+	case r >= 0x1100 && r <= 0x115f, r >= 0xa960 && r <= 0xa97c:
+		return clsJL
+	case r >= 0x1160 && r <= 0x11a7, r >= 0xd7b0 && r <= 0xd7c6:
+		return clsJV
+	case r >= 0x11a8 && r <= 0x11ff, r >= 0xd7cb && r <= 0xd7fb:
+		return clsJT
+	case unicode.Is(smallKana, r):
+		return clsNS
+	case unicode.In(r, unicode.Mn, unicode.Mc, unicode.Me, unicode.Cc, unicode.Cf):
+		return clsCM
+	case unicode.Is(unicode.Nd, r):
+		return clsNU
+	case unicode.Is(unicode.Ps, r):
+		return clsOP
+	case unicode.Is(unicode.Pe, r):
+		return clsCL
+	case unicode.In(r, unicode.Pi, unicode.Pf):
+		return clsQU
+	case unicode.Is(unicode.Sc, r):
+		return clsPR
+	case unicode.Is(unicode.Zs, r):
+		return clsBA
+	case unicode.Is(unicode.Hebrew, r) && unicode.Is(unicode.L, r):
+		return clsHL
+	case isEmojiBase(r):
+		return clsEB
+	case r >= 0x1f000 && r <= 0x1faff, r >= 0x2600 && r <= 0x27bf:
+		return clsID
+	case r >= 0x3000 && r <= 0x303f, r >= 0xff01 && r <= 0xff60, r >= 0x3200 && r <= 0x33ff:
+		return clsID
+	}
+	for _, s := range ideographic {
+		if unicode.Is(s, r) {
+			return clsID
+		}
+	}
+	return clsAL
+}
+
+// isEmojiBase reports whether r is an emoji that takes skin tone
+// modifiers.
+func isEmojiBase(r rune) bool {
+	switch {
+	case r == 0x261d, r == 0x26f9, r >= 0x270a && r <= 0x270d,
+		r == 0x1f385, r >= 0x1f3c2 && r <= 0x1f3c4, r >= 0x1f3c7 && r <= 0x1f3cc,
+		r >= 0x1f442 && r <= 0x1f443, r >= 0x1f446 && r <= 0x1f450,
+		r >= 0x1f466 && r <= 0x1f478, r == 0x1f47c, r >= 0x1f481 && r <= 0x1f487,
+		r == 0x1f4aa, r >= 0x1f574 && r <= 0x1f575, r == 0x1f57a, r == 0x1f590,
+		r >= 0x1f595 && r <= 0x1f596, r >= 0x1f645 && r <= 0x1f647,
+		r >= 0x1f64b && r <= 0x1f64f, r == 0x1f6a3, r >= 0x1f6b4 && r <= 0x1f6b6,
+		r == 0x1f6c0, r == 0x1f6cc, r == 0x1f90c, r == 0x1f90f,
+		r >= 0x1f918 && r <= 0x1f91f, r == 0x1f926, r >= 0x1f930 && r <= 0x1f939,
+		r >= 0x1f93c && r <= 0x1f93e, r == 0x1f977, r >= 0x1f9b5 && r <= 0x1f9b6,
+		r >= 0x1f9b8 && r <= 0x1f9b9, r == 0x1f9bb, r >= 0x1f9cd && r <= 0x1f9cf,
+		r >= 0x1f9d1 && r <= 0x1f9dd:
+		return true
+	}
+	return false
+}
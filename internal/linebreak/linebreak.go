@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package linebreak finds line break opportunities in text according
+// to the Unicode Line Breaking Algorithm, UAX #14.
+package linebreak
+
+// Break is the kind of line break opportunity after a rune.
+type Break uint8
+
+const (
+	// NoBreak prohibits a line break.
+	NoBreak Break = iota
+	// Allowed permits a line break.
+	Allowed
+	// Mandatory requires a line break.
+	Mandatory
+)
+
+// Breaks returns the line break opportunity after each rune of a
+// text. The text may always be broken after its last rune.
+func Breaks(runes []rune) []Break {
+	breaks := make([]Break, len(runes))
+	if len(runes) == 0 {
+		return breaks
+	}
+	classes := make([]class, len(runes))
+	for i, r := range runes {
+		classes[i] = classOf(r)
+	}
+	// prev is the class of the previous rune, after applying
+	// LB9 and LB10.
+	prev := resolveMark(classes[0])
+	// beforeSpace is the class of the last rune before a
+	// sequence of spaces.
+	beforeSpace := prev
+	// riCount is the number of consecutive regional indicators.
+	riCount := 0
+	if prev == clsRI {
+		riCount = 1
+	}
+	for i := 1; i < len(runes); i++ {
+		cur := classes[i]
+		// LB9: treat combining marks as their base.
+		if (cur == clsCM || cur == clsZWJ) && !isBreakOrSpace(prev) {
+			breaks[i-1] = NoBreak
+			continue
+		}
+		cur = resolveMark(cur)
+		breaks[i-1] = pairBreak(prev, cur, beforeSpace, classes[i-1], riCount)
+		if cur == clsRI {
+			riCount++
+		} else {
+			riCount = 0
+		}
+		if cur != clsSP {
+			beforeSpace = cur
+		}
+		prev = cur
+	}
+	breaks[len(runes)-1] = Allowed
+	if c := classes[len(runes)-1]; c == clsBK || c == clsLF || c == clsNL || c == clsCR {
+		breaks[len(runes)-1] = Mandatory
+	}
+	return breaks
+}
+
+// resolveMark resolves a combining mark without a base to AL
+// (LB10).
+func resolveMark(c class) class {
+	if c == clsCM || c == clsZWJ {
+		return clsAL
+	}
+	return c
+}
+
+func isBreakOrSpace(c class) bool {
+	switch c {
+	case clsBK, clsCR, clsLF, clsNL, clsSP, clsZW:
+		return true
+	}
+	return false
+}
+
+func isAlpha(c class) bool {
+	return c == clsAL || c == clsHL
+}
+
+// pairBreak returns the break opportunity between a rune of class
+// a and one of class b. beforeSpace is the class of the last rune
+// that isn't a space before b, and last is the original class of the
+// rune preceding b. riCount is the number of regional indicators
+// ending with a.
+func pairBreak(a, b, beforeSpace, last class, riCount int) Break {
+	switch {
+	// LB4 and LB5: break after hard line breaks.
+	case a == clsCR && b == clsLF:
+		return NoBreak
+	case a == clsBK || a == clsCR || a == clsLF || a == clsNL:
+		return Mandatory
+	// LB6: don't break before hard line breaks.
+	case b == clsBK || b == clsCR || b == clsLF || b == clsNL:
+		return NoBreak
+	// LB7: don't break before spaces or zero width space.
+	case b == clsSP || b == clsZW:
+		return NoBreak
+	// LB8: break after zero width space, even after spaces.
+	case beforeSpace == clsZW:
+		return Allowed
+	// LB8a: don't break after zero width joiner.
+	case last == clsZWJ:
+		return NoBreak
+	// LB11: don't break around word joiners.
+	case a == clsWJ || b == clsWJ:
+		return NoBreak
+	// LB12 and LB12a: don't break around glue.
+	case a == clsGL:
+		return NoBreak
+	case b == clsGL && a != clsSP && a != clsBA && a != clsHY:
+		return NoBreak
+	// LB13: don't break before closing punctuation.
+	case b == clsCL || b == clsCP || b == clsEX || b == clsIS || b == clsSY:
+		return NoBreak
+	// LB14: don't break after opening punctuation, even after spaces.
+	case beforeSpace == clsOP:
+		return NoBreak
+	// LB15: don't break between quotes and opening punctuation.
+	case beforeSpace == clsQU && b == clsOP:
+		return NoBreak
+	// LB16: don't break between closing punctuation and nonstarters.
+	case (beforeSpace == clsCL || beforeSpace == clsCP) && b == clsNS:
+		return NoBreak
+	// LB17: don't break within em dash pairs.
+	case beforeSpace == clsB2 && b == clsB2:
+		return NoBreak
+	// LB18: break after spaces.
+	case a == clsSP:
+		return Allowed
+	// LB19: don't break around quotation marks.
+	case a == clsQU || b == clsQU:
+		return NoBreak
+	// LB21: don't break before hyphens and small kana, or after
+	// acute accents.
+	case b == clsBA || b == clsHY || b == clsNS || a == clsBB:
+		return NoBreak
+	// LB21b: don't break between solidus and Hebrew letters.
+	case a == clsSY && b == clsHL:
+		return NoBreak
+	// LB22: don't break before ellipses.
+	case b == clsIN:
+		return NoBreak
+	// LB23 and LB23a: don't break between letters and numbers, or
+	// ideographs and numeric affixes.
+	case isAlpha(a) && b == clsNU, a == clsNU && isAlpha(b):
+		return NoBreak
+	case a == clsPR && (b == clsID || b == clsEB || b == clsEM):
+		return NoBreak
+	case (a == clsID || a == clsEB || a == clsEM) && b == clsPO:
+		return NoBreak
+	// LB24: don't break between numeric affixes and letters.
+	case (a == clsPR || a == clsPO) && isAlpha(b), isAlpha(a) && (b == clsPR || b == clsPO):
+		return NoBreak
+	// LB25: don't break within numbers.
+	case (a == clsCL || a == clsCP || a == clsNU) && (b == clsPO || b == clsPR),
+		(a == clsPO || a == clsPR) && (b == clsOP || b == clsNU),
+		(a == clsHY || a == clsIS || a == clsNU || a == clsSY) && b == clsNU:
+		return NoBreak
+	// LB26 and LB27: keep Korean syllables together.
+	case a == clsJL && (b == clsJL || b == clsJV || b == clsH2 || b == clsH3),
+		(a == clsJV || a == clsH2) && (b == clsJV || b == clsJT),
+		(a == clsJT || a == clsH3) && b == clsJT:
+		return NoBreak
+	case isHangul(a) && b == clsPO, a == clsPR && isHangul(b):
+		return NoBreak
+	// LB28: don't break between letters.
+	case isAlpha(a) && isAlpha(b):
+		return NoBreak
+	// LB29: don't break between numeric punctuation and letters.
+	case a == clsIS && isAlpha(b):
+		return NoBreak
+	// LB30: don't break between letters and parentheses.
+	case (isAlpha(a) || a == clsNU) && b == clsOP, a == clsCP && (isAlpha(b) || b == clsNU):
+		return NoBreak
+	// LB30a: break between pairs of regional indicators.
+	case a == clsRI && b == clsRI:
+		if riCount%2 == 1 {
+			return NoBreak
+		}
+		return Allowed
+	// LB30b: don't break before emoji modifiers.
+	case a == clsEB && b == clsEM:
+		return NoBreak
+	}
+	// LB31: break everywhere else.
+	return Allowed
+}
+
+func isHangul(c class) bool {
+	switch c {
+	case clsJL, clsJV, clsJT, clsH2, clsH3:
+		return true
+	}
+	return false
+}
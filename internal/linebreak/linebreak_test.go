@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package linebreak
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBreaks(t *testing.T) {
+	// The expected segments are separated by '|' at the allowed
+	// breaks.
+	tests := []string{
+		"hello |world",
+		"hello   |world",
+		"well-|known",
+		"(parenthesized) |text",
+		"\"quoted\" |text",
+		"1,000.00 |$10 |10%",
+		"https://|example.com/|path",
+		"end. |Start",
+		"日|本|語",
+		"日|本。|語",
+		"キャッ|ト",
+		"a\u00a0b",
+		"a\u2060b",
+		"e\u0301x|日",
+		"x |\u0301y",
+		"🇩🇪|🇫🇷",
+		"👍🏽|x",
+		"한|국|어",
+		"a\u200b|b",
+	}
+	for _, test := range tests {
+		txt := strings.ReplaceAll(test, "|", "")
+		var got strings.Builder
+		runes := []rune(txt)
+		breaks := Breaks(runes)
+		for i, r := range runes {
+			got.WriteRune(r)
+			if breaks[i] == Allowed && i < len(runes)-1 {
+				got.WriteByte('|')
+			}
+		}
+		if got.String() != test {
+			t.Errorf("got %q, expected %q", got.String(), test)
+		}
+	}
+}
+
+func TestMandatoryBreaks(t *testing.T) {
+	runes := []rune("a\r\nb\nc d")
+	want := []Break{NoBreak, NoBreak, Mandatory, NoBreak, Mandatory, NoBreak, Mandatory, Allowed}
+	got := Breaks(runes)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("break after rune %d: got %d, expected %d", i, got[i], want[i])
+		}
+	}
+}
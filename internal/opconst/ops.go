@@ -33,6 +33,10 @@ const (
 	TypeCursor
 	TypePath
 	TypeStroke
+	TypeCursorImage
+	TypePointerLock
+	TypeCache
+	TypeMask
 )
 
 const (
@@ -61,6 +65,10 @@ const (
 	TypeCursorLen          = 1 + 1
 	TypePathLen            = 8 + 1
 	TypeStrokeLen          = 1 + 4
+	TypeCursorImageLen     = 1
+	TypePointerLockLen     = 1 + 1
+	TypeCacheLen           = 1 + 4 + 4
+	TypeMaskLen            = 1 + 4*4 + 4
 )
 
 // StateMask is a bitmask of state types a load operation
@@ -104,14 +112,18 @@ func (t OpType) Size() int {
 		TypeCursorLen,
 		TypePathLen,
 		TypeStrokeLen,
+		TypeCursorImageLen,
+		TypePointerLockLen,
+		TypeCacheLen,
+		TypeMaskLen,
 	}[t-firstOpIndex]
 }
 
 func (t OpType) NumRefs() int {
 	switch t {
-	case TypeKeyInput, TypeKeyFocus, TypePointerInput, TypeProfile, TypeCall, TypeClipboardRead, TypeClipboardWrite, TypeCursor:
+	case TypeKeyInput, TypeKeyFocus, TypePointerInput, TypeProfile, TypeCall, TypeClipboardRead, TypeClipboardWrite, TypeCursor, TypeCursorImage, TypePointerLock, TypeCache:
 		return 1
-	case TypeImage:
+	case TypeImage, TypeMask:
 		return 2
 	default:
 		return 0
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package ops
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/cybriq/giocore/internal/opconst"
+)
+
+// Mask is the shadow of paint.MaskOp.
+type Mask struct {
+	Image *image.Alpha
+	// Version points to the version of the mask, and identifies the
+	// mask.
+	Version *int
+	// Rect is the area of Image to paint, placed with its top-left
+	// corner at the origin.
+	Rect  image.Rectangle
+	Color color.NRGBA
+}
+
+// MaskKey identifies the tinted image of a mask.
+type MaskKey struct {
+	version *int
+	v       int
+	rect    image.Rectangle
+	color   color.NRGBA
+}
+
+// DecodeMask decodes a mask op.
+func DecodeMask(data []byte, refs []interface{}) Mask {
+	if opconst.OpType(data[0]) != opconst.TypeMask {
+		panic("invalid op")
+	}
+	bo := binary.LittleEndian
+	return Mask{
+		Image:   refs[0].(*image.Alpha),
+		Version: refs[1].(*int),
+		Rect: image.Rectangle{
+			Min: image.Point{
+				X: int(int32(bo.Uint32(data[1:]))),
+				Y: int(int32(bo.Uint32(data[5:]))),
+			},
+			Max: image.Point{
+				X: int(int32(bo.Uint32(data[9:]))),
+				Y: int(int32(bo.Uint32(data[13:]))),
+			},
+		},
+		Color: color.NRGBA{
+			R: data[17+0],
+			G: data[17+1],
+			B: data[17+2],
+			A: data[17+3],
+		},
+	}
+}
+
+// Key returns the key of the tinted image of m at its current
+// version.
+func (m Mask) Key() MaskKey {
+	return MaskKey{version: m.Version, v: *m.Version, rect: m.Rect, color: m.Color}
+}
+
+// Tint returns an image of the area of the mask filled with its
+// color, for renderers that can't paint masks directly. The image
+// origin is the top-left corner of the area.
+func (m Mask) Tint() *image.RGBA {
+	img := image.NewRGBA(image.Rectangle{Max: m.Rect.Size()})
+	draw.DrawMask(img, img.Rect, image.NewUniform(m.Color), image.Point{}, m.Image, m.Rect.Min, draw.Src)
+	return img
+}
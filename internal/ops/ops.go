@@ -4,6 +4,8 @@ package ops
 
 import (
 	"encoding/binary"
+	"image"
+	"image/color"
 	"math"
 
 	"github.com/cybriq/giocore/f32"
@@ -56,3 +58,95 @@ func DecodeLoad(data []byte) (int, opconst.StateMask) {
 	bo := binary.LittleEndian
 	return int(bo.Uint32(data[2:])), opconst.StateMask(data[1])
 }
+
+// LinearGradient is the shadow of paint.LinearGradientOp.
+type LinearGradient struct {
+	Stop1, Stop2   f32.Point
+	Color1, Color2 color.NRGBA
+}
+
+// DecodeStroke decodes the width of a stroke op.
+func DecodeStroke(data []byte) float32 {
+	_ = data[4]
+	if opconst.OpType(data[0]) != opconst.TypeStroke {
+		panic("invalid op")
+	}
+	bo := binary.LittleEndian
+	return math.Float32frombits(bo.Uint32(data[1:]))
+}
+
+// DecodeClip decodes the bounds of a clip op and whether its path is
+// an outline.
+func DecodeClip(data []byte) (bounds f32.Rectangle, outline bool) {
+	if opconst.OpType(data[0]) != opconst.TypeClip {
+		panic("invalid op")
+	}
+	bo := binary.LittleEndian
+	bounds = f32.Rectangle{
+		Min: f32.Point{
+			X: float32(int32(bo.Uint32(data[1:]))),
+			Y: float32(int32(bo.Uint32(data[5:]))),
+		},
+		Max: f32.Point{
+			X: float32(int32(bo.Uint32(data[9:]))),
+			Y: float32(int32(bo.Uint32(data[13:]))),
+		},
+	}
+	return bounds, data[17] == 1
+}
+
+// DecodeImage decodes the image and handle of an image op. Both are
+// nil for the zero paint.ImageOp.
+func DecodeImage(data []byte, refs []interface{}) (*image.RGBA, interface{}) {
+	if opconst.OpType(data[0]) != opconst.TypeImage {
+		panic("invalid op")
+	}
+	handle := refs[1]
+	if handle == nil {
+		return nil, nil
+	}
+	return refs[0].(*image.RGBA), handle
+}
+
+// DecodeColor decodes a color op.
+func DecodeColor(data []byte) color.NRGBA {
+	if opconst.OpType(data[0]) != opconst.TypeColor {
+		panic("invalid op")
+	}
+	return color.NRGBA{
+		R: data[1],
+		G: data[2],
+		B: data[3],
+		A: data[4],
+	}
+}
+
+// DecodeLinearGradient decodes a linear gradient op.
+func DecodeLinearGradient(data []byte) LinearGradient {
+	if opconst.OpType(data[0]) != opconst.TypeLinearGradient {
+		panic("invalid op")
+	}
+	bo := binary.LittleEndian
+	return LinearGradient{
+		Stop1: f32.Point{
+			X: math.Float32frombits(bo.Uint32(data[1:])),
+			Y: math.Float32frombits(bo.Uint32(data[5:])),
+		},
+		Stop2: f32.Point{
+			X: math.Float32frombits(bo.Uint32(data[9:])),
+			Y: math.Float32frombits(bo.Uint32(data[13:])),
+		},
+		Color1: color.NRGBA{
+			R: data[17+0],
+			G: data[17+1],
+			B: data[17+2],
+			A: data[17+3],
+		},
+		Color2: color.NRGBA{
+			R: data[21+0],
+			G: data[21+1],
+			B: data[21+2],
+			A: data[21+3],
+		},
+	}
+}
@@ -18,6 +18,10 @@ type Reader struct {
 	deferDone bool
 }
 
+// noOps is the empty operation list a Reader returns to at the end
+// of a call read by ResetCall.
+var noOps op.Ops
+
 // EncodedOp represents an encoded op returned by
 // Reader.
 type EncodedOp struct {
@@ -125,7 +129,7 @@ func (r *Reader) Decode() (EncodedOp, bool) {
 			block := r.stack[len(r.stack)-1]
 			n += block.endPC.data - r.pc.data - opconst.TypeAuxLen
 			data = data[:n]
-		case opconst.TypeCall:
+		case opconst.TypeCall, opconst.TypeCache:
 			if deferring {
 				deferring = false
 				// Copy macro for deferred execution.
@@ -138,24 +142,17 @@ func (r *Reader) Decode() (EncodedOp, bool) {
 			}
 			var op macroOp
 			op.decode(data, refs)
-			macroData := op.ops.Data()[op.pc.data:]
-			if opconst.OpType(macroData[0]) != opconst.TypeMacro {
-				panic("invalid macro reference")
-			}
-			var opDef opMacroDef
-			opDef.decode(macroData[:opconst.TypeMacro.Size()])
 			retPC := r.pc
 			retPC.data += n
 			retPC.refs += nrefs
-			r.stack = append(r.stack, macro{
-				ops:   r.ops,
-				retPC: retPC,
-				endPC: opDef.endpc,
-			})
-			r.ops = op.ops
-			r.pc = op.pc
-			r.pc.data += opconst.TypeMacro.Size()
-			r.pc.refs += opconst.TypeMacro.NumRefs()
+			r.call(op, r.ops, retPC)
+			if t == opconst.TypeCache {
+				// Return the cache operation with the key of the
+				// macro. Callers may use SkipCall to skip the
+				// cached operations.
+				key = Key{ops: op.ops, pc: op.pc.data, version: op.ops.Version()}
+				return EncodedOp{Key: key, Data: data, Refs: refs}, true
+			}
 			continue
 		case opconst.TypeMacro:
 			var op opMacroDef
@@ -169,6 +166,45 @@ func (r *Reader) Decode() (EncodedOp, bool) {
 	}
 }
 
+// call starts reading the operations of the macro invoked by m,
+// returning to retPC of retOps at the end of the macro.
+func (r *Reader) call(m macroOp, retOps *op.Ops, retPC PC) {
+	macroData := m.ops.Data()[m.pc.data:]
+	if opconst.OpType(macroData[0]) != opconst.TypeMacro {
+		panic("invalid macro reference")
+	}
+	var opDef opMacroDef
+	opDef.decode(macroData[:opconst.TypeMacro.Size()])
+	r.stack = append(r.stack, macro{
+		ops:   retOps,
+		retPC: retPC,
+		endPC: opDef.endpc,
+	})
+	r.ops = m.ops
+	r.pc = m.pc
+	r.pc.data += opconst.TypeMacro.Size()
+	r.pc.refs += opconst.TypeMacro.NumRefs()
+}
+
+// SkipCall skips the remaining operations of the innermost call. In
+// particular, SkipCall right after decoding a TypeCache operation
+// skips the cached operations.
+func (r *Reader) SkipCall() {
+	b := r.stack[len(r.stack)-1]
+	r.ops = b.ops
+	r.pc = b.retPC
+	r.stack = r.stack[:len(r.stack)-1]
+}
+
+// ResetCall is like Reset, except it reads only the operations invoked
+// by the call or cache operation encoded in data and refs.
+func (r *Reader) ResetCall(data []byte, refs []interface{}) {
+	var m macroOp
+	m.decode(data, refs)
+	r.Reset(&noOps)
+	r.call(m, &noOps, PC{})
+}
+
 func (op *opMacroDef) decode(data []byte) {
 	if opconst.OpType(data[0]) != opconst.TypeMacro {
 		panic("invalid op")
@@ -186,7 +222,7 @@ func (op *opMacroDef) decode(data []byte) {
 }
 
 func (m *macroOp) decode(data []byte, refs []interface{}) {
-	if opconst.OpType(data[0]) != opconst.TypeCall {
+	if t := opconst.OpType(data[0]); t != opconst.TypeCall && t != opconst.TypeCache {
 		panic("invalid op")
 	}
 	data = data[:9]
@@ -127,5 +127,19 @@ determines that the gesture is a drag and sets its Grab flag. The
 click handler receives a Cancel (removing the highlight) and further
 movements for the scroll handler has priority Grabbed, scrolling the
 list.
+
+Pointer lock
+
+The LockOp operation extends a grab beyond the window: the platform
+pointer is confined to the window (LockConfine) or hidden and held
+in place (LockRelative), and every pointer event is delivered to the
+locking handler with Grabbed priority. Other handlers in the matching
+sets are cancelled when the lock takes effect.
+
+With LockRelative, Move and Drag events carry the raw pointer motion
+in their Delta field, which is useful for 3D viewports and sliders
+with unbounded drag.
+
+The lock is released when the LockOp no longer appears in the frame.
 */
 package pointer
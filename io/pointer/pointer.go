@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"image"
+	"image/draw"
 	"strings"
 	"time"
 
@@ -37,6 +38,29 @@ type Event struct {
 	Position f32.Point
 	// Scroll is the scroll amount, if any.
 	Scroll f32.Point
+	// ScrollSource is the kind of device that generated a
+	// Scroll event.
+	ScrollSource ScrollSource
+	// ScrollSteps is the number of discrete steps, such as mouse
+	// wheel notches, that make up a Scroll event from a
+	// ScrollSourceWheel.
+	ScrollSteps image.Point
+	// ScrollPhase is the phase of a Scroll or Pinch event that is
+	// part of a continuous sequence, such as a touchpad gesture.
+	ScrollPhase ScrollPhase
+	// Delta is the unaccelerated relative motion of the pointer,
+	// for Move and Drag events delivered while the pointer is locked
+	// by a LockOp with mode LockRelative. Like Position, it is
+	// transformed to the coordinates of the handler. Position is
+	// unchanged for such events. For Pinch events, Delta is the
+	// motion of the center of the fingers.
+	Delta f32.Point
+	// PinchScale is the scale factor of a Pinch event, relative
+	// to the previous event of the sequence.
+	PinchScale float32
+	// PinchRotation is the clockwise rotation in radians of a Pinch
+	// event, relative to the previous event of the sequence.
+	PinchRotation float32
 	// Modifiers is the set of active modifiers when
 	// the mouse button was pressed.
 	Modifiers key.Modifiers
@@ -55,6 +79,21 @@ type CursorNameOp struct {
 	Name CursorName
 }
 
+// CursorImageOp sets a custom bitmap cursor for the current area.
+type CursorImageOp struct {
+	Image *CursorImage
+}
+
+// CursorImage is a custom bitmap cursor. Create one with
+// NewCursorImage.
+//
+// Platforms may cache native resources for a CursorImage, so
+// create a new CursorImage to change the bitmap.
+type CursorImage struct {
+	src     *image.RGBA
+	hotspot image.Point
+}
+
 // InputOp declares an input handler ready for pointer
 // events.
 type InputOp struct {
@@ -77,6 +116,17 @@ type PassOp struct {
 	Pass bool
 }
 
+// LockOp requests that the platform pointer be confined to
+// or locked in the window for as long as the op is present in
+// the frame. While the lock is in effect, the handler identified
+// by Tag receives every pointer event with Grabbed priority,
+// regardless of its hit area. The handler must be declared by an
+// InputOp in the same frame.
+type LockOp struct {
+	Tag  event.Tag
+	Mode LockMode
+}
+
 type ID uint16
 
 // Type of an Event.
@@ -94,6 +144,16 @@ type Buttons uint8
 // CursorName is the name of a cursor.
 type CursorName string
 
+// LockMode is the mode of a pointer lock.
+type LockMode uint8
+
+// ScrollSource is the kind of device that generated a Scroll
+// event.
+type ScrollSource uint8
+
+// ScrollPhase is the phase of a Scroll event.
+type ScrollPhase uint8
+
 // Must match app/internal/input.areaKind
 type areaKind uint8
 
@@ -114,6 +174,34 @@ const (
 	CursorGrab CursorName = "grab"
 	// CursorNone hides the cursor. To show it again, use any other cursor.
 	CursorNone CursorName = "none"
+	// CursorWait is the cursor for a busy program that cannot be interacted
+	// with.
+	CursorWait CursorName = "wait"
+	// CursorProgress is the cursor for a busy program that can still be
+	// interacted with.
+	CursorProgress CursorName = "progress"
+	// CursorNotAllowed is the cursor for a disallowed action.
+	CursorNotAllowed CursorName = "not-allowed"
+	// CursorMove is the cursor for a movable object.
+	CursorMove CursorName = "move"
+	// CursorNorthEastResize is the cursor for resizing the top-right corner.
+	CursorNorthEastResize CursorName = "ne-resize"
+	// CursorNorthWestResize is the cursor for resizing the top-left corner.
+	CursorNorthWestResize CursorName = "nw-resize"
+	// CursorSouthEastResize is the cursor for resizing the bottom-right corner.
+	CursorSouthEastResize CursorName = "se-resize"
+	// CursorSouthWestResize is the cursor for resizing the bottom-left corner.
+	CursorSouthWestResize CursorName = "sw-resize"
+	// CursorNorthEastSouthWestResize is the cursor for resizing along the
+	// top-right to bottom-left diagonal.
+	CursorNorthEastSouthWestResize CursorName = "nesw-resize"
+	// CursorNorthWestSouthEastResize is the cursor for resizing along the
+	// top-left to bottom-right diagonal.
+	CursorNorthWestSouthEastResize CursorName = "nwse-resize"
+	// CursorZoomIn is the cursor for zooming in.
+	CursorZoomIn CursorName = "zoom-in"
+	// CursorZoomOut is the cursor for zooming out.
+	CursorZoomOut CursorName = "zoom-out"
 )
 
 const (
@@ -134,6 +222,9 @@ const (
 	Leave
 	// Scroll of a pointer.
 	Scroll
+	// Pinch of a touchpad. Pinch events are reported
+	// by platforms with touchpad gestures.
+	Pinch
 )
 
 const (
@@ -143,6 +234,38 @@ const (
 	Touch
 )
 
+const (
+	// ScrollSourceUnknown is for scroll events from devices
+	// the platform doesn't identify.
+	ScrollSourceUnknown ScrollSource = iota
+	// ScrollSourceWheel is for scroll events from a mouse wheel
+	// with discrete steps.
+	ScrollSourceWheel
+	// ScrollSourceFinger is for scroll events from fingers on a
+	// touchpad. A ScrollPhaseEnd event marks the lifting of the
+	// fingers, after which kinetic scrolling is up to the
+	// receiver.
+	ScrollSourceFinger
+	// ScrollSourceContinuous is for scroll events from other
+	// devices with continuous motion, such as trackballs.
+	ScrollSourceContinuous
+)
+
+const (
+	// ScrollPhaseNone is the phase of scroll events that are not
+	// part of a sequence, such as mouse wheel events.
+	ScrollPhaseNone ScrollPhase = iota
+	// ScrollPhaseBegin is the phase of the first event of a
+	// scroll sequence.
+	ScrollPhaseBegin
+	// ScrollPhaseUpdate is the phase of the following events of a
+	// scroll sequence.
+	ScrollPhaseUpdate
+	// ScrollPhaseEnd is the phase of the event that ends a scroll
+	// sequence. Its Scroll amount is usually zero.
+	ScrollPhaseEnd
+)
+
 const (
 	// Shared priority is for handlers that
 	// are part of a matching set larger than 1.
@@ -165,6 +288,17 @@ const (
 	ButtonTertiary
 )
 
+const (
+	// LockNone means the pointer is not locked.
+	LockNone LockMode = iota
+	// LockConfine keeps the platform pointer inside the
+	// window.
+	LockConfine
+	// LockRelative hides the platform pointer and keeps it at its
+	// current position. Motion is reported in Event.Delta.
+	LockRelative
+)
+
 const (
 	areaRect areaKind = iota
 	areaEllipse
@@ -202,6 +336,56 @@ func (op CursorNameOp) Add(o *op.Ops) {
 	data[0] = byte(opconst.TypeCursor)
 }
 
+// Add panics if the cursor image is nil.
+func (op CursorImageOp) Add(o *op.Ops) {
+	if op.Image == nil {
+		panic("Image must be non-nil")
+	}
+	data := o.Write1(opconst.TypeCursorImageLen, op.Image)
+	data[0] = byte(opconst.TypeCursorImage)
+}
+
+// NewCursorImage creates a cursor from the src bitmap. The hotspot is
+// the position of the pointer within src, relative to its top-left
+// corner, and is clamped to the bitmap. NewCursorImage copies src if
+// it is not already an *image.RGBA with a zero origin.
+//
+// NewCursorImage panics if src is empty.
+func NewCursorImage(src image.Image, hotspot image.Point) *CursorImage {
+	bounds := src.Bounds()
+	if bounds.Empty() {
+		panic("empty cursor image")
+	}
+	if hotspot.X >= bounds.Dx() {
+		hotspot.X = bounds.Dx() - 1
+	}
+	if hotspot.Y >= bounds.Dy() {
+		hotspot.Y = bounds.Dy() - 1
+	}
+	if hotspot.X < 0 {
+		hotspot.X = 0
+	}
+	if hotspot.Y < 0 {
+		hotspot.Y = 0
+	}
+	if src, ok := src.(*image.RGBA); ok && bounds.Min == (image.Point{}) && src.Stride == bounds.Dx()*4 {
+		return &CursorImage{src: src, hotspot: hotspot}
+	}
+	dst := image.NewRGBA(image.Rectangle{Max: bounds.Size()})
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	return &CursorImage{src: dst, hotspot: hotspot}
+}
+
+// Image returns the premultiplied cursor bitmap.
+func (c *CursorImage) Image() *image.RGBA {
+	return c.src
+}
+
+// Hotspot returns the position of the pointer within the bitmap.
+func (c *CursorImage) Hotspot() image.Point {
+	return c.hotspot
+}
+
 // Add panics if the scroll range does not contain zero.
 func (op InputOp) Add(o *op.Ops) {
 	if op.Tag == nil {
@@ -231,6 +415,19 @@ func (op PassOp) Add(o *op.Ops) {
 	}
 }
 
+// Add panics if the mode is LockNone.
+func (op LockOp) Add(o *op.Ops) {
+	if op.Tag == nil {
+		panic("Tag must be non-nil")
+	}
+	if op.Mode == LockNone {
+		panic("invalid lock mode LockNone")
+	}
+	data := o.Write1(opconst.TypePointerLockLen, op.Tag)
+	data[0] = byte(opconst.TypePointerLock)
+	data[1] = byte(op.Mode)
+}
+
 func (t Type) String() string {
 	switch t {
 	case Press:
@@ -249,6 +446,8 @@ func (t Type) String() string {
 		return "Leave"
 	case Scroll:
 		return "Scroll"
+	case Pinch:
+		return "Pinch"
 	default:
 		panic("unknown Type")
 	}
@@ -298,6 +497,49 @@ func (b Buttons) String() string {
 	return strings.Join(strs, "|")
 }
 
+func (s ScrollSource) String() string {
+	switch s {
+	case ScrollSourceUnknown:
+		return "ScrollSourceUnknown"
+	case ScrollSourceWheel:
+		return "ScrollSourceWheel"
+	case ScrollSourceFinger:
+		return "ScrollSourceFinger"
+	case ScrollSourceContinuous:
+		return "ScrollSourceContinuous"
+	default:
+		panic("unknown scroll source")
+	}
+}
+
+func (p ScrollPhase) String() string {
+	switch p {
+	case ScrollPhaseNone:
+		return "ScrollPhaseNone"
+	case ScrollPhaseBegin:
+		return "ScrollPhaseBegin"
+	case ScrollPhaseUpdate:
+		return "ScrollPhaseUpdate"
+	case ScrollPhaseEnd:
+		return "ScrollPhaseEnd"
+	default:
+		panic("unknown scroll phase")
+	}
+}
+
+func (m LockMode) String() string {
+	switch m {
+	case LockNone:
+		return "LockNone"
+	case LockConfine:
+		return "LockConfine"
+	case LockRelative:
+		return "LockRelative"
+	default:
+		panic("unknown lock mode")
+	}
+}
+
 func (c CursorName) String() string {
 	if c == CursorDefault {
 		return "default"
@@ -26,6 +26,18 @@ type pointerQueue struct {
 	// states holds the storage for save/restore ops.
 	states  []collectState
 	scratch []event.Tag
+
+	// cursorImage is the custom cursor, if any. It takes
+	// precedence over cursor.
+	cursorImage *pointer.CursorImage
+	// lock is the pointer lock of the most recent frame.
+	lock pointerLock
+}
+
+// pointerLock represents a pointer.LockOp.
+type pointerLock struct {
+	tag  event.Tag
+	mode pointer.LockMode
 }
 
 type hitNode struct {
@@ -39,8 +51,9 @@ type hitNode struct {
 }
 
 type cursorNode struct {
-	name pointer.CursorName
-	area int
+	name  pointer.CursorName
+	image *pointer.CursorImage
+	area  int
 }
 
 type pointerInfo struct {
@@ -174,6 +187,16 @@ func (q *pointerQueue) collectHandlers(r *ops.Reader, events *handlerEvents) {
 				name: encOp.Refs[0].(pointer.CursorName),
 				area: len(q.areas) - 1,
 			})
+		case opconst.TypeCursorImage:
+			q.cursors = append(q.cursors, cursorNode{
+				image: encOp.Refs[0].(*pointer.CursorImage),
+				area:  len(q.areas) - 1,
+			})
+		case opconst.TypePointerLock:
+			q.lock = pointerLock{
+				tag:  encOp.Refs[0].(event.Tag),
+				mode: pointer.LockMode(encOp.Data[1]),
+			}
 		}
 	}
 }
@@ -209,6 +232,16 @@ func (q *pointerQueue) invTransform(areaIdx int, p f32.Point) f32.Point {
 	return q.areas[areaIdx].trans.Invert().Transform(p)
 }
 
+// invTransformVector is like invTransform for the linear part of the
+// transformation, for relative motion.
+func (q *pointerQueue) invTransformVector(areaIdx int, v f32.Point) f32.Point {
+	if areaIdx == -1 {
+		return v
+	}
+	inv := q.areas[areaIdx].trans.Invert()
+	return inv.Transform(v).Sub(inv.Transform(f32.Point{}))
+}
+
 func (q *pointerQueue) hit(areaIdx int, p f32.Point) bool {
 	for areaIdx != -1 {
 		a := &q.areas[areaIdx]
@@ -238,6 +271,8 @@ func (q *pointerQueue) Frame(root *op.Ops, events *handlerEvents) {
 	q.hitTree = q.hitTree[:0]
 	q.areas = q.areas[:0]
 	q.cursors = q.cursors[:0]
+	prevLock := q.lock
+	q.lock = pointerLock{}
 	q.reader.Reset(root)
 	q.collectHandlers(&q.reader, events)
 	for k, h := range q.handlers {
@@ -264,12 +299,42 @@ func (q *pointerQueue) Frame(root *op.Ops, events *handlerEvents) {
 			}
 		}
 	}
+	if h, ok := q.handlers[q.lock.tag]; q.lock.tag != nil && (!ok || !h.active) {
+		// Locks require a matching handler.
+		q.lock = pointerLock{}
+	}
+	if q.lock.tag != nil {
+		if q.lock.tag != prevLock.tag {
+			q.grabLock(events)
+		}
+		return
+	}
 	for i := range q.pointers {
 		p := &q.pointers[i]
 		q.deliverEnterLeaveEvents(p, events, p.last)
 	}
 }
 
+// grabLock makes the locking handler the only handler of every
+// pointer, cancelling the others.
+func (q *pointerQueue) grabLock(events *handlerEvents) {
+	tag := q.lock.tag
+	for i := range q.pointers {
+		p := &q.pointers[i]
+		var dropped []event.Tag
+		for _, k := range p.handlers {
+			if k != tag {
+				dropped = append(dropped, k)
+			}
+		}
+		cancelHandlers(events, dropped...)
+		q.dropHandlers(events, dropped...)
+		if p.pressed && len(p.handlers) == 0 {
+			p.handlers = append(p.handlers, tag)
+		}
+	}
+}
+
 func cancelHandlers(events *handlerEvents, tags ...event.Tag) {
 	for _, k := range tags {
 		events.Add(k, pointer.Event{Type: pointer.Cancel})
@@ -322,6 +387,18 @@ func (q *pointerQueue) Push(e pointer.Event, events *handlerEvents) {
 		e.Type = pointer.Drag
 	}
 
+	if q.lock.tag != nil {
+		switch e.Type {
+		case pointer.Press:
+			p.pressed = true
+			p.handlers = append(p.handlers[:0], q.lock.tag)
+		case pointer.Release:
+			p.pressed = false
+		}
+		q.deliverLockedEvent(events, e)
+		return
+	}
+
 	if e.Type == pointer.Release {
 		q.deliverEvent(p, events, e)
 		p.pressed = false
@@ -368,6 +445,23 @@ func (q *pointerQueue) deliverEvent(p *pointerInfo, events *handlerEvents, e poi
 	}
 }
 
+// deliverLockedEvent delivers e to the handler holding the
+// pointer lock.
+func (q *pointerQueue) deliverLockedEvent(events *handlerEvents, e pointer.Event) {
+	h := q.handlers[q.lock.tag]
+	if e.Type&h.types == 0 {
+		return
+	}
+	if e.Type == pointer.Scroll {
+		_, e.Scroll.X = setScrollEvent(e.Scroll.X, h.scrollRange.Min.X, h.scrollRange.Max.X)
+		_, e.Scroll.Y = setScrollEvent(e.Scroll.Y, h.scrollRange.Min.Y, h.scrollRange.Max.Y)
+	}
+	e.Priority = pointer.Grabbed
+	e.Position = q.invTransform(h.area, e.Position)
+	e.Delta = q.invTransformVector(h.area, e.Delta)
+	events.Add(q.lock.tag, e)
+}
+
 func (q *pointerQueue) deliverScrollEvent(p *pointerInfo, events *handlerEvents, e pointer.Event) {
 	foremost := true
 	if p.pressed && len(p.handlers) == 1 {
@@ -375,8 +469,11 @@ func (q *pointerQueue) deliverScrollEvent(p *pointerInfo, events *handlerEvents,
 		foremost = false
 	}
 	var sx, sy = e.Scroll.X, e.Scroll.Y
+	// Deliver events without distance, such as the end of a scroll
+	// sequence, to every handler.
+	phaseOnly := sx == 0 && sy == 0
 	for _, k := range p.handlers {
-		if sx == 0 && sy == 0 {
+		if sx == 0 && sy == 0 && !phaseOnly {
 			return
 		}
 		h := q.handlers[k]
@@ -424,11 +521,13 @@ func (q *pointerQueue) deliverEnterLeaveEvents(p *pointerInfo, events *handlerEv
 	}
 	// Deliver Enter events and update cursor.
 	q.cursor = pointer.CursorDefault
+	q.cursorImage = nil
 	for _, k := range hits {
 		h := q.handlers[k]
 		for i := len(q.cursors) - 1; i >= 0; i-- {
 			if c := q.cursors[i]; c.area == h.area {
 				q.cursor = c.name
+				q.cursorImage = c.image
 				break
 			}
 		}
@@ -35,6 +35,34 @@ func TestPointerWakeup(t *testing.T) {
 	}
 }
 
+func TestPointerCache(t *testing.T) {
+	handler := new(int)
+	// Record the handler in a separate, long-lived Ops.
+	var cached op.Ops
+	m := op.Record(&cached)
+	addPointerHandler(&cached, handler, image.Rect(0, 0, 100, 100))
+	call := m.Stop()
+
+	var ops op.Ops
+	op.Offset(f32.Pt(50, 0)).Add(&ops)
+	op.CacheOp{Call: call}.Add(&ops)
+
+	var r Router
+	r.Frame(&ops)
+	r.Queue(
+		pointer.Event{
+			Type:     pointer.Move,
+			Position: f32.Pt(100, 50),
+		},
+		// Outside the offset handler area.
+		pointer.Event{
+			Type:     pointer.Move,
+			Position: f32.Pt(25, 50),
+		},
+	)
+	assertEventSequence(t, r.Events(handler), pointer.Cancel, pointer.Enter, pointer.Move, pointer.Leave)
+}
+
 func TestPointerDrag(t *testing.T) {
 	handler := new(int)
 	var ops op.Ops
@@ -254,6 +282,58 @@ func TestPointerPriority(t *testing.T) {
 	assertScrollEvent(t, hev3[1], f32.Pt(-20, -30))
 }
 
+func TestScrollPhase(t *testing.T) {
+	handler1 := new(int)
+	handler2 := new(int)
+	var ops op.Ops
+
+	pointer.Rect(image.Rect(0, 0, 100, 100)).Add(&ops)
+	pointer.InputOp{
+		Tag:          handler1,
+		Types:        pointer.Scroll,
+		ScrollBounds: image.Rectangle{Max: image.Point{Y: 100}},
+	}.Add(&ops)
+	pointer.InputOp{
+		Tag:          handler2,
+		Types:        pointer.Scroll,
+		ScrollBounds: image.Rectangle{Max: image.Point{Y: 20}},
+	}.Add(&ops)
+
+	var r Router
+	r.Frame(&ops)
+	r.Queue(
+		pointer.Event{
+			Type:         pointer.Scroll,
+			Position:     f32.Pt(50, 50),
+			Scroll:       f32.Pt(0, 10),
+			ScrollSource: pointer.ScrollSourceFinger,
+			ScrollPhase:  pointer.ScrollPhaseBegin,
+		},
+		// The end of the sequence carries no distance and must
+		// reach both handlers.
+		pointer.Event{
+			Type:         pointer.Scroll,
+			Position:     f32.Pt(50, 50),
+			ScrollSource: pointer.ScrollSourceFinger,
+			ScrollPhase:  pointer.ScrollPhaseEnd,
+		},
+	)
+	hev1 := r.Events(handler1)
+	hev2 := r.Events(handler2)
+	assertEventSequence(t, hev1, pointer.Cancel, pointer.Scroll)
+	assertEventSequence(t, hev2, pointer.Cancel, pointer.Scroll, pointer.Scroll)
+	assertScrollEvent(t, hev2[1], f32.Pt(0, 10))
+	for _, e := range [][]event.Event{hev1, hev2} {
+		end := e[len(e)-1].(pointer.Event)
+		if end.ScrollPhase != pointer.ScrollPhaseEnd {
+			t.Errorf("got phase %v, expected %v", end.ScrollPhase, pointer.ScrollPhaseEnd)
+		}
+		if end.ScrollSource != pointer.ScrollSourceFinger {
+			t.Errorf("got source %v, expected %v", end.ScrollSource, pointer.ScrollSourceFinger)
+		}
+	}
+}
+
 func TestPointerEnterLeave(t *testing.T) {
 	handler1 := new(int)
 	handler2 := new(int)
@@ -649,6 +729,150 @@ func TestCursorNameOp(t *testing.T) {
 	}
 }
 
+func TestCursorImageOp(t *testing.T) {
+	ops := new(op.Ops)
+	var r Router
+	var h, h2 int
+	img := pointer.NewCursorImage(image.NewNRGBA(image.Rect(0, 0, 16, 16)), image.Pt(8, 8))
+	if got, want := img.Image().Bounds(), image.Rect(0, 0, 16, 16); got != want {
+		t.Errorf("got bounds %v; want %v", got, want)
+	}
+	st := op.Save(ops)
+	pointer.Rect(image.Rect(0, 0, 50, 50)).Add(ops)
+	pointer.InputOp{Tag: &h}.Add(ops)
+	pointer.CursorImageOp{Image: img}.Add(ops)
+	st.Load()
+	pointer.Rect(image.Rect(50, 50, 100, 100)).Add(ops)
+	pointer.InputOp{Tag: &h2}.Add(ops)
+	pointer.CursorNameOp{Name: pointer.CursorWait}.Add(ops)
+	r.Frame(ops)
+
+	for _, tc := range []struct {
+		pos   f32.Point
+		name  pointer.CursorName
+		image *pointer.CursorImage
+	}{
+		{pos: f32.Pt(25, 25), name: pointer.CursorDefault, image: img},
+		{pos: f32.Pt(75, 75), name: pointer.CursorWait},
+		{pos: f32.Pt(200, 200), name: pointer.CursorDefault},
+	} {
+		r.Queue(pointer.Event{
+			Type:     pointer.Move,
+			Source:   pointer.Mouse,
+			Position: tc.pos,
+		})
+		if got, want := r.Cursor(), tc.name; got != want {
+			t.Errorf("%v: got %q; want %q", tc.pos, got, want)
+		}
+		if got, want := r.CursorImage(), tc.image; got != want {
+			t.Errorf("%v: got cursor image %p; want %p", tc.pos, got, want)
+		}
+	}
+}
+
+func TestPointerLock(t *testing.T) {
+	handler1 := new(int)
+	handler2 := new(int)
+	var ops op.Ops
+
+	types := pointer.Press | pointer.Release | pointer.Move | pointer.Drag | pointer.Enter | pointer.Leave
+	addHandlers := func() {
+		pointer.Rect(image.Rect(0, 0, 100, 100)).Add(&ops)
+		pointer.InputOp{Tag: handler1, Types: types}.Add(&ops)
+		pointer.InputOp{Tag: handler2, Types: types}.Add(&ops)
+	}
+	addHandlers()
+
+	var r Router
+	r.Frame(&ops)
+	r.Queue(
+		pointer.Event{
+			Type:     pointer.Press,
+			Position: f32.Pt(50, 50),
+		},
+	)
+	assertEventSequence(t, r.Events(handler1), pointer.Cancel, pointer.Enter, pointer.Press)
+	assertEventSequence(t, r.Events(handler2), pointer.Cancel, pointer.Enter, pointer.Press)
+	if got, want := r.PointerLock(), pointer.LockNone; got != want {
+		t.Errorf("got lock %v; want %v", got, want)
+	}
+
+	// Lock the pointer to handler1.
+	pointer.LockOp{Tag: handler1, Mode: pointer.LockRelative}.Add(&ops)
+	r.Frame(&ops)
+	if got, want := r.PointerLock(), pointer.LockRelative; got != want {
+		t.Errorf("got lock %v; want %v", got, want)
+	}
+	assertEventSequence(t, r.Events(handler2), pointer.Cancel)
+	r.Queue(
+		// Move outside the hit areas.
+		pointer.Event{
+			Type:     pointer.Move,
+			Position: f32.Pt(50, 50),
+			Delta:    f32.Pt(500, 0),
+		},
+		pointer.Event{
+			Type:     pointer.Release,
+			Position: f32.Pt(50, 50),
+		},
+		pointer.Event{
+			Type:     pointer.Press,
+			Position: f32.Pt(150, 150),
+		},
+	)
+	hev1 := r.Events(handler1)
+	assertEventSequence(t, hev1, pointer.Drag, pointer.Release, pointer.Press)
+	assertEventPriorities(t, hev1, pointer.Grabbed, pointer.Grabbed, pointer.Grabbed)
+	if got, want := hev1[0].(pointer.Event).Delta, f32.Pt(500, 0); got != want {
+		t.Errorf("got delta %v; want %v", got, want)
+	}
+	assertEventSequence(t, r.Events(handler2))
+
+	// Release the lock.
+	ops.Reset()
+	addHandlers()
+	r.Frame(&ops)
+	if got, want := r.PointerLock(), pointer.LockNone; got != want {
+		t.Errorf("got lock %v; want %v", got, want)
+	}
+	// The pointer is still pressed outside the hit area.
+	r.Queue(
+		pointer.Event{
+			Type:     pointer.Release,
+			Position: f32.Pt(150, 150),
+		},
+	)
+	assertEventSequence(t, r.Events(handler1), pointer.Leave, pointer.Release)
+	assertEventSequence(t, r.Events(handler2))
+}
+
+func TestPointerLockTransform(t *testing.T) {
+	handler := new(int)
+	var ops op.Ops
+	op.Affine(f32.Affine2D{}.Offset(f32.Pt(10, 10)).Scale(f32.Point{}, f32.Pt(2, 4))).Add(&ops)
+	addPointerHandler(&ops, handler, image.Rect(0, 0, 100, 100))
+	pointer.LockOp{Tag: handler, Mode: pointer.LockRelative}.Add(&ops)
+
+	var r Router
+	r.Frame(&ops)
+	r.Queue(
+		pointer.Event{
+			Type:     pointer.Move,
+			Position: f32.Pt(50, 50),
+			Delta:    f32.Pt(8, 8),
+		},
+	)
+	evts := r.Events(handler)
+	if len(evts) == 0 {
+		t.Fatal("no events")
+	}
+	// The delta is scaled, but not offset, to the handler.
+	e := evts[len(evts)-1].(pointer.Event)
+	if got, want := e.Delta, f32.Pt(4, 2); got != want {
+		t.Errorf("got delta %v; want %v", got, want)
+	}
+}
+
 // addPointerHandler adds a pointer.InputOp for the tag in a
 // rectangular area.
 func addPointerHandler(ops *op.Ops, tag event.Tag, area image.Rectangle) {
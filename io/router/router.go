@@ -124,6 +124,18 @@ func (q *Router) Cursor() pointer.CursorName {
 	return q.pqueue.cursor
 }
 
+// CursorImage returns the last custom cursor set, or nil if
+// the cursor is named by Cursor.
+func (q *Router) CursorImage() *pointer.CursorImage {
+	return q.pqueue.cursorImage
+}
+
+// PointerLock returns the pointer lock mode requested by the
+// most recent call to Frame.
+func (q *Router) PointerLock() pointer.LockMode {
+	return q.pqueue.lock.mode
+}
+
 func (q *Router) collect() {
 	for encOp, ok := q.reader.Decode(); ok; encOp, ok = q.reader.Decode() {
 		switch opconst.OpType(encOp.Data[0]) {
@@ -113,6 +113,27 @@ type CallOp struct {
 	pc  pc
 }
 
+// CacheOp invokes the operations of Call like Call.Add, but marks
+// them as cacheable. A renderer may render the operations once to an
+// offscreen texture and reuse the texture for later frames.
+//
+// The texture is invalidated when the Ops containing the recording of
+// Call is reset, or when the window size or the current transformation
+// changes. Changes to the whole pixel offset of the transformation,
+// such as from scrolling, reuse the texture unless they reveal parts
+// of the operations that were outside the window when rendered. For
+// caching to be effective, Call must be recorded in an Ops that is not
+// reset every frame. A texture not used by a frame is evicted at the
+// end of that frame. Calls too large for a texture are drawn uncached.
+//
+// The cached operations are rendered clipped to the window, and the
+// rendering is clipped by the current clip. The operations don't
+// inherit the current paint material. Input operations are not
+// affected by caching.
+type CacheOp struct {
+	Call CallOp
+}
+
 // InvalidateOp requests a redraw at the given time. Use
 // the zero value to request an immediate redraw.
 type InvalidateOp struct {
@@ -301,11 +322,20 @@ func (m MacroOp) fill() {
 // panics if the Ops containing the recording
 // has been reset.
 func (c CallOp) Add(o *Ops) {
+	c.write(o, opconst.TypeCall)
+}
+
+func (c CacheOp) Add(o *Ops) {
+	c.Call.write(o, opconst.TypeCache)
+}
+
+// write encodes c as an operation of type t.
+func (c CallOp) write(o *Ops, t opconst.OpType) {
 	if c.ops == nil {
 		return
 	}
-	data := o.Write1(opconst.TypeCallLen, c.ops)
-	data[0] = byte(opconst.TypeCall)
+	data := o.Write1(t.Size(), c.ops)
+	data[0] = byte(t)
 	bo := binary.LittleEndian
 	bo.PutUint32(data[1:], uint32(c.pc.data))
 	bo.PutUint32(data[5:], uint32(c.pc.refs))
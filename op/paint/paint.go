@@ -47,6 +47,31 @@ type LinearGradientOp struct {
 type PaintOp struct {
 }
 
+// Mask is an alpha image for painting with MaskOp. Unlike the images
+// of ImageOps, a Mask may be modified between frames: call Invalidate
+// after changing its pixels to make renderers reload them. See
+// github.com/cybriq/giocore/io/system.FrameEvent for when data
+// referenced by operations is safe to modify.
+type Mask struct {
+	img *image.Alpha
+	// version is incremented by Invalidate. Its address identifies
+	// the mask in caches of renderers.
+	version int
+}
+
+// MaskOp fills the area Rect of a mask, placed with the top-left
+// corner of Rect at the origin, with Color weighted by the mask
+// values. The painted area is clipped by the current clip area like
+// PaintOp, but the current brush is unaffected. MaskOps with a Rect
+// outside the bounds of the mask image are ignored.
+//
+// Renderers place masks drawn with offset transforms on whole pixels.
+type MaskOp struct {
+	Mask  *Mask
+	Rect  image.Rectangle
+	Color color.NRGBA
+}
+
 // NewImageOp creates an ImageOp backed by src. See
 // github.com/cybriq/giocore/io/system.FrameEvent for a description of when data
 // referenced by operations is safe to re-use.
@@ -134,6 +159,39 @@ func (c LinearGradientOp) Add(o *op.Ops) {
 	data[21+3] = c.Color2.A
 }
 
+// NewMask returns a Mask backed by img.
+func NewMask(img *image.Alpha) *Mask {
+	return &Mask{img: img}
+}
+
+// Image returns the image of the mask.
+func (m *Mask) Image() *image.Alpha {
+	return m.img
+}
+
+// Invalidate notifies renderers that the mask image has changed.
+func (m *Mask) Invalidate() {
+	m.version++
+}
+
+func (m MaskOp) Add(o *op.Ops) {
+	r := m.Rect
+	if m.Mask == nil || r.Empty() || !r.In(m.Mask.img.Rect) {
+		return
+	}
+	data := o.Write2(opconst.TypeMaskLen, m.Mask.img, &m.Mask.version)
+	data[0] = byte(opconst.TypeMask)
+	bo := binary.LittleEndian
+	bo.PutUint32(data[1:], uint32(r.Min.X))
+	bo.PutUint32(data[5:], uint32(r.Min.Y))
+	bo.PutUint32(data[9:], uint32(r.Max.X))
+	bo.PutUint32(data[13:], uint32(r.Max.Y))
+	data[17+0] = m.Color.R
+	data[17+1] = m.Color.G
+	data[17+2] = m.Color.B
+	data[17+3] = m.Color.A
+}
+
 func (d PaintOp) Add(o *op.Ops) {
 	data := o.Write(opconst.TypePaintLen)
 	data[0] = byte(opconst.TypePaint)
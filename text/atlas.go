@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/op/paint"
+)
+
+const (
+	// atlasSize is the width and height of atlas pages.
+	atlasSize = 512
+	// maxAtlasPages is the number of atlas pages. The glyphs of the
+	// oldest page are dropped to make room for another.
+	maxAtlasPages = 8
+	// subpixelBuckets is the number of horizontal subpixel positions
+	// glyphs are rasterized at.
+	subpixelBuckets = 4
+)
+
+// glyphAtlas caches rasterized glyphs in alpha mask pages and paints
+// text with paint.MaskOps instead of filling glyph outlines. The color
+// of the text is applied when painting, so every color shares the
+// glyphs of the atlas.
+//
+// Glyphs are rasterized at one of subpixelBuckets horizontal
+// positions, chosen by their position relative to the layout origin;
+// text painted at fractional offsets uses the buckets of its whole
+// pixel positions. Renderers place masks on whole pixels of the
+// absolute glyph positions.
+type glyphAtlas struct {
+	// maxPPEM is the largest text size painted from the atlas.
+	maxPPEM fixed.Int26_6
+	glyphs  map[maskKey]atlasGlyph
+	// pages contains the atlas pages, oldest first. Glyphs are placed
+	// in the last page.
+	pages []*atlasPage
+}
+
+type maskKey struct {
+	face   Face
+	ppem   fixed.Int26_6
+	id     GlyphID
+	bucket int
+}
+
+// atlasPage is a mask that glyphs are drawn into as they are placed.
+// Pages are never cleared, so the operations of previous frames keep
+// painting their glyphs.
+type atlasPage struct {
+	mask *paint.Mask
+	// x and y are the position of the next glyph in the current row,
+	// and rowHeight is the height of the row.
+	x, y, rowHeight int
+}
+
+// atlasGlyph is a glyph placed in an atlas page.
+type atlasGlyph struct {
+	// page is nil for glyphs that are not painted from the atlas.
+	page *atlasPage
+	// rect is the area of the glyph in the page.
+	rect image.Rectangle
+	// origin is the position of the glyph relative to the pen
+	// position.
+	origin image.Point
+}
+
+// paint records the operations for painting the glyphs of a layout
+// in a color. It reports false if the layout contains no glyphs or
+// the face doesn't rasterize glyphs.
+func (a *glyphAtlas) paint(face Face, ppem fixed.Int26_6, l Layout, col color.NRGBA) (op.CallOp, bool) {
+	rf, ok := face.(RasterFace)
+	if !ok || l.Glyphs == nil || ppem > a.maxPPEM {
+		return op.CallOp{}, false
+	}
+	if a.glyphs == nil {
+		a.glyphs = make(map[maskKey]atlasGlyph)
+	}
+	ops := new(op.Ops)
+	m := op.Record(ops)
+	var x fixed.Int26_6
+	for _, g := range l.Glyphs {
+		px, py := x+g.Offset.X, g.Offset.Y
+		x += g.Advance
+		ix := px.Floor()
+		bucket := int(px-fixed.I(ix)) * subpixelBuckets / 64
+		key := maskKey{face: rf, ppem: ppem, id: g.ID, bucket: bucket}
+		ag, ok := a.glyphs[key]
+		if !ok {
+			ag = a.place(rf, key)
+			a.glyphs[key] = ag
+		}
+		if ag.page == nil {
+			continue
+		}
+		stack := op.Save(ops)
+		op.Offset(f32.Point{
+			X: float32(ix + ag.origin.X),
+			Y: float32(py)/64 + float32(ag.origin.Y),
+		}).Add(ops)
+		paint.MaskOp{Mask: ag.page.mask, Rect: ag.rect, Color: col}.Add(ops)
+		stack.Load()
+	}
+	return m.Stop(), true
+}
+
+// place rasterizes the glyph of a key and draws it into the current
+// page, starting a new page if the current page is full.
+func (a *glyphAtlas) place(face RasterFace, key maskKey) atlasGlyph {
+	offset := fixed.Point26_6{X: fixed.Int26_6(key.bucket * 64 / subpixelBuckets)}
+	mask, origin := face.Rasterize(key.ppem, key.id, offset)
+	if mask == nil {
+		return atlasGlyph{}
+	}
+	// Surround glyphs with a transparent pixel to avoid sampling
+	// their neighbours.
+	size := mask.Bounds().Size()
+	padded := size.Add(image.Pt(2, 2))
+	if padded.X > atlasSize || padded.Y > atlasSize {
+		return atlasGlyph{}
+	}
+	var p *atlasPage
+	if n := len(a.pages); n > 0 {
+		p = a.pages[n-1]
+	}
+	if p != nil && p.x+padded.X > atlasSize {
+		p.x, p.y, p.rowHeight = 0, p.y+p.rowHeight, 0
+	}
+	if p == nil || p.y+padded.Y > atlasSize {
+		p = a.newPage()
+	}
+	pos := image.Pt(p.x+1, p.y+1)
+	r := image.Rectangle{Min: pos, Max: pos.Add(size)}
+	draw.Draw(p.mask.Image(), r, mask, mask.Bounds().Min, draw.Src)
+	p.mask.Invalidate()
+	p.x += padded.X
+	if padded.Y > p.rowHeight {
+		p.rowHeight = padded.Y
+	}
+	return atlasGlyph{page: p, rect: r, origin: origin}
+}
+
+// newPage adds an empty page, dropping the glyphs of the oldest page
+// if there are too many.
+func (a *glyphAtlas) newPage() *atlasPage {
+	if len(a.pages) >= maxAtlasPages {
+		old := a.pages[0]
+		for k, g := range a.glyphs {
+			if g.page == old {
+				delete(a.glyphs, k)
+			}
+		}
+		a.pages = append(a.pages[:0], a.pages[1:]...)
+	}
+	p := &atlasPage{
+		mask: paint.NewMask(image.NewAlpha(image.Rect(0, 0, atlasSize, atlasSize))),
+	}
+	a.pages = append(a.pages, p)
+	return p
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/op"
+)
+
+// squareFace rasterizes every glyph as a square the size of its
+// glyph id.
+type squareFace struct {
+	rasterized int
+}
+
+func (f *squareFace) Layout(ppem fixed.Int26_6, maxWidth int, txt io.Reader) ([]Line, error) {
+	return nil, nil
+}
+
+func (f *squareFace) Shape(ppem fixed.Int26_6, str Layout) op.CallOp {
+	return op.CallOp{}
+}
+
+func (f *squareFace) Rasterize(ppem fixed.Int26_6, id GlyphID, offset fixed.Point26_6) (*image.Alpha, image.Point) {
+	f.rasterized++
+	if id == 0 {
+		return nil, image.Point{}
+	}
+	mask := image.NewAlpha(image.Rect(0, 0, int(id), int(id)))
+	for i := range mask.Pix {
+		mask.Pix[i] = 0xff
+	}
+	return mask, image.Pt(0, -int(id))
+}
+
+func TestGlyphAtlas(t *testing.T) {
+	face := new(squareFace)
+	a := &glyphAtlas{maxPPEM: fixed.I(20)}
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	l := Layout{
+		Text: "abca",
+		Glyphs: []Glyph{
+			{ID: 10, Advance: fixed.I(10)},
+			// Glyph without mask.
+			{ID: 0, Advance: fixed.I(5)},
+			{ID: 20, Advance: fixed.I(20)},
+			{ID: 10, Advance: fixed.I(10)},
+		},
+	}
+	if _, ok := a.paint(face, fixed.I(21), l, red); ok {
+		t.Error("text larger than maxPPEM painted from atlas")
+	}
+	if _, ok := a.paint(face, fixed.I(20), l, red); !ok {
+		t.Fatal("text not painted from atlas")
+	}
+	if face.rasterized != 3 {
+		t.Errorf("rasterized %d glyphs, expected 3", face.rasterized)
+	}
+	if len(a.pages) != 1 {
+		t.Fatalf("got %d atlas pages, expected 1", len(a.pages))
+	}
+	g := a.glyphs[maskKey{face: face, ppem: fixed.I(20), id: 20}]
+	if want := image.Rect(13, 1, 33, 21); g.rect != want {
+		t.Errorf("got glyph rect %v, expected %v", g.rect, want)
+	}
+	img := g.page.mask.Image()
+	if got := img.AlphaAt(20, 10).A; got != 0xff {
+		t.Errorf("got glyph pixel %#x", got)
+	}
+	if got := img.AlphaAt(12, 10).A; got != 0 {
+		t.Errorf("got padding pixel %#x", got)
+	}
+
+	// Other colors reuse the placed glyphs.
+	a.paint(face, fixed.I(20), l, red)
+	a.paint(face, fixed.I(20), l, color.NRGBA{B: 0xff, A: 0x80})
+	if face.rasterized != 3 {
+		t.Errorf("rasterized %d glyphs, expected 3", face.rasterized)
+	}
+
+	// Subpixel positions are rasterized separately, in the same page.
+	l.Glyphs[0].Offset.X = 16
+	a.paint(face, fixed.I(20), l, red)
+	if face.rasterized != 4 {
+		t.Errorf("rasterized %d glyphs, expected 4", face.rasterized)
+	}
+	ng := a.glyphs[maskKey{face: face, ppem: fixed.I(20), id: 10, bucket: 1}]
+	if want := image.Rect(35, 1, 45, 11); ng.page != g.page || ng.rect != want {
+		t.Errorf("got new glyph rect %v, expected %v in the first page", ng.rect, want)
+	}
+	if g2 := a.glyphs[maskKey{face: face, ppem: fixed.I(20), id: 20}]; g2 != g {
+		t.Error("placing a glyph moved previous glyphs")
+	}
+}
+
+func TestGlyphAtlasPages(t *testing.T) {
+	face := new(squareFace)
+	a := &glyphAtlas{maxPPEM: fixed.I(500)}
+	// Four glyphs fill a page.
+	const size = atlasSize/2 - 2
+	var l Layout
+	for i := 0; i < 5; i++ {
+		l.Glyphs = append(l.Glyphs, Glyph{ID: GlyphID(size - i)})
+	}
+	a.paint(face, fixed.I(20), l, color.NRGBA{})
+	if len(a.glyphs) != 5 || len(a.pages) != 2 {
+		t.Errorf("got %d glyphs in %d pages, expected 5 in 2", len(a.glyphs), len(a.pages))
+	}
+	first := a.pages[0]
+	// Filling more pages drops the glyphs of the oldest page.
+	for i := 5; len(a.pages) < maxAtlasPages || a.pages[0] == first; i++ {
+		l := Layout{Glyphs: []Glyph{{ID: GlyphID(size - i)}}}
+		a.paint(face, fixed.I(20), l, color.NRGBA{})
+	}
+	if len(a.pages) != maxAtlasPages {
+		t.Errorf("got %d pages, expected %d", len(a.pages), maxAtlasPages)
+	}
+	for k, g := range a.glyphs {
+		if g.page == first {
+			t.Errorf("glyph %d of dropped page kept", k.id)
+		}
+	}
+	if _, ok := a.glyphs[maskKey{face: face, ppem: fixed.I(20), id: size}]; ok {
+		t.Error("glyph of dropped page kept")
+	}
+}
+
+func TestSetGlyphAtlas(t *testing.T) {
+	face := new(squareFace)
+	c := NewCache([]FontFace{{Face: face}})
+	l := Layout{Text: "a", Glyphs: []Glyph{{ID: 10}}}
+	c.PaintText(Font{}, fixed.I(20), l, color.NRGBA{A: 0xff})
+	if face.rasterized != 0 {
+		t.Fatal("text painted from a disabled atlas")
+	}
+	c.SetGlyphAtlas(fixed.I(20))
+	c.PaintText(Font{}, fixed.I(20), l, color.NRGBA{A: 0xff})
+	if face.rasterized != 1 {
+		t.Error("text painted with the previous atlas size")
+	}
+}
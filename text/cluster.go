@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"sort"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// Cluster is a grapheme cluster of a Layout: a sequence of runes
+// displayed and edited as a single character, such as a letter with
+// combining accents, a flag or an emoji with modifiers. Clusters are
+// the positions of a caret.
+type Cluster struct {
+	// Start and End are the byte offsets of the cluster in the
+	// Layout text.
+	Start, End int
+	// Advance is the sum of the advances of the runes of the
+	// cluster.
+	Advance fixed.Int26_6
+}
+
+// ClusterIndex returns the index of the cluster that contains the
+// byte offset, or the number of clusters if the offset is at or past
+// the end of the text. Every rune is a cluster of a layout without
+// Clusters.
+func (l Layout) ClusterIndex(offset int) int {
+	return clusterIndex(clustersOf(l), offset)
+}
+
+// clusterIndex is like Layout.ClusterIndex for a slice of clusters.
+func clusterIndex(clusters []Cluster, offset int) int {
+	return sort.Search(len(clusters), func(i int) bool {
+		return clusters[i].End > offset
+	})
+}
+
+// ClusterX returns the x position of the caret before the cluster
+// with index idx, relative to the start of the line. The index of
+// the position after the last cluster is the number of clusters.
+// The caret before a cluster of a right-to-left run is at the right
+// edge of the cluster.
+func (l Line) ClusterX(idx int) fixed.Int26_6 {
+	clusters, xs, rtl := l.clusterPositions()
+	n := len(xs)
+	switch {
+	case n == 0:
+		return 0
+	case idx < 0:
+		idx = 0
+	case idx >= n:
+		// Use the trailing edge of the last cluster.
+		c := clusters[n-1]
+		if rtl[n-1] {
+			return xs[n-1]
+		}
+		return xs[n-1] + c.Advance
+	}
+	if rtl[idx] {
+		return xs[idx] + clusters[idx].Advance
+	}
+	return xs[idx]
+}
+
+// ClusterAt returns the index of the caret position closest to the x
+// position, relative to the start of the line.
+func (l Line) ClusterAt(x fixed.Int26_6) int {
+	clusters, xs, rtl := l.clusterPositions()
+	best, dist := 0, fixed.Int26_6(-1)
+	consider := func(idx int, pos fixed.Int26_6) {
+		d := pos - x
+		if d < 0 {
+			d = -d
+		}
+		if dist == -1 || d < dist {
+			best, dist = idx, d
+		}
+	}
+	for i, left := range xs {
+		right := left + clusters[i].Advance
+		if rtl[i] {
+			left, right = right, left
+		}
+		// The leading edge is the position before the cluster,
+		// the trailing edge the position after it.
+		consider(i, left)
+		consider(i+1, right)
+	}
+	return best
+}
+
+// clusterPositions returns the clusters of the line, the x position
+// of the left edge of every cluster, and whether the cluster is part
+// of a right-to-left run.
+func (l Line) clusterPositions() ([]Cluster, []fixed.Int26_6, []bool) {
+	clusters := clustersOf(l.Layout)
+	xs := make([]fixed.Int26_6, len(clusters))
+	rtl := make([]bool, len(clusters))
+	runs := l.Runs
+	if len(runs) == 0 {
+		runs = []Run{{Direction: LTR, End: len(l.Layout.Text)}}
+	}
+	var x fixed.Int26_6
+	for _, r := range runs {
+		start, end := clusterIndex(clusters, r.Start), clusterIndex(clusters, r.End)
+		for k := start; k < end; k++ {
+			i := k
+			if r.Direction == RTL {
+				i = start + end - 1 - k
+			}
+			xs[i] = x
+			rtl[i] = r.Direction == RTL
+			x += clusters[i].Advance
+		}
+	}
+	return clusters, xs, rtl
+}
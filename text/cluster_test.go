@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+func TestClusterIndex(t *testing.T) {
+	l := Layout{
+		Text: "ae\u0301x",
+		Clusters: []Cluster{
+			{Start: 0, End: 1},
+			{Start: 1, End: 4},
+			{Start: 4, End: 5},
+		},
+	}
+	for offset, want := range []int{0, 1, 1, 1, 2, 3} {
+		if got := l.ClusterIndex(offset); got != want {
+			t.Errorf("ClusterIndex(%d) = %d, expected %d", offset, got, want)
+		}
+	}
+}
+
+func TestClusterX(t *testing.T) {
+	// "abCD" where CD is right-to-left, displayed as "abDC".
+	l := Line{
+		Layout: Layout{
+			Text: "abCD",
+			Clusters: []Cluster{
+				{Start: 0, End: 1, Advance: fixed.I(1)},
+				{Start: 1, End: 2, Advance: fixed.I(2)},
+				{Start: 2, End: 3, Advance: fixed.I(4)},
+				{Start: 3, End: 4, Advance: fixed.I(8)},
+			},
+		},
+		Runs: []Run{
+			{Direction: LTR, Start: 0, End: 2},
+			{Direction: RTL, Start: 2, End: 4},
+		},
+	}
+	xs := []int{0, 1, 15, 11, 3}
+	for idx, want := range xs {
+		if got := l.ClusterX(idx); got != fixed.I(want) {
+			t.Errorf("ClusterX(%d) = %v, expected %v", idx, got, fixed.I(want))
+		}
+	}
+	tests := []struct {
+		x   int
+		idx int
+	}{
+		{-5, 0},
+		{2, 1},
+		{14, 2},
+		{10, 3},
+		{4, 2},
+		{20, 2},
+	}
+	for _, test := range tests {
+		if got := l.ClusterAt(fixed.I(test.x)); got != test.idx {
+			t.Errorf("ClusterAt(%d) = %d, expected %d", test.x, got, test.idx)
+		}
+	}
+	// Lines without runs are left-to-right.
+	l.Runs = nil
+	if got, want := l.ClusterX(3), fixed.I(7); got != want {
+		t.Errorf("ClusterX(3) = %v, expected %v", got, want)
+	}
+	var empty Line
+	if got := empty.ClusterAt(fixed.I(10)); got != 0 {
+		t.Errorf("empty ClusterAt = %d, expected 0", got)
+	}
+}
+
+func TestClusterWithoutClusters(t *testing.T) {
+	// Layouts of faces that don't report clusters have a cluster for
+	// every rune.
+	l := Line{
+		Layout: Layout{
+			Text:     "aéx",
+			Advances: []fixed.Int26_6{fixed.I(1), fixed.I(2), fixed.I(4)},
+		},
+	}
+	for offset, want := range []int{0, 1, 1, 2, 3} {
+		if got := l.Layout.ClusterIndex(offset); got != want {
+			t.Errorf("ClusterIndex(%d) = %d, expected %d", offset, got, want)
+		}
+	}
+	for idx, want := range []int{0, 1, 3, 7} {
+		if got := l.ClusterX(idx); got != fixed.I(want) {
+			t.Errorf("ClusterX(%d) = %v, expected %v", idx, got, fixed.I(want))
+		}
+	}
+	if got := l.ClusterAt(fixed.I(4)); got != 2 {
+		t.Errorf("ClusterAt(4) = %d, expected 2", got)
+	}
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/op/clip"
+)
+
+// Decoration is a set of lines drawn along text.
+type Decoration uint8
+
+const (
+	// Underline draws a line below the baseline.
+	Underline Decoration = 1 << iota
+	// Strikethrough draws a line through the text.
+	Strikethrough
+	// Overline draws a line above the text.
+	Overline
+)
+
+// DecorationClip returns a clip operation for the decoration lines of
+// a line of text, relative to the start of its baseline. The lines
+// are placed according to the metrics of the face, or proportional to
+// the text size if the face is not a DecorationFace. Underlines are
+// interrupted where they cross the outlines of glyphs, such as their
+// descenders.
+func DecorationClip(face Face, ppem fixed.Int26_6, l Line, d Decoration) op.CallOp {
+	ops := new(op.Ops)
+	macro := op.Record(ops)
+	var p clip.Path
+	p.Begin(ops)
+	for _, r := range decorationRects(face, ppem, l, d) {
+		p.MoveTo(f32.Point{X: float32(r.Min.X) / 64, Y: float32(r.Min.Y) / 64})
+		p.LineTo(f32.Point{X: float32(r.Max.X) / 64, Y: float32(r.Min.Y) / 64})
+		p.LineTo(f32.Point{X: float32(r.Max.X) / 64, Y: float32(r.Max.Y) / 64})
+		p.LineTo(f32.Point{X: float32(r.Min.X) / 64, Y: float32(r.Max.Y) / 64})
+		p.Close()
+	}
+	clip.Outline{Path: p.End()}.Op().Add(ops)
+	return macro.Stop()
+}
+
+// decorationRects returns the rectangles of the decoration lines of a
+// line of text.
+func decorationRects(face Face, ppem fixed.Int26_6, l Line, d Decoration) []fixed.Rectangle26_6 {
+	df, _ := face.(DecorationFace)
+	m := decorationMetrics(df, ppem)
+	var rects []fixed.Rectangle26_6
+	rect := func(x0, x1, y, thickness fixed.Int26_6) {
+		rects = append(rects, fixed.Rectangle26_6{
+			Min: fixed.Point26_6{X: x0, Y: y},
+			Max: fixed.Point26_6{X: x1, Y: y + thickness},
+		})
+	}
+	if d&Underline != 0 {
+		y, t := m.UnderlinePosition, m.UnderlineThickness
+		// Leave a gap of the line thickness around glyph outlines.
+		var ink []Extent
+		if df != nil {
+			ink = df.InkExtents(ppem, l.Layout, y-t, y+2*t)
+		}
+		x := fixed.Int26_6(0)
+		for _, e := range ink {
+			if start := e.Start - t; start > x {
+				rect(x, start, y, t)
+			}
+			if end := e.End + t; end > x {
+				x = end
+			}
+		}
+		if x < l.Width {
+			rect(x, l.Width, y, t)
+		}
+	}
+	if d&Strikethrough != 0 {
+		rect(0, l.Width, m.StrikeoutPosition, m.StrikeoutThickness)
+	}
+	if d&Overline != 0 {
+		rect(0, l.Width, -l.Ascent, m.UnderlineThickness)
+	}
+	return rects
+}
+
+// decorationMetrics returns the decoration metrics of a face, with
+// missing metrics proportional to the text size. Lines are at least
+// a pixel thick.
+func decorationMetrics(face DecorationFace, ppem fixed.Int26_6) DecorationMetrics {
+	var m DecorationMetrics
+	if face != nil {
+		m = face.Decorations(ppem)
+	}
+	if m.UnderlineThickness <= 0 {
+		m.UnderlinePosition = ppem / 10
+		m.UnderlineThickness = ppem / 16
+	}
+	if m.StrikeoutThickness <= 0 {
+		m.StrikeoutPosition = -ppem * 3 / 10
+		m.StrikeoutThickness = m.UnderlineThickness
+	}
+	if m.UnderlineThickness < fixed.I(1) {
+		m.UnderlineThickness = fixed.I(1)
+	}
+	if m.StrikeoutThickness < fixed.I(1) {
+		m.StrikeoutThickness = fixed.I(1)
+	}
+	return m
+}
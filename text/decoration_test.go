@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// decorationFace has fixed decoration metrics and a glyph crossing
+// the underline.
+type decorationFace struct {
+	squareFace
+}
+
+func (f *decorationFace) Decorations(ppem fixed.Int26_6) DecorationMetrics {
+	return DecorationMetrics{
+		UnderlinePosition:  fixed.I(2),
+		UnderlineThickness: fixed.I(1),
+		StrikeoutPosition:  fixed.I(-4),
+		StrikeoutThickness: fixed.I(2),
+	}
+}
+
+func (f *decorationFace) InkExtents(ppem fixed.Int26_6, str Layout, top, bottom fixed.Int26_6) []Extent {
+	if top != fixed.I(1) || bottom != fixed.I(4) {
+		return nil
+	}
+	return []Extent{{Start: fixed.I(10), End: fixed.I(15)}}
+}
+
+func rect(x0, y0, x1, y1 int) fixed.Rectangle26_6 {
+	return fixed.Rectangle26_6{
+		Min: fixed.Point26_6{X: fixed.I(x0), Y: fixed.I(y0)},
+		Max: fixed.Point26_6{X: fixed.I(x1), Y: fixed.I(y1)},
+	}
+}
+
+func TestDecorationRects(t *testing.T) {
+	l := Line{Width: fixed.I(30), Ascent: fixed.I(8)}
+	face := new(decorationFace)
+	got := decorationRects(face, fixed.I(10), l, Underline|Strikethrough|Overline)
+	want := []fixed.Rectangle26_6{
+		// The underline skips the glyph and a gap around it.
+		rect(0, 2, 9, 3),
+		rect(16, 2, 30, 3),
+		rect(0, -4, 30, -2),
+		rect(0, -8, 30, -7),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rects %v, expected %v", got, want)
+	}
+
+	// Faces without metrics get lines proportional to the size.
+	got = decorationRects(new(squareFace), fixed.I(20), l, Underline|Strikethrough)
+	want = []fixed.Rectangle26_6{
+		rect(0, 2, 30, 3),
+		rect(0, -6, 30, -5),
+	}
+	// 20/16 is 1.25 pixels thick.
+	want[0].Max.Y += 16
+	want[1].Max.Y += 16
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rects %v, expected %v", got, want)
+	}
+}
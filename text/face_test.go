@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import "testing"
+
+func TestFaceForStyle(t *testing.T) {
+	regular, bold, italic := new(squareFace), new(squareFace), new(squareFace)
+	c := NewCache([]FontFace{
+		{Font: Font{Typeface: "sans"}, Face: regular},
+		{Font: Font{Typeface: "sans", Weight: Bold}, Face: bold},
+		{Font: Font{Typeface: "sans", Style: Italic, Weight: Medium}, Face: italic},
+	})
+	tests := []struct {
+		font Font
+		want Face
+	}{
+		{Font{Typeface: "sans", Weight: Bold}, bold},
+		// The nearest weight is preferred over Normal.
+		{Font{Typeface: "sans", Weight: Bold - 50}, bold},
+		{Font{Typeface: "sans", Weight: Medium - 50}, regular},
+		// The style is preferred over the weight.
+		{Font{Typeface: "sans", Style: Italic, Weight: Bold}, italic},
+		{Font{Typeface: "serif", Style: Italic}, italic},
+	}
+	for _, test := range tests {
+		if got := c.lookup(test.font).face; got != test.want {
+			t.Errorf("%+v: got face %p, expected %p", test.font, got, test.want)
+		}
+	}
+}
@@ -3,6 +3,8 @@
 package text
 
 import (
+	"image/color"
+
 	"golang.org/x/image/math/fixed"
 
 	"github.com/cybriq/giocore/op"
@@ -13,6 +15,11 @@ type layoutCache struct {
 	head, tail *layoutElem
 }
 
+type measureCache struct {
+	m          map[layoutKey]*measureElem
+	head, tail *measureElem
+}
+
 type pathCache struct {
 	m          map[pathKey]*path
 	head, tail *path
@@ -24,6 +31,12 @@ type layoutElem struct {
 	layout     []Line
 }
 
+type measureElem struct {
+	next, prev *measureElem
+	key        layoutKey
+	val        Measurement
+}
+
 type path struct {
 	next, prev *path
 	key        pathKey
@@ -34,15 +47,50 @@ type layoutKey struct {
 	ppem     fixed.Int26_6
 	maxWidth int
 	str      string
+	// opts is the key of the LayoutOptions of the layout.
+	opts optionsKey
 }
 
 type pathKey struct {
 	ppem fixed.Int26_6
 	str  string
+	// layout is the layoutHash of the layout, to tell apart layouts
+	// of the same text with different options.
+	layout uint64
+	// color is the color of text painted from a glyph atlas.
+	color color.NRGBA
+	// decoration is the decoration of a decoration clip.
+	decoration Decoration
 }
 
 const maxSize = 1000
 
+// layoutHash returns the FNV-1a hash of the rune advances and glyph
+// positions of a layout.
+func layoutHash(l Layout) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	add := func(v int32) {
+		for i := 0; i < 4; i++ {
+			h ^= uint64(byte(v >> (8 * i)))
+			h *= prime64
+		}
+	}
+	for _, a := range l.Advances {
+		add(int32(a))
+	}
+	for _, g := range l.Glyphs {
+		add(int32(g.ID))
+		add(int32(g.Advance))
+		add(int32(g.Offset.X))
+		add(int32(g.Offset.Y))
+	}
+	return h
+}
+
 func (l *layoutCache) Get(k layoutKey) ([]Line, bool) {
 	if lt, ok := l.m[k]; ok {
 		l.remove(lt)
@@ -82,6 +130,45 @@ func (l *layoutCache) insert(lt *layoutElem) {
 	lt.next.prev = lt
 }
 
+func (c *measureCache) Get(k layoutKey) (Measurement, bool) {
+	if v, ok := c.m[k]; ok {
+		c.remove(v)
+		c.insert(v)
+		return v.val, true
+	}
+	return Measurement{}, false
+}
+
+func (c *measureCache) Put(k layoutKey, v Measurement) {
+	if c.m == nil {
+		c.m = make(map[layoutKey]*measureElem)
+		c.head = new(measureElem)
+		c.tail = new(measureElem)
+		c.head.prev = c.tail
+		c.tail.next = c.head
+	}
+	val := &measureElem{key: k, val: v}
+	c.m[k] = val
+	c.insert(val)
+	if len(c.m) > maxSize {
+		oldest := c.tail.next
+		c.remove(oldest)
+		delete(c.m, oldest.key)
+	}
+}
+
+func (c *measureCache) remove(v *measureElem) {
+	v.next.prev = v.prev
+	v.prev.next = v.next
+}
+
+func (c *measureCache) insert(v *measureElem) {
+	v.next = c.head
+	v.prev = c.head.prev
+	v.prev.next = v
+	v.next.prev = v
+}
+
 func (c *pathCache) Get(k pathKey) (op.CallOp, bool) {
 	if v, ok := c.m[k]; ok {
 		c.remove(v)
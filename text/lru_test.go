@@ -21,6 +21,18 @@ func TestLayoutLRU(t *testing.T) {
 	testLRU(t, put, get)
 }
 
+func TestMeasureLRU(t *testing.T) {
+	c := new(measureCache)
+	put := func(i int) {
+		c.Put(layoutKey{str: strconv.Itoa(i)}, Measurement{})
+	}
+	get := func(i int) bool {
+		_, ok := c.Get(layoutKey{str: strconv.Itoa(i)})
+		return ok
+	}
+	testLRU(t, put, get)
+}
+
 func TestPathLRU(t *testing.T) {
 	c := new(pathCache)
 	put := func(i int) {
@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"image"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/op/paint"
+)
+
+// Paragraph describes the layout of a paragraph of text.
+type Paragraph struct {
+	// Alignment is the horizontal alignment of the lines. Start
+	// and End are relative to the direction of each line.
+	Alignment Alignment
+	// MinWidth is the minimum width of the paragraph. Lines are
+	// aligned within the larger of MinWidth and the width of the
+	// widest line.
+	MinWidth int
+	// LineHeight scales the height of the lines, the sum of their
+	// ascent and descent. The extra space is divided equally above
+	// and below each line. If zero, 1 is used.
+	LineHeight float32
+	// MaxLines limits the number of lines. If zero, the number of
+	// lines is unlimited.
+	MaxLines int
+	// Ellipsis is appended to the last line of a paragraph that is
+	// truncated by MaxLines, for example "…". The line is shortened
+	// to make room for it.
+	Ellipsis string
+	// Options contains the tab stops and spacing of the text. They
+	// are ignored if the Shaper is not an OptionsShaper.
+	Options LayoutOptions
+}
+
+// ParagraphLayout is a Paragraph laid out by a Shaper.
+type ParagraphLayout struct {
+	// Lines contains the positioned lines of the paragraph.
+	Lines []PositionedLine
+	// Size is the size of the paragraph.
+	Size image.Point
+	// Truncated reports whether lines were removed because of
+	// MaxLines.
+	Truncated bool
+	// Call paints the lines of the paragraph with the current
+	// paint material, and the color glyphs if the Shaper is a
+	// ColorShaper.
+	Call op.CallOp
+}
+
+// PositionedLine is a Line of a ParagraphLayout.
+type PositionedLine struct {
+	Line
+	// Offset is the position of the start of the line baseline,
+	// relative to the top left corner of the paragraph.
+	Offset fixed.Point26_6
+}
+
+// Layout a text with a Shaper and record the operations for painting
+// it in ops.
+func (p Paragraph) Layout(ops *op.Ops, s Shaper, font Font, size fixed.Int26_6, maxWidth int, str string) ParagraphLayout {
+	lines := p.layoutString(s, font, size, maxWidth, str)
+	var pl ParagraphLayout
+	if p.MaxLines > 0 && len(lines) > p.MaxLines {
+		// Copy the lines; they may belong to the Shaper cache.
+		lines = append([]Line(nil), lines[:p.MaxLines]...)
+		pl.Truncated = true
+		if p.Ellipsis != "" {
+			last := &lines[len(lines)-1]
+			*last = p.ellipsize(s, font, size, maxWidth, *last)
+		}
+	}
+	lineHeight := p.LineHeight
+	if lineHeight <= 0 {
+		lineHeight = 1
+	}
+	width := fixed.I(p.MinWidth)
+	for _, l := range lines {
+		if l.Width > width {
+			width = l.Width
+		}
+	}
+	pl.Size.X = width.Ceil()
+	var y fixed.Int26_6
+	for _, l := range lines {
+		h := l.Ascent + l.Descent
+		leading := fixed.Int26_6(float32(h)*lineHeight) - h
+		pl.Lines = append(pl.Lines, PositionedLine{
+			Line: l,
+			Offset: fixed.Point26_6{
+				X: align(p.Alignment, l.Direction, l.Width, pl.Size.X),
+				Y: y + leading/2 + l.Ascent,
+			},
+		})
+		y += h + leading
+	}
+	pl.Size.Y = y.Ceil()
+	cs, _ := s.(ColorShaper)
+	m := op.Record(ops)
+	for _, l := range pl.Lines {
+		stack := op.Save(ops)
+		op.Offset(f32.Point{
+			X: float32(l.Offset.X) / 64,
+			Y: float32(l.Offset.Y) / 64,
+		}).Add(ops)
+		tstack := op.Save(ops)
+		s.Shape(font, size, l.Layout).Add(ops)
+		paint.PaintOp{}.Add(ops)
+		tstack.Load()
+		if cs != nil {
+			cs.ShapeColor(font, size, l.Layout).Add(ops)
+		}
+		stack.Load()
+	}
+	pl.Call = m.Stop()
+	return pl
+}
+
+// unboundedWidth is a maximum width for laying out text on a single
+// line.
+const unboundedWidth = 1 << 24
+
+// ellipsize shortens a line to make room for the ellipsis and lays
+// out the result.
+func (p Paragraph) ellipsize(s Shaper, font Font, size fixed.Int26_6, maxWidth int, l Line) Line {
+	var ellipsis fixed.Int26_6
+	for _, el := range p.layoutString(s, font, size, unboundedWidth, p.Ellipsis) {
+		ellipsis += el.Width
+	}
+	// Estimate the clusters that fit with the ellipsis from their
+	// advances.
+	avail := fixed.I(maxWidth) - ellipsis
+	clusters := clustersOf(l.Layout)
+	var w fixed.Int26_6
+	n := 0
+	for n < len(clusters) && w+clusters[n].Advance <= avail {
+		w += clusters[n].Advance
+		n++
+	}
+	// Shaping the shortened text may change its width, so remove
+	// clusters until it fits.
+	for {
+		end := 0
+		if n > 0 {
+			end = clusters[n-1].End
+		}
+		str := strings.TrimRightFunc(l.Layout.Text[:end], unicode.IsSpace) + p.Ellipsis
+		lines := p.layoutString(s, font, size, unboundedWidth, str)
+		if len(lines) == 0 {
+			return l
+		}
+		if lines[0].Width <= fixed.I(maxWidth) || n == 0 {
+			return lines[0]
+		}
+		n--
+	}
+}
+
+// layoutString lays out a string with the options of the paragraph.
+func (p Paragraph) layoutString(s Shaper, font Font, size fixed.Int26_6, maxWidth int, str string) []Line {
+	if o, ok := s.(OptionsShaper); ok {
+		return o.LayoutStringWithOptions(font, size, maxWidth, p.Options, str)
+	}
+	return s.LayoutString(font, size, maxWidth, str)
+}
+
+// clustersOf returns the grapheme clusters of a layout, or a cluster
+// for every rune if the layout doesn't contain clusters.
+func clustersOf(l Layout) []Cluster {
+	if l.Clusters != nil {
+		return l.Clusters
+	}
+	var clusters []Cluster
+	for i, adv := range l.Advances {
+		start := 0
+		if i > 0 {
+			start = clusters[i-1].End
+		}
+		_, n := utf8.DecodeRuneInString(l.Text[start:])
+		clusters = append(clusters, Cluster{Start: start, End: start + n, Advance: adv})
+	}
+	return clusters
+}
+
+// align returns the x offset of a line with the given width and
+// direction in a paragraph of width maxWidth.
+func align(a Alignment, dir Direction, width fixed.Int26_6, maxWidth int) fixed.Int26_6 {
+	if dir == RTL {
+		switch a {
+		case Start:
+			a = End
+		case End:
+			a = Start
+		}
+	}
+	mw := fixed.I(maxWidth)
+	switch a {
+	case Middle:
+		return fixed.I(((mw - width) / 2).Floor())
+	case End:
+		return fixed.I((mw - width).Floor())
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text_test
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/font/opentype"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/text"
+)
+
+func newTestShaper(t *testing.T) text.Shaper {
+	face, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return text.NewCache([]text.FontFace{{Face: face}})
+}
+
+func TestParagraphAlignment(t *testing.T) {
+	s := newTestShaper(t)
+	ops := new(op.Ops)
+	size := fixed.I(20)
+	p := text.Paragraph{Alignment: text.End}
+	pl := p.Layout(ops, s, text.Font{}, size, 1e6, "a\nlonger line")
+	if len(pl.Lines) != 2 {
+		t.Fatalf("got %d lines, expected 2", len(pl.Lines))
+	}
+	width := pl.Lines[1].Width
+	if got, want := pl.Size.X, width.Ceil(); got != want {
+		t.Errorf("got paragraph width %d, expected %d", got, want)
+	}
+	for _, l := range pl.Lines {
+		if end := l.Offset.X + l.Width; end > fixed.I(pl.Size.X) || end < fixed.I(pl.Size.X-1) {
+			t.Errorf("line %q ends at %v, expected %d", l.Layout.Text, end, pl.Size.X)
+		}
+	}
+	p.Alignment = text.Middle
+	p.MinWidth = 1000
+	pl = p.Layout(ops, s, text.Font{}, size, 1e6, "a")
+	l := pl.Lines[0]
+	if got, want := l.Offset.X, fixed.I(((fixed.I(1000) - l.Width) / 2).Floor()); got != want {
+		t.Errorf("got centered offset %v, expected %v", got, want)
+	}
+}
+
+func TestParagraphLineHeight(t *testing.T) {
+	s := newTestShaper(t)
+	ops := new(op.Ops)
+	size := fixed.I(20)
+	single := text.Paragraph{}.Layout(ops, s, text.Font{}, size, 1e6, "a\nb")
+	double := text.Paragraph{LineHeight: 2}.Layout(ops, s, text.Font{}, size, 1e6, "a\nb")
+	d1 := single.Lines[1].Offset.Y - single.Lines[0].Offset.Y
+	d2 := double.Lines[1].Offset.Y - double.Lines[0].Offset.Y
+	if d2 < 2*d1-1 || d2 > 2*d1+1 {
+		t.Errorf("got line distance %v, expected %v", d2, 2*d1)
+	}
+	if double.Size.Y < 2*single.Size.Y-1 {
+		t.Errorf("got height %d, expected %d", double.Size.Y, 2*single.Size.Y)
+	}
+}
+
+func TestParagraphMaxLines(t *testing.T) {
+	s := newTestShaper(t)
+	ops := new(op.Ops)
+	size := fixed.I(20)
+	p := text.Paragraph{MaxLines: 2, Ellipsis: "…"}
+	pl := p.Layout(ops, s, text.Font{}, size, 1e6, "one\ntwo\nthree")
+	if !pl.Truncated {
+		t.Error("paragraph not truncated")
+	}
+	if len(pl.Lines) != 2 {
+		t.Fatalf("got %d lines, expected 2", len(pl.Lines))
+	}
+	if got, want := pl.Lines[1].Layout.Text, "two…"; got != want {
+		t.Errorf("got last line %q, expected %q", got, want)
+	}
+	// The ellipsis replaces the end of lines that fill the width.
+	lines := s.LayoutString(text.Font{}, size, 1e6, "abcdef ")
+	maxWidth := lines[0].Width.Ceil()
+	pl = p.Layout(ops, s, text.Font{}, size, maxWidth, "abcdef abcdef abcdef")
+	last := pl.Lines[len(pl.Lines)-1]
+	if got, want := last.Layout.Text, "abcd…"; got != want {
+		t.Errorf("got last line %q, expected %q", got, want)
+	}
+	if last.Width > fixed.I(maxWidth) {
+		t.Errorf("last line width %v exceeds %d", last.Width, maxWidth)
+	}
+	// The ellipsized line fits at every width that fits the
+	// ellipsis.
+	ell := s.LayoutString(text.Font{}, size, 1e6, "…")[0].Width.Ceil()
+	for w := ell; w < maxWidth; w++ {
+		pl = p.Layout(ops, s, text.Font{}, size, w, "abcdef abcdef abcdef")
+		last := pl.Lines[len(pl.Lines)-1]
+		if !strings.HasSuffix(last.Layout.Text, "…") {
+			t.Errorf("width %d: last line %q doesn't end with the ellipsis", w, last.Layout.Text)
+		}
+		if last.Width > fixed.I(w) {
+			t.Errorf("width %d: last line width %v exceeds the width", w, last.Width)
+		}
+	}
+	// The Shaper cache must not be modified.
+	lines = s.LayoutString(text.Font{}, size, 1e6, "one\ntwo\nthree")
+	if got := lines[1].Layout.Text; got != "two\n" {
+		t.Errorf("cached line modified to %q", got)
+	}
+}
+
+func TestParagraphOptions(t *testing.T) {
+	s := newTestShaper(t)
+	ops := new(op.Ops)
+	size := fixed.I(20)
+	plain := text.Paragraph{}.Layout(ops, s, text.Font{}, size, 1e6, "abc")
+	p := text.Paragraph{Options: text.LayoutOptions{LetterSpacing: fixed.I(10)}}
+	spaced := p.Layout(ops, s, text.Font{}, size, 1e6, "abc")
+	if got, want := spaced.Lines[0].Width, plain.Lines[0].Width+fixed.I(30); got != want {
+		t.Errorf("got letter spaced width %v, expected %v", got, want)
+	}
+	// The unspaced layout is still cached.
+	plain = text.Paragraph{}.Layout(ops, s, text.Font{}, size, 1e6, "abc")
+	if got, want := plain.Lines[0].Width, spaced.Lines[0].Width-fixed.I(30); got != want {
+		t.Errorf("got cached width %v, expected %v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// StackLines positions lines below each other, starting at the top
+// left corner. Use the Lines of a ParagraphLayout for aligned lines.
+func StackLines(lines []Line) []PositionedLine {
+	positioned := make([]PositionedLine, len(lines))
+	var y fixed.Int26_6
+	for i, l := range lines {
+		positioned[i] = PositionedLine{
+			Line:   l,
+			Offset: fixed.Point26_6{Y: y + l.Ascent},
+		}
+		y += l.Ascent + l.Descent
+	}
+	return positioned
+}
+
+// OffsetAt returns the byte offset of the caret position closest to a
+// point. Offsets are relative to the start of the text of the first
+// line, and the text of lines is the concatenation of their Layout
+// texts. Use utf8.RuneCountInString for the rune offset.
+func OffsetAt(lines []PositionedLine, pt fixed.Point26_6) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	idx := len(lines) - 1
+	for i, l := range lines {
+		if pt.Y < l.Offset.Y+l.Descent {
+			idx = i
+			break
+		}
+	}
+	start := 0
+	for _, l := range lines[:idx] {
+		start += len(l.Layout.Text)
+	}
+	l := lines[idx]
+	clusters := clustersOf(l.Layout)
+	c := l.ClusterAt(pt.X - l.Offset.X)
+	if c == len(clusters) && c > 0 && strings.HasSuffix(l.Layout.Text, "\n") {
+		// The position after the newline belongs to the next line.
+		c--
+	}
+	if c < len(clusters) {
+		return start + clusters[c].Start
+	}
+	return start + len(l.Layout.Text)
+}
+
+// CaretRect returns the zero width rectangle of the caret before the
+// byte offset, spanning the ascent and descent of its line. An offset
+// at the end of a line is at the start of the next line. Offsets are
+// relative to the start of the text of the first line.
+func CaretRect(lines []PositionedLine, offset int) fixed.Rectangle26_6 {
+	if len(lines) == 0 {
+		return fixed.Rectangle26_6{}
+	}
+	idx, start := lineOf(lines, offset)
+	l := lines[idx]
+	x := l.Offset.X + l.ClusterX(l.Layout.ClusterIndex(offset-start))
+	return fixed.Rectangle26_6{
+		Min: fixed.Point26_6{X: x, Y: l.Offset.Y - l.Ascent},
+		Max: fixed.Point26_6{X: x, Y: l.Offset.Y + l.Descent},
+	}
+}
+
+// SelectionRects returns the rectangles covering the clusters between
+// the byte offsets start and end, at most one for every visually
+// contiguous part of a line. Offsets are relative to the start of the
+// text of the first line.
+func SelectionRects(lines []PositionedLine, start, end int) []fixed.Rectangle26_6 {
+	if start > end {
+		start, end = end, start
+	}
+	var rects []fixed.Rectangle26_6
+	type span struct {
+		x0, x1 fixed.Int26_6
+	}
+	var spans []span
+	lineStart := 0
+	for _, l := range lines {
+		s, e := start-lineStart, end-lineStart
+		lineStart += len(l.Layout.Text)
+		if e <= 0 || s >= len(l.Layout.Text) {
+			continue
+		}
+		clusters, xs, _ := l.clusterPositions()
+		spans = spans[:0]
+		for i, c := range clusters {
+			if c.End > s && c.Start < e {
+				spans = append(spans, span{x0: xs[i], x1: xs[i] + c.Advance})
+			}
+		}
+		sort.Slice(spans, func(i, j int) bool {
+			return spans[i].x0 < spans[j].x0
+		})
+		first := len(rects)
+		for _, sp := range spans {
+			if n := len(rects); n > first && sp.x0 <= rects[n-1].Max.X-l.Offset.X {
+				if x := l.Offset.X + sp.x1; x > rects[n-1].Max.X {
+					rects[n-1].Max.X = x
+				}
+				continue
+			}
+			rects = append(rects, fixed.Rectangle26_6{
+				Min: fixed.Point26_6{X: l.Offset.X + sp.x0, Y: l.Offset.Y - l.Ascent},
+				Max: fixed.Point26_6{X: l.Offset.X + sp.x1, Y: l.Offset.Y + l.Descent},
+			})
+		}
+	}
+	return rects
+}
+
+// lineOf returns the index of the line containing the byte offset and
+// the offset of the start of the line.
+func lineOf(lines []PositionedLine, offset int) (int, int) {
+	start := 0
+	for i, l := range lines {
+		end := start + len(l.Layout.Text)
+		if offset < end || i == len(lines)-1 {
+			return i, start
+		}
+		start = end
+	}
+	return 0, 0
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text_test
+
+import (
+	"io"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/font/opentype"
+	"github.com/cybriq/giocore/text"
+)
+
+func TestOffsetAt(t *testing.T) {
+	s := newTestShaper(t)
+	lines := text.StackLines(s.LayoutString(text.Font{}, fixed.I(20), 1e6, "ab\ncd"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, expected 2", len(lines))
+	}
+	l0, l1 := lines[0], lines[1]
+	if l1.Offset.Y <= l0.Offset.Y {
+		t.Fatalf("second line at %v, above first line at %v", l1.Offset.Y, l0.Offset.Y)
+	}
+	a := l0.Layout.Clusters[0].Advance
+	tests := []struct {
+		pt   fixed.Point26_6
+		want int
+	}{
+		{fixed.Point26_6{X: -fixed.I(10), Y: -fixed.I(100)}, 0},
+		{fixed.Point26_6{X: a/2 - 1, Y: l0.Offset.Y}, 0},
+		{fixed.Point26_6{X: a/2 + 1, Y: l0.Offset.Y}, 1},
+		// Past the end of a line ending in a newline.
+		{fixed.Point26_6{X: fixed.I(1000), Y: l0.Offset.Y}, 2},
+		{fixed.Point26_6{X: 0, Y: l1.Offset.Y}, 3},
+		{fixed.Point26_6{X: fixed.I(1000), Y: fixed.I(1000)}, 5},
+	}
+	for _, test := range tests {
+		if got := text.OffsetAt(lines, test.pt); got != test.want {
+			t.Errorf("OffsetAt(%v) = %d, expected %d", test.pt, got, test.want)
+		}
+	}
+}
+
+func TestCaretRect(t *testing.T) {
+	s := newTestShaper(t)
+	lines := text.StackLines(s.LayoutString(text.Font{}, fixed.I(20), 1e6, "ab\ncd"))
+	l0, l1 := lines[0], lines[1]
+	a := l0.Layout.Clusters[0].Advance
+	tests := []struct {
+		offset int
+		line   text.PositionedLine
+		x      fixed.Int26_6
+	}{
+		{0, l0, 0},
+		{1, l0, a},
+		{3, l1, 0},
+		{5, l1, l1.Width},
+	}
+	for _, test := range tests {
+		got := text.CaretRect(lines, test.offset)
+		want := fixed.Rectangle26_6{
+			Min: fixed.Point26_6{X: test.x, Y: test.line.Offset.Y - test.line.Ascent},
+			Max: fixed.Point26_6{X: test.x, Y: test.line.Offset.Y + test.line.Descent},
+		}
+		if got != want {
+			t.Errorf("CaretRect(%d) = %v, expected %v", test.offset, got, want)
+		}
+	}
+}
+
+func TestSelectionRects(t *testing.T) {
+	s := newTestShaper(t)
+	lines := text.StackLines(s.LayoutString(text.Font{}, fixed.I(20), 1e6, "ab\ncd"))
+	l0, l1 := lines[0], lines[1]
+	rects := text.SelectionRects(lines, 4, 1)
+	if len(rects) != 2 {
+		t.Fatalf("got %d rectangles, expected 2", len(rects))
+	}
+	a := l0.Layout.Clusters[0].Advance
+	if got, want := rects[0].Min, (fixed.Point26_6{X: a, Y: l0.Offset.Y - l0.Ascent}); got != want {
+		t.Errorf("got first rectangle at %v, expected %v", got, want)
+	}
+	c := l1.Layout.Clusters[0].Advance
+	if got, want := rects[1].Max, (fixed.Point26_6{X: c, Y: l1.Offset.Y + l1.Descent}); got != want {
+		t.Errorf("got second rectangle end %v, expected %v", got, want)
+	}
+}
+
+func TestSelectionRectsBidi(t *testing.T) {
+	// "abCD" where CD is right-to-left, displayed as "abDC".
+	lines := []text.PositionedLine{{
+		Line: text.Line{
+			Layout: text.Layout{
+				Text: "abCD",
+				Clusters: []text.Cluster{
+					{Start: 0, End: 1, Advance: fixed.I(1)},
+					{Start: 1, End: 2, Advance: fixed.I(2)},
+					{Start: 2, End: 3, Advance: fixed.I(4)},
+					{Start: 3, End: 4, Advance: fixed.I(8)},
+				},
+			},
+			Ascent: fixed.I(10),
+			Runs: []text.Run{
+				{Direction: text.LTR, Start: 0, End: 2},
+				{Direction: text.RTL, Start: 2, End: 4},
+			},
+		},
+		Offset: fixed.Point26_6{X: fixed.I(100), Y: fixed.I(10)},
+	}}
+	// Selecting "bC" covers two visually separate parts.
+	rects := text.SelectionRects(lines, 1, 3)
+	want := [][2]int{{101, 103}, {111, 115}}
+	if len(rects) != len(want) {
+		t.Fatalf("got %d rectangles, expected %d", len(rects), len(want))
+	}
+	for i, r := range rects {
+		if r.Min.X != fixed.I(want[i][0]) || r.Max.X != fixed.I(want[i][1]) {
+			t.Errorf("rectangle %d spans %v-%v, expected %d-%d", i, r.Min.X, r.Max.X, want[i][0], want[i][1])
+		}
+	}
+	// Selecting "CD" is contiguous.
+	if rects := text.SelectionRects(lines, 2, 4); len(rects) != 1 {
+		t.Errorf("got %d rectangles for a run, expected 1", len(rects))
+	}
+	if got, want := text.CaretRect(lines, 2).Min.X, fixed.I(115); got != want {
+		t.Errorf("got caret at %v, expected %v", got, want)
+	}
+}
+
+// noClusterFace is a face that doesn't report the clusters of its
+// layouts.
+type noClusterFace struct {
+	text.Face
+}
+
+func (f noClusterFace) Layout(ppem fixed.Int26_6, maxWidth int, txt io.Reader) ([]text.Line, error) {
+	lines, err := f.Face.Layout(ppem, maxWidth, txt)
+	for i := range lines {
+		lines[i].Layout.Clusters = nil
+	}
+	return lines, err
+}
+
+func TestSelectionWithoutClusters(t *testing.T) {
+	face, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := text.NewCache([]text.FontFace{{Face: noClusterFace{face}}})
+	lines := text.StackLines(s.LayoutString(text.Font{}, fixed.I(20), 1e6, "aé\ncd"))
+	if len(lines) != 2 || lines[0].Layout.Clusters != nil {
+		t.Fatalf("got lines %v, expected 2 lines without clusters", lines)
+	}
+	l0, l1 := lines[0], lines[1]
+	a := l0.Layout.Advances[0]
+	e := l0.Layout.Advances[1]
+	if got := text.OffsetAt(lines, fixed.Point26_6{X: a + e/2 + 1, Y: l0.Offset.Y}); got != 3 {
+		t.Errorf("OffsetAt after é = %d, expected 3", got)
+	}
+	if got := text.OffsetAt(lines, fixed.Point26_6{X: fixed.I(1000), Y: l1.Offset.Y}); got != 6 {
+		t.Errorf("OffsetAt end = %d, expected 6", got)
+	}
+	if got := text.CaretRect(lines, 3).Min.X; got != a+e {
+		t.Errorf("CaretRect(3) at %v, expected %v", got, a+e)
+	}
+	rects := text.SelectionRects(lines, 1, 5)
+	if len(rects) != 2 {
+		t.Fatalf("got %d rectangles, expected 2", len(rects))
+	}
+	if got, want := rects[0].Min.X, a; got != want {
+		t.Errorf("got first rectangle at %v, expected %v", got, want)
+	}
+	if got, want := rects[1].Max.X, l1.Layout.Advances[0]; got != want {
+		t.Errorf("got second rectangle end %v, expected %v", got, want)
+	}
+}
@@ -3,12 +3,14 @@
 package text
 
 import (
+	"image/color"
 	"io"
 	"strings"
 
 	"golang.org/x/image/math/fixed"
 
 	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/op/paint"
 )
 
 // Shaper implements layout and shaping of text.
@@ -21,6 +23,62 @@ type Shaper interface {
 	Shape(font Font, size fixed.Int26_6, layout Layout) op.CallOp
 }
 
+// OptionsShaper is a Shaper that lays out text with LayoutOptions.
+type OptionsShaper interface {
+	Shaper
+	// LayoutWithOptions is Layout with layout options.
+	LayoutWithOptions(font Font, size fixed.Int26_6, maxWidth int, opts LayoutOptions, txt io.Reader) ([]Line, error)
+	// LayoutStringWithOptions is LayoutWithOptions for strings.
+	LayoutStringWithOptions(font Font, size fixed.Int26_6, maxWidth int, opts LayoutOptions, str string) []Line
+}
+
+// Measurer is a Shaper that measures text without building its
+// lines.
+type Measurer interface {
+	Shaper
+	// Measure returns the measurements of a text laid out by
+	// LayoutString.
+	Measure(font Font, size fixed.Int26_6, maxWidth int, str string) Measurement
+}
+
+// Measurement contains the measurements of laid out text.
+type Measurement struct {
+	// Width is the width of the widest line.
+	Width fixed.Int26_6
+	// Height is the sum of the ascents and descents of the lines.
+	Height fixed.Int26_6
+	// Lines is the number of lines.
+	Lines int
+	// Baseline is the distance from the top of the text to the
+	// baseline of the first line.
+	Baseline fixed.Int26_6
+}
+
+// ColorShaper is a Shaper with color glyphs.
+type ColorShaper interface {
+	Shaper
+	// ShapeColor returns the operations for painting the color
+	// glyphs of a line of text, with the current color as the
+	// color of foreground layers.
+	ShapeColor(font Font, size fixed.Int26_6, layout Layout) op.CallOp
+}
+
+// TextPainter is a Shaper that paints text.
+type TextPainter interface {
+	Shaper
+	// PaintText returns the operations for painting a line of text,
+	// including its color glyphs, in a color.
+	PaintText(font Font, size fixed.Int26_6, layout Layout, color color.NRGBA) op.CallOp
+}
+
+// DecorationShaper is a Shaper with text decorations.
+type DecorationShaper interface {
+	Shaper
+	// Decorate returns a clip operation for the decoration lines of
+	// a line of text. See DecorationClip.
+	Decorate(font Font, size fixed.Int26_6, line Line, d Decoration) op.CallOp
+}
+
 // A FontFace is a Font and a matching Face.
 type FontFace struct {
 	Font Font
@@ -31,49 +89,99 @@ type FontFace struct {
 // registered fonts.
 //
 // If a font matches no registered shape, Cache falls back to the
-// first registered face.
+// registered face of the same typeface and style with the nearest
+// weight, then to the Regular style, and then to the typeface of the
+// first registered face. Faces of variable fonts are varied to match
+// the weight and variations of the font.
 //
 // The LayoutString and ShapeString results are cached and re-used if
-// possible.
+// possible. Measure re-uses the cached LayoutString results.
 type Cache struct {
 	def   Typeface
 	faces map[Font]*faceCache
+	// instances contains the varied faces of variable fonts.
+	instances map[Font]*faceCache
+	atlas     glyphAtlas
 }
 
 type faceCache struct {
-	face        Face
-	layoutCache layoutCache
-	pathCache   pathCache
+	face         Face
+	layoutCache  layoutCache
+	measureCache measureCache
+	pathCache    pathCache
+	colorCache   pathCache
+	atlasCache   pathCache
+	decoCache    pathCache
 }
 
 func (c *Cache) lookup(font Font) *faceCache {
-	f := c.faceForStyle(font)
+	if f, ok := c.instances[font]; ok {
+		return f
+	}
+	static := font
+	static.Variations = ""
+	f := c.faceForStyle(static)
+	if f == nil {
+		static.Typeface = c.def
+		f = c.faceForStyle(static)
+	}
 	if f == nil {
-		font.Typeface = c.def
-		f = c.faceForStyle(font)
+		return nil
+	}
+	vf, ok := f.face.(VariableFace)
+	if !ok || c.faces[static] == f && font.Variations == "" {
+		return f
+	}
+	values := font.Variations.Values()
+	hasWeight := false
+	for _, v := range values {
+		hasWeight = hasWeight || v.Tag == "wght"
 	}
-	return f
+	if !hasWeight {
+		// Weight is a CSS weight subtracted 400.
+		values = append(values, Variation{Tag: "wght", Value: float32(font.Weight + 400)})
+	}
+	inst := &faceCache{face: vf.Vary(values)}
+	if c.instances == nil {
+		c.instances = make(map[Font]*faceCache)
+	}
+	c.instances[font] = inst
+	return inst
 }
 
 func (c *Cache) faceForStyle(font Font) *faceCache {
-	tf := c.faces[font]
-	if tf == nil {
-		font := font
-		font.Weight = Normal
-		tf = c.faces[font]
+	if tf := c.faces[font]; tf != nil {
+		return tf
+	}
+	if tf := c.nearestWeight(font); tf != nil {
+		return tf
 	}
-	if tf == nil {
-		font := font
-		font.Style = Regular
-		tf = c.faces[font]
+	font.Style = Regular
+	return c.nearestWeight(font)
+}
+
+// nearestWeight returns the face matching font with the nearest
+// weight, preferring the lighter of two equally near weights.
+func (c *Cache) nearestWeight(font Font) *faceCache {
+	var best *faceCache
+	var bestFont Font
+	for f, tf := range c.faces {
+		if f.Typeface != font.Typeface || f.Variant != font.Variant || f.Style != font.Style {
+			continue
+		}
+		d, bestD := weightDist(f.Weight, font.Weight), weightDist(bestFont.Weight, font.Weight)
+		if best == nil || d < bestD || d == bestD && f.Weight < bestFont.Weight {
+			best, bestFont = tf, f
+		}
 	}
-	if tf == nil {
-		font := font
-		font.Style = Regular
-		font.Weight = Normal
-		tf = c.faces[font]
+	return best
+}
+
+func weightDist(w1, w2 Weight) Weight {
+	if w1 > w2 {
+		return w1 - w2
 	}
-	return tf
+	return w2 - w1
 }
 
 func NewCache(collection []FontFace) *Cache {
@@ -98,7 +206,28 @@ func (s *Cache) Layout(font Font, size fixed.Int26_6, maxWidth int, txt io.Reade
 // LayoutString is a caching implementation of the Shaper interface.
 func (s *Cache) LayoutString(font Font, size fixed.Int26_6, maxWidth int, str string) []Line {
 	cache := s.lookup(font)
-	return cache.layout(size, maxWidth, str)
+	return cache.layout(size, maxWidth, LayoutOptions{}, str)
+}
+
+// LayoutWithOptions implements the OptionsShaper interface. The
+// options are ignored if the Face is not an OptionsFace.
+func (s *Cache) LayoutWithOptions(font Font, size fixed.Int26_6, maxWidth int, opts LayoutOptions, txt io.Reader) ([]Line, error) {
+	cache := s.lookup(font)
+	return layoutWithOptions(cache.face, size, maxWidth, opts, txt)
+}
+
+// LayoutStringWithOptions is a caching implementation of the
+// OptionsShaper interface.
+func (s *Cache) LayoutStringWithOptions(font Font, size fixed.Int26_6, maxWidth int, opts LayoutOptions, str string) []Line {
+	cache := s.lookup(font)
+	return cache.layout(size, maxWidth, opts, str)
+}
+
+// Measure is a caching implementation of the Measurer interface. Text
+// is laid out to measure it if the Face is not a MeasureFace.
+func (s *Cache) Measure(font Font, size fixed.Int26_6, maxWidth int, str string) Measurement {
+	cache := s.lookup(font)
+	return cache.measure(size, maxWidth, str)
 }
 
 // Shape is a caching implementation of the Shaper interface. Shape assumes that the layout
@@ -108,30 +237,166 @@ func (s *Cache) Shape(font Font, size fixed.Int26_6, layout Layout) op.CallOp {
 	return cache.shape(size, layout)
 }
 
-func (f *faceCache) layout(ppem fixed.Int26_6, maxWidth int, str string) []Line {
+// ShapeColor is a caching implementation of the ColorShaper
+// interface. ShapeColor returns an empty CallOp if the Face is not a
+// ColorFace.
+func (s *Cache) ShapeColor(font Font, size fixed.Int26_6, layout Layout) op.CallOp {
+	cache := s.lookup(font)
+	return cache.shapeColor(size, layout)
+}
+
+// SetGlyphAtlas makes PaintText paint text no larger than maxSize
+// from images of rasterized glyphs instead of filling their outlines,
+// if the Face is a RasterFace. Painting small text from images is
+// faster, but the glyphs are not scaled or transformed as precisely.
+// A zero maxSize disables the glyph atlas.
+func (s *Cache) SetGlyphAtlas(maxSize fixed.Int26_6) {
+	s.atlas.maxPPEM = maxSize
+	// Drop the text painted with the previous size.
+	for _, f := range s.faces {
+		f.atlasCache = pathCache{}
+	}
+	for _, f := range s.instances {
+		f.atlasCache = pathCache{}
+	}
+}
+
+// PaintText is a caching implementation of the TextPainter interface.
+func (s *Cache) PaintText(font Font, size fixed.Int26_6, layout Layout, color color.NRGBA) op.CallOp {
+	cache := s.lookup(font)
+	if cache == nil {
+		return op.CallOp{}
+	}
+	pk := pathKey{
+		ppem:   size,
+		str:    layout.Text,
+		layout: layoutHash(layout),
+		color:  color,
+	}
+	if call, ok := cache.atlasCache.Get(pk); ok {
+		return call
+	}
+	ops := new(op.Ops)
+	m := op.Record(ops)
+	if call, ok := s.atlas.paint(cache.face, size, layout, color); ok {
+		call.Add(ops)
+	} else {
+		stack := op.Save(ops)
+		paint.ColorOp{Color: color}.Add(ops)
+		cache.shape(size, layout).Add(ops)
+		paint.PaintOp{}.Add(ops)
+		stack.Load()
+	}
+	stack := op.Save(ops)
+	paint.ColorOp{Color: color}.Add(ops)
+	cache.shapeColor(size, layout).Add(ops)
+	stack.Load()
+	call := m.Stop()
+	cache.atlasCache.Put(pk, call)
+	return call
+}
+
+// Decorate is a caching implementation of the DecorationShaper
+// interface.
+func (s *Cache) Decorate(font Font, size fixed.Int26_6, line Line, d Decoration) op.CallOp {
+	cache := s.lookup(font)
+	if cache == nil {
+		return DecorationClip(nil, size, line, d)
+	}
+	pk := pathKey{
+		ppem:       size,
+		str:        line.Layout.Text,
+		layout:     layoutHash(line.Layout),
+		decoration: d,
+	}
+	if call, ok := cache.decoCache.Get(pk); ok {
+		return call
+	}
+	call := DecorationClip(cache.face, size, line, d)
+	cache.decoCache.Put(pk, call)
+	return call
+}
+
+func (f *faceCache) layout(ppem fixed.Int26_6, maxWidth int, opts LayoutOptions, str string) []Line {
 	if f == nil {
 		return nil
 	}
+	k, cacheable := opts.key()
+	if !cacheable {
+		l, _ := layoutWithOptions(f.face, ppem, maxWidth, opts, strings.NewReader(str))
+		return l
+	}
 	lk := layoutKey{
 		ppem:     ppem,
 		maxWidth: maxWidth,
 		str:      str,
+		opts:     k,
 	}
 	if l, ok := f.layoutCache.Get(lk); ok {
 		return l
 	}
-	l, _ := f.face.Layout(ppem, maxWidth, strings.NewReader(str))
+	l, _ := layoutWithOptions(f.face, ppem, maxWidth, opts, strings.NewReader(str))
 	f.layoutCache.Put(lk, l)
 	return l
 }
 
+func (f *faceCache) measure(ppem fixed.Int26_6, maxWidth int, str string) Measurement {
+	if f == nil {
+		return Measurement{}
+	}
+	lk := layoutKey{
+		ppem:     ppem,
+		maxWidth: maxWidth,
+		str:      str,
+	}
+	if m, ok := f.measureCache.Get(lk); ok {
+		return m
+	}
+	var m Measurement
+	if l, ok := f.layoutCache.Get(lk); ok {
+		m = measure(l)
+	} else if mf, ok := f.face.(MeasureFace); ok {
+		m, _ = mf.Measure(ppem, maxWidth, strings.NewReader(str))
+	} else {
+		l, _ := f.face.Layout(ppem, maxWidth, strings.NewReader(str))
+		m = measure(l)
+	}
+	f.measureCache.Put(lk, m)
+	return m
+}
+
+// layoutWithOptions lays out text with a face, with options if the
+// face is an OptionsFace.
+func layoutWithOptions(face Face, ppem fixed.Int26_6, maxWidth int, opts LayoutOptions, txt io.Reader) ([]Line, error) {
+	if of, ok := face.(OptionsFace); ok {
+		return of.LayoutWithOptions(ppem, maxWidth, opts, txt)
+	}
+	return face.Layout(ppem, maxWidth, txt)
+}
+
+// measure returns the measurements of lines.
+func measure(lines []Line) Measurement {
+	m := Measurement{Lines: len(lines)}
+	for i, l := range lines {
+		if i == 0 {
+			m.Baseline = l.Ascent
+		}
+		if l.Width > m.Width {
+			m.Width = l.Width
+		}
+		m.Height += l.Ascent + l.Descent
+	}
+	return m
+}
+
 func (f *faceCache) shape(ppem fixed.Int26_6, layout Layout) op.CallOp {
 	if f == nil {
 		return op.CallOp{}
 	}
 	pk := pathKey{
-		ppem: ppem,
-		str:  layout.Text,
+		ppem:   ppem,
+		str:    layout.Text,
+		layout: layoutHash(layout),
 	}
 	if clip, ok := f.pathCache.Get(pk); ok {
 		return clip
@@ -140,3 +405,24 @@ func (f *faceCache) shape(ppem fixed.Int26_6, layout Layout) op.CallOp {
 	f.pathCache.Put(pk, clip)
 	return clip
 }
+
+func (f *faceCache) shapeColor(ppem fixed.Int26_6, layout Layout) op.CallOp {
+	if f == nil {
+		return op.CallOp{}
+	}
+	face, ok := f.face.(ColorFace)
+	if !ok {
+		return op.CallOp{}
+	}
+	pk := pathKey{
+		ppem:   ppem,
+		str:    layout.Text,
+		layout: layoutHash(layout),
+	}
+	if call, ok := f.colorCache.Get(pk); ok {
+		return call
+	}
+	call := face.ShapeColor(ppem, layout)
+	f.colorCache.Put(pk, call)
+	return call
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text_test
+
+import (
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/font/opentype"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/text"
+)
+
+func TestMeasure(t *testing.T) {
+	s := newTestShaper(t).(text.Measurer)
+	size := fixed.I(20)
+	const str = "a few words\nof text"
+	for _, maxWidth := range []int{1e6, 50} {
+		m := s.Measure(text.Font{}, size, maxWidth, str)
+		lines := s.LayoutString(text.Font{}, size, maxWidth, str)
+		if m.Lines != len(lines) {
+			t.Errorf("maxWidth %d: got %d lines, expected %d", maxWidth, m.Lines, len(lines))
+		}
+		if m.Baseline != lines[0].Ascent {
+			t.Errorf("maxWidth %d: got baseline %v, expected %v", maxWidth, m.Baseline, lines[0].Ascent)
+		}
+		pl := text.Paragraph{}.Layout(new(op.Ops), s, text.Font{}, size, maxWidth, str)
+		if got, want := m.Width.Ceil(), pl.Size.X; got != want {
+			t.Errorf("maxWidth %d: got width %d, expected %d", maxWidth, got, want)
+		}
+		if got, want := m.Height.Ceil(), pl.Size.Y; got != want {
+			t.Errorf("maxWidth %d: got height %d, expected %d", maxWidth, got, want)
+		}
+	}
+	if m := s.Measure(text.Font{}, size, 50, str); m.Lines < 3 {
+		t.Errorf("got %d wrapped lines, expected at least 3", m.Lines)
+	}
+}
+
+func TestCacheLayoutOptions(t *testing.T) {
+	s := newTestShaper(t).(*text.Cache)
+	size := fixed.I(20)
+	plain := s.LayoutString(text.Font{}, size, 1e6, "abc")[0]
+	opts := text.LayoutOptions{LetterSpacing: fixed.I(30)}
+	spaced := s.LayoutStringWithOptions(text.Font{}, size, 1e6, opts, "abc")[0]
+	if spaced.Width == plain.Width {
+		t.Fatal("letter spacing didn't change the layout")
+	}
+	if s.Shape(text.Font{}, size, plain.Layout) != s.Shape(text.Font{}, size, plain.Layout) {
+		t.Error("shape not cached")
+	}
+	if s.Shape(text.Font{}, size, spaced.Layout) == s.Shape(text.Font{}, size, plain.Layout) {
+		t.Error("letter spaced text shaped from the plain text cache")
+	}
+	col := color.NRGBA{A: 0xff}
+	if s.PaintText(text.Font{}, size, spaced.Layout, col) == s.PaintText(text.Font{}, size, plain.Layout, col) {
+		t.Error("letter spaced text painted from the plain text cache")
+	}
+	if s.Decorate(text.Font{}, size, spaced, text.Underline) == s.Decorate(text.Font{}, size, plain, text.Underline) {
+		t.Error("letter spaced text decorated from the plain text cache")
+	}
+}
+
+func TestCacheTabStops(t *testing.T) {
+	s := newTestShaper(t).(*text.Cache)
+	size := fixed.I(20)
+	const str = "a\tb"
+	for _, n := range []int{1, 12} {
+		// Options with many tab stops are laid out without caching.
+		var stops []fixed.Int26_6
+		for i := 1; i <= n; i++ {
+			stops = append(stops, fixed.I(100*i))
+		}
+		for _, first := range []int{50, 70} {
+			stops[0] = fixed.I(first)
+			opts := text.LayoutOptions{TabStops: stops}
+			l := s.LayoutStringWithOptions(text.Font{}, size, 1e6, opts, str)[0]
+			b := l.Layout.Advances[0] + l.Layout.Advances[1]
+			if b != fixed.I(first) {
+				t.Errorf("%d stops: got b at %v, expected %v", n, b, fixed.I(first))
+			}
+		}
+	}
+}
+
+type testHyphenator []int
+
+func (h testHyphenator) Hyphenate(word string) []int {
+	return h
+}
+
+func TestCacheHyphenator(t *testing.T) {
+	s := newTestShaper(t).(*text.Cache)
+	size := fixed.I(20)
+	maxWidth := s.LayoutString(text.Font{}, size, 1e6, "a hyphen-")[0].Width.Ceil()
+	const str = "a hyphenation"
+	plain := s.LayoutString(text.Font{}, size, maxWidth, str)
+	opts := text.LayoutOptions{Hyphenator: &testHyphenator{6}}
+	hyphenated := s.LayoutStringWithOptions(text.Font{}, size, maxWidth, opts, str)
+	if got, want := plain[0].Layout.Text, "a "; got != want {
+		t.Errorf("got unhyphenated first line %q, expected %q", got, want)
+	}
+	if got, want := hyphenated[0].Layout.Text, "a hyphen"; got != want {
+		t.Errorf("got hyphenated first line %q, expected %q", got, want)
+	}
+}
+
+func TestCacheUncomparableHyphenator(t *testing.T) {
+	s := newTestShaper(t).(*text.Cache)
+	size := fixed.I(20)
+	maxWidth := s.LayoutString(text.Font{}, size, 1e6, "a hyphen-")[0].Width.Ceil()
+	const str = "a hyphenation"
+	for _, h := range []testHyphenator{{6}, {3}} {
+		// Slices are not comparable; layouts with them are not cached.
+		opts := text.LayoutOptions{Hyphenator: h}
+		lines := s.LayoutStringWithOptions(text.Font{}, size, maxWidth, opts, str)
+		if got, want := lines[0].Layout.Text, "a "+"hyphenation"[:h[0]]; got != want {
+			t.Errorf("got hyphenated first line %q, expected %q", got, want)
+		}
+	}
+}
+
+func BenchmarkMeasure(b *testing.B) {
+	face, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		b.Fatal(err)
+	}
+	s := text.NewCache([]text.FontFace{{Face: face}})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Measure(text.Font{}, fixed.I(20), 200, "a few words of text")
+	}
+}
@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"image/color"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/f32"
+	"github.com/cybriq/giocore/internal/bidi"
+	"github.com/cybriq/giocore/internal/grapheme"
+	"github.com/cybriq/giocore/internal/linebreak"
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/op/paint"
+)
+
+// Span is a part of a rich text with a single style.
+type Span struct {
+	Text string
+	Font Font
+	Size fixed.Int26_6
+	// Color is the color of the text and its decorations.
+	Color color.NRGBA
+	// Decoration is the set of lines drawn with the text.
+	Decoration Decoration
+}
+
+// SpanLine is a line of a rich text.
+type SpanLine struct {
+	// Width is the width of the line.
+	Width fixed.Int26_6
+	// Ascent is the largest ascent of the runs of the line.
+	Ascent fixed.Int26_6
+	// Descent is the largest descent of the runs of the line,
+	// including the line gap.
+	Descent fixed.Int26_6
+	// Runs contains the parts of the spans on the line, in visual
+	// order.
+	Runs []SpanRun
+}
+
+// SpanRun is the part of a Span on a SpanLine.
+type SpanRun struct {
+	// Line is the layout of the part, as laid out by the Shaper.
+	Line
+	// Span is the index of the span.
+	Span int
+	// X is the position of the run relative to the start of the
+	// line.
+	X fixed.Int26_6
+}
+
+// unbounded is a line width that never causes a line to break.
+const unbounded = 1 << 24
+
+// LayoutSpans lays out the spans of a rich text as a single paragraph
+// of lines no wider than maxWidth. Lines are broken at the same
+// opportunities as the text of a single span. The runs of a line are
+// ordered by the bidirectional levels of the text, and the parts of
+// a right-to-left run that belong to different spans are placed from
+// right to left.
+func LayoutSpans(s Shaper, maxWidth int, spans []Span) []SpanLine {
+	var runes []rune
+	// advs contains the advance of every rune. The advance of a
+	// grapheme cluster is assigned to its first rune.
+	var advs []fixed.Int26_6
+	// runeSpan and runeOff record the span index and byte offset
+	// in the span of every rune.
+	var runeSpan, runeOff []int
+	for i, sp := range spans {
+		// spanAdvs maps the byte offsets of the clusters of the
+		// span to their advances.
+		spanAdvs := make([]fixed.Int26_6, len(sp.Text))
+		lineOff := 0
+		for _, l := range s.LayoutString(sp.Font, sp.Size, unbounded, sp.Text) {
+			for _, c := range clustersOf(l.Layout) {
+				if off := lineOff + c.Start; off < len(spanAdvs) {
+					spanAdvs[off] += c.Advance
+				}
+			}
+			lineOff += len(l.Layout.Text)
+		}
+		for off, r := range sp.Text {
+			runes = append(runes, r)
+			advs = append(advs, spanAdvs[off])
+			runeSpan = append(runeSpan, i)
+			runeOff = append(runeOff, off)
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	breaks := linebreak.Breaks(runes)
+	graphemes := grapheme.Breaks(runes)
+	levels, bases := paragraphLevels(runes)
+	var lines []SpanLine
+	// textOffset returns the span and byte offset of the rune with
+	// index i.
+	textOffset := func(i int) (int, int) {
+		if i == len(runes) {
+			last := len(spans) - 1
+			return last, len(spans[last].Text)
+		}
+		return runeSpan[i], runeOff[i]
+	}
+	// part is the text of a span between byte offsets.
+	type part struct {
+		span, from, to int
+	}
+	var parts []part
+	addLine := func(start, end int) {
+		var l SpanLine
+		addRun := func(p part) {
+			sp := spans[p.span]
+			ls := s.LayoutString(sp.Font, sp.Size, unbounded, sp.Text[p.from:p.to])
+			if len(ls) == 0 {
+				return
+			}
+			r := SpanRun{Line: ls[0], Span: p.span, X: l.Width}
+			l.Width += r.Width
+			if r.Ascent > l.Ascent {
+				l.Ascent = r.Ascent
+			}
+			if r.Descent > l.Descent {
+				l.Descent = r.Descent
+			}
+			l.Runs = append(l.Runs, r)
+		}
+		if start == end {
+			// Keep the metrics of empty lines.
+			span, off := textOffset(start)
+			addRun(part{span: span, from: off, to: off})
+			lines = append(lines, l)
+			return
+		}
+		for _, br := range bidi.Runs(runes[start:end], levels[start:end], bases[start]) {
+			// Split the directional run into the parts of its
+			// spans.
+			parts = parts[:0]
+			for i := start + br.Start; i < start+br.End; {
+				j := i + 1
+				for j < start+br.End && runeSpan[j] == runeSpan[i] {
+					j++
+				}
+				to := runeOff[j-1] + utf8.RuneLen(runes[j-1])
+				parts = append(parts, part{span: runeSpan[i], from: runeOff[i], to: to})
+				i = j
+			}
+			if br.Level.RTL() {
+				for a, b := 0, len(parts)-1; a < b; a, b = a+1, b-1 {
+					parts[a], parts[b] = parts[b], parts[a]
+				}
+			}
+			for _, p := range parts {
+				addRun(p)
+			}
+		}
+		lines = append(lines, l)
+	}
+	maxDotX := fixed.I(maxWidth)
+	start := 0
+	// word and cluster are the indices after the last line break
+	// opportunity and the last grapheme cluster boundary.
+	word, cluster := -1, -1
+	var x fixed.Int26_6
+	for i, r := range runes {
+		// Break the line if we're out of space. Spaces may
+		// overflow the line.
+		if i > start && x+advs[i] > maxDotX && !unicode.IsSpace(r) {
+			end := i
+			switch {
+			case word > start:
+				end = word
+			case cluster > start:
+				end = cluster
+			}
+			addLine(start, end)
+			start = end
+			word, cluster = -1, -1
+			x = 0
+			for _, adv := range advs[start:i] {
+				x += adv
+			}
+		}
+		x += advs[i]
+		if graphemes[i] {
+			cluster = i + 1
+		}
+		switch breaks[i] {
+		case linebreak.Allowed:
+			word = i + 1
+		case linebreak.Mandatory:
+			addLine(start, i+1)
+			start = i + 1
+			word, cluster = -1, -1
+			x = 0
+		}
+	}
+	if start < len(runes) || len(lines) == 0 || breaks[len(runes)-1] == linebreak.Mandatory {
+		addLine(start, len(runes))
+	}
+	return lines
+}
+
+// paragraphLevels resolves the bidirectional levels of the paragraphs
+// of a text, and returns the level and paragraph level of every rune.
+func paragraphLevels(runes []rune) ([]bidi.Level, []bidi.Level) {
+	levels := make([]bidi.Level, len(runes))
+	bases := make([]bidi.Level, len(runes))
+	for start := 0; start < len(runes); {
+		end := start
+		for end < len(runes) && runes[end] != '\n' {
+			end++
+		}
+		pl, base := bidi.Levels(runes[start:end], bidi.Auto)
+		copy(levels[start:], pl)
+		// Include the newline in the paragraph.
+		if end < len(runes) {
+			levels[end] = base
+			end++
+		}
+		for i := start; i < end; i++ {
+			bases[i] = base
+		}
+		start = end
+	}
+	return levels, bases
+}
+
+// PaintSpans records the operations for painting the lines of a
+// rich text laid out by LayoutSpans, including color glyphs if the
+// Shaper is a ColorShaper. Text is painted with PaintText if the
+// Shaper is a TextPainter. Decorations are placed by the Shaper if it
+// is a DecorationShaper. The first line is placed at the origin and
+// the following lines below it.
+func PaintSpans(ops *op.Ops, s Shaper, spans []Span, lines []SpanLine) op.CallOp {
+	cs, _ := s.(ColorShaper)
+	tp, _ := s.(TextPainter)
+	ds, _ := s.(DecorationShaper)
+	m := op.Record(ops)
+	var y fixed.Int26_6
+	for _, l := range lines {
+		y += l.Ascent
+		for _, r := range l.Runs {
+			sp := spans[r.Span]
+			stack := op.Save(ops)
+			op.Offset(f32.Point{
+				X: float32(r.X) / 64,
+				Y: float32(y) / 64,
+			}).Add(ops)
+			paint.ColorOp{Color: sp.Color}.Add(ops)
+			if tp != nil {
+				tp.PaintText(sp.Font, sp.Size, r.Layout, sp.Color).Add(ops)
+			} else {
+				tstack := op.Save(ops)
+				s.Shape(sp.Font, sp.Size, r.Layout).Add(ops)
+				paint.PaintOp{}.Add(ops)
+				tstack.Load()
+				if cs != nil {
+					cs.ShapeColor(sp.Font, sp.Size, r.Layout).Add(ops)
+				}
+			}
+			if sp.Decoration != 0 {
+				if ds != nil {
+					ds.Decorate(sp.Font, sp.Size, r.Line, sp.Decoration).Add(ops)
+				} else {
+					DecorationClip(nil, sp.Size, r.Line, sp.Decoration).Add(ops)
+				}
+				paint.PaintOp{}.Add(ops)
+			}
+			stack.Load()
+		}
+		y += l.Descent
+	}
+	return m.Stop()
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text_test
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cybriq/giocore/op"
+	"github.com/cybriq/giocore/text"
+)
+
+func TestLayoutSpans(t *testing.T) {
+	s := newTestShaper(t)
+	spans := []text.Span{
+		{Text: "hello ", Size: fixed.I(20)},
+		{Text: "big", Size: fixed.I(40)},
+		{Text: " world", Size: fixed.I(20)},
+	}
+	lines := text.LayoutSpans(s, 1e6, spans)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, expected 1", len(lines))
+	}
+	l := lines[0]
+	if len(l.Runs) != 3 {
+		t.Fatalf("got %d runs, expected 3", len(l.Runs))
+	}
+	if big := l.Runs[1]; big.Ascent != l.Ascent {
+		t.Errorf("got line ascent %v, expected the ascent %v of the largest span", l.Ascent, big.Ascent)
+	}
+	var x fixed.Int26_6
+	for i, r := range l.Runs {
+		if r.X != x {
+			t.Errorf("run %d: got x %v, expected %v", i, r.X, x)
+		}
+		x += r.Width
+	}
+	if l.Width != x {
+		t.Errorf("got line width %v, expected %v", l.Width, x)
+	}
+	// Lines wrap across spans.
+	maxWidth := (l.Runs[0].Width + l.Runs[1].Width).Ceil()
+	lines = text.LayoutSpans(s, maxWidth, spans)
+	var got []string
+	for _, l := range lines {
+		var txt string
+		for _, r := range l.Runs {
+			txt += fmt.Sprintf("[%d:%s]", r.Span, r.Layout.Text)
+		}
+		got = append(got, txt)
+	}
+	want := []string{"[0:hello ][1:big][2: ]", "[2:world]"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got lines %q, expected %q", got, want)
+	}
+	ops := new(op.Ops)
+	text.PaintSpans(ops, s, spans, lines)
+}
+
+func TestLayoutSpansNewlines(t *testing.T) {
+	s := newTestShaper(t)
+	spans := []text.Span{
+		{Text: "a\nb", Size: fixed.I(20)},
+		{Text: "c\n", Size: fixed.I(20), Decoration: text.Underline},
+	}
+	lines := text.LayoutSpans(s, 1e6, spans)
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, expected 3", len(lines))
+	}
+	if n := len(lines[1].Runs); n != 2 {
+		t.Errorf("got %d runs on the second line, expected 2", n)
+	}
+	if last := lines[2]; len(last.Runs) != 1 || last.Ascent == 0 {
+		t.Errorf("empty last line has no metrics")
+	}
+}
+
+func TestLayoutSpansBidi(t *testing.T) {
+	s := newTestShaper(t)
+	spans := []text.Span{
+		{Text: "abc ", Size: fixed.I(20)},
+		{Text: "אבג", Size: fixed.I(20)},
+		{Text: " דה", Size: fixed.I(30)},
+		{Text: " def", Size: fixed.I(20)},
+	}
+	lines := text.LayoutSpans(s, 1e6, spans)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, expected 1", len(lines))
+	}
+	// The right-to-left spans are placed from right to left.
+	var got []int
+	var x fixed.Int26_6
+	for i, r := range lines[0].Runs {
+		got = append(got, r.Span)
+		if r.X != x {
+			t.Errorf("run %d: got x %v, expected %v", i, r.X, x)
+		}
+		x += r.Width
+	}
+	if want := []int{0, 2, 1, 3}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got spans %v in visual order, expected %v", got, want)
+	}
+}
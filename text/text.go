@@ -3,7 +3,11 @@
 package text
 
 import (
+	"image"
 	"io"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"golang.org/x/image/math/fixed"
 
@@ -22,11 +26,68 @@ type Line struct {
 	Descent fixed.Int26_6
 	// Bounds is the visible bounds of the line.
 	Bounds fixed.Rectangle26_6
+	// Direction is the base direction of the paragraph of the line.
+	Direction Direction
+	// Runs lists the directional runs of the line in visual order.
+	Runs []Run
+}
+
+// Run is a part of a Line with a single direction.
+type Run struct {
+	Direction Direction
+	// Start and End are the byte offsets of the run in the
+	// Layout text.
+	Start, End int
 }
 
 type Layout struct {
-	Text     string
+	Text string
+	// Advances contains the advance of each rune of Text.
 	Advances []fixed.Int26_6
+	// Glyphs contains the shaped glyphs of Text in visual order,
+	// or nil if the Face doesn't shape text.
+	Glyphs []Glyph
+	// Clusters contains the grapheme clusters of Text in logical
+	// order, or nil if the Face doesn't segment text.
+	Clusters []Cluster
+}
+
+// GlyphID identifies a glyph of a Face. Faces backed by several
+// fonts, such as font collections, store the index of the font in the
+// high 16 bits and the index of the glyph in that font in the low 16
+// bits. Use NewGlyphID, Font and Index to build and take apart glyph
+// IDs.
+type GlyphID uint32
+
+// Glyph is a positioned glyph of a Layout.
+type Glyph struct {
+	ID GlyphID
+	// Cluster is the byte offset in the Layout text of the first
+	// rune that maps to the glyph. Glyphs inserted by the layout,
+	// such as the hyphens of hyphenated lines, have a Cluster equal
+	// to the length of the text.
+	Cluster int
+	// Advance is the distance to the pen position of the next glyph.
+	Advance fixed.Int26_6
+	// Offset is the displacement of the glyph from its pen position,
+	// with positive Y pointing down.
+	Offset fixed.Point26_6
+}
+
+// NewGlyphID returns the GlyphID of the glyph with index idx in the
+// font with index font of a Face.
+func NewGlyphID(font int, idx uint16) GlyphID {
+	return GlyphID(font)<<16 | GlyphID(idx)
+}
+
+// Font returns the index of the font of the glyph.
+func (id GlyphID) Font() int {
+	return int(id >> 16)
+}
+
+// Index returns the index of the glyph in its font.
+func (id GlyphID) Index() uint16 {
+	return uint16(id)
 }
 
 // Style is the font style.
@@ -43,6 +104,29 @@ type Font struct {
 	Style    Style
 	// Weight is the text weight. If zero, Normal is used instead.
 	Weight Weight
+	// Variations contains the axis values of variable fonts. The
+	// weight axis follows Weight unless Variations sets it.
+	Variations Variations
+}
+
+// Variations is a list of axis values for variable fonts, written as
+// comma separated axis tags and values such as "wght 650, wdth 87.5".
+type Variations string
+
+// Variation is the value of an axis of a variable font.
+type Variation struct {
+	// Tag identifies the axis, such as "wght" for weight, "wdth"
+	// for width or "opsz" for optical size.
+	Tag   string
+	Value float32
+}
+
+// Axis is a variation axis of a variable font.
+type Axis struct {
+	Tag string
+	// Min, Default and Max are the range and default value of the
+	// axis.
+	Min, Default, Max float32
 }
 
 // Face implements text layout and shaping for a particular font. All
@@ -52,6 +136,115 @@ type Face interface {
 	Shape(ppem fixed.Int26_6, str Layout) op.CallOp
 }
 
+// OptionsFace is a Face that lays out text with LayoutOptions.
+type OptionsFace interface {
+	Face
+	// LayoutWithOptions is Layout with layout options. Layout is
+	// LayoutWithOptions with the zero LayoutOptions.
+	LayoutWithOptions(ppem fixed.Int26_6, maxWidth int, opts LayoutOptions, txt io.Reader) ([]Line, error)
+}
+
+// MeasureFace is a Face that measures text without building its
+// lines.
+type MeasureFace interface {
+	Face
+	// Measure returns the measurements of the lines of Layout.
+	Measure(ppem fixed.Int26_6, maxWidth int, txt io.Reader) (Measurement, error)
+}
+
+// LayoutOptions controls the spacing and hyphenation of laid out
+// text. The spacing is part of the rune advances and is accounted for
+// when breaking lines.
+type LayoutOptions struct {
+	// TabStops lists the positions of the tab stops relative to the
+	// start of lines, in increasing order. A tab advances to the
+	// first stop after its position.
+	TabStops []fixed.Int26_6
+	// TabWidth is the distance between the tab stops following
+	// TabStops. If zero, the width of 8 spaces is used. If both
+	// TabStops and TabWidth are zero, tabs have the advance of their
+	// glyph.
+	TabWidth fixed.Int26_6
+	// LetterSpacing is added to the advance of every grapheme
+	// cluster.
+	LetterSpacing fixed.Int26_6
+	// WordSpacing is added to the advance of every space.
+	WordSpacing fixed.Int26_6
+	// Hyphenator, if not nil, breaks words that don't fit a line.
+	// Cached layouts are keyed by the Hyphenator; layouts with
+	// hyphenators that are not comparable, such as slices, are not
+	// cached. Use a pointer to such hyphenators.
+	Hyphenator Hyphenator
+}
+
+// ColorFace is a Face with color glyphs, such as emoji.
+type ColorFace interface {
+	Face
+	// ShapeColor returns the operations for painting the color
+	// glyphs of a line of text. Shape excludes the color glyphs
+	// from its outline.
+	ShapeColor(ppem fixed.Int26_6, str Layout) op.CallOp
+}
+
+// RasterFace is a Face that rasterizes its glyphs.
+type RasterFace interface {
+	Face
+	// Rasterize returns the coverage mask of a glyph drawn with its
+	// pen position at offset from the origin, and the position of
+	// the mask relative to the origin. The mask is nil for glyphs
+	// without outline, and for color glyphs.
+	Rasterize(ppem fixed.Int26_6, id GlyphID, offset fixed.Point26_6) (*image.Alpha, image.Point)
+}
+
+// DecorationFace is a Face with the positions of text decorations.
+type DecorationFace interface {
+	Face
+	// Decorations returns the decoration metrics of the face.
+	Decorations(ppem fixed.Int26_6) DecorationMetrics
+	// InkExtents returns the horizontal extents of the glyph outlines
+	// of a line of text between top and bottom, relative to the
+	// start of the baseline and with y pointing down. The extents
+	// are sorted and don't overlap.
+	InkExtents(ppem fixed.Int26_6, str Layout, top, bottom fixed.Int26_6) []Extent
+}
+
+// DecorationMetrics contains the positions of the lines drawn by
+// text decorations, relative to the baseline and with y pointing
+// down. A zero thickness denotes a face without the metrics of the
+// line.
+type DecorationMetrics struct {
+	// UnderlinePosition is the position of the top of underlines.
+	UnderlinePosition  fixed.Int26_6
+	UnderlineThickness fixed.Int26_6
+	// StrikeoutPosition is the position of the top of strikeout
+	// lines.
+	StrikeoutPosition  fixed.Int26_6
+	StrikeoutThickness fixed.Int26_6
+}
+
+// Extent is a horizontal range.
+type Extent struct {
+	Start, End fixed.Int26_6
+}
+
+// VariableFace is a Face of a variable font.
+type VariableFace interface {
+	Face
+	// Axes returns the variation axes of the face.
+	Axes() []Axis
+	// Vary returns the instance of the face with the axis values.
+	// Axes without values have their default value.
+	Vary(values []Variation) Face
+}
+
+// Hyphenator finds the positions where words may be broken by
+// a hyphen at the end of a line.
+type Hyphenator interface {
+	// Hyphenate returns the byte offsets in word where the word may
+	// be broken, in increasing order.
+	Hyphenate(word string) []int
+}
+
 // Typeface identifies a particular typeface design. The empty
 // string denotes the default typeface.
 type Typeface string
@@ -61,12 +254,20 @@ type Variant string
 
 type Alignment uint8
 
+// Direction is the direction of text.
+type Direction uint8
+
 const (
 	Start Alignment = iota
 	End
 	Middle
 )
 
+const (
+	LTR Direction = iota
+	RTL
+)
+
 const (
 	Regular Style = iota
 	Italic
@@ -90,3 +291,85 @@ func (a Alignment) String() string {
 		panic("unreachable")
 	}
 }
+
+func (d Direction) String() string {
+	switch d {
+	case LTR:
+		return "LTR"
+	case RTL:
+		return "RTL"
+	default:
+		panic("unreachable")
+	}
+}
+
+// maxKeyTabStops is the largest number of tab stops of LayoutOptions
+// with an optionsKey.
+const maxKeyTabStops = 8
+
+// optionsKey is a comparable representation of LayoutOptions.
+type optionsKey struct {
+	tabStops      [maxKeyTabStops]fixed.Int26_6
+	nTabStops     int
+	tabWidth      fixed.Int26_6
+	letterSpacing fixed.Int26_6
+	wordSpacing   fixed.Int26_6
+	hyphenator    Hyphenator
+}
+
+// key returns a comparable representation of o, and false if o
+// can't be represented. The key of the zero LayoutOptions is the zero
+// optionsKey.
+func (o LayoutOptions) key() (optionsKey, bool) {
+	if len(o.TabStops) > maxKeyTabStops || !isComparable(o.Hyphenator) {
+		return optionsKey{}, false
+	}
+	k := optionsKey{
+		nTabStops:     len(o.TabStops),
+		tabWidth:      o.TabWidth,
+		letterSpacing: o.LetterSpacing,
+		wordSpacing:   o.WordSpacing,
+		hyphenator:    o.Hyphenator,
+	}
+	copy(k.tabStops[:], o.TabStops)
+	return k, true
+}
+
+// isComparable reports whether h can be compared and used as a map key.
+func isComparable(h Hyphenator) (ok bool) {
+	if h == nil {
+		return true
+	}
+	t := reflect.TypeOf(h)
+	if !t.Comparable() {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		return true
+	}
+	// Comparable types may contain interface values that are not.
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return h == h
+}
+
+// Values returns the axis values of v. Malformed entries are
+// ignored.
+func (v Variations) Values() []Variation {
+	var values []Variation
+	for _, entry := range strings.Split(string(v), ",") {
+		fields := strings.Fields(entry)
+		if len(fields) != 2 {
+			continue
+		}
+		val, err := strconv.ParseFloat(fields[1], 32)
+		if err != nil {
+			continue
+		}
+		values = append(values, Variation{Tag: fields[0], Value: float32(val)})
+	}
+	return values
+}
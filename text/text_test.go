@@ -0,0 +1,12 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import "testing"
+
+func TestGlyphID(t *testing.T) {
+	id := NewGlyphID(3, 0xfffe)
+	if f, idx := id.Font(), id.Index(); f != 3 || idx != 0xfffe {
+		t.Errorf("got font %d and index %#x, expected 3 and 0xfffe", f, idx)
+	}
+}